@@ -11,9 +11,19 @@ import (
 	"log/slog"
 
 	"github.com/CamPlume1/khoury-classroom/internal/config"
+	"github.com/CamPlume1/khoury-classroom/internal/forkqueue"
 	"github.com/CamPlume1/khoury-classroom/internal/github/appclient"
+	"github.com/CamPlume1/khoury-classroom/internal/gradepublisher"
+	"github.com/CamPlume1/khoury-classroom/internal/handlers/common"
+	"github.com/CamPlume1/khoury-classroom/internal/handlers/webhooks"
+	"github.com/CamPlume1/khoury-classroom/internal/rosterinviter"
+	"github.com/CamPlume1/khoury-classroom/internal/rostersync"
+	"github.com/CamPlume1/khoury-classroom/internal/scheduler"
 	"github.com/CamPlume1/khoury-classroom/internal/server"
+	"github.com/CamPlume1/khoury-classroom/internal/snapshotter"
 	"github.com/CamPlume1/khoury-classroom/internal/storage/postgres"
+	"github.com/CamPlume1/khoury-classroom/internal/tokensweeper"
+	"github.com/CamPlume1/khoury-classroom/internal/webhookredeliverer"
 	"github.com/CamPlume1/khoury-classroom/internal/types"
 	"github.com/joho/godotenv"
 )
@@ -49,13 +59,36 @@ func main() {
 		log.Fatalf("Unable to establish connection with GitHub: %v", err)
 	}
 
-	// Initialize the server
-	app := server.New(types.Params{
+	// Record the installations of the app from before it received installation webhooks
+	if err := common.BackfillAppInstallations(ctx, GitHubApp, db); err != nil {
+		slog.Error("Failed to backfill app installations", "error", err)
+	}
+
+	params := types.Params{
 		Store:     db,
 		GitHubApp: GitHubApp,
 		UserCfg:   cfg.GitHubUserClient,
 		Domains: cfg.Domains,
-	})
+		Admins:    cfg.Admins,
+	}
+
+	// Initialize the server
+	app := server.New(params)
+
+	// Register the time-based jobs: scheduled grade publishing, roster invitations and reconciliation, deadline snapshots, expired token cleanup and webhook redelivery
+	jobs := scheduler.New(params.Store)
+	jobs.Register(gradepublisher.New(params.Store, params.GitHubApp).Job())
+	jobs.Register(rosterinviter.New(params.Store, params.GitHubApp).Job())
+	jobs.Register(rostersync.New(params.Store, params.GitHubApp).Job())
+	jobs.Register(snapshotter.New(params.Store, params.GitHubApp).Job())
+	jobs.Register(tokensweeper.New(params.Store).Job())
+	jobs.Register(webhookredeliverer.New(params.Store, params.GitHubApp).Job())
+
+	// Start the fork queue and webhook delivery workers, and the scheduler, which only runs jobs on one replica at a time
+	workerCtx, stopWorkers := context.WithCancel(ctx)
+	go forkqueue.New(params.Store, params.GitHubApp, &params.UserCfg).Start(workerCtx)
+	go webhooks.NewDeliveryQueue(params).Start(workerCtx)
+	go jobs.Start(workerCtx)
 
 	// Start the server in a separate goroutine
 	go func() {
@@ -71,6 +104,7 @@ func main() {
 
 	// Begin shutdown process
 	slog.Info("Shutting down server")
+	stopWorkers()
 	if err := app.Shutdown(); err != nil {
 		slog.Error("Failed to shutdown server", "error", err)
 	}
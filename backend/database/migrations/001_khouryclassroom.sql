@@ -4,6 +4,7 @@ CREATE TABLE IF NOT EXISTS classrooms (
     org_id INTEGER NOT NULL,
     org_name VARCHAR(255) NOT NULL,
     student_team_name VARCHAR(255),
+    suspended_at TIMESTAMP, -- set while the org has the app uninstalled or suspended
     created_at TIMESTAMP DEFAULT (NOW() AT TIME ZONE 'UTC'),
     UNIQUE (name, org_id)
 );
@@ -28,9 +29,18 @@ CREATE TABLE IF NOT EXISTS users (
     last_name VARCHAR(255), --TODO: this should be not null eventually
     github_username VARCHAR(255) NOT NULL, 
     github_user_id INTEGER NOT NULL,
+    email VARCHAR(255),
     created_at TIMESTAMP DEFAULT (NOW() AT TIME ZONE 'UTC')
 );
 
+-- the last report of reconciling each classroom's roster against its org membership, student team and invitations
+CREATE TABLE IF NOT EXISTS roster_reconciliations (
+    classroom_id INTEGER PRIMARY KEY,
+    report JSONB NOT NULL, -- models.RosterReconciliation
+    reconciled_at TIMESTAMP DEFAULT (NOW() AT TIME ZONE 'UTC') NOT NULL,
+    FOREIGN KEY (classroom_id) REFERENCES classrooms(id)
+);
+
 -- TODO: Impose length on tokens
 CREATE TABLE IF NOT EXISTS classroom_tokens (
     token VARCHAR(255) PRIMARY KEY, 
@@ -47,11 +57,38 @@ CREATE TABLE IF NOT EXISTS classroom_membership (
     classroom_role USER_ROLE NOT NULL,
     created_at TIMESTAMP DEFAULT (NOW() AT TIME ZONE 'UTC'),
     status USER_STATUS NOT NULL, -- represents whether the user has "requested" to join the org, been invited to the org, or is in the org
+    student_identifier VARCHAR(255), -- the student's ID at the institution, used to key LMS gradebook exports
+    section VARCHAR(255),
     FOREIGN KEY (user_id) REFERENCES users(id),
     FOREIGN KEY (classroom_id) REFERENCES classrooms(id),
     PRIMARY KEY (user_id, classroom_id)
 );
 
+-- a roster imported into a classroom, whose org invitations are sent in batches in the background
+CREATE TABLE IF NOT EXISTS roster_imports (
+    id SERIAL PRIMARY KEY,
+    classroom_id INTEGER NOT NULL,
+    created_at TIMESTAMP DEFAULT (NOW() AT TIME ZONE 'UTC') NOT NULL,
+    FOREIGN KEY (classroom_id) REFERENCES classrooms(id)
+);
+
+-- what importing each row of a roster did, models.RosterImportStatus
+CREATE TABLE IF NOT EXISTS roster_import_rows (
+    roster_import_id INTEGER NOT NULL,
+    row_number INTEGER NOT NULL,
+    github_username VARCHAR(255) NOT NULL,
+    status VARCHAR(255) NOT NULL,
+    message TEXT DEFAULT '' NOT NULL,
+    user_id INTEGER,
+    classroom_role USER_ROLE,
+    FOREIGN KEY (roster_import_id) REFERENCES roster_imports(id),
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    PRIMARY KEY (roster_import_id, row_number)
+);
+
+CREATE INDEX IF NOT EXISTS roster_import_rows_pending ON roster_import_rows (roster_import_id)
+    WHERE status = 'PENDING_INVITE';
+
 CREATE TABLE IF NOT EXISTS assignment_templates (
     template_repo_id INTEGER PRIMARY KEY,
     template_repo_owner VARCHAR(255) NOT NULL,
@@ -64,7 +101,8 @@ CREATE TABLE IF NOT EXISTS assignment_base_repos (
     base_repo_owner VARCHAR(255) NOT NULL,
     base_repo_name VARCHAR(255) NOT NULL,
     created_at TIMESTAMP DEFAULT (NOW() AT TIME ZONE 'UTC'),
-    initialized BOOLEAN DEFAULT FALSE NOT NULL
+    initialized BOOLEAN DEFAULT FALSE NOT NULL,
+    deleted_at TIMESTAMP -- when the repository was deleted or transferred out of the org
 );
 
 CREATE TABLE IF NOT EXISTS rubrics (
@@ -74,17 +112,55 @@ CREATE TABLE IF NOT EXISTS rubrics (
     classroom_id INTEGER NOT NULL,
     reusable BOOLEAN NOT NULL,
     created_at TIMESTAMP DEFAULT (NOW() AT TIME ZONE 'UTC'),
+    deleted_at TIMESTAMP, -- rubrics are soft deleted, feedback given with their items keeps them around
+    version INTEGER DEFAULT 1 NOT NULL, -- the current version, bumped whenever graded items are edited
     FOREIGN KEY (classroom_id) REFERENCES classrooms(id)
 );
 
+-- each version of a rubric after the first, created by editing items that feedback was already given with
+CREATE TABLE IF NOT EXISTS rubric_versions (
+    rubric_id INTEGER NOT NULL,
+    version INTEGER NOT NULL,
+    created_by INTEGER,
+    created_at TIMESTAMP DEFAULT (NOW() AT TIME ZONE 'UTC'),
+    PRIMARY KEY (rubric_id, version),
+    FOREIGN KEY (rubric_id) REFERENCES rubrics(id),
+    FOREIGN KEY (created_by) REFERENCES users(id)
+);
+
+-- groups of rubric items whose points together are capped between min_points and max_points
+CREATE TABLE IF NOT EXISTS rubric_sections (
+    id SERIAL PRIMARY KEY,
+    rubric_id INTEGER NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    position INTEGER DEFAULT 0 NOT NULL,
+    max_points INTEGER,
+    min_points INTEGER,
+    created_at TIMESTAMP DEFAULT (NOW() AT TIME ZONE 'UTC'),
+    deleted BOOLEAN DEFAULT FALSE NOT NULL,
+    FOREIGN KEY (rubric_id) REFERENCES rubrics(id),
+    CONSTRAINT section_min_below_max
+        CHECK (min_points IS NULL OR max_points IS NULL OR min_points <= max_points)
+);
+
 CREATE TABLE IF NOT EXISTS rubric_items (
     id SERIAL PRIMARY KEY,
     rubric_id INTEGER,
+    section_id INTEGER, -- NULL for items outside of any section
+    position INTEGER DEFAULT 0 NOT NULL,
     point_value INTEGER NOT NULL,
     explanation VARCHAR(255) NOT NULL,
     created_at TIMESTAMP DEFAULT (NOW() AT TIME ZONE 'UTC'),
     deleted BOOLEAN DEFAULT FALSE,
-    FOREIGN KEY (rubric_id) REFERENCES rubrics(id)
+    -- items that feedback was given with are never edited in place. Editing one creates a new
+    -- item in the rubric's next version, and feedback stays pinned to the item it was given with
+    version INTEGER DEFAULT 1 NOT NULL,
+    original_item_id INTEGER, -- the first version of the item, NULL for the first version itself
+    superseded_by INTEGER, -- the next version of the item, NULL for the current one
+    FOREIGN KEY (rubric_id) REFERENCES rubrics(id),
+    FOREIGN KEY (section_id) REFERENCES rubric_sections(id),
+    FOREIGN KEY (original_item_id) REFERENCES rubric_items(id),
+    FOREIGN KEY (superseded_by) REFERENCES rubric_items(id)
 );
 
 CREATE TABLE IF NOT EXISTS assignment_outlines (
@@ -104,6 +180,76 @@ CREATE TABLE IF NOT EXISTS assignment_outlines (
     FOREIGN KEY (base_repo_id) REFERENCES assignment_base_repos(base_repo_id)
 );
 
+-- how late submissions to an assignment are penalized. Assignments without a policy
+-- don't accept late submissions at all
+CREATE TABLE IF NOT EXISTS late_policies (
+    assignment_outline_id INTEGER PRIMARY KEY,
+    grace_period_minutes INTEGER DEFAULT 0 NOT NULL,
+    penalty_percent_per_day INTEGER DEFAULT 0 NOT NULL,
+    max_penalty_percent INTEGER DEFAULT 100 NOT NULL,
+    hard_cutoff_hours INTEGER, -- after the deadline, when submissions stop being accepted
+    allow_late_tokens BOOLEAN DEFAULT FALSE NOT NULL,
+    created_at TIMESTAMP DEFAULT (NOW() AT TIME ZONE 'UTC'),
+    updated_at TIMESTAMP DEFAULT (NOW() AT TIME ZONE 'UTC'),
+    FOREIGN KEY (assignment_outline_id) REFERENCES assignment_outlines(id)
+);
+
+-- how many late tokens each student in a classroom can spend, each pushing one deadline back a day
+CREATE TABLE IF NOT EXISTS late_token_budgets (
+    classroom_id INTEGER PRIMARY KEY,
+    tokens_per_student INTEGER DEFAULT 0 NOT NULL,
+    updated_at TIMESTAMP DEFAULT (NOW() AT TIME ZONE 'UTC'),
+    FOREIGN KEY (classroom_id) REFERENCES classrooms(id)
+);
+
+-- how large the teams of a group assignment can be
+CREATE TABLE IF NOT EXISTS group_settings (
+    assignment_outline_id INTEGER PRIMARY KEY,
+    min_team_size INTEGER DEFAULT 1 NOT NULL CHECK (min_team_size >= 1),
+    max_team_size INTEGER NOT NULL,
+    updated_at TIMESTAMP DEFAULT (NOW() AT TIME ZONE 'UTC'),
+    FOREIGN KEY (assignment_outline_id) REFERENCES assignment_outlines(id),
+    CHECK (max_team_size >= min_team_size)
+);
+
+-- the branches of an assignment's repositories. Assignments without a layout submit on main,
+-- with development and feedback branches
+CREATE TABLE IF NOT EXISTS branch_layouts (
+    assignment_outline_id INTEGER PRIMARY KEY,
+    submission_branch VARCHAR(255) DEFAULT 'main' NOT NULL,
+    feedback_branch VARCHAR(255) DEFAULT 'feedback' NOT NULL,
+    development_branch BOOLEAN DEFAULT TRUE NOT NULL,
+    extra_branches TEXT[] DEFAULT '{}' NOT NULL,
+    updated_at TIMESTAMP DEFAULT (NOW() AT TIME ZONE 'UTC'),
+    FOREIGN KEY (assignment_outline_id) REFERENCES assignment_outlines(id),
+    CHECK (submission_branch <> feedback_branch)
+);
+
+-- the teams of students sharing a fork of a group assignment
+CREATE TABLE IF NOT EXISTS teams (
+    id SERIAL PRIMARY KEY,
+    assignment_outline_id INTEGER NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    join_token VARCHAR(255) UNIQUE NOT NULL, -- shared with teammates to join the team
+    created_by INTEGER NOT NULL,
+    created_at TIMESTAMP DEFAULT (NOW() AT TIME ZONE 'UTC'),
+    FOREIGN KEY (assignment_outline_id) REFERENCES assignment_outlines(id),
+    FOREIGN KEY (created_by) REFERENCES users(id),
+    UNIQUE (assignment_outline_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS team_members (
+    team_id INTEGER NOT NULL,
+    assignment_outline_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    joined_at TIMESTAMP DEFAULT (NOW() AT TIME ZONE 'UTC'),
+    FOREIGN KEY (team_id) REFERENCES teams(id),
+    FOREIGN KEY (assignment_outline_id) REFERENCES assignment_outlines(id),
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    PRIMARY KEY (team_id, user_id),
+    UNIQUE (assignment_outline_id, user_id) -- a student is on at most one team per assignment
+);
+
 CREATE TABLE IF NOT EXISTS assignment_outline_tokens (
     token VARCHAR(255) PRIMARY KEY, 
     expires_at TIMESTAMP,
@@ -134,12 +280,18 @@ CREATE TABLE IF NOT EXISTS student_works (
     repo_name VARCHAR(255) UNIQUE NOT NULL,
     unique_due_date TIMESTAMP,
     grades_published_timestamp TIMESTAMP,
+    submitted_at TIMESTAMP, -- when the work was last pushed to its submission branch
     work_state WORK_STATE NOT NULL,
     created_at TIMESTAMP DEFAULT (NOW() AT TIME ZONE 'UTC'),
     commit_amount INTEGER DEFAULT 0,
     first_commit_date TIMESTAMP,
     last_commit_date TIMESTAMP,
-    FOREIGN KEY (assignment_outline_id) REFERENCES assignment_outlines(id)
+    team_id INTEGER UNIQUE, -- the team sharing this work, on group assignments
+    repo_deleted_at TIMESTAMP, -- when the repository was deleted or transferred out of the org
+    repo_archived BOOLEAN DEFAULT FALSE NOT NULL,
+    repo_public BOOLEAN DEFAULT FALSE NOT NULL,
+    FOREIGN KEY (assignment_outline_id) REFERENCES assignment_outlines(id),
+    FOREIGN KEY (team_id) REFERENCES teams(id)
 );
 
 CREATE TABLE IF NOT EXISTS work_contributors (
@@ -151,37 +303,218 @@ CREATE TABLE IF NOT EXISTS work_contributors (
     PRIMARY KEY (user_id, student_work_id)
 );
 
+-- each time a student opened or updated a pull request into the submission branch of their work
+CREATE TABLE IF NOT EXISTS submission_attempts (
+    id SERIAL PRIMARY KEY,
+    student_work_id INTEGER NOT NULL,
+    pull_number INTEGER NOT NULL,
+    head_sha VARCHAR(40) NOT NULL,
+    action VARCHAR(255) NOT NULL, -- the pull_request event action, e.g. opened or synchronize
+    opened_by INTEGER, -- NULL if whoever pushed isn't a gitmarks user
+    created_at TIMESTAMP DEFAULT (NOW() AT TIME ZONE 'UTC'),
+    FOREIGN KEY (student_work_id) REFERENCES student_works(id),
+    FOREIGN KEY (opened_by) REFERENCES users(id)
+);
+
+CREATE INDEX IF NOT EXISTS submission_attempts_work ON submission_attempts (student_work_id);
+
+CREATE TABLE IF NOT EXISTS late_token_uses (
+    id SERIAL PRIMARY KEY,
+    student_work_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    tokens INTEGER NOT NULL CHECK (tokens > 0),
+    created_at TIMESTAMP DEFAULT (NOW() AT TIME ZONE 'UTC'),
+    FOREIGN KEY (student_work_id) REFERENCES student_works(id),
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+DO $$ BEGIN
+    CREATE TYPE SNAPSHOT_REASON AS ENUM ('SUBMITTED', 'DEADLINE', 'MANUAL');
+EXCEPTION
+    WHEN duplicate_object THEN null;
+END $$;
+
+-- the commits of a student work pinned for grading, newest last. The latest snapshot is the one
+-- graded, so pushes made during grading don't move the code TAs are commenting on
+CREATE TABLE IF NOT EXISTS submission_snapshots (
+    id SERIAL PRIMARY KEY,
+    student_work_id INTEGER NOT NULL,
+    commit_sha VARCHAR(40) NOT NULL,
+    tag_name VARCHAR(255), -- the tag marking the commit in the student's repository, if it could be created
+    reason SNAPSHOT_REASON NOT NULL,
+    created_by INTEGER, -- NULL for snapshots taken automatically
+    created_at TIMESTAMP DEFAULT (NOW() AT TIME ZONE 'UTC'),
+    FOREIGN KEY (student_work_id) REFERENCES student_works(id),
+    FOREIGN KEY (created_by) REFERENCES users(id)
+);
+
+CREATE INDEX IF NOT EXISTS submission_snapshots_work ON submission_snapshots (student_work_id);
+
+-- a student's personal deadline for an assignment. Rows are never deleted: granting a new extension
+-- revokes the one before it, so the table doubles as the audit trail of every extension
+CREATE TABLE IF NOT EXISTS deadline_extensions (
+    id SERIAL PRIMARY KEY,
+    assignment_outline_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    due_date TIMESTAMP NOT NULL,
+    reason TEXT,
+    granted_by INTEGER NOT NULL,
+    created_at TIMESTAMP DEFAULT (NOW() AT TIME ZONE 'UTC'),
+    revoked_at TIMESTAMP,
+    revoked_by INTEGER,
+    FOREIGN KEY (assignment_outline_id) REFERENCES assignment_outlines(id),
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    FOREIGN KEY (granted_by) REFERENCES users(id),
+    FOREIGN KEY (revoked_by) REFERENCES users(id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS deadline_extensions_one_active
+    ON deadline_extensions (assignment_outline_id, user_id) WHERE revoked_at IS NULL;
+
+DO $$ BEGIN
+    CREATE TYPE DIFF_SIDE AS ENUM ('LEFT', 'RIGHT');
+EXCEPTION
+    WHEN duplicate_object THEN null;
+END $$;
+
 CREATE TABLE IF NOT EXISTS feedback_comment (
     id SERIAL PRIMARY KEY,
     student_work_id INTEGER NOT NULL,
     rubric_item_id INTEGER NOT NULL,
     ta_user_id INTEGER NOT NULL,
+    file_path VARCHAR(255), -- with no lines, the comment is on the entire file
+    file_line INTEGER, -- the last line of a multi-line comment
+    file_start_line INTEGER,
+    file_side DIFF_SIDE, -- the side of the diff the lines are on, GitHub assumes RIGHT when NULL
+    file_start_side DIFF_SIDE,
+    github_comment_id BIGINT, -- the review comment posted on the student's feedback PR, if any
+    github_review_id BIGINT, -- the review a TA left on GitHub that the feedback was imported from, if any
+    published_at TIMESTAMP, -- NULL while the feedback is a draft that students cannot see
+    acknowledged_at TIMESTAMP, -- when a student resolved the feedback's review thread on GitHub
+    acknowledged_by INTEGER,
+    created_at TIMESTAMP DEFAULT (NOW() AT TIME ZONE 'UTC'),
+    deleted_at TIMESTAMP, -- deleted feedback no longer counts, but is kept with its history
+    FOREIGN KEY (student_work_id) REFERENCES student_works(id),
+    FOREIGN KEY (rubric_item_id) REFERENCES rubric_items(id),
+    FOREIGN KEY (ta_user_id) REFERENCES users(id),
+    FOREIGN KEY (acknowledged_by) REFERENCES users(id),
+    -- comments are on the entire work, an entire file, a line, or a range of lines
+    CONSTRAINT if_file_line_then_file_path
+        CHECK (file_line IS NULL OR file_path IS NOT NULL),
+    CONSTRAINT file_line_range
+        CHECK (file_start_line IS NULL OR (file_line IS NOT NULL AND file_start_line < file_line)),
+    CONSTRAINT if_file_side_then_file_line
+        CHECK (file_side IS NULL OR file_line IS NOT NULL),
+    CONSTRAINT if_file_start_side_then_file_start_line
+        CHECK (file_start_side IS NULL OR file_start_line IS NOT NULL)
+);
+
+DO $$ BEGIN
+    CREATE TYPE FEEDBACK_COMMENT_ACTION AS ENUM ('EDIT', 'DELETE');
+EXCEPTION
+    WHEN duplicate_object THEN null;
+END $$;
+
+-- what a feedback comment was before each time it was edited or deleted
+CREATE TABLE IF NOT EXISTS feedback_comment_history (
+    id SERIAL PRIMARY KEY,
+    feedback_comment_id INTEGER NOT NULL,
+    action FEEDBACK_COMMENT_ACTION NOT NULL,
+    rubric_item_id INTEGER NOT NULL,
+    point_value INTEGER NOT NULL,
+    explanation VARCHAR(255) NOT NULL,
     file_path VARCHAR(255),
     file_line INTEGER,
+    file_start_line INTEGER,
+    file_side DIFF_SIDE,
+    file_start_side DIFF_SIDE,
+    changed_by INTEGER NOT NULL,
+    changed_at TIMESTAMP DEFAULT (NOW() AT TIME ZONE 'UTC'),
+    FOREIGN KEY (feedback_comment_id) REFERENCES feedback_comment(id),
+    FOREIGN KEY (rubric_item_id) REFERENCES rubric_items(id),
+    FOREIGN KEY (changed_by) REFERENCES users(id)
+);
+
+-- the body of the review a work's draft feedback will be posted with
+CREATE TABLE IF NOT EXISTS review_drafts (
+    student_work_id INTEGER PRIMARY KEY,
+    ta_user_id INTEGER NOT NULL,
+    body TEXT DEFAULT '' NOT NULL,
+    updated_at TIMESTAMP DEFAULT (NOW() AT TIME ZONE 'UTC'),
+    FOREIGN KEY (student_work_id) REFERENCES student_works(id),
+    FOREIGN KEY (ta_user_id) REFERENCES users(id)
+);
+
+-- the TA responsible for grading a student work
+CREATE TABLE IF NOT EXISTS grading_assignments (
+    student_work_id INTEGER PRIMARY KEY,
+    ta_user_id INTEGER NOT NULL,
+    assigned_by INTEGER NOT NULL,
     created_at TIMESTAMP DEFAULT (NOW() AT TIME ZONE 'UTC'),
+    updated_at TIMESTAMP DEFAULT (NOW() AT TIME ZONE 'UTC'),
     FOREIGN KEY (student_work_id) REFERENCES student_works(id),
-    FOREIGN KEY (rubric_item_id) REFERENCES rubric_items(id),
     FOREIGN KEY (ta_user_id) REFERENCES users(id),
-    -- if file path exists, enforce that file line also exists.
-    -- cannot comment on an entire file (for now), only lines and entire work
-    CONSTRAINT if_file_path_then_file_line
-        CHECK (NOT (file_path IS NOT NULL AND file_line IS NULL))
+    FOREIGN KEY (assigned_by) REFERENCES users(id)
 );
 
--- TODO CREATE AUTO GRADER RESULT TABLE
+-- when the grades of an assignment are (or were last) released to students
+CREATE TABLE IF NOT EXISTS grade_publications (
+    assignment_outline_id INTEGER PRIMARY KEY,
+    publish_at TIMESTAMP NOT NULL,
+    published_at TIMESTAMP, -- NULL until the publication has run
+    scheduled_by INTEGER NOT NULL,
+    last_error TEXT,
+    created_at TIMESTAMP DEFAULT (NOW() AT TIME ZONE 'UTC'),
+    updated_at TIMESTAMP DEFAULT (NOW() AT TIME ZONE 'UTC'),
+    FOREIGN KEY (assignment_outline_id) REFERENCES assignment_outlines(id),
+    FOREIGN KEY (scheduled_by) REFERENCES users(id)
+);
 
-CREATE VIEW student_works_with_scores AS
-SELECT sw.*,
-    CASE 
-        WHEN COUNT(ri.id) = 0 THEN NULL
-        ELSE COALESCE(SUM(ri.point_value), 0) + COALESCE(ao.default_score, 0)
-    END AS manual_feedback_score,
-    NULL AS auto_grader_score -- TODO REPLACE WITH MAXIMUM AUTO GRADER SCORE
-FROM student_works sw
-LEFT JOIN feedback_comment fc ON sw.id = fc.student_work_id
-LEFT JOIN rubric_items ri ON fc.rubric_item_id = ri.id
-LEFT JOIN assignment_outlines ao ON ao.id = sw.assignment_outline_id
-GROUP BY sw.id, ao.default_score;
+DO $$ BEGIN
+    CREATE TYPE AUTOGRADER_SCORE_POLICY AS
+    ENUM('MAX', 'LATEST');
+EXCEPTION
+    WHEN duplicate_object THEN null;
+END $$;
+
+-- assignments without a config use the maximum autograder score and get no autograder workflow
+CREATE TABLE IF NOT EXISTS autograder_configs (
+    assignment_outline_id INTEGER PRIMARY KEY,
+    score_policy AUTOGRADER_SCORE_POLICY DEFAULT 'MAX' NOT NULL,
+    setup_command TEXT,
+    tests JSONB DEFAULT '[]' NOT NULL, -- [{name, run, points, timeout_minutes}], rendered into .github/workflows/autograder.yml
+    timeout_minutes INTEGER DEFAULT 10 NOT NULL,
+    created_at TIMESTAMP DEFAULT (NOW() AT TIME ZONE 'UTC'),
+    updated_at TIMESTAMP DEFAULT (NOW() AT TIME ZONE 'UTC'),
+    FOREIGN KEY (assignment_outline_id) REFERENCES assignment_outlines(id)
+);
+
+CREATE TABLE IF NOT EXISTS autograder_results (
+    id SERIAL PRIMARY KEY,
+    student_work_id INTEGER NOT NULL,
+    commit_sha VARCHAR(40) NOT NULL,
+    score INTEGER DEFAULT 0 NOT NULL, -- sum of the test scores, kept up to date as test results arrive
+    max_score INTEGER DEFAULT 0 NOT NULL,
+    -- scored on the server from a report of the provisioned autograder workflow, rather than read from a check run
+    -- the student's workflows could have written. only verified results count towards grades
+    verified BOOLEAN DEFAULT FALSE NOT NULL,
+    created_at TIMESTAMP DEFAULT (NOW() AT TIME ZONE 'UTC'),
+    updated_at TIMESTAMP DEFAULT (NOW() AT TIME ZONE 'UTC'),
+    FOREIGN KEY (student_work_id) REFERENCES student_works(id),
+    UNIQUE (student_work_id, commit_sha, verified)
+);
+
+CREATE TABLE IF NOT EXISTS autograder_test_results (
+    id SERIAL PRIMARY KEY,
+    autograder_result_id INTEGER NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    status VARCHAR(255) NOT NULL,
+    score INTEGER DEFAULT 0 NOT NULL,
+    max_score INTEGER DEFAULT 0 NOT NULL,
+    created_at TIMESTAMP DEFAULT (NOW() AT TIME ZONE 'UTC'),
+    FOREIGN KEY (autograder_result_id) REFERENCES autograder_results(id),
+    UNIQUE (autograder_result_id, name)
+);
 
 
 DO $$ BEGIN
@@ -192,15 +525,170 @@ EXCEPTION
 END $$;
 
 
+DO $$ BEGIN
+    CREATE TYPE REGRADE_OUTCOME AS
+    ENUM('ACCEPTED', 'ADJUSTED', 'REJECTED');
+EXCEPTION
+    WHEN duplicate_object THEN null;
+END $$;
+
 CREATE TABLE IF NOT EXISTS regrade_requests (
     id SERIAL PRIMARY KEY, 
     feedback_comment_id INTEGER NOT NULL,
+    student_user_id INTEGER NOT NULL,
     regrade_state REGRADE_STATE NOT NULL,
     student_comment TEXT NOT NULL,
+    github_comment_id BIGINT, -- set when the regrade was requested by replying to the feedback on GitHub
+    outcome REGRADE_OUTCOME,
+    adjusted_point_value INTEGER, -- replaces the rubric item points in the score once finalized
+    ta_user_id INTEGER,
+    ta_comment TEXT,
+    resolved_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT (NOW() AT TIME ZONE 'UTC'),
+    FOREIGN KEY (feedback_comment_id) REFERENCES feedback_comment(id),
+    FOREIGN KEY (student_user_id) REFERENCES users(id),
+    FOREIGN KEY (ta_user_id) REFERENCES users(id),
+    CONSTRAINT finalized_regrade_has_outcome
+        CHECK ((regrade_state = 'REGRADE_FINALIZED') = (outcome IS NOT NULL))
+);
+
+-- only one open regrade per feedback comment
+CREATE UNIQUE INDEX IF NOT EXISTS regrade_requests_one_open_per_comment
+    ON regrade_requests (feedback_comment_id)
+    WHERE regrade_state = 'REGRADE_REQUESTED';
+
+DO $$ BEGIN
+    CREATE TYPE GRADING_AUDIT_ACTION AS ENUM (
+        'FEEDBACK_CREATED', 'FEEDBACK_EDITED', 'FEEDBACK_DELETED',
+        'RUBRIC_UPDATED', 'RUBRIC_DELETED', 'RUBRIC_SECTION_CREATED', 'RUBRIC_SECTION_UPDATED',
+        'RUBRIC_SECTION_DELETED', 'RUBRIC_ITEM_PROPAGATED',
+        'REGRADE_RESOLVED',
+        'DEADLINE_EXTENSION_GRANTED', 'DEADLINE_EXTENSION_REVOKED',
+        'GRADES_PUBLISHED', 'GRADE_PUBLICATION_SCHEDULED', 'GRADE_PUBLICATION_CANCELLED');
+EXCEPTION
+    WHEN duplicate_object THEN null;
+END $$;
+
+-- every action that changed a grade or how it is computed, with the values before and after it.
+-- Entries are scoped to the work, assignment, student or rubric they affect so their history can be
+-- read back from any of them. actor_user_id is null for actions the system took on its own
+CREATE TABLE IF NOT EXISTS grading_audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    action GRADING_AUDIT_ACTION NOT NULL,
+    actor_user_id INTEGER,
+    classroom_id INTEGER NOT NULL,
+    assignment_outline_id INTEGER,
+    student_work_id INTEGER,
+    student_user_id INTEGER,
+    rubric_id INTEGER,
+    subject_id BIGINT,
+    before JSONB,
+    after JSONB,
     created_at TIMESTAMP DEFAULT (NOW() AT TIME ZONE 'UTC'),
-    FOREIGN KEY (feedback_comment_id) REFERENCES feedback_comment(id)
+    FOREIGN KEY (actor_user_id) REFERENCES users(id),
+    FOREIGN KEY (classroom_id) REFERENCES classrooms(id),
+    FOREIGN KEY (assignment_outline_id) REFERENCES assignment_outlines(id),
+    FOREIGN KEY (student_work_id) REFERENCES student_works(id),
+    FOREIGN KEY (student_user_id) REFERENCES users(id),
+    FOREIGN KEY (rubric_id) REFERENCES rubrics(id)
 );
 
+CREATE INDEX IF NOT EXISTS grading_audit_log_work ON grading_audit_log (student_work_id);
+CREATE INDEX IF NOT EXISTS grading_audit_log_assignment ON grading_audit_log (assignment_outline_id);
+CREATE INDEX IF NOT EXISTS grading_audit_log_rubric ON grading_audit_log (rubric_id);
+
+-- the audit log is append-only, entries can never be changed or removed
+CREATE OR REPLACE FUNCTION reject_grading_audit_log_change() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'grading_audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS grading_audit_log_append_only ON grading_audit_log;
+CREATE TRIGGER grading_audit_log_append_only
+    BEFORE UPDATE OR DELETE ON grading_audit_log
+    FOR EACH ROW EXECUTE FUNCTION reject_grading_audit_log_change();
+
+DROP TRIGGER IF EXISTS grading_audit_log_no_truncate ON grading_audit_log;
+CREATE TRIGGER grading_audit_log_no_truncate
+    BEFORE TRUNCATE ON grading_audit_log
+    FOR EACH STATEMENT EXECUTE FUNCTION reject_grading_audit_log_change();
+
+-- how late each work was submitted and the penalty that earns under its assignment's late policy.
+-- Without a policy, late submissions are accepted without a penalty.
+-- Mirrors models.EvaluateLateness, which answers the same question for a submission made now
+CREATE VIEW student_work_lateness AS
+SELECT sw.id AS student_work_id,
+    dl.deadline,
+    COALESCE(lt.tokens, 0) AS late_tokens_used,
+    lateness.days_late,
+    lateness.past_cutoff,
+    CASE
+        WHEN lateness.past_cutoff THEN 100
+        ELSE LEAST(lateness.days_late * COALESCE(lp.penalty_percent_per_day, 0), COALESCE(lp.max_penalty_percent, 100))
+    END AS late_penalty_percent
+FROM student_works sw
+JOIN assignment_outlines ao ON ao.id = sw.assignment_outline_id
+LEFT JOIN late_policies lp ON lp.assignment_outline_id = sw.assignment_outline_id
+LEFT JOIN LATERAL (
+    SELECT SUM(ltu.tokens)::INTEGER AS tokens FROM late_token_uses ltu WHERE ltu.student_work_id = sw.id
+) lt ON TRUE
+CROSS JOIN LATERAL (
+    SELECT COALESCE(sw.unique_due_date, ao.main_due_date) + MAKE_INTERVAL(days => COALESCE(lt.tokens, 0)) AS deadline
+) dl
+CROSS JOIN LATERAL (
+    SELECT
+        CASE
+            WHEN sw.submitted_at IS NULL OR dl.deadline IS NULL
+                OR sw.submitted_at <= dl.deadline + MAKE_INTERVAL(mins => COALESCE(lp.grace_period_minutes, 0)) THEN 0
+            ELSE CEIL(EXTRACT(EPOCH FROM (sw.submitted_at - dl.deadline)) / 86400)::INTEGER
+        END AS days_late,
+        COALESCE(sw.submitted_at > dl.deadline + MAKE_INTERVAL(hours => lp.hard_cutoff_hours), FALSE) AS past_cutoff
+) lateness;
+
+CREATE VIEW student_works_with_scores AS
+SELECT sw.*,
+    -- late penalties take their percentage off both scores
+    ROUND((CASE 
+        WHEN COALESCE(fs.comment_count, 0) = 0 THEN NULL
+        ELSE COALESCE(fs.points, 0) + COALESCE(ao.default_score, 0)
+    END) * (100 - swl.late_penalty_percent) / 100.0)::INTEGER AS manual_feedback_score,
+    ROUND((CASE
+        WHEN agc.score_policy = 'LATEST' THEN (
+            SELECT ar.score FROM autograder_results ar
+            WHERE ar.student_work_id = sw.id AND ar.verified
+            ORDER BY ar.created_at DESC
+            LIMIT 1
+        )
+        ELSE (SELECT MAX(ar.score) FROM autograder_results ar WHERE ar.student_work_id = sw.id AND ar.verified)
+    END) * (100 - swl.late_penalty_percent) / 100.0)::INTEGER AS auto_grader_score,
+    swl.late_penalty_percent
+FROM student_works sw
+LEFT JOIN LATERAL (
+    SELECT SUM(sections.points)::INTEGER AS points, SUM(sections.comment_count)::INTEGER AS comment_count
+    FROM (
+        -- a section's items together add at most max_points and take off at most min_points
+        -- (GREATEST and LEAST ignore the NULL caps of uncapped and unsectioned items)
+        SELECT COUNT(*) AS comment_count,
+            LEAST(GREATEST(SUM(COALESCE(rg.adjusted_point_value, ri.point_value)), MIN(rs.min_points)), MIN(rs.max_points)) AS points
+        FROM feedback_comment fc
+        JOIN rubric_items ri ON fc.rubric_item_id = ri.id
+        LEFT JOIN rubric_sections rs ON rs.id = ri.section_id
+        -- a finalized regrade overrides the points of the comment it disputed
+        LEFT JOIN LATERAL (
+            SELECT rr.adjusted_point_value FROM regrade_requests rr
+            WHERE rr.feedback_comment_id = fc.id AND rr.regrade_state = 'REGRADE_FINALIZED' AND rr.adjusted_point_value IS NOT NULL
+            ORDER BY rr.resolved_at DESC
+            LIMIT 1
+        ) rg ON TRUE
+        WHERE fc.student_work_id = sw.id AND fc.deleted_at IS NULL
+        GROUP BY ri.section_id
+    ) sections
+) fs ON TRUE
+LEFT JOIN assignment_outlines ao ON ao.id = sw.assignment_outline_id
+LEFT JOIN autograder_configs agc ON agc.assignment_outline_id = sw.assignment_outline_id
+JOIN student_work_lateness swl ON swl.student_work_id = sw.id;
+
 CREATE TABLE IF NOT EXISTS sessions (
     github_user_id INTEGER PRIMARY KEY,
     access_token VARCHAR(255) NOT NULL,
@@ -209,3 +697,149 @@ CREATE TABLE IF NOT EXISTS sessions (
     expires_in INTEGER,
     created_at TIMESTAMP DEFAULT (NOW() AT TIME ZONE 'UTC')
 );
+
+DO $$ BEGIN
+    CREATE TYPE FORK_JOB_STATE AS
+    ENUM('PENDING', 'IN_PROGRESS', 'COMPLETED', 'FAILED');
+EXCEPTION
+    WHEN duplicate_object THEN null;
+END $$;
+
+-- queue of student forks waiting to be finished (feedback PR, rulesets, student work) once GitHub is done copying them
+CREATE TABLE IF NOT EXISTS fork_jobs (
+    id SERIAL PRIMARY KEY,
+    assignment_outline_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    org_name VARCHAR(255) NOT NULL,
+    repo_name VARCHAR(255) UNIQUE NOT NULL,
+    first_commit_sha VARCHAR(40) NOT NULL,
+    team_id INTEGER UNIQUE, -- the team the fork is shared with, on group assignments
+    state FORK_JOB_STATE NOT NULL DEFAULT 'PENDING',
+    attempts INTEGER DEFAULT 0 NOT NULL,
+    last_error TEXT,
+    next_attempt_at TIMESTAMP DEFAULT (NOW() AT TIME ZONE 'UTC') NOT NULL,
+    created_at TIMESTAMP DEFAULT (NOW() AT TIME ZONE 'UTC'),
+    updated_at TIMESTAMP DEFAULT (NOW() AT TIME ZONE 'UTC'),
+    FOREIGN KEY (assignment_outline_id) REFERENCES assignment_outlines(id),
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    FOREIGN KEY (team_id) REFERENCES teams(id),
+    UNIQUE (assignment_outline_id, user_id) -- retrying an acceptance resumes the existing fork instead of creating another
+);
+
+DO $$ BEGIN
+    CREATE TYPE FORK_JOB_STEP AS
+    ENUM('CREATE_FORK', 'AWAIT_FORK', 'RESET_TO_FIRST_COMMIT', 'CREATE_FEEDBACK_PR', 'CREATE_BRANCH_RULESET', 'CREATE_PUSH_RULESET', 'REMOVE_TEAM_ACCESS', 'GRANT_TEAM_ACCESS', 'CREATE_STUDENT_WORK');
+EXCEPTION
+    WHEN duplicate_object THEN null;
+END $$;
+
+-- checkpoints of the acceptance steps a fork job has completed, so a retry can skip them
+CREATE TABLE IF NOT EXISTS fork_job_steps (
+    fork_job_id INTEGER NOT NULL,
+    step FORK_JOB_STEP NOT NULL,
+    completed_at TIMESTAMP DEFAULT (NOW() AT TIME ZONE 'UTC'),
+    FOREIGN KEY (fork_job_id) REFERENCES fork_jobs(id),
+    PRIMARY KEY (fork_job_id, step)
+);
+
+DO $$ BEGIN
+    CREATE TYPE WEBHOOK_DELIVERY_STATE AS
+    ENUM('PENDING', 'PROCESSING', 'PROCESSED', 'FAILED');
+EXCEPTION
+    WHEN duplicate_object THEN null;
+END $$;
+
+-- every webhook event GitHub delivered, processed in the background so failures can be retried and replayed
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    delivery_id VARCHAR(255) PRIMARY KEY, -- X-GitHub-Delivery, which redeliveries keep
+    event VARCHAR(255) NOT NULL,
+    action VARCHAR(255),
+    org_name VARCHAR(255),
+    repo_name VARCHAR(255), -- owner/name of the repository the event happened in, lowercased. Its deliveries are processed one at a time, in order
+    payload JSONB NOT NULL,
+    state WEBHOOK_DELIVERY_STATE NOT NULL DEFAULT 'PENDING',
+    attempts INTEGER DEFAULT 0 NOT NULL,
+    last_error TEXT,
+    next_attempt_at TIMESTAMP DEFAULT (NOW() AT TIME ZONE 'UTC') NOT NULL,
+    received_at TIMESTAMP DEFAULT (NOW() AT TIME ZONE 'UTC') NOT NULL,
+    processed_at TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT (NOW() AT TIME ZONE 'UTC') NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_state ON webhook_deliveries (state, next_attempt_at);
+CREATE INDEX IF NOT EXISTS webhook_deliveries_org ON webhook_deliveries (org_name, received_at);
+CREATE INDEX IF NOT EXISTS webhook_deliveries_repo ON webhook_deliveries (repo_name, received_at);
+
+-- the accounts the app is installed on, kept in sync by installation webhooks
+CREATE TABLE IF NOT EXISTS app_installations (
+    installation_id BIGINT PRIMARY KEY,
+    account_id BIGINT NOT NULL,
+    account_login VARCHAR(255) NOT NULL,
+    account_type VARCHAR(255) NOT NULL, -- Organization or User
+    repository_selection VARCHAR(255), -- all or selected
+    suspended_at TIMESTAMP,
+    uninstalled_at TIMESTAMP, -- uninstalled installations are kept, reinstalling gets a new installation
+    created_at TIMESTAMP DEFAULT (NOW() AT TIME ZONE 'UTC'),
+    updated_at TIMESTAMP DEFAULT (NOW() AT TIME ZONE 'UTC')
+);
+
+CREATE INDEX IF NOT EXISTS app_installations_account ON app_installations (account_id);
+
+DO $$ BEGIN
+    CREATE TYPE REPOSITORY_ALERT_KIND AS
+    ENUM('REPO_DELETED', 'REPO_TRANSFERRED', 'REPO_ARCHIVED', 'REPO_PUBLICIZED', 'RULESET_EDITED', 'RULESET_DELETED');
+EXCEPTION
+    WHEN duplicate_object THEN null;
+END $$;
+
+-- something happened to a classroom's repositories on GitHub that gitmarks didn't do, e.g. a protection ruleset was removed
+CREATE TABLE IF NOT EXISTS repository_alerts (
+    id SERIAL PRIMARY KEY,
+    classroom_id INTEGER NOT NULL,
+    assignment_outline_id INTEGER,
+    student_work_id INTEGER, -- NULL for alerts on an assignment's base repository
+    repo_name VARCHAR(255) NOT NULL,
+    kind REPOSITORY_ALERT_KIND NOT NULL,
+    actor_gh_username VARCHAR(255), -- who did it on GitHub
+    ruleset_id BIGINT,
+    ruleset_name VARCHAR(255),
+    reapplied_at TIMESTAMP, -- when the ruleset was put back as gitmarks created it
+    resolved_at TIMESTAMP,
+    resolved_by INTEGER,
+    created_at TIMESTAMP DEFAULT (NOW() AT TIME ZONE 'UTC'),
+    FOREIGN KEY (classroom_id) REFERENCES classrooms(id),
+    FOREIGN KEY (assignment_outline_id) REFERENCES assignment_outlines(id),
+    FOREIGN KEY (student_work_id) REFERENCES student_works(id),
+    FOREIGN KEY (resolved_by) REFERENCES users(id)
+);
+
+CREATE INDEX IF NOT EXISTS repository_alerts_classroom ON repository_alerts (classroom_id);
+
+DO $$ BEGIN
+    CREATE TYPE SCHEDULED_JOB_STATE AS
+    ENUM('PENDING', 'RUNNING', 'SUCCEEDED', 'FAILED');
+EXCEPTION
+    WHEN duplicate_object THEN null;
+END $$;
+
+-- time-based work run by the scheduler on whichever backend replica holds the scheduler lock
+CREATE TABLE IF NOT EXISTS scheduled_jobs (
+    id BIGSERIAL PRIMARY KEY,
+    job_type VARCHAR(255) NOT NULL,
+    payload JSONB DEFAULT '{}' NOT NULL,
+    recurring BOOLEAN DEFAULT FALSE NOT NULL, -- the next run is scheduled when this one finishes
+    state SCHEDULED_JOB_STATE NOT NULL DEFAULT 'PENDING',
+    attempts INTEGER DEFAULT 0 NOT NULL,
+    max_attempts INTEGER NOT NULL,
+    last_error TEXT,
+    run_at TIMESTAMP DEFAULT (NOW() AT TIME ZONE 'UTC') NOT NULL,
+    started_at TIMESTAMP,
+    finished_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT (NOW() AT TIME ZONE 'UTC') NOT NULL,
+    updated_at TIMESTAMP DEFAULT (NOW() AT TIME ZONE 'UTC') NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS scheduled_jobs_state ON scheduled_jobs (state, run_at);
+-- a recurring job is only ever scheduled once at a time
+CREATE UNIQUE INDEX IF NOT EXISTS scheduled_jobs_recurring ON scheduled_jobs (job_type)
+    WHERE recurring AND state IN ('PENDING', 'RUNNING');
@@ -0,0 +1,16 @@
+package config
+
+// The people who operate this deployment, e.g. to look after the scheduled jobs. They're identified by their
+// GitHub user IDs, since unlike logins those can't be renamed or taken over by someone else.
+type Admins struct {
+	GitHubUserIDs []int64 `env:"GITHUB_USER_IDS" envSeparator:","`
+}
+
+func (a Admins) IsAdmin(githubUserID int64) bool {
+	for _, userID := range a.GitHubUserIDs {
+		if userID == githubUserID {
+			return true
+		}
+	}
+	return false
+}
@@ -7,6 +7,7 @@ type Config struct {
 	GitHubAppClient  `envPrefix:"APP_"`
 	GitHubUserClient `envPrefix:"CLIENT_"`
 	Domains 		 `envPrefix:"DOMAINS_"`
+	Admins           `envPrefix:"ADMINS_"`
 }
 
 func LoadConfig() (Config, error) {
@@ -110,6 +110,10 @@ func AssignmentNotAcceptedError() APIError {
 	return NewAPIError(http.StatusBadRequest, fmt.Errorf("student has not accepted this assignment yet"))
 }
 
+func ClassroomSuspendedError() APIError {
+	return NewAPIError(http.StatusConflict, fmt.Errorf("the GitHub app has been uninstalled or suspended in this classroom's organization"))
+}
+
 
 
 func CriticalGithubError() APIError {
@@ -0,0 +1,346 @@
+package forkqueue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/CamPlume1/khoury-classroom/internal/config"
+	"github.com/CamPlume1/khoury-classroom/internal/github"
+	"github.com/CamPlume1/khoury-classroom/internal/github/sharedclient"
+	"github.com/CamPlume1/khoury-classroom/internal/github/userclient"
+	"github.com/CamPlume1/khoury-classroom/internal/handlers/common"
+	"github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/CamPlume1/khoury-classroom/internal/storage"
+	gh "github.com/google/go-github/github"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	pollInterval = 5 * time.Second
+	maxAttempts  = 10
+	maxBackoff   = 2 * time.Minute
+)
+
+// Signals that GitHub has not finished copying the fork, so the job should be retried later
+var errForkNotReady = errors.New("fork is not ready yet")
+
+// Finishes setting up student forks in the background so accepting an assignment doesn't block on GitHub
+type Queue struct {
+	store     storage.Storage
+	appClient github.GitHubAppClient
+	userCfg   *config.GitHubUserClient
+}
+
+func New(store storage.Storage, appClient github.GitHubAppClient, userCfg *config.GitHubUserClient) *Queue {
+	return &Queue{store: store, appClient: appClient, userCfg: userCfg}
+}
+
+// Polls for runnable fork jobs until the context is cancelled
+func (q *Queue) Start(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		q.drain(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Runs claimed jobs until there are none left that are due
+func (q *Queue) drain(ctx context.Context) {
+	for ctx.Err() == nil {
+		job, err := q.store.ClaimNextForkJob(ctx)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return
+		}
+		if err != nil {
+			slog.Error("Failed to claim fork job", "error", err)
+			return
+		}
+
+		q.Run(ctx, job)
+	}
+}
+
+// Runs a claimed fork job and records the outcome
+func (q *Queue) Run(ctx context.Context, job models.ForkJob) {
+	err := q.process(ctx, job)
+
+	switch {
+	case err == nil:
+		err = q.store.FinishForkJob(ctx, job.ID, models.ForkJobStateCompleted, nil)
+	case errors.Is(err, errForkNotReady) && job.Attempts < maxAttempts:
+		err = q.store.RetryForkJob(ctx, job.ID, time.Now().Add(backoff(job.Attempts)), nil)
+	case !isPermanent(err) && job.Attempts < maxAttempts:
+		slog.Warn("Fork job failed, retrying", "job_id", job.ID, "repo", job.RepoName, "attempts", job.Attempts, "error", err)
+		message := err.Error()
+		err = q.store.RetryForkJob(ctx, job.ID, time.Now().Add(backoff(job.Attempts)), &message)
+	default:
+		slog.Error("Fork job failed", "job_id", job.ID, "repo", job.RepoName, "error", err)
+		message := err.Error()
+		err = q.store.FinishForkJob(ctx, job.ID, models.ForkJobStateFailed, &message)
+	}
+
+	if err != nil {
+		slog.Error("Failed to record fork job outcome", "job_id", job.ID, "error", err)
+	}
+}
+
+// Whether retrying a failed job can't help: GitHub rejected a request outright, or something the job needs is gone.
+// Anything else, e.g. a GitHub outage, rate limit or network error, is assumed to be transient.
+func isPermanent(err error) bool {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return true
+	}
+
+	var errResponse *gh.ErrorResponse
+	if !errors.As(err, &errResponse) || errResponse.Response == nil {
+		return false
+	}
+	status := errResponse.Response.StatusCode
+	return status >= 400 && status < 500 && status != http.StatusRequestTimeout && status != http.StatusTooManyRequests
+}
+
+// Exponential backoff between attempts, capped at maxBackoff
+func backoff(attempts int) time.Duration {
+	delay := time.Second
+	for i := 1; i < attempts && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxBackoff)
+}
+
+// Everything the acceptance steps of a single job act on
+type acceptance struct {
+	job        models.ForkJob
+	assignment models.AssignmentOutline
+	baseRepo   models.AssignmentBaseRepo
+	layout     models.BranchLayout
+	classroom  models.Classroom
+	client     github.GitHubUserClient
+}
+
+// Runs the acceptance steps of a job in order, skipping and checkpointing completed ones
+func (q *Queue) process(ctx context.Context, job models.ForkJob) error {
+	a, err := q.loadAcceptance(ctx, job)
+	if err != nil {
+		return err
+	}
+
+	completed := make(map[models.ForkJobStep]bool, len(job.CompletedSteps))
+	for _, step := range job.CompletedSteps {
+		completed[step] = true
+	}
+
+	steps := map[models.ForkJobStep]func(context.Context, acceptance) error{
+		models.ForkJobStepCreateFork:          createFork,
+		models.ForkJobStepAwaitFork:           awaitFork,
+		models.ForkJobStepResetToFirstCommit:  resetToFirstCommit,
+		models.ForkJobStepCreateFeedbackPR:    createFeedbackPR,
+		models.ForkJobStepCreateBranchRuleset: createBranchRuleset,
+		models.ForkJobStepCreatePushRuleset:   createPushRuleset,
+		models.ForkJobStepRemoveTeamAccess:    removeTeamAccess,
+		models.ForkJobStepGrantTeamAccess:     q.grantTeamAccess,
+		models.ForkJobStepCreateStudentWork:   q.createStudentWork,
+	}
+
+	for _, step := range models.ForkJobSteps {
+		if completed[step] {
+			continue
+		}
+
+		err = steps[step](ctx, a)
+		if errors.Is(err, errForkNotReady) {
+			return err
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", step, err)
+		}
+
+		err = q.store.CompleteForkJobStep(ctx, job.ID, step)
+		if err != nil {
+			return fmt.Errorf("error checkpointing %s: %w", step, err)
+		}
+	}
+
+	return nil
+}
+
+func (q *Queue) loadAcceptance(ctx context.Context, job models.ForkJob) (acceptance, error) {
+	assignment, err := q.store.GetAssignmentByID(ctx, int64(job.AssignmentOutlineID))
+	if err != nil {
+		return acceptance{}, fmt.Errorf("error getting assignment: %w", err)
+	}
+
+	baseRepo, err := q.store.GetBaseRepoByID(ctx, assignment.BaseRepoID)
+	if err != nil {
+		return acceptance{}, fmt.Errorf("error getting base repo: %w", err)
+	}
+
+	layout, err := common.GetBranchLayout(ctx, q.store, int64(assignment.ID))
+	if err != nil {
+		return acceptance{}, fmt.Errorf("error getting branch layout: %w", err)
+	}
+
+	classroom, err := q.store.GetClassroomByID(ctx, assignment.ClassroomID)
+	if err != nil {
+		return acceptance{}, fmt.Errorf("error getting classroom: %w", err)
+	}
+
+	// The fork belongs to the student, so act with their credentials
+	session, err := q.store.GetSession(ctx, job.GitHubUserID)
+	if err != nil {
+		return acceptance{}, fmt.Errorf("error getting student session: %w", err)
+	}
+	client, err := userclient.NewFromSession(q.userCfg.OAuthConfig(), &session)
+	if err != nil {
+		return acceptance{}, fmt.Errorf("error creating student client: %w", err)
+	}
+
+	return acceptance{job: job, assignment: assignment, baseRepo: baseRepo, layout: layout, classroom: classroom, client: client}, nil
+}
+
+// Forks the base repository, unless an earlier attempt already did
+func createFork(ctx context.Context, a acceptance) error {
+	_, err := a.client.GetRepository(ctx, a.job.OrgName, a.job.RepoName)
+	if err == nil {
+		return nil
+	}
+	// Only a missing repository means it still needs forking, anything else fails the attempt
+	var errResponse *gh.ErrorResponse
+	if !errors.As(err, &errResponse) || errResponse.Response.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("error checking for an existing fork: %w", err)
+	}
+
+	return a.client.ForkRepository(ctx, a.baseRepo.BaseRepoOwner, a.baseRepo.BaseRepoName, a.job.OrgName, a.job.RepoName)
+}
+
+// Wait to perform actions on the fork until it is finished initializing
+func awaitFork(ctx context.Context, a acceptance) error {
+	studentWorkRepo, err := a.client.GetRepository(ctx, a.job.OrgName, a.job.RepoName)
+	if err != nil || !a.client.CheckForkIsReady(ctx, studentWorkRepo) {
+		return errForkNotReady
+	}
+
+	return nil
+}
+
+// Force push to the first commit, then merge them back in to get rid of the "enable actions" button
+func resetToFirstCommit(ctx context.Context, a acceptance) error {
+	err := a.client.SetBranchToCommit(ctx, a.job.OrgName, a.job.RepoName, a.layout.SubmissionBranch, a.job.FirstCommitSHA)
+	if err != nil {
+		return fmt.Errorf("error setting branch to commit: %w", err)
+	}
+
+	err = a.client.SyncForkWithUpstream(ctx, a.job.OrgName, a.job.RepoName, a.layout.SubmissionBranch)
+	if err != nil {
+		return fmt.Errorf("error syncing fork with upstream: %w", err)
+	}
+
+	return nil
+}
+
+// Create feedback pull request, which is always the first pull request of a student work
+func createFeedbackPR(ctx context.Context, a acceptance) error {
+	if _, err := a.client.GetPullRequest(ctx, a.job.OrgName, a.job.RepoName, 1); err == nil {
+		return nil
+	}
+
+	return a.client.CreateFeedbackPR(ctx, a.job.OrgName, a.job.RepoName, a.layout.SubmissionBranch, a.layout.FeedbackBranch)
+}
+
+// KHO-239
+func createBranchRuleset(ctx context.Context, a acceptance) error {
+	rulesets, err := a.client.ListRulesets(ctx, a.job.OrgName, a.job.RepoName)
+	if err != nil {
+		return fmt.Errorf("error listing rulesets: %w", err)
+	}
+	for _, ruleset := range rulesets {
+		if ruleset.Name == sharedclient.BranchRulesetName {
+			return nil
+		}
+	}
+
+	return a.client.CreateBranchRuleset(ctx, a.job.OrgName, a.job.RepoName, a.layout.SubmissionBranch, a.layout.FeedbackBranch)
+}
+
+// Rulesets don't carry over to forks, so the fork needs its own protection of the autograder and deadline workflows
+func createPushRuleset(ctx context.Context, a acceptance) error {
+	rulesets, err := a.client.ListRulesets(ctx, a.job.OrgName, a.job.RepoName)
+	if err != nil {
+		return fmt.Errorf("error listing rulesets: %w", err)
+	}
+	for _, ruleset := range rulesets {
+		if ruleset.Name == sharedclient.PushRulesetName {
+			return nil
+		}
+	}
+
+	return a.client.CreatePushRuleset(ctx, a.job.OrgName, a.job.RepoName)
+}
+
+// Remove student team's access to forked repo
+func removeTeamAccess(ctx context.Context, a acceptance) error {
+	return a.client.RemoveRepoFromTeam(ctx, a.classroom.OrgName, *a.classroom.StudentTeamName, a.job.OrgName, a.job.RepoName)
+}
+
+// On group assignments, give the accepting student's teammates access to the fork they share
+func (q *Queue) grantTeamAccess(ctx context.Context, a acceptance) error {
+	if a.job.TeamID == nil {
+		return nil
+	}
+
+	members, err := q.store.ListTeamMembers(ctx, *a.job.TeamID)
+	if err != nil {
+		return fmt.Errorf("error getting team members: %w", err)
+	}
+	for _, member := range members {
+		if member.UserID == a.job.UserID {
+			continue
+		}
+		err = q.appClient.AssignPermissionToUser(ctx, a.job.OrgName, a.job.RepoName, member.GithubUsername, "push")
+		if err != nil {
+			return fmt.Errorf("error granting %s access: %w", member.GithubUsername, err)
+		}
+	}
+
+	return nil
+}
+
+func (q *Queue) createStudentWork(ctx context.Context, a acceptance) error {
+	if work, err := q.store.GetWorkByRepoName(ctx, a.job.RepoName); err == nil {
+		return q.attachTeam(ctx, a, int64(work.ID))
+	}
+
+	// students granted an extension before accepting start out with their own deadline
+	dueDate := a.assignment.MainDueDate
+	extension, err := q.store.GetActiveDeadlineExtension(ctx, int64(a.assignment.ID), a.job.UserID)
+	if err == nil {
+		dueDate = &extension.DueDate
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("error getting deadline extension: %w", err)
+	}
+
+	work, err := q.store.CreateStudentWork(ctx, a.assignment.ID, a.job.GitHubUserID, a.job.RepoName, models.WorkStateAccepted, dueDate)
+	if err != nil {
+		return err
+	}
+	return q.attachTeam(ctx, a, int64(work.ID))
+}
+
+// Makes every member of the job's team a contributor to the work they share
+func (q *Queue) attachTeam(ctx context.Context, a acceptance, studentWorkID int64) error {
+	if a.job.TeamID == nil {
+		return nil
+	}
+	return q.store.AttachTeamToWork(ctx, studentWorkID, *a.job.TeamID)
+}
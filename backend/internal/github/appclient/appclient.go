@@ -3,6 +3,8 @@ package appclient
 import (
 	"context"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/CamPlume1/khoury-classroom/internal/config"
 	"github.com/CamPlume1/khoury-classroom/internal/errs"
@@ -93,6 +95,70 @@ func (api *AppAPI) ListInstallations(ctx context.Context) ([]*github.Installatio
 	return installations, nil
 }
 
+func (api *AppAPI) ListHookDeliveries(ctx context.Context, since time.Time) ([]models.HookDelivery, error) {
+	client, err := api.getClientWithJWTAuth(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting github client with JWT auth: %v", err)
+	}
+
+	// the delivery log is paginated with cursors, which the client doesn't parse, so the next page is followed by hand
+	var deliveries []models.HookDelivery
+	endpoint := "app/hook/deliveries?per_page=100"
+	for endpoint != "" {
+		req, err := client.NewRequest("GET", endpoint, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var page []models.HookDelivery
+		resp, err := client.Do(ctx, req, &page)
+		if err != nil {
+			return nil, fmt.Errorf("error listing hook deliveries: %v", err)
+		}
+
+		for _, delivery := range page {
+			if delivery.DeliveredAt.Before(since) {
+				return deliveries, nil
+			}
+			deliveries = append(deliveries, delivery)
+		}
+		endpoint = nextPageLink(resp.Header.Get("Link"))
+	}
+
+	return deliveries, nil
+}
+
+func (api *AppAPI) RedeliverHookDelivery(ctx context.Context, deliveryID int64) error {
+	client, err := api.getClientWithJWTAuth(ctx)
+	if err != nil {
+		return fmt.Errorf("error getting github client with JWT auth: %v", err)
+	}
+
+	req, err := client.NewRequest("POST", fmt.Sprintf("app/hook/deliveries/%d/attempts", deliveryID), nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = client.Do(ctx, req, nil)
+	if err != nil {
+		return fmt.Errorf("error redelivering hook delivery: %v", err)
+	}
+
+	return nil
+}
+
+// The URL of the next page from a Link header, or an empty string on the last page
+func nextPageLink(header string) string {
+	for _, link := range strings.Split(header, ",") {
+		parts := strings.Split(link, ";")
+		if len(parts) < 2 || strings.TrimSpace(parts[1]) != `rel="next"` {
+			continue
+		}
+		return strings.Trim(strings.TrimSpace(parts[0]), "<>")
+	}
+	return ""
+}
+
 func (api *AppAPI) AssignPermissionToTeam(ctx context.Context, teamID int64, ownerName string, repoName string, permission string) error {
 	opt := &github.TeamAddTeamRepoOptions{
 		Permission: permission,
@@ -57,42 +57,52 @@ func atoi(s string) int {
 	return n
 }
 
-func (api *AppAPI) GetFileTree(owner string, repo string) ([]models.FileTreeNode, error) {
-	// get default branch
-	ghRepo, err := api.GetRepository(context.Background(), owner, repo)
-	if err != nil {
-		return nil, err
-	}
-	if ghRepo.DefaultBranch == nil {
-		return nil, errs.MissingDefaultBranchError()
-	}
+func (api *AppAPI) GetFileTree(owner string, repo string, commitSHA string) ([]models.FileTreeNode, error) {
+	if commitSHA == "" {
+		// get default branch
+		ghRepo, err := api.GetRepository(context.Background(), owner, repo)
+		if err != nil {
+			return nil, err
+		}
+		if ghRepo.DefaultBranch == nil {
+			return nil, errs.MissingDefaultBranchError()
+		}
 
-	// Get the reference to the branch
-	ref, _, err := api.Client.Git.GetRef(context.Background(), owner, repo, "heads/"+*ghRepo.DefaultBranch)
-	if err != nil {
-		return nil, fmt.Errorf("error fetching branch ref: %v", err)
+		// Get the reference to the branch
+		ref, _, err := api.Client.Git.GetRef(context.Background(), owner, repo, "heads/"+*ghRepo.DefaultBranch)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching branch ref: %v", err)
+		}
+		commitSHA = ref.Object.GetSHA()
 	}
 
-	// Get the commit from the ref
-	commitSHA := ref.Object.GetSHA()
+	// Get the commit
 	commit, _, err := api.Client.Git.GetCommit(context.Background(), owner, repo, commitSHA)
 	if err != nil {
 		return nil, fmt.Errorf("error fetching commit: %v", err)
 	}
 
-	// Get the git tree from latest commit
+	// Get the git tree of the commit
 	treeSHA := commit.Tree.GetSHA()
 	gitTree, _, err := api.Client.Git.GetTree(context.Background(), owner, repo, treeSHA, true)
 	if err != nil {
 		return nil, fmt.Errorf("error fetching tree: %v", err)
 	}
 
-	// Get the touched files from the PR
+	// Get the files the PR touched up to the commit
 	// hardcode PR number to 1 since we auto create the PR on fork
-	touched, _, err := api.Client.PullRequests.ListFiles(context.Background(), owner, repo, 1, nil)
+	pr, err := api.GetPullRequest(context.Background(), owner, repo, 1)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching pull request: %v", err)
+	}
+	comparison, err := api.CompareCommits(context.Background(), owner, repo, pr.GetBase().GetSHA(), commitSHA)
 	if err != nil {
 		return nil, fmt.Errorf("error fetching touched files: %v", err)
 	}
+	var touched []*github.CommitFile
+	for i := range comparison.Files {
+		touched = append(touched, &comparison.Files[i])
+	}
 
 	// Merge the touched files list with the git tree to yield final desired tree
 	statuses := createStatusMap(touched)
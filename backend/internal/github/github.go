@@ -16,7 +16,14 @@ type GitHubAppClient interface { // All methods in the APP client
 	// Get the installations of the github app
 	ListInstallations(ctx context.Context) ([]*github.Installation, error)
 
-	GetFileTree(owner string, repo string) ([]models.FileTreeNode, error)
+	// List the app's webhook delivery attempts since a time, newest first
+	ListHookDeliveries(ctx context.Context, since time.Time) ([]models.HookDelivery, error)
+
+	// Ask GitHub to deliver one of the app's webhook deliveries again
+	RedeliverHookDelivery(ctx context.Context, deliveryID int64) error
+
+	// Get the file tree of a repository at a commit, or the head of its default branch if commitSHA is empty
+	GetFileTree(owner string, repo string, commitSHA string) ([]models.FileTreeNode, error)
 	GetFileBlob(owner string, repo string, sha string) ([]byte, error)
 
 	// Add a repository permission to a team
@@ -56,8 +63,8 @@ type GitHubUserClient interface { // All methods in the OAUTH client
 	// Sync a fork with its upstream repository
 	SyncForkWithUpstream(ctx context.Context, owner, repo, branch string) error
 
-	// Create initial feedback pull request
-	CreateFeedbackPR(ctx context.Context, owner, repo string) error
+	// Create initial feedback pull request, from the submission branch into the feedback branch
+	CreateFeedbackPR(ctx context.Context, owner, repo, headBranch, baseBranch string) error
 }
 
 type GitHubBaseClient interface { //All methods in the SHARED client
@@ -74,20 +81,50 @@ type GitHubBaseClient interface { //All methods in the SHARED client
 	// Create a new branch in a repository
 	CreateBranch(ctx context.Context, owner, repo, baseBranch, newBranchName string) (*github.Reference, error)
 
+	// Compare two commits, listing the commits head is ahead of base by and the files changed between them
+	CompareCommits(ctx context.Context, owner string, repo string, base string, head string) (*github.CommitsComparison, error)
+
+	// Create a lightweight tag on a commit
+	CreateTag(ctx context.Context, owner string, repo string, tagName string, sha string) (*github.Reference, error)
+
 	// List the branches in a repository
 	ListBranches(ctx context.Context, owner string, repo string, opts *github.ListOptions) ([]*github.Branch, error)
 
 	// Get the details of a pull request
 	GetPullRequest(ctx context.Context, owner string, repo string, pullNumber int) (*github.PullRequest, error)
 
+	// List the check runs of a check suite (e.g. the jobs of a workflow run)
+	ListCheckRunsForCheckSuite(ctx context.Context, owner string, repo string, checkSuiteID int64) ([]*github.CheckRun, error)
+
 	// Get the diff of a pull request
 	GetPullRequestDiff(ctx context.Context, owner string, repo string, pullNumber int) (string, error)
 
 	// Create a new pull request in a repository
 	CreatePullRequest(ctx context.Context, owner string, repo string, baseBranch string, headBranch string, title string, body string) (*github.PullRequest, error)
 
-	// Create a new pull request review
-	CreatePRReview(ctx context.Context, owner string, repo string, body string, comments []models.PRReviewComment) (*github.PullRequestComment, error)
+	// Create a new pull request review on a commit, or the pull request's head if commitID is empty
+	CreatePRReview(ctx context.Context, owner string, repo string, commitID string, body string, comments []models.PRReviewComment) (*github.PullRequestComment, error)
+
+	// List the reviews left on a pull request
+	ListPRReviews(ctx context.Context, owner string, repo string, pullNumber int) ([]*github.PullRequestReview, error)
+
+	// Comment on an entire file of a pull request, at the given commit
+	CreateFileComment(ctx context.Context, owner string, repo string, pullNumber int, commitID string, path string, body string) (*github.PullRequestComment, error)
+
+	// List the comments left as part of a pull request review
+	ListReviewComments(ctx context.Context, owner string, repo string, pullNumber int, reviewID int64) ([]models.GitHubReviewComment, error)
+
+	// Reply to a pull request review comment
+	ReplyToPRComment(ctx context.Context, owner string, repo string, pullNumber int, commentID int64, body string) (*github.PullRequestComment, error)
+
+	// Edit the body of a pull request review comment
+	EditPRComment(ctx context.Context, owner string, repo string, commentID int64, body string) (*github.PullRequestComment, error)
+
+	// Delete a pull request review comment
+	DeletePRComment(ctx context.Context, owner string, repo string, commentID int64) error
+
+	// Request reviews on a pull request from the given users
+	RequestPRReviewers(ctx context.Context, owner string, repo string, pullNumber int, reviewers []string) error
 
 	// Get the details of a user
 	GetUser(ctx context.Context, userName string) (*github.User, error)
@@ -98,6 +135,12 @@ type GitHubBaseClient interface { //All methods in the SHARED client
 	// Get all the invitations to an organization
 	GetOrgInvitations(ctx context.Context, orgName string) ([]*github.Invitation, error)
 
+	// Get the invitations to an organization that expired or failed
+	GetFailedOrgInvitations(ctx context.Context, orgName string) ([]models.FailedOrgInvitation, error)
+
+	// Get all the members of an organization
+	GetOrgMembers(ctx context.Context, orgName string) ([]*github.User, error)
+
 	// Invite a user to an organization
 	InviteUserToOrganization(ctx context.Context, orgName string, userID int64) error
 
@@ -116,6 +159,9 @@ type GitHubBaseClient interface { //All methods in the SHARED client
 	// Get the details of a repository
 	GetRepository(ctx context.Context, owner string, repoName string) (*github.Repository, error)
 
+	// Set the default branch of a repository
+	SetDefaultBranch(ctx context.Context, owner string, repoName string, branch string) error
+
 	// Get the details of a team
 	GetTeam(ctx context.Context, teamID int64) (*github.Team, error)
 
@@ -146,14 +192,20 @@ type GitHubBaseClient interface { //All methods in the SHARED client
 	//Create push ruleset to protect .github folders
 	CreatePushRuleset(ctx context.Context, orgName, repoName string) error
 
-	//Create rulesets to protect corresponding branches
-	CreateBranchRuleset(ctx context.Context, orgName, repoName string) error
+	//Create rulesets to protect the submission and feedback branches
+	CreateBranchRuleset(ctx context.Context, orgName, repoName, submissionBranch, feedbackBranch string) error
+
+	// List the rulesets of a repository
+	ListRulesets(ctx context.Context, orgName, repoName string) ([]models.Ruleset, error)
 
-	//Creates PR enforcements
-	CreatePREnforcement(ctx context.Context, orgName, repoName, branchName string) error
+	// Delete a ruleset of a repository
+	DeleteRuleset(ctx context.Context, orgName, repoName string, rulesetID int64) error
+
+	//Creates PR enforcements, rejecting pull requests into the feedback branch
+	CreatePREnforcement(ctx context.Context, orgName, repoName, branchName, feedbackBranch string) error
 
 	// Create empty commit (will create a diff that allows feedback PR to be created)
-	CreateEmptyCommit(ctx context.Context, owner, repo string) error
+	CreateEmptyCommit(ctx context.Context, owner, repo, branch string) error
 
 	// Check if a fork has finished initializing
 	CheckForkIsReady(ctx context.Context, repo *github.Repository) bool
@@ -167,4 +219,7 @@ type GitHubBaseClient interface { //All methods in the SHARED client
 	FileExists(owner string, repo string, path string) (bool, error)
 
 	CreateDeadlineEnforcement(ctx context.Context, deadline *time.Time, orgName, repoName, branchName, serverUrl string) error
+
+	// Create the autograder workflow of an assignment
+	CreateAutograderWorkflow(ctx context.Context, config models.AutograderConfig, orgName, repoName, branchName, serverUrl string) error
 }
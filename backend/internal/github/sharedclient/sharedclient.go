@@ -3,7 +3,9 @@ package sharedclient
 import (
 	"context"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
@@ -119,6 +121,14 @@ func (api *CommonAPI) GetPullRequest(ctx context.Context, owner string, repo str
 	return pr, err
 }
 
+func (api *CommonAPI) ListCheckRunsForCheckSuite(ctx context.Context, owner string, repo string, checkSuiteID int64) ([]*github.CheckRun, error) {
+	results, _, err := api.Client.Checks.ListCheckRunsCheckSuite(ctx, owner, repo, checkSuiteID, nil)
+	if err != nil {
+		return nil, err
+	}
+	return results.CheckRuns, nil
+}
+
 func (api *CommonAPI) GetPullRequestDiff(ctx context.Context, owner string, repo string, pullNumber int) (string, error) {
 	diff, _, err := api.Client.PullRequests.GetRaw(ctx, owner, repo, pullNumber, github.RawOptions{Type: github.Diff})
 	if err != nil {
@@ -143,7 +153,23 @@ func (api *CommonAPI) CreatePullRequest(ctx context.Context, owner string, repo
 	return pr, nil
 }
 
-func (api *CommonAPI) CreatePRReview(ctx context.Context, owner string, repo string, body string, comments []models.PRReviewComment) (*github.PullRequestComment, error) {
+func (api *CommonAPI) ListPRReviews(ctx context.Context, owner string, repo string, pullNumber int) ([]*github.PullRequestReview, error) {
+	var reviews []*github.PullRequestReview
+	opt := &github.ListOptions{PerPage: 100}
+	for {
+		page, resp, err := api.Client.PullRequests.ListReviews(ctx, owner, repo, pullNumber, opt)
+		if err != nil {
+			return nil, err
+		}
+		reviews = append(reviews, page...)
+		if resp.NextPage == 0 {
+			return reviews, nil
+		}
+		opt.Page = resp.NextPage
+	}
+}
+
+func (api *CommonAPI) CreatePRReview(ctx context.Context, owner string, repo string, commitID string, body string, comments []models.PRReviewComment) (*github.PullRequestComment, error) {
 	// hardcode PR number to 1 since we auto create the PR on fork
 	endpoint := fmt.Sprintf("/repos/%s/%s/pulls/%d/reviews", owner, repo, 1)
 
@@ -153,6 +179,9 @@ func (api *CommonAPI) CreatePRReview(ctx context.Context, owner string, repo str
 		"body":     body,
 		"comments": comments,
 	}
+	if commitID != "" {
+		requestBody["commit_id"] = commitID
+	}
 
 	req, err := api.Client.NewRequest("POST", endpoint, requestBody)
 	if err != nil {
@@ -171,6 +200,82 @@ func (api *CommonAPI) CreatePRReview(ctx context.Context, owner string, repo str
 	return &cmt, nil
 }
 
+func (api *CommonAPI) CompareCommits(ctx context.Context, owner string, repo string, base string, head string) (*github.CommitsComparison, error) {
+	comparison, _, err := api.Client.Repositories.CompareCommits(ctx, owner, repo, base, head)
+	return comparison, err
+}
+
+func (api *CommonAPI) CreateTag(ctx context.Context, owner string, repo string, tagName string, sha string) (*github.Reference, error) {
+	ref, _, err := api.Client.Git.CreateRef(ctx, owner, repo, &github.Reference{
+		Ref:    github.String("refs/tags/" + tagName),
+		Object: &github.GitObject{SHA: github.String(sha)},
+	})
+	return ref, err
+}
+
+func (api *CommonAPI) CreateFileComment(ctx context.Context, owner string, repo string, pullNumber int, commitID string, path string, body string) (*github.PullRequestComment, error) {
+	endpoint := fmt.Sprintf("/repos/%s/%s/pulls/%d/comments", owner, repo, pullNumber)
+
+	// go-github doesn't know about subject_type, which is what makes it a comment on the entire file
+	requestBody := map[string]interface{}{
+		"body":         body,
+		"commit_id":    commitID,
+		"path":         path,
+		"subject_type": "file",
+	}
+
+	req, err := api.Client.NewRequest("POST", endpoint, requestBody)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %v", err)
+	}
+
+	var cmt github.PullRequestComment
+	_, err = api.Client.Do(ctx, req, &cmt)
+	if err != nil {
+		return nil, fmt.Errorf("error creating file comment: %v", err)
+	}
+
+	return &cmt, nil
+}
+
+func (api *CommonAPI) ListReviewComments(ctx context.Context, owner string, repo string, pullNumber int, reviewID int64) ([]models.GitHubReviewComment, error) {
+	endpoint := fmt.Sprintf("/repos/%s/%s/pulls/%d/reviews/%d/comments?per_page=100", owner, repo, pullNumber, reviewID)
+
+	// go-github drops the lines and subject type of the comments, so they're decoded into our own model
+	req, err := api.Client.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %v", err)
+	}
+
+	var comments []models.GitHubReviewComment
+	_, err = api.Client.Do(ctx, req, &comments)
+	if err != nil {
+		return nil, fmt.Errorf("error listing review comments: %v", err)
+	}
+
+	return comments, nil
+}
+
+func (api *CommonAPI) ReplyToPRComment(ctx context.Context, owner string, repo string, pullNumber int, commentID int64, body string) (*github.PullRequestComment, error) {
+	comment, _, err := api.Client.PullRequests.CreateCommentInReplyTo(ctx, owner, repo, pullNumber, body, commentID)
+	return comment, err
+}
+
+func (api *CommonAPI) EditPRComment(ctx context.Context, owner string, repo string, commentID int64, body string) (*github.PullRequestComment, error) {
+	comment, _, err := api.Client.PullRequests.EditComment(ctx, owner, repo, commentID, &github.PullRequestComment{Body: &body})
+	return comment, err
+}
+
+func (api *CommonAPI) DeletePRComment(ctx context.Context, owner string, repo string, commentID int64) error {
+	_, err := api.Client.PullRequests.DeleteComment(ctx, owner, repo, commentID)
+	return err
+}
+
+func (api *CommonAPI) RequestPRReviewers(ctx context.Context, owner string, repo string, pullNumber int, reviewers []string) error {
+	_, _, err := api.Client.PullRequests.RequestReviewers(ctx, owner, repo, pullNumber, github.ReviewersRequest{Reviewers: reviewers})
+	return err
+}
+
 func (api *CommonAPI) GetUserOrgs(ctx context.Context) ([]models.Organization, error) {
 	// Construct the URL for the list assignments endpoint
 	endpoint := "/user/orgs"
@@ -203,6 +308,39 @@ func (api *CommonAPI) GetUser(ctx context.Context, userName string) (*github.Use
 	return user, err
 }
 
+// Name of the ruleset protecting the feedback and default branches of student works
+const BranchRulesetName = "Feedback and Main Branch Protedtion: PR Enforcement"
+
+// Name of the ruleset protecting the .github directory of base repositories and student works, and with it the
+// deadline and autograder workflows
+const PushRulesetName = "Restrict .github Directory Edits: Preserves Submission Deadline"
+
+func (api *CommonAPI) ListRulesets(ctx context.Context, orgName, repoName string) ([]models.Ruleset, error) {
+	endpoint := fmt.Sprintf("/repos/%s/%s/rulesets", orgName, repoName)
+	req, err := api.Client.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var rulesets []models.Ruleset
+	_, err = api.Client.Do(ctx, req, &rulesets)
+	if err != nil {
+		return nil, err
+	}
+
+	return rulesets, nil
+}
+
+func (api *CommonAPI) DeleteRuleset(ctx context.Context, orgName, repoName string, rulesetID int64) error {
+	endpoint := fmt.Sprintf("/repos/%s/%s/rulesets/%d", orgName, repoName, rulesetID)
+	req, err := api.Client.NewRequest("DELETE", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	_, err = api.Client.Do(ctx, req, nil)
+	return err
+}
+
 func (api *CommonAPI) createRuleSet(ctx context.Context, ruleset interface{}, orgName, repoName string) error {
 	endpoint := fmt.Sprintf("/repos/%s/%s/rulesets", orgName, repoName)
 	req, err := api.Client.NewRequest("POST", endpoint, ruleset)
@@ -216,7 +354,7 @@ func (api *CommonAPI) createRuleSet(ctx context.Context, ruleset interface{}, or
 // Given a repo name and org name, create a push ruleset to protect the .github directory
 func (api *CommonAPI) CreatePushRuleset(ctx context.Context, orgName, repoName string) error {
 	body := map[string]interface{}{
-		"name":        "Restrict .github Directory Edits: Preserves Submission Deadline",
+		"name":        PushRulesetName,
 		"target":      "push",
 		"enforcement": "active",
 		"rules": []interface{}{
@@ -231,15 +369,15 @@ func (api *CommonAPI) CreatePushRuleset(ctx context.Context, orgName, repoName s
 	return api.createRuleSet(ctx, body, orgName, repoName)
 }
 
-func (api *CommonAPI) CreateBranchRuleset(ctx context.Context, orgName, repoName string) error {
+func (api *CommonAPI) CreateBranchRuleset(ctx context.Context, orgName, repoName, submissionBranch, feedbackBranch string) error {
 	body := map[string]interface{}{
-		"name":        "Feedback and Main Branch Protedtion: PR Enforcement",
+		"name":        BranchRulesetName,
 		"target":      "branch",
 		"enforcement": "active",
 		"conditions": map[string]interface{}{
 			"ref_name": map[string]interface{}{
 				"exclude": []interface{}{},
-				"include": []interface{}{"refs/heads/feedback", "~DEFAULT_BRANCH"},
+				"include": []interface{}{"refs/heads/" + feedbackBranch, "refs/heads/" + submissionBranch},
 			},
 		},
 		"rules": []interface{}{
@@ -298,18 +436,18 @@ func (api *CommonAPI) CreateDeadlineEnforcement(ctx context.Context, deadline *t
 		RepoName:          repoName,
 		OwnerName:         orgName,
 		DestinationBranch: branchName,
-		Content:           actionWithDeadline(serverUrl),
+		Content:           actionWithDeadline(branchName, serverUrl),
 		CommitMessage:     "Deadline enforcement GH action files",
 	}
 	return api.EditRepository(ctx, &addition)
 }
 
-func actionWithDeadline(serverUrl string) string {
+func actionWithDeadline(branchName, serverUrl string) string {
 	scriptString := `name: deadline-enforcement
 
 on:
   pull_request:
-    branches: [ main ]
+    branches: [ %s ]
     types: [opened, reopened, edited, synchronize]
   workflow_dispatch:
 
@@ -327,11 +465,16 @@ jobs:
           RESPONSE=$(curl -s "%s/overdue/$REPO_NAME")
           echo "Response received: $RESPONSE"
           CONTENT=$(echo "$RESPONSE" | jq -r '.overdue')
-          echo "Parsed content: $CONTENT"
+          STATUS=$(echo "$RESPONSE" | jq -r '.outcome.status')
+          PENALTY=$(echo "$RESPONSE" | jq -r '.outcome.penalty_percent')
+          echo "Parsed content: $CONTENT ($STATUS)"
           
           if [[ "$CONTENT" == "true" ]]; then
             echo "::error::Webhook check failed: This assignment is overdue"
             exit 1
+          elif [[ "$CONTENT" == "false" && "$STATUS" == "LATE" ]]; then
+            echo "::warning::This submission is late and will lose $PENALTY%% of its score"
+            exit 0
           elif [[ "$CONTENT" == "false" ]]; then
             echo "Webhook check passed: This assignment is currently on time"
             exit 0
@@ -340,10 +483,128 @@ jobs:
             exit 1
           fi`
 
-	return fmt.Sprintf(scriptString, strings.TrimRight(serverUrl, "/"))
+	return fmt.Sprintf(scriptString, branchName, strings.TrimRight(serverUrl, "/"))
+}
+
+func (api *CommonAPI) CreateAutograderWorkflow(ctx context.Context, config models.AutograderConfig, orgName, repoName, branchName, serverUrl string) error {
+	content, err := autograderAction(config, branchName, serverUrl)
+	if err != nil {
+		return err
+	}
+
+	addition := models.RepositoryAddition{
+		FilePath:          ".github/workflows/autograder.yml",
+		RepoName:          repoName,
+		OwnerName:         orgName,
+		DestinationBranch: branchName,
+		Content:           content,
+		CommitMessage:     "Autograder GH action files",
+	}
+	return api.EditRepository(ctx, &addition)
+}
+
+// Renders a workflow that runs each autograder test as its own step, then reports their outcomes to the backend
+// from a separate job authenticated with the run's GitHub OIDC token. Student code only runs in the test job,
+// which can't request a token, and the backend works out the scores from the outcomes itself.
+func autograderAction(config models.AutograderConfig, branchName, serverUrl string) (string, error) {
+	serverUrl = strings.TrimRight(serverUrl, "/")
+
+	var steps strings.Builder
+	if config.SetupCommand != nil && strings.TrimSpace(*config.SetupCommand) != "" {
+		fmt.Fprintf(&steps, `
+      - name: Setup
+        run: |
+%s
+`, indentLines(*config.SetupCommand, 10))
+	}
+
+	var outputs strings.Builder
+	report := make([]map[string]interface{}, 0, len(config.Tests))
+	for i, test := range config.Tests {
+		timeout := ""
+		if test.TimeoutMinutes != nil {
+			timeout = fmt.Sprintf("\n        timeout-minutes: %d", *test.TimeoutMinutes)
+		}
+		fmt.Fprintf(&steps, `
+      - name: %s
+        id: test-%d
+        continue-on-error: true%s
+        run: |
+%s
+`, strconv.Quote(test.Name), i, timeout, indentLines(test.Run, 10))
+		fmt.Fprintf(&outputs, "\n      test-%d: ${{ steps.test-%d.outcome }}", i, i)
+
+		report = append(report, map[string]interface{}{
+			"name":   test.Name,
+			"output": fmt.Sprintf("test-%d", i),
+		})
+	}
+
+	reportJSON, err := json.Marshal(report)
+	if err != nil {
+		return "", err
+	}
+
+	scriptString := `name: autograder
+
+on:
+  push:
+    branches: [%s]
+  workflow_dispatch:
+
+permissions:
+  contents: read
+
+jobs:
+  tests:
+    # only grade student works, not the assignment base repository
+    if: ${{ github.event.repository.fork }}
+    runs-on: ubuntu-latest
+    timeout-minutes: %d
+    permissions:
+      contents: read
+    outputs:%s
+    steps:
+      - name: Checkout repository
+        uses: actions/checkout@v4
+%s
+  report:
+    needs: tests
+    if: ${{ always() && github.event.repository.fork }}
+    runs-on: ubuntu-latest
+    permissions:
+      id-token: write
+    steps:
+      - name: Report results to GitMarks
+        env:
+          OUTCOMES: ${{ toJSON(needs.tests.outputs) }}
+        run: |
+          cat <<'RESULTS' > tests.json
+          %s
+          RESULTS
+          jq --argjson outcomes "$OUTCOMES" '{tests: map({name, status: ($outcomes[.output] // "skipped")})}' tests.json > report.json
+          cat report.json
+
+          TOKEN=$(curl -s -H "Authorization: bearer $ACTIONS_ID_TOKEN_REQUEST_TOKEN" "$ACTIONS_ID_TOKEN_REQUEST_URL&audience=%s" | jq -r '.value')
+          curl -s --fail-with-body -X POST "%s/autograder/results" \
+            -H "Authorization: Bearer $TOKEN" \
+            -H "Content-Type: application/json" \
+            --data @report.json`
+
+	return fmt.Sprintf(scriptString, strconv.Quote(branchName), config.TimeoutMinutes, outputs.String(), steps.String(), reportJSON, serverUrl, serverUrl), nil
+}
+
+// Indents every line of a (possibly multiline) shell command for a YAML block scalar
+func indentLines(text string, spaces int) string {
+	indent := strings.Repeat(" ", spaces)
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = indent + line
+	}
+	return strings.Join(lines, "\n")
 }
 
-func targetBranchProtectionAction() string {
+func targetBranchProtectionAction(feedbackBranch string) string {
 	var actionString = `name: check-pr-target-branch
   
 on:
@@ -356,21 +617,21 @@ jobs:
     steps:
     - name: Check PR destination branch
       run: |
-        if [[ "${{ github.event.pull_request.base.ref }}" == "feedback" ]]; then
-            echo "Error: Pull requests targeting the 'feedback' branch are not allowed"
+        if [[ "${{ github.event.pull_request.base.ref }}" == "%[1]s" ]]; then
+            echo "Error: Pull requests targeting the '%[1]s' branch are not allowed"
             exit 1
         fi`
-	return actionString
+	return fmt.Sprintf(actionString, feedbackBranch)
 }
 
-func (api *CommonAPI) CreatePREnforcement(ctx context.Context, orgName, repoName, branchName string) error {
+func (api *CommonAPI) CreatePREnforcement(ctx context.Context, orgName, repoName, branchName, feedbackBranch string) error {
 
 	addition := models.RepositoryAddition{
 		FilePath:          ".github/workflows/check-pr-target-branch.yml",
 		RepoName:          repoName,
 		OwnerName:         orgName,
 		DestinationBranch: branchName,
-		Content:           targetBranchProtectionAction(),
+		Content:           targetBranchProtectionAction(feedbackBranch),
 		CommitMessage:     "Deadline enforcement GH action files",
 	}
 	return api.EditRepository(ctx, &addition)
@@ -477,21 +738,61 @@ func (api *CommonAPI) SetUserMembershipInOrg(ctx context.Context, orgName string
 }
 
 func (api *CommonAPI) GetOrgInvitations(ctx context.Context, orgName string) ([]*github.Invitation, error) {
-	req, err := api.Client.NewRequest("GET", fmt.Sprintf("/orgs/%s/invitations", orgName), nil)
-	if err != nil {
-		return nil, fmt.Errorf("error creating request: %v", err)
+	var invitations []*github.Invitation
+	for page := 1; page != 0; {
+		req, err := api.Client.NewRequest("GET", fmt.Sprintf("/orgs/%s/invitations?per_page=100&page=%d", orgName, page), nil)
+		if err != nil {
+			return nil, fmt.Errorf("error creating request: %v", err)
+		}
+
+		var pageInvitations []*github.Invitation
+		resp, err := api.Client.Do(ctx, req, &pageInvitations)
+		if err != nil {
+			return nil, fmt.Errorf("error getting org invitations: %v", err)
+		}
+		invitations = append(invitations, pageInvitations...)
+		page = resp.NextPage
 	}
 
-	var invitations []*github.Invitation
+	return invitations, nil
+}
 
-	_, err = api.Client.Do(ctx, req, &invitations)
-	if err != nil {
-		return nil, fmt.Errorf("error getting org invitations: %v", err)
+func (api *CommonAPI) GetFailedOrgInvitations(ctx context.Context, orgName string) ([]models.FailedOrgInvitation, error) {
+	var invitations []models.FailedOrgInvitation
+	for page := 1; page != 0; {
+		req, err := api.Client.NewRequest("GET", fmt.Sprintf("/orgs/%s/failed_invitations?per_page=100&page=%d", orgName, page), nil)
+		if err != nil {
+			return nil, fmt.Errorf("error creating request: %v", err)
+		}
+
+		var pageInvitations []models.FailedOrgInvitation
+		resp, err := api.Client.Do(ctx, req, &pageInvitations)
+		if err != nil {
+			return nil, fmt.Errorf("error getting failed org invitations: %v", err)
+		}
+		invitations = append(invitations, pageInvitations...)
+		page = resp.NextPage
 	}
 
 	return invitations, nil
 }
 
+func (api *CommonAPI) GetOrgMembers(ctx context.Context, orgName string) ([]*github.User, error) {
+	opt := &github.ListMembersOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	var members []*github.User
+	for {
+		page, resp, err := api.Client.Organizations.ListMembers(ctx, orgName, opt)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, page...)
+		if resp.NextPage == 0 {
+			return members, nil
+		}
+		opt.Page = resp.NextPage
+	}
+}
+
 func (api *CommonAPI) CancelOrgInvitation(ctx context.Context, orgName string, userName string) error {
 	invitations, err := api.GetOrgInvitations(ctx, orgName)
 	if err != nil {
@@ -535,6 +836,11 @@ func (api *CommonAPI) GetRepository(ctx context.Context, owner string, repoName
 	return repo, err
 }
 
+func (api *CommonAPI) SetDefaultBranch(ctx context.Context, owner string, repoName string, branch string) error {
+	_, _, err := api.Client.Repositories.Edit(ctx, owner, repoName, &github.Repository{DefaultBranch: &branch})
+	return err
+}
+
 func (api *CommonAPI) UpdateTeamRepoPermissions(ctx context.Context, org, teamSlug, owner, repo, permission string) error {
 	endpoint := fmt.Sprintf("/orgs/%s/teams/%s/repos/%s/%s", org, teamSlug, owner, repo)
 
@@ -633,21 +939,24 @@ func (api *CommonAPI) RemoveTeamMember(ctx context.Context, orgName string, team
 }
 
 func (api *CommonAPI) GetTeamMembers(ctx context.Context, teamID int64) ([]*github.User, error) {
-	members, _, err := api.Client.Teams.ListTeamMembers(ctx, teamID, nil)
-	return members, err
-}
-
-func (api *CommonAPI) CreateEmptyCommit(ctx context.Context, owner, repo string) error {
-	ghRepo, err := api.GetRepository(ctx, owner, repo)
-	if err != nil {
-		return err
-	}
-	if ghRepo.DefaultBranch == nil {
-		return errs.MissingDefaultBranchError()
+	opt := &github.TeamListTeamMembersOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	var members []*github.User
+	for {
+		page, resp, err := api.Client.Teams.ListTeamMembers(ctx, teamID, opt)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, page...)
+		if resp.NextPage == 0 {
+			return members, nil
+		}
+		opt.Page = resp.NextPage
 	}
+}
 
-	// Get the reference to main branch
-	ref, _, err := api.Client.Git.GetRef(context.Background(), owner, repo, "heads/"+*ghRepo.DefaultBranch)
+func (api *CommonAPI) CreateEmptyCommit(ctx context.Context, owner, repo, branch string) error {
+	// Get the reference to the branch
+	ref, _, err := api.Client.Git.GetRef(context.Background(), owner, repo, "heads/"+branch)
 	if err != nil {
 		return err
 	}
@@ -687,8 +996,8 @@ func (api *CommonAPI) CreateEmptyCommit(ctx context.Context, owner, repo string)
 		return errs.GithubAPIError(err)
 	}
 
-	// update main to point to the new empty commit
-	endpoint = fmt.Sprintf("/repos/%s/%s/git/refs/heads/%s", owner, repo, *ghRepo.DefaultBranch)
+	// update the branch to point to the new empty commit
+	endpoint = fmt.Sprintf("/repos/%s/%s/git/refs/heads/%s", owner, repo, branch)
 	req, err = api.Client.NewRequest("PATCH", endpoint, map[string]interface{}{
 		"sha":   commit.SHA,
 		"force": true,
@@ -172,25 +172,14 @@ func (api *UserAPI) SyncForkWithUpstream(ctx context.Context, owner, repo string
 	return nil
 }
 
-func (api *UserAPI) CreateFeedbackPR(ctx context.Context, owner, repo string) error {
-	// get default branch
-	ghRepo, err := api.GetRepository(ctx, owner, repo)
-	if err != nil {
-		fmt.Println("Error getting repository:", err)
-		return err
-	}
-	if ghRepo.DefaultBranch == nil {
-		fmt.Println("Missing default branch")
-		return errs.MissingDefaultBranchError()
-	}
-
+func (api *UserAPI) CreateFeedbackPR(ctx context.Context, owner, repo, headBranch, baseBranch string) error {
 	endpoint := fmt.Sprintf("/repos/%s/%s/pulls", owner, repo)
 
 	//Initialize post request
 	req, err := api.Client.NewRequest("POST", endpoint, map[string]interface{}{
 		"title": "Feedback",
-		"head":  owner + ":" + *ghRepo.DefaultBranch,
-		"base":  "feedback",
+		"head":  owner + ":" + headBranch,
+		"base":  baseBranch,
 		"body":  "Grade and feedback will be left here. Do not close or modify this PR!<br>Once graded, reply with a justification to any deduction you would like to dispute.",
 	})
 	if err != nil {
@@ -0,0 +1,69 @@
+package gradepublisher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/CamPlume1/khoury-classroom/internal/github"
+	"github.com/CamPlume1/khoury-classroom/internal/handlers/common"
+	"github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/CamPlume1/khoury-classroom/internal/scheduler"
+	"github.com/CamPlume1/khoury-classroom/internal/storage"
+	"github.com/jackc/pgx/v5"
+)
+
+// The scheduled job type that publishes grades
+const JobName = "publish_grades"
+
+const (
+	pollInterval = 30 * time.Second
+	retryDelay   = 5 * time.Minute
+)
+
+// Publishes the grades of assignments whose scheduled release time has passed
+type Publisher struct {
+	store     storage.Storage
+	appClient github.GitHubAppClient
+}
+
+func New(store storage.Storage, appClient github.GitHubAppClient) *Publisher {
+	return &Publisher{store: store, appClient: appClient}
+}
+
+// The recurring job that runs the publications that are due. Failed publications are rescheduled on their own,
+// so the job itself is only retried on its next run.
+func (p *Publisher) Job() scheduler.JobType {
+	return scheduler.JobType{Name: JobName, Run: p.drain, Every: pollInterval, MaxAttempts: 1}
+}
+
+// Runs claimed publications until there are none left that are due
+func (p *Publisher) drain(ctx context.Context, _ models.ScheduledJob) error {
+	for ctx.Err() == nil {
+		publication, err := p.store.ClaimDueGradePublication(ctx)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to claim grade publication: %w", err)
+		}
+
+		assignmentID := int64(publication.AssignmentOutlineID)
+
+		// Nobody is around to act as when the schedule fires, so reviews are posted by the app
+		published, err := common.PublishAssignmentGrades(ctx, p.appClient, p.store, assignmentID, nil)
+		if err != nil {
+			slog.Error("Failed to publish grades", "assignment_id", assignmentID, "published_works", published, "error", err)
+			err = p.store.RetryGradePublication(ctx, assignmentID, time.Now().Add(retryDelay), err.Error())
+			if err != nil {
+				slog.Error("Failed to reschedule grade publication", "assignment_id", assignmentID, "error", err)
+			}
+			continue
+		}
+
+		slog.Info("Published grades", "assignment_id", assignmentID, "published_works", published)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package autograder
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/CamPlume1/khoury-classroom/internal/errs"
+	"github.com/CamPlume1/khoury-classroom/internal/handlers/common"
+	"github.com/CamPlume1/khoury-classroom/internal/middleware"
+	"github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+)
+
+// Records the test results reported by the autograder workflow of a student work
+func (s *AutograderService) ReportHandler(c *fiber.Ctx) error {
+	claims, err := middleware.GetWorkflowClaims(c)
+	if err != nil {
+		return errs.AuthenticationError()
+	}
+
+	// Only trust results from the autograder workflow we provisioned. Its .github directory is protected on every
+	// branch of the fork, but reports are still only taken from runs on the submission branch.
+	if !strings.HasPrefix(claims.WorkflowRef, claims.Repository+"/.github/workflows/autograder.yml@") {
+		return errs.InsufficientPermissionsError()
+	}
+
+	orgName, repoName, found := strings.Cut(claims.Repository, "/")
+	if !found || claims.SHA == "" {
+		return errs.BadRequest(errors.New("workflow token is missing repository details"))
+	}
+
+	studentWork, err := s.store.GetWorkByRepoName(c.Context(), repoName)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && !strings.EqualFold(studentWork.OrgName, orgName)) {
+		return errs.NotFound("student work", "repository", claims.Repository)
+	}
+	if err != nil {
+		fmt.Println("Error getting student work:", err)
+		return errs.InternalServerError()
+	}
+
+	assignmentID := int64(studentWork.AssignmentOutlineID)
+	layout, err := common.GetBranchLayout(c.Context(), s.store, assignmentID)
+	if err != nil {
+		fmt.Println("Error getting branch layout:", err)
+		return errs.InternalServerError()
+	}
+	if claims.Ref != "refs/heads/"+layout.SubmissionBranch {
+		return errs.InsufficientPermissionsError()
+	}
+
+	config, err := s.store.GetAutograderConfig(c.Context(), assignmentID)
+	if err != nil {
+		fmt.Println("Error getting autograder config:", err)
+		return errs.InternalServerError()
+	}
+
+	// The workflow only reports pass or fail, the points come from the config
+	var report models.AutograderReport
+	if err := c.BodyParser(&report); err != nil {
+		return errs.InvalidRequestBody(report)
+	}
+
+	result, err := s.store.RecordAutograderResult(c.Context(), int64(studentWork.ID), claims.SHA, true, config.ScoreReport(report))
+	if err != nil {
+		fmt.Println("Error recording autograder result:", err)
+		return errs.InternalServerError()
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"autograder_result": result})
+}
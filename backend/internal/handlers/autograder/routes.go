@@ -0,0 +1,17 @@
+package autograder
+
+import (
+	"strings"
+
+	"github.com/CamPlume1/khoury-classroom/internal/middleware"
+	"github.com/CamPlume1/khoury-classroom/internal/types"
+	"github.com/gofiber/fiber/v2"
+)
+
+func Routes(app *fiber.App, params types.Params) {
+	service := newAutograderService(params.Store)
+	baseRouter := app.Group("")
+
+	// Report the results of an autograder workflow run (the workflow requests its token with our URL as the audience)
+	baseRouter.Post("/autograder/results", middleware.ProtectedWorkflow(strings.TrimRight(params.Domains.BACKEND_URL, "/")), service.ReportHandler)
+}
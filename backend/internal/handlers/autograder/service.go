@@ -0,0 +1,17 @@
+package autograder
+
+import (
+	"github.com/CamPlume1/khoury-classroom/internal/storage"
+)
+
+type AutograderService struct {
+	store storage.Storage
+}
+
+func newAutograderService(
+	store storage.Storage,
+) *AutograderService {
+	return &AutograderService{
+		store: store,
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/CamPlume1/khoury-classroom/internal/handlers/common"
 	"github.com/CamPlume1/khoury-classroom/internal/middleware"
 	"github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/CamPlume1/khoury-classroom/internal/storage"
 	"github.com/CamPlume1/khoury-classroom/internal/utils"
 	"github.com/gofiber/fiber/v2"
 	gh "github.com/google/go-github/github"
@@ -84,6 +85,22 @@ func (s *AssignmentService) createAssignment() fiber.Handler {
 			return errs.InvalidRequestBody(assignmentData)
 		}
 
+		// The branch layout is optional, and has to be known before the base repository is initialized
+		var layoutData struct {
+			BranchLayout *models.BranchLayout `json:"branch_layout"`
+		}
+		if err := c.BodyParser(&layoutData); err != nil {
+			return errs.InvalidRequestBody(layoutData)
+		}
+		if layoutData.BranchLayout != nil {
+			if layoutData.BranchLayout.ExtraBranches == nil {
+				layoutData.BranchLayout.ExtraBranches = []string{}
+			}
+			if invalid := layoutData.BranchLayout.Validate(); len(invalid) > 0 {
+				return errs.InvalidRequestData(invalid)
+			}
+		}
+
 		// Check if user has at least Professor role
 		_, err := s.RequireAtLeastRole(c, assignmentData.ClassroomID, models.Professor)
 		if err != nil {
@@ -104,13 +121,16 @@ func (s *AssignmentService) createAssignment() fiber.Handler {
 		if err != nil {
 			return err
 		}
+		if classroom.SuspendedAt != nil {
+			return errs.ClassroomSuspendedError()
+		}
 		template, err := s.store.GetAssignmentTemplateByID(c.Context(), assignmentData.TemplateID)
 		if err != nil {
 			return err
 		}
 
 		// Create base repository and store locally
-		baseRepoName, err := generateUniqueRepoName(c.Context(), s.appClient, classroom.OrgName, classroom.Name, assignmentData.Name)
+		baseRepoName, err := generateUniqueRepoName(c.Context(), s.appClient, s.store, classroom.OrgName, classroom.Name, assignmentData.Name)
 		if err != nil {
 			return err
 		}
@@ -130,6 +150,13 @@ func (s *AssignmentService) createAssignment() fiber.Handler {
 		if err != nil {
 			return err
 		}
+		if layoutData.BranchLayout != nil {
+			layoutData.BranchLayout.AssignmentOutlineID = createdAssignment.ID
+			_, err = s.store.UpsertBranchLayout(c.Context(), *layoutData.BranchLayout)
+			if err != nil {
+				return err
+			}
+		}
 
 		return c.Status(http.StatusOK).JSON(fiber.Map{
 			"created_assignment": createdAssignment,
@@ -219,6 +246,9 @@ func (s *AssignmentService) useAssignmentToken() fiber.Handler {
 			fmt.Println("Error getting base repo:", err)
 			return errs.InternalServerError()
 		}
+		if baseRepo.DeletedAt != nil {
+			return errs.BadRequest(errors.New("the assignment's base repository was deleted from the organization"))
+		}
 
 		// Get classroom
 		classroom, err := s.store.GetClassroomByID(c.Context(), assignment.ClassroomID)
@@ -226,6 +256,9 @@ func (s *AssignmentService) useAssignmentToken() fiber.Handler {
 			fmt.Println("Error getting classroom:", err)
 			return errs.InternalServerError()
 		}
+		if classroom.SuspendedAt != nil {
+			return errs.ClassroomSuspendedError()
+		}
 
 		// Check if user has at least student role
 		_, err = s.RequireAtLeastRole(c, classroom.ID, models.Student)
@@ -264,8 +297,53 @@ func (s *AssignmentService) useAssignmentToken() fiber.Handler {
 			}
 		}
 
+		// Group assignments are accepted once per team, and the fork is shared by all of its members
+		var team *models.Team
+		if assignment.GroupAssignment {
+			team, err = s.getTeamReadyToAccept(c, assignment, *user.ID)
+			if err != nil {
+				return err
+			}
+		}
+
+		// Resume the student's (or their team's) earlier acceptance against its existing fork rather than forking again
+		var forkJob models.ForkJob
+		if team != nil {
+			forkJob, err = s.store.GetForkJobByTeam(c.Context(), team.ID)
+		} else {
+			forkJob, err = s.store.GetForkJobByAssignmentAndUser(c.Context(), int64(assignment.ID), *user.ID)
+		}
+		if err == nil {
+			if forkJob.State == models.ForkJobStateFailed {
+				// a teammate may have retried it first
+				resetJob, err := s.store.ResetForkJob(c.Context(), forkJob.ID)
+				if err == nil {
+					forkJob = resetJob
+				} else if !errors.Is(err, pgx.ErrNoRows) {
+					fmt.Println("Error resetting fork job:", err)
+					return errs.InternalServerError()
+				}
+			}
+
+			return c.Status(http.StatusAccepted).JSON(fiber.Map{
+				"message":  "Assignment already accepted",
+				"repo_url": fmt.Sprintf("https://github.com/%s/%s", forkJob.OrgName, forkJob.RepoName),
+				"job_id":   forkJob.ID,
+				"status":   forkJob.State,
+			})
+		} else if !errors.Is(err, pgx.ErrNoRows) {
+			fmt.Println("Error getting fork job:", err)
+			return errs.InternalServerError()
+		}
+
 		// Generate fork name, appending a numeric suffix if necessary
-		forkName, err := generateUniqueRepoName(c.Context(), s.appClient, classroom.OrgName, baseRepo.BaseRepoName, githubUser.Login)
+		forkOwnerName := githubUser.Login
+		var teamID *int64
+		if team != nil {
+			forkOwnerName = team.Name
+			teamID = &team.ID
+		}
+		forkName, err := generateUniqueRepoName(c.Context(), s.appClient, s.store, classroom.OrgName, baseRepo.BaseRepoName, forkOwnerName)
 		if err != nil {
 			return err
 		}
@@ -279,108 +357,266 @@ func (s *AssignmentService) useAssignmentToken() fiber.Handler {
 			}
 		}
 
-		firstCommitSHA, err := s.getFirstCommitSHA(c.Context(), client, baseRepo.BaseRepoOwner, baseRepo.BaseRepoName)
+		layout, err := common.GetBranchLayout(c.Context(), s.store, int64(assignment.ID))
+		if err != nil {
+			fmt.Println("Error getting branch layout:", err)
+			return errs.InternalServerError()
+		}
+
+		firstCommitSHA, err := s.getFirstCommitSHA(c.Context(), client, baseRepo.BaseRepoOwner, baseRepo.BaseRepoName, layout.SubmissionBranch)
 		if err != nil {
 			fmt.Println("Error getting first commit SHA:", err)
 			return errs.InternalServerError()
 		}
 
-		// Generate fork
-		err = client.ForkRepository(c.Context(),
-			baseRepo.BaseRepoOwner,
-			baseRepo.BaseRepoName,
-			classroom.OrgName,
-			forkName)
+		// Queue the fork and the rest of the setup, which has to wait until GitHub has finished copying the fork
+		forkJob, err = s.store.CreateForkJob(c.Context(), models.ForkJob{
+			AssignmentOutlineID: assignment.ID,
+			UserID:              *user.ID,
+			OrgName:             classroom.OrgName,
+			RepoName:            forkName,
+			FirstCommitSHA:      *firstCommitSHA,
+			TeamID:              teamID,
+		})
+		if errors.Is(err, pgx.ErrNoRows) && team != nil {
+			// a teammate accepted the assignment at the same time, share their fork
+			forkJob, err = s.store.GetForkJobByTeam(c.Context(), team.ID)
+			if err != nil {
+				fmt.Println("Error getting team fork job:", err)
+				return errs.InternalServerError()
+			}
+
+			return c.Status(http.StatusAccepted).JSON(fiber.Map{
+				"message":  "Assignment already accepted",
+				"repo_url": fmt.Sprintf("https://github.com/%s/%s", forkJob.OrgName, forkJob.RepoName),
+				"job_id":   forkJob.ID,
+				"status":   forkJob.State,
+			})
+		}
 		if err != nil {
-			fmt.Println("Error forking repository:", err)
-			return errs.GithubAPIError(err)
+			fmt.Println("Error creating fork job:", err)
+			return errs.InternalServerError()
 		}
 
-		// TODO HERE: insert repo name to fork_queue table, quit
-		// listen for webhook repository creation: if repo name in fork_queue table, create PR and remove read rights etc
+		return c.Status(http.StatusAccepted).JSON(fiber.Map{
+			"message":  "Assignment Accepted!",
+			"repo_url": fmt.Sprintf("https://github.com/%s/%s", classroom.OrgName, forkName),
+			"job_id":   forkJob.ID,
+			"status":   forkJob.State,
+		})
+	}
+}
+
+// Returns the status of a queued assignment fork.
+func (s *AssignmentService) getForkJobStatus() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		jobID, err := strconv.ParseInt(c.Params("job_id"), 10, 64)
+		if err != nil {
+			return errs.BadRequest(err)
+		}
 
-		// Wait to perform actions on the fork until it is finished initializing
-		initialDelay := 1 * time.Second
-		maxDelay := 30 * time.Second
+		_, _, user, err := middleware.GetClientAndUser(c, s.store, s.userCfg)
+		if err != nil {
+			return errs.AuthenticationError()
+		}
 
-		var studentWorkRepo *gh.Repository
-		for {
-			repo, err := client.GetRepository(c.Context(), classroom.OrgName, forkName)
+		forkJob, err := s.store.GetForkJob(c.Context(), jobID)
+		if err != nil {
+			return errs.NotFound("fork job", "id", c.Params("job_id"))
+		}
+
+		// Students may only see their own forks
+		if user.ID == nil || *user.ID != forkJob.UserID {
+			assignment, err := s.store.GetAssignmentByID(c.Context(), int64(forkJob.AssignmentOutlineID))
 			if err != nil {
-				if initialDelay > maxDelay {
-					fmt.Println("Fork wait loop timed out")
-					return errs.GithubAPIError(errors.New("fork unsuccessful, please try again later"))
-				}
-				time.Sleep(initialDelay)
-				initialDelay *= 2
-				continue
+				return errs.InternalServerError()
 			}
-
-			studentWorkRepo = repo
-			if client.CheckForkIsReady(c.Context(), studentWorkRepo) {
-				break
+			_, err = s.RequireAtLeastRole(c, assignment.ClassroomID, models.TA)
+			if err != nil {
+				return err
 			}
+		}
 
-			if initialDelay > maxDelay {
-				fmt.Println("Fork wait loop timed out")
-				return errs.GithubAPIError(errors.New("fork unsuccessful, please try again later"))
-			}
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"job_id":     forkJob.ID,
+			"status":     forkJob.State,
+			"completed_steps": forkJob.CompletedSteps,
+			"attempts":   forkJob.Attempts,
+			"last_error": forkJob.LastError,
+			"repo_url":   fmt.Sprintf("https://github.com/%s/%s", forkJob.OrgName, forkJob.RepoName),
+		})
+	}
+}
+
+// Returns the autograder config of an assignment.
+func (s *AssignmentService) getAutograderConfig() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		assignment, err := s.getAssignmentInClassroom(c, models.TA)
+		if err != nil {
+			return err
+		}
+
+		config, err := s.store.GetAutograderConfig(c.Context(), int64(assignment.ID))
+		if err != nil {
+			return errs.InternalServerError()
+		}
 
-			time.Sleep(initialDelay)
-			initialDelay *= 2
+		return c.Status(http.StatusOK).JSON(fiber.Map{"autograder_config": config})
+	}
+}
+
+// Updates the autograder config of an assignment.
+func (s *AssignmentService) updateAutograderConfig() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		assignment, err := s.getAssignmentInClassroom(c, models.Professor)
+		if err != nil {
+			return err
+		}
+		assignmentID := int64(assignment.ID)
+
+		var config models.AutograderConfig
+		if err := c.BodyParser(&config); err != nil {
+			return errs.InvalidRequestBody(config)
+		}
+		if config.ScorePolicy == "" {
+			config.ScorePolicy = models.AutograderScorePolicyMax
 		}
+		if config.TimeoutMinutes == 0 {
+			config.TimeoutMinutes = 10
+		}
+		if config.Tests == nil {
+			config.Tests = []models.AutograderTest{}
+		}
+		if invalid := validateAutograderConfig(config); len(invalid) > 0 {
+			return errs.InvalidRequestData(invalid)
+		}
+		config.AssignmentOutlineID = int32(assignmentID)
 
-		// Force push to the first commit, then merge them back in to get rid of the "enable actions" button
-		err = client.SetBranchToCommit(c.Context(), studentWorkRepo.GetOrganization().GetLogin(), studentWorkRepo.GetName(), "main", *firstCommitSHA)
+		config, err = s.store.UpsertAutograderConfig(c.Context(), config)
 		if err != nil {
-			fmt.Println("Error setting branch to commit:", err)
-			return errs.GithubAPIError(err)
+			return errs.InternalServerError()
 		}
 
-		err = client.SyncForkWithUpstream(c.Context(), studentWorkRepo.GetOrganization().GetLogin(), studentWorkRepo.GetName(), "main")
+		// Keep the workflow of an already initialized base repository in sync with the config
+		baseRepo, err := s.store.GetBaseRepoByID(c.Context(), assignment.BaseRepoID)
 		if err != nil {
-			fmt.Println("Error syncing fork with upstream:", err)
-			return errs.GithubAPIError(err)
+			return errs.InternalServerError()
+		}
+		if baseRepo.Initialized {
+			err = common.CreateAutograderWorkflow(c.Context(), s.appClient, s.store, assignmentID, baseRepo.BaseRepoOwner, baseRepo.BaseRepoName, s.domains.BACKEND_URL)
+			if err != nil {
+				fmt.Println("Error creating autograder workflow:", err)
+				return errs.GithubAPIError(err)
+			}
 		}
 
-		// Create feedback pull request
-		err = client.CreateFeedbackPR(c.Context(), studentWorkRepo.GetOrganization().GetLogin(), studentWorkRepo.GetName())
+		return c.Status(http.StatusOK).JSON(fiber.Map{"autograder_config": config})
+	}
+}
+
+// Returns the problems with an autograder config, keyed by field.
+func validateAutograderConfig(config models.AutograderConfig) map[string]string {
+	invalid := make(map[string]string)
+	if config.ScorePolicy != models.AutograderScorePolicyMax && config.ScorePolicy != models.AutograderScorePolicyLatest {
+		invalid["score_policy"] = "must be MAX or LATEST"
+	}
+	if config.TimeoutMinutes < 1 || config.TimeoutMinutes > 360 {
+		invalid["timeout_minutes"] = "must be between 1 and 360"
+	}
+
+	names := make(map[string]bool)
+	for i, test := range config.Tests {
+		field := fmt.Sprintf("tests[%d]", i)
+		switch {
+		case strings.TrimSpace(test.Name) == "":
+			invalid[field] = "name is required"
+		case names[test.Name]:
+			invalid[field] = "name must be unique"
+		case strings.TrimSpace(test.Run) == "":
+			invalid[field] = "run is required"
+		case test.Points < 0:
+			invalid[field] = "points cannot be negative"
+		case test.TimeoutMinutes != nil && (*test.TimeoutMinutes < 1 || *test.TimeoutMinutes > config.TimeoutMinutes):
+			invalid[field] = "timeout_minutes must be between 1 and the workflow timeout"
+		}
+		names[test.Name] = true
+	}
+
+	return invalid
+}
+
+// Lists the acceptances in a classroom that have not finished setting up.
+func (s *AssignmentService) getUnfinishedForkJobs() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		classroomID, err := strconv.ParseInt(c.Params("classroom_id"), 10, 64)
 		if err != nil {
-			fmt.Println("Error creating feedback pull request:", err)
-			return errs.CriticalGithubError()
+			return errs.BadRequest(err)
 		}
 
-		// KHO-239
-		err = client.CreateBranchRuleset(c.Context(), studentWorkRepo.GetOrganization().GetLogin(), studentWorkRepo.GetName())
+		_, err = s.RequireAtLeastRole(c, classroomID, models.Professor)
 		if err != nil {
-			fmt.Println("Error creating branch ruleset:", err)
-			return errs.CriticalGithubError()
+			return err
 		}
 
-		// Remove student team's access to forked repo
-		err = client.RemoveRepoFromTeam(c.Context(), classroom.OrgName, *classroom.StudentTeamName, classroom.OrgName, studentWorkRepo.GetName())
+		forkJobs, err := s.store.ListUnfinishedForkJobs(c.Context(), classroomID)
 		if err != nil {
-			fmt.Println("Error removing repo from team:", err)
-			return errs.GithubAPIError(err)
+			fmt.Println("Error listing fork jobs:", err)
+			return errs.InternalServerError()
 		}
 
-		// Insert into DB
-		_, err = s.store.CreateStudentWork(c.Context(), assignment.ID, githubUser.ID, forkName, models.WorkStateAccepted, assignment.MainDueDate)
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"fork_jobs": forkJobs,
+		})
+	}
+}
+
+// Re-drives a stuck acceptance, resuming at the first step it has not completed.
+func (s *AssignmentService) retryForkJob() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		classroomID, err := strconv.ParseInt(c.Params("classroom_id"), 10, 64)
+		if err != nil {
+			return errs.BadRequest(err)
+		}
+		jobID, err := strconv.ParseInt(c.Params("job_id"), 10, 64)
+		if err != nil {
+			return errs.BadRequest(err)
+		}
+
+		_, err = s.RequireAtLeastRole(c, classroomID, models.Professor)
 		if err != nil {
-			fmt.Println("Error creating student work:", err)
 			return err
 		}
 
+		forkJob, err := s.store.GetForkJob(c.Context(), jobID)
+		if err != nil {
+			return errs.NotFound("fork job", "id", c.Params("job_id"))
+		}
+		assignment, err := s.store.GetAssignmentByID(c.Context(), int64(forkJob.AssignmentOutlineID))
+		if err != nil {
+			return errs.InternalServerError()
+		}
+		if assignment.ClassroomID != classroomID {
+			return errs.NotFound("fork job", "id", c.Params("job_id"))
+		}
+
+		forkJob, err = s.store.ResetForkJob(c.Context(), jobID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return errs.BadRequest(errors.New("fork job has already completed or is running"))
+		}
+		if err != nil {
+			fmt.Println("Error resetting fork job:", err)
+			return errs.InternalServerError()
+		}
+
 		return c.Status(http.StatusOK).JSON(fiber.Map{
-			"message":  "Assignment Accepted!",
-			"repo_url": studentWorkRepo.GetHTMLURL(),
+			"fork_job": forkJob,
 		})
 	}
 }
 
-func (s *AssignmentService) getFirstCommitSHA(ctx context.Context, client github.GitHubBaseClient, orgName string, repoName string) (*string, error) {
+func (s *AssignmentService) getFirstCommitSHA(ctx context.Context, client github.GitHubBaseClient, orgName string, repoName string, branch string) (*string, error) {
 	commits, err := client.ListCommits(ctx, orgName, repoName, &gh.CommitsListOptions{
-		SHA: "main",
+		SHA: branch,
 	})
 	if err != nil {
 		return nil, err
@@ -414,15 +650,19 @@ func (s *AssignmentService) checkAssignmentName() fiber.Handler {
 }
 
 // Generates a unique repository name by appending a numeric suffix if necessary.
-func generateUniqueRepoName(ctx context.Context, client github.GitHubBaseClient, orgName string, parts ...string) (string, error) {
-	// Check if fork name already exists
+func generateUniqueRepoName(ctx context.Context, client github.GitHubBaseClient, store storage.Storage, orgName string, parts ...string) (string, error) {
+	// Check if fork name already exists, or is reserved by a fork that is still queued
 	suffixStr := ""
 	maxAttempts := 10
 	for i := 0; i < maxAttempts; i++ {
 		allParts := append(parts, suffixStr)
 		forkName := generateSlugCase(allParts...)
+		reserved, err := store.ForkJobRepoNameExists(ctx, forkName)
+		if err != nil {
+			return "", err
+		}
 		studentWorkRepo, _ := client.GetRepository(ctx, orgName, forkName) // don't check error because we are checking if repo exists
-		if studentWorkRepo == nil {
+		if studentWorkRepo == nil && !reserved {
 			return forkName, nil
 		}
 		suffixStr = strconv.Itoa(i + 1)
@@ -481,6 +721,11 @@ func (s *AssignmentService) updateAssignmentRubric() fiber.Handler {
 			return errs.BadRequest(error)
 		}
 
+		rubric, err := s.store.GetRubric(c.Context(), rubricID)
+		if err != nil || rubric.DeletedAt != nil {
+			return errs.NotFound("rubric", "id", rubricID)
+		}
+
 		updatedAssignment, err := s.store.UpdateAssignmentRubric(c.Context(), rubricID, assignmentID)
 		if err != nil {
 			return errs.InternalServerError()
@@ -511,6 +756,11 @@ func (s *AssignmentService) getAssignmentRubric() fiber.Handler {
 			return errs.InternalServerError()
 		}
 
+		sections, err := s.store.GetRubricSections(c.Context(), rubric.ID)
+		if err != nil {
+			return errs.InternalServerError()
+		}
+
 		rubricItems, err := s.store.GetRubricItems(c.Context(), rubric.ID)
 		if err != nil {
 			return errs.InternalServerError()
@@ -518,6 +768,7 @@ func (s *AssignmentService) getAssignmentRubric() fiber.Handler {
 
 		return c.Status(http.StatusOK).JSON(models.FullRubric{
 			Rubric:      rubric,
+			Sections:    sections,
 			RubricItems: rubricItems,
 		})
 	}
@@ -665,24 +916,10 @@ func (s *AssignmentService) GetCommitCount() fiber.Handler {
 
 		totalCommits := 0
 		for _, work := range works {
-			var branchOpts gh.ListOptions
-			branches, err := s.appClient.ListBranches(c.Context(), work.OrgName, work.RepoName, &branchOpts)
+			allCommits, err := common.ListWorkCommits(c.Context(), s.appClient, work.OrgName, work.RepoName, work.Contributors)
 			if err != nil {
 				return errs.GithubAPIError(err)
 			}
-			var allCommits []*gh.RepositoryCommit
-
-			for _, branch := range branches {
-				var opts gh.CommitsListOptions
-				// Assumes a single contirbutor, KHO-144
-				opts.Author = work.Contributors[0].GithubUsername
-				opts.SHA = *branch.Name
-				commits, err := s.appClient.ListCommits(c.Context(), work.OrgName, work.RepoName, &opts)
-				if err != nil {
-					return errs.GithubAPIError(err)
-				}
-				allCommits = append(allCommits, commits...)
-			}
 			totalCommits += len(allCommits)
 
 		}
@@ -0,0 +1,80 @@
+package assignments
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/CamPlume1/khoury-classroom/internal/errs"
+	"github.com/CamPlume1/khoury-classroom/internal/handlers/common"
+	"github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Returns the branches of an assignment's repositories: which one students submit on, which one feedback is left
+// against, and which others are created alongside them.
+func (s *AssignmentService) getBranchLayout() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		assignment, err := s.getAssignmentInClassroom(c, models.Student)
+		if err != nil {
+			return err
+		}
+
+		layout, err := common.GetBranchLayout(c.Context(), s.store, int64(assignment.ID))
+		if err != nil {
+			return errs.InternalServerError()
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{"branch_layout": layout})
+	}
+}
+
+// Sets the branches of an assignment's repositories. Forks keep the layout they were made with, so it can only
+// change until the first student accepts the assignment; an already initialized base repository is updated to match.
+func (s *AssignmentService) updateBranchLayout() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		assignment, err := s.getAssignmentInClassroom(c, models.Professor)
+		if err != nil {
+			return err
+		}
+
+		layout := models.DefaultBranchLayout
+		if err := c.BodyParser(&layout); err != nil {
+			return errs.InvalidRequestBody(models.BranchLayout{})
+		}
+		if layout.ExtraBranches == nil {
+			layout.ExtraBranches = []string{}
+		}
+		if invalid := layout.Validate(); len(invalid) > 0 {
+			return errs.InvalidRequestData(invalid)
+		}
+		layout.AssignmentOutlineID = assignment.ID
+
+		accepted, err := s.store.AssignmentHasForkJobs(c.Context(), int64(assignment.ID))
+		if err != nil {
+			return errs.InternalServerError()
+		}
+		if accepted {
+			return errs.BadRequest(errors.New("the branch layout can't change once students have accepted the assignment"))
+		}
+
+		layout, err = s.store.UpsertBranchLayout(c.Context(), layout)
+		if err != nil {
+			return errs.InternalServerError()
+		}
+
+		baseRepo, err := s.store.GetBaseRepoByID(c.Context(), assignment.BaseRepoID)
+		if err != nil {
+			return errs.InternalServerError()
+		}
+		if baseRepo.Initialized {
+			err = common.ApplyBranchLayout(c.Context(), s.appClient, s.store, assignment, baseRepo.BaseRepoOwner, baseRepo.BaseRepoName, s.domains.BACKEND_URL)
+			if err != nil {
+				fmt.Println("Error applying branch layout:", err)
+				return errs.GithubAPIError(err)
+			}
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{"branch_layout": layout})
+	}
+}
@@ -0,0 +1,280 @@
+package assignments
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/CamPlume1/khoury-classroom/internal/errs"
+	"github.com/CamPlume1/khoury-classroom/internal/handlers/common"
+	"github.com/CamPlume1/khoury-classroom/internal/middleware"
+	"github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/CamPlume1/khoury-classroom/internal/storage"
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+)
+
+// Helper function for checking that every user is a student in the classroom
+func (s *AssignmentService) requireStudents(c *fiber.Ctx, classroomID int64, userIDs []int64) error {
+	invalid := make(map[string]string)
+	for _, userID := range userIDs {
+		user, err := s.store.GetUserInClassroom(c.Context(), classroomID, userID)
+		if err != nil || user.Role != models.Student {
+			invalid[strconv.FormatInt(userID, 10)] = "user is not a student in this classroom"
+		}
+	}
+	if len(invalid) > 0 {
+		return errs.InvalidRequestData(invalid)
+	}
+	return nil
+}
+
+// Helper function for granting a deadline extension, recording it and the extension it replaces in the grading audit log
+func grantExtension(c *fiber.Ctx, store storage.Storage, classroomID int64, extension models.DeadlineExtension) (models.DeadlineExtension, error) {
+	var replaced *models.DeadlineExtension
+	previous, err := store.GetActiveDeadlineExtension(c.Context(), extension.AssignmentOutlineID, extension.UserID)
+	if err == nil {
+		replaced = &previous
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		return models.DeadlineExtension{}, err
+	}
+
+	granted, err := store.GrantDeadlineExtension(c.Context(), extension)
+	if err != nil {
+		return models.DeadlineExtension{}, err
+	}
+
+	err = auditDeadlineExtension(c, store, classroomID, models.GradingAuditExtensionGranted, extension.GrantedBy, replaced, granted)
+	if err != nil {
+		return models.DeadlineExtension{}, err
+	}
+	return granted, nil
+}
+
+// Records a change to a student's deadline extension in the grading audit log
+func auditDeadlineExtension(c *fiber.Ctx, store storage.Storage, classroomID int64, action models.GradingAuditAction, actorUserID int64, before *models.DeadlineExtension, after models.DeadlineExtension) error {
+	return common.RecordGradingAudit(c.Context(), store, models.GradingAuditEntry{
+		Action:              action,
+		ActorUserID:         &actorUserID,
+		ClassroomID:         classroomID,
+		AssignmentOutlineID: &after.AssignmentOutlineID,
+		StudentUserID:       &after.UserID,
+		SubjectID:           &after.ID,
+	}, before, after)
+}
+
+// Returns the deadline extensions on an assignment, including revoked ones if asked for its full history.
+func (s *AssignmentService) getDeadlineExtensions() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		assignment, err := s.getAssignmentInClassroom(c, models.TA)
+		if err != nil {
+			return err
+		}
+
+		extensions, err := s.store.ListDeadlineExtensions(c.Context(), int64(assignment.ID), c.QueryBool("include_revoked"))
+		if err != nil {
+			return errs.InternalServerError()
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"deadline_extensions": extensions,
+		})
+	}
+}
+
+// Grants a student a personal deadline for an assignment, replacing any extension they already had.
+// A team's work is only due later once every teammate has been granted an extension.
+func (s *AssignmentService) grantDeadlineExtension() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		assignment, err := s.getAssignmentInClassroom(c, models.Professor)
+		if err != nil {
+			return err
+		}
+
+		_, _, user, err := middleware.GetClientAndUser(c, s.store, s.userCfg)
+		if err != nil {
+			return errs.AuthenticationError()
+		}
+
+		var requestBody models.DeadlineExtensionRequest
+		if err := c.BodyParser(&requestBody); err != nil {
+			return errs.InvalidRequestBody(requestBody)
+		}
+
+		var dueDate time.Time
+		switch {
+		case requestBody.DueDate != nil && requestBody.ExtensionHours == nil:
+			dueDate = *requestBody.DueDate
+		case requestBody.DueDate == nil && requestBody.ExtensionHours != nil:
+			if *requestBody.ExtensionHours <= 0 {
+				return errs.BadRequest(errors.New("extension_hours must be positive"))
+			}
+			if assignment.MainDueDate == nil {
+				return errs.BadRequest(errors.New("assignment has no deadline to extend, give a due date instead"))
+			}
+			dueDate = assignment.MainDueDate.Add(time.Duration(*requestBody.ExtensionHours) * time.Hour)
+		default:
+			return errs.BadRequest(errors.New("exactly one of due_date and extension_hours is required"))
+		}
+
+		err = s.requireStudents(c, assignment.ClassroomID, []int64{requestBody.UserID})
+		if err != nil {
+			return err
+		}
+
+		var extension models.DeadlineExtension
+		err = s.store.WithTx(c.Context(), func(store storage.Storage) error {
+			extension, err = grantExtension(c, store, assignment.ClassroomID, models.DeadlineExtension{
+				AssignmentOutlineID: int64(assignment.ID),
+				UserID:              requestBody.UserID,
+				DueDate:             dueDate,
+				Reason:              requestBody.Reason,
+				GrantedBy:           *user.ID,
+			})
+			return err
+		})
+		if err != nil {
+			fmt.Println("Error granting deadline extension:", err)
+			return errs.InternalServerError()
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"deadline_extension": extension,
+		})
+	}
+}
+
+// Revokes a deadline extension, moving the student back to the assignment's deadline.
+func (s *AssignmentService) revokeDeadlineExtension() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		assignment, err := s.getAssignmentInClassroom(c, models.Professor)
+		if err != nil {
+			return err
+		}
+		extensionID, err := strconv.ParseInt(c.Params("extension_id"), 10, 64)
+		if err != nil {
+			return errs.BadRequest(err)
+		}
+
+		_, _, user, err := middleware.GetClientAndUser(c, s.store, s.userCfg)
+		if err != nil {
+			return errs.AuthenticationError()
+		}
+
+		var extension models.DeadlineExtension
+		err = s.store.WithTx(c.Context(), func(store storage.Storage) error {
+			extension, err = store.RevokeDeadlineExtension(c.Context(), int64(assignment.ID), extensionID, *user.ID)
+			if err != nil {
+				return err
+			}
+
+			active := extension
+			active.RevokedAt, active.RevokedBy = nil, nil
+			return auditDeadlineExtension(c, store, assignment.ClassroomID, models.GradingAuditExtensionRevoked, *user.ID, &active, extension)
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			return errs.NotFound("active deadline extension", "id", c.Params("extension_id"))
+		}
+		if err != nil {
+			fmt.Println("Error revoking deadline extension:", err)
+			return errs.InternalServerError()
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"deadline_extension": extension,
+		})
+	}
+}
+
+// Extends the deadlines of several students by the same number of hours on several (or every) assignment in a classroom.
+func (s *AssignmentService) grantBulkDeadlineExtensions() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		classroomID, err := strconv.ParseInt(c.Params("classroom_id"), 10, 64)
+		if err != nil {
+			return errs.BadRequest(err)
+		}
+
+		_, err = s.RequireAtLeastRole(c, classroomID, models.Professor)
+		if err != nil {
+			return err
+		}
+
+		_, _, user, err := middleware.GetClientAndUser(c, s.store, s.userCfg)
+		if err != nil {
+			return errs.AuthenticationError()
+		}
+
+		var requestBody models.BulkDeadlineExtensionRequest
+		if err := c.BodyParser(&requestBody); err != nil || len(requestBody.UserIDs) == 0 || requestBody.ExtensionHours <= 0 {
+			return errs.InvalidRequestBody(requestBody)
+		}
+
+		err = s.requireStudents(c, classroomID, requestBody.UserIDs)
+		if err != nil {
+			return err
+		}
+
+		classroomAssignments, err := s.store.GetAssignmentsInClassroom(c.Context(), classroomID)
+		if err != nil {
+			return errs.InternalServerError()
+		}
+		assignments := classroomAssignments
+		if len(requestBody.AssignmentIDs) > 0 {
+			byID := make(map[int64]models.AssignmentOutline)
+			for _, assignment := range classroomAssignments {
+				byID[int64(assignment.ID)] = assignment
+			}
+			assignments = []models.AssignmentOutline{}
+			for _, assignmentID := range requestBody.AssignmentIDs {
+				assignment, ok := byID[assignmentID]
+				if !ok {
+					return errs.NotFound("assignment", "id", strconv.FormatInt(assignmentID, 10))
+				}
+				assignments = append(assignments, assignment)
+			}
+		}
+
+		// every extension is granted or none are, so a failed bulk grant can simply be retried
+		results := make([]models.BulkDeadlineExtensionResult, len(requestBody.UserIDs))
+		for i, userID := range requestBody.UserIDs {
+			results[i] = models.BulkDeadlineExtensionResult{UserID: userID, DeadlineExtensions: []models.DeadlineExtension{}}
+		}
+		skippedAssignments := []int64{}
+		err = s.store.WithTx(c.Context(), func(store storage.Storage) error {
+			for _, assignment := range assignments {
+				// there is nothing to extend on assignments without a deadline
+				if assignment.MainDueDate == nil {
+					skippedAssignments = append(skippedAssignments, int64(assignment.ID))
+					continue
+				}
+				dueDate := assignment.MainDueDate.Add(time.Duration(requestBody.ExtensionHours) * time.Hour)
+
+				for i, userID := range requestBody.UserIDs {
+					extension, err := grantExtension(c, store, classroomID, models.DeadlineExtension{
+						AssignmentOutlineID: int64(assignment.ID),
+						UserID:              userID,
+						DueDate:             dueDate,
+						Reason:              requestBody.Reason,
+						GrantedBy:           *user.ID,
+					})
+					if err != nil {
+						return err
+					}
+					results[i].DeadlineExtensions = append(results[i].DeadlineExtensions, extension)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			fmt.Println("Error granting deadline extensions:", err)
+			return errs.InternalServerError()
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"results":             results,
+			"skipped_assignments": skippedAssignments,
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package assignments
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/CamPlume1/khoury-classroom/internal/errs"
+	"github.com/CamPlume1/khoury-classroom/internal/handlers/common"
+	"github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Exports the gradebook of a single assignment, as CSV or an LMS import file.
+func (s *AssignmentService) exportAssignmentGradebook() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		assignment, err := s.getAssignmentInClassroom(c, models.TA)
+		if err != nil {
+			return err
+		}
+
+		options, err := common.NewGradebookOptions(c.Query("format"), c.Query("identifier"), c.QueryBool("include_unpublished"))
+		if err != nil {
+			return errs.BadRequest(err)
+		}
+
+		gradebook, err := common.ExportGradebook(c.Context(), s.store, assignment.ClassroomID, []models.AssignmentOutline{assignment}, options)
+		if err != nil {
+			fmt.Println("Error exporting gradebook:", err)
+			return errs.InternalServerError()
+		}
+
+		c.Attachment(fmt.Sprintf("%s-gradebook.csv", assignment.Name))
+		return c.Status(http.StatusOK).Send(gradebook)
+	}
+}
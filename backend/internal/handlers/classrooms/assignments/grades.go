@@ -0,0 +1,217 @@
+package assignments
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/CamPlume1/khoury-classroom/internal/errs"
+	"github.com/CamPlume1/khoury-classroom/internal/handlers/common"
+	"github.com/CamPlume1/khoury-classroom/internal/middleware"
+	"github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/CamPlume1/khoury-classroom/internal/storage"
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+)
+
+// Helper function for getting an assignment, checking it belongs to the classroom and the user has the role there
+func (s *AssignmentService) getAssignmentInClassroom(c *fiber.Ctx, role models.ClassroomRole) (models.AssignmentOutline, error) {
+	classroomID, err := strconv.ParseInt(c.Params("classroom_id"), 10, 64)
+	if err != nil {
+		return models.AssignmentOutline{}, errs.BadRequest(err)
+	}
+	assignmentID, err := strconv.ParseInt(c.Params("assignment_id"), 10, 64)
+	if err != nil {
+		return models.AssignmentOutline{}, errs.BadRequest(err)
+	}
+
+	_, err = s.RequireAtLeastRole(c, classroomID, role)
+	if err != nil {
+		return models.AssignmentOutline{}, err
+	}
+
+	assignment, err := s.store.GetAssignmentByID(c.Context(), assignmentID)
+	if err != nil || assignment.ClassroomID != classroomID {
+		return models.AssignmentOutline{}, errs.NotFound("assignment", "id", c.Params("assignment_id"))
+	}
+
+	return assignment, nil
+}
+
+// Returns when an assignment's grades are scheduled to be, or were last, published, and how many works have draft grades.
+func (s *AssignmentService) getGradePublication() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		assignment, err := s.getAssignmentInClassroom(c, models.TA)
+		if err != nil {
+			return err
+		}
+		assignmentID := int64(assignment.ID)
+
+		var publication *models.GradePublication
+		gradePublication, err := s.store.GetGradePublication(c.Context(), assignmentID)
+		if err == nil {
+			publication = &gradePublication
+		} else if !errors.Is(err, pgx.ErrNoRows) {
+			return errs.InternalServerError()
+		}
+
+		works, err := s.store.GetWorksWithUnpublishedGrades(c.Context(), assignmentID)
+		if err != nil {
+			return errs.InternalServerError()
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"grade_publication": publication,
+			"unpublished_works": len(works),
+		})
+	}
+}
+
+// Releases the draft grades of every work in an assignment at once, either immediately or at a scheduled time.
+func (s *AssignmentService) publishGrades() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		assignment, err := s.getAssignmentInClassroom(c, models.Professor)
+		if err != nil {
+			return err
+		}
+		assignmentID := int64(assignment.ID)
+
+		client, _, user, err := middleware.GetClientAndUser(c, s.store, s.userCfg)
+		if err != nil {
+			return errs.AuthenticationError()
+		}
+
+		var requestBody models.PublishGradesRequest
+		if len(c.Body()) > 0 {
+			if err := c.BodyParser(&requestBody); err != nil {
+				return errs.InvalidRequestBody(requestBody)
+			}
+		}
+
+		now := time.Now().UTC()
+		if requestBody.PublishAt != nil && requestBody.PublishAt.After(now) {
+			previous, err := s.getScheduledPublication(c, assignmentID)
+			if err != nil {
+				return errs.InternalServerError()
+			}
+
+			var publication models.GradePublication
+			err = s.store.WithTx(c.Context(), func(store storage.Storage) error {
+				publication, err = store.UpsertGradePublication(c.Context(), assignmentID, *requestBody.PublishAt, nil, *user.ID)
+				if err != nil {
+					return err
+				}
+				return auditGradePublication(c, store, assignment, models.GradingAuditGradePublicationScheduled, *user.ID, previous, &publication)
+			})
+			if err != nil {
+				return errs.InternalServerError()
+			}
+
+			return c.Status(http.StatusOK).JSON(fiber.Map{
+				"grade_publication": publication,
+			})
+		}
+
+		published, err := common.PublishAssignmentGrades(c.Context(), client, s.store, assignmentID, user.ID)
+		if err != nil {
+			// works that did publish stay published, the rest are left as drafts to publish again
+			fmt.Println("Error publishing grades:", err)
+			return errs.GithubAPIError(err)
+		}
+
+		publication, err := s.store.UpsertGradePublication(c.Context(), assignmentID, now, &now, *user.ID)
+		if err != nil {
+			return errs.InternalServerError()
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"grade_publication": publication,
+			"published_works":   published,
+		})
+	}
+}
+
+// Cancels the scheduled publication of an assignment's grades.
+func (s *AssignmentService) cancelGradePublication() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		assignment, err := s.getAssignmentInClassroom(c, models.Professor)
+		if err != nil {
+			return err
+		}
+		assignmentID := int64(assignment.ID)
+
+		_, _, user, err := middleware.GetClientAndUser(c, s.store, s.userCfg)
+		if err != nil {
+			return errs.AuthenticationError()
+		}
+
+		scheduled, err := s.getScheduledPublication(c, assignmentID)
+		if err != nil {
+			return errs.InternalServerError()
+		}
+
+		err = s.store.WithTx(c.Context(), func(store storage.Storage) error {
+			if err := store.CancelGradePublication(c.Context(), assignmentID); err != nil {
+				return err
+			}
+			return auditGradePublication(c, store, assignment, models.GradingAuditGradePublicationCancelled, *user.ID, scheduled, nil)
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			return errs.NotFound("scheduled grade publication", "assignment id", c.Params("assignment_id"))
+		}
+		if err != nil {
+			return errs.InternalServerError()
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"message": "Grade publication cancelled",
+		})
+	}
+}
+
+// Returns the grading audit log of an assignment: every change to its works' grades, its rubric, deadline extensions and grade publication.
+func (s *AssignmentService) getAssignmentAuditLog() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		assignment, err := s.getAssignmentInClassroom(c, models.TA)
+		if err != nil {
+			return err
+		}
+
+		entries, err := s.store.GetAssignmentAuditLog(c.Context(), int64(assignment.ID))
+		if err != nil {
+			return errs.InternalServerError()
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"audit_log": entries,
+		})
+	}
+}
+
+// Helper function for getting an assignment's grade publication if it's scheduled and hasn't happened yet
+func (s *AssignmentService) getScheduledPublication(c *fiber.Ctx, assignmentID int64) (*models.GradePublication, error) {
+	publication, err := s.store.GetGradePublication(c.Context(), assignmentID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if publication.PublishedAt != nil {
+		return nil, nil
+	}
+	return &publication, nil
+}
+
+// Records the scheduling or cancellation of an assignment's grade publication in the grading audit log
+func auditGradePublication(c *fiber.Ctx, store storage.Storage, assignment models.AssignmentOutline, action models.GradingAuditAction, actorUserID int64, before *models.GradePublication, after *models.GradePublication) error {
+	assignmentID := int64(assignment.ID)
+	return common.RecordGradingAudit(c.Context(), store, models.GradingAuditEntry{
+		Action:              action,
+		ActorUserID:         &actorUserID,
+		ClassroomID:         assignment.ClassroomID,
+		AssignmentOutlineID: &assignmentID,
+	}, before, after)
+}
@@ -0,0 +1,92 @@
+package assignments
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/CamPlume1/khoury-classroom/internal/errs"
+	"github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+)
+
+func validateLatePolicy(policy models.LatePolicy) map[string]string {
+	invalid := make(map[string]string)
+	if policy.GracePeriodMinutes < 0 {
+		invalid["grace_period_minutes"] = "cannot be negative"
+	}
+	if policy.PenaltyPercentPerDay < 0 || policy.PenaltyPercentPerDay > 100 {
+		invalid["penalty_percent_per_day"] = "must be between 0 and 100"
+	}
+	if policy.MaxPenaltyPercent < 0 || policy.MaxPenaltyPercent > 100 {
+		invalid["max_penalty_percent"] = "must be between 0 and 100"
+	}
+	if policy.HardCutoffHours != nil && *policy.HardCutoffHours < 0 {
+		invalid["hard_cutoff_hours"] = "cannot be negative"
+	}
+	return invalid
+}
+
+// Returns the late policy of an assignment, null if it has none and late submissions carry no penalty.
+func (s *AssignmentService) getLatePolicy() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		assignment, err := s.getAssignmentInClassroom(c, models.TA)
+		if err != nil {
+			return err
+		}
+
+		var latePolicy *models.LatePolicy
+		policy, err := s.store.GetLatePolicy(c.Context(), int64(assignment.ID))
+		if err == nil {
+			latePolicy = &policy
+		} else if !errors.Is(err, pgx.ErrNoRows) {
+			return errs.InternalServerError()
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{"late_policy": latePolicy})
+	}
+}
+
+// Sets how late submissions to an assignment are penalized.
+func (s *AssignmentService) updateLatePolicy() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		assignment, err := s.getAssignmentInClassroom(c, models.Professor)
+		if err != nil {
+			return err
+		}
+
+		policy := models.LatePolicy{MaxPenaltyPercent: 100}
+		if err := c.BodyParser(&policy); err != nil {
+			return errs.InvalidRequestBody(models.LatePolicy{})
+		}
+		policy.AssignmentOutlineID = assignment.ID
+
+		if invalid := validateLatePolicy(policy); len(invalid) > 0 {
+			return errs.InvalidRequestData(invalid)
+		}
+
+		policy, err = s.store.UpsertLatePolicy(c.Context(), policy)
+		if err != nil {
+			return errs.InternalServerError()
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{"late_policy": policy})
+	}
+}
+
+// Removes the late policy of an assignment, so late submissions carry no penalty.
+func (s *AssignmentService) deleteLatePolicy() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		assignment, err := s.getAssignmentInClassroom(c, models.Professor)
+		if err != nil {
+			return err
+		}
+
+		err = s.store.DeleteLatePolicy(c.Context(), int64(assignment.ID))
+		if err != nil {
+			return errs.InternalServerError()
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{"message": "Late policy removed"})
+	}
+}
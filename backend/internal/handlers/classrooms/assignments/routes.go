@@ -20,6 +20,18 @@ func AssignmentRoutes(router fiber.Router, service *AssignmentService, params *t
 	// Use a token to accept an assignment
 	assignmentRouter.Post("/token/:token", service.useAssignmentToken())
 
+	// Get the acceptances in a classroom that have not finished setting up
+	assignmentRouter.Get("/fork-jobs", service.getUnfinishedForkJobs())
+
+	// Get the status of a queued assignment fork
+	assignmentRouter.Get("/fork-jobs/:job_id", service.getForkJobStatus())
+
+	// Re-drive a stuck acceptance from the step it failed at
+	assignmentRouter.Post("/fork-jobs/:job_id/retry", service.retryForkJob())
+
+	// Extend the deadlines of several students across assignments
+	assignmentRouter.Post("/extensions/bulk", service.grantBulkDeadlineExtensions())
+
 	// Get the details of an assignment
 	assignmentRouter.Get("/assignment/:assignment_id", service.getAssignment())
 
@@ -35,6 +47,78 @@ func AssignmentRoutes(router fiber.Router, service *AssignmentService, params *t
 	// Get the rubric and rubric items attached to an assignment
 	assignmentRouter.Get("/assignment/:assignment_id/rubric", service.getAssignmentRubric())
 
+	// Get the autograder config of an assignment
+	assignmentRouter.Get("/assignment/:assignment_id/autograder", service.getAutograderConfig())
+
+	// Update the autograder config of an assignment
+	assignmentRouter.Put("/assignment/:assignment_id/autograder", service.updateAutograderConfig())
+
+	// Get the scheduled or last publication of an assignment's grades
+	assignmentRouter.Get("/assignment/:assignment_id/publish-grades", service.getGradePublication())
+
+	// Publish the draft grades of an assignment, now or at a scheduled time
+	assignmentRouter.Post("/assignment/:assignment_id/publish-grades", service.publishGrades())
+
+	// Cancel the scheduled publication of an assignment's grades
+	assignmentRouter.Delete("/assignment/:assignment_id/publish-grades", service.cancelGradePublication())
+
+	// Export the gradebook of an assignment
+	assignmentRouter.Get("/assignment/:assignment_id/gradebook", service.exportAssignmentGradebook())
+
+	// Get the grading audit log of an assignment
+	assignmentRouter.Get("/assignment/:assignment_id/audit-log", service.getAssignmentAuditLog())
+
+	// Get the late policy of an assignment
+	assignmentRouter.Get("/assignment/:assignment_id/late-policy", service.getLatePolicy())
+
+	// Update the late policy of an assignment
+	assignmentRouter.Put("/assignment/:assignment_id/late-policy", service.updateLatePolicy())
+
+	// Remove the late policy of an assignment
+	assignmentRouter.Delete("/assignment/:assignment_id/late-policy", service.deleteLatePolicy())
+
+	// Get the deadline extensions on an assignment
+	assignmentRouter.Get("/assignment/:assignment_id/extensions", service.getDeadlineExtensions())
+
+	// Grant a student a deadline extension on an assignment
+	assignmentRouter.Post("/assignment/:assignment_id/extensions", service.grantDeadlineExtension())
+
+	// Revoke a deadline extension
+	assignmentRouter.Delete("/assignment/:assignment_id/extensions/:extension_id", service.revokeDeadlineExtension())
+
+	// Get the branch layout of an assignment's repositories
+	assignmentRouter.Get("/assignment/:assignment_id/branch-layout", service.getBranchLayout())
+
+	// Update the branch layout of an assignment's repositories
+	assignmentRouter.Put("/assignment/:assignment_id/branch-layout", service.updateBranchLayout())
+
+	// Get the team sizes of a group assignment
+	assignmentRouter.Get("/assignment/:assignment_id/group-settings", service.getGroupSettings())
+
+	// Update the team sizes of a group assignment
+	assignmentRouter.Put("/assignment/:assignment_id/group-settings", service.updateGroupSettings())
+
+	// Get the teams of a group assignment
+	assignmentRouter.Get("/assignment/:assignment_id/teams", service.getTeams())
+
+	// Create a team on a group assignment
+	assignmentRouter.Post("/assignment/:assignment_id/teams", service.createTeam())
+
+	// Get the current student's team on a group assignment
+	assignmentRouter.Get("/assignment/:assignment_id/teams/mine", service.getMyTeam())
+
+	// Create teams on a group assignment from a CSV
+	assignmentRouter.Post("/assignment/:assignment_id/teams/import", service.importTeams())
+
+	// Replace the join token of a team
+	assignmentRouter.Post("/assignment/:assignment_id/teams/:team_id/token", service.regenerateTeamToken())
+
+	// Remove a student from a team
+	assignmentRouter.Delete("/assignment/:assignment_id/teams/:team_id/members/:user_id", service.removeTeamMember())
+
+	// Use a team token to join a team
+	assignmentRouter.Post("/teams/token/:token", service.joinTeam())
+
 	// Check if an assignment name exists
 	assignmentRouter.Get("/assignment/:assignment_name/exists", service.checkAssignmentName())
 
@@ -0,0 +1,573 @@
+package assignments
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/CamPlume1/khoury-classroom/internal/errs"
+	"github.com/CamPlume1/khoury-classroom/internal/middleware"
+	"github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/CamPlume1/khoury-classroom/internal/utils"
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+)
+
+// Helper function for getting the team sizes of an assignment, which are unbounded until configured
+func (s *AssignmentService) getTeamSizes(ctx context.Context, assignmentID int64) (models.GroupSettings, error) {
+	settings, err := s.store.GetGroupSettings(ctx, assignmentID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		settings = models.DefaultGroupSettings
+		settings.AssignmentOutlineID = int32(assignmentID)
+		return settings, nil
+	}
+	return settings, err
+}
+
+// Helper function for getting the team a student is accepting a group assignment with, checking it is large enough
+func (s *AssignmentService) getTeamReadyToAccept(c *fiber.Ctx, assignment models.AssignmentOutline, userID int64) (*models.Team, error) {
+	team, err := s.store.GetTeamOfUser(c.Context(), int64(assignment.ID), userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, errs.BadRequest(errors.New("create or join a team before accepting this group assignment"))
+	}
+	if err != nil {
+		return nil, errs.InternalServerError()
+	}
+
+	settings, err := s.getTeamSizes(c.Context(), int64(assignment.ID))
+	if err != nil {
+		return nil, errs.InternalServerError()
+	}
+	if len(team.Members) < settings.MinTeamSize {
+		return nil, errs.BadRequest(fmt.Errorf("teams need at least %d members to accept this assignment", settings.MinTeamSize))
+	}
+
+	return &team, nil
+}
+
+// Helper function for getting a group assignment's team from the route, checking it belongs to the assignment
+func (s *AssignmentService) getTeamInAssignment(c *fiber.Ctx, assignment models.AssignmentOutline) (models.Team, error) {
+	teamID, err := strconv.ParseInt(c.Params("team_id"), 10, 64)
+	if err != nil {
+		return models.Team{}, errs.BadRequest(err)
+	}
+
+	team, err := s.store.GetTeam(c.Context(), teamID)
+	if err != nil || team.AssignmentOutlineID != assignment.ID {
+		return models.Team{}, errs.NotFound("team", "id", c.Params("team_id"))
+	}
+	return team, nil
+}
+
+// Helper function for checking whether a user is on a team
+func isTeamMember(team models.Team, userID int64) bool {
+	return slices.ContainsFunc(team.Members, func(member models.TeamMember) bool { return member.UserID == userID })
+}
+
+// Gives a student who joined a team after its fork was shared with the team access to it
+func (s *AssignmentService) grantLateJoinerAccess(ctx context.Context, team models.Team, githubUsername string) error {
+	forkJob, err := s.store.GetForkJobByTeam(ctx, team.ID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// until then, the fork job grants the whole team access
+	if !slices.Contains(forkJob.CompletedSteps, models.ForkJobStepGrantTeamAccess) {
+		return nil
+	}
+	return s.appClient.AssignPermissionToUser(ctx, forkJob.OrgName, forkJob.RepoName, githubUsername, "push")
+}
+
+// Returns the team sizes of a group assignment.
+func (s *AssignmentService) getGroupSettings() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		assignment, err := s.getAssignmentInClassroom(c, models.Student)
+		if err != nil {
+			return err
+		}
+
+		settings, err := s.getTeamSizes(c.Context(), int64(assignment.ID))
+		if err != nil {
+			return errs.InternalServerError()
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{"group_settings": settings})
+	}
+}
+
+// Sets the team sizes of a group assignment. A max team size of 0 means teams can be any size.
+func (s *AssignmentService) updateGroupSettings() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		assignment, err := s.getAssignmentInClassroom(c, models.Professor)
+		if err != nil {
+			return err
+		}
+		if !assignment.GroupAssignment {
+			return errs.BadRequest(errors.New("assignment is not a group assignment"))
+		}
+
+		var settings models.GroupSettings
+		if err := c.BodyParser(&settings); err != nil {
+			return errs.InvalidRequestBody(settings)
+		}
+		if settings.MinTeamSize < 1 {
+			return errs.InvalidRequestData(map[string]string{"min_team_size": "must be at least 1"})
+		}
+		if settings.MaxTeamSize != 0 && settings.MaxTeamSize < settings.MinTeamSize {
+			return errs.InvalidRequestData(map[string]string{"max_team_size": "must be 0 (unlimited) or at least the min team size"})
+		}
+		settings.AssignmentOutlineID = assignment.ID
+
+		settings, err = s.store.UpsertGroupSettings(c.Context(), settings)
+		if err != nil {
+			return errs.InternalServerError()
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{"group_settings": settings})
+	}
+}
+
+// Returns every team of a group assignment, with their join tokens.
+func (s *AssignmentService) getTeams() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		assignment, err := s.getAssignmentInClassroom(c, models.TA)
+		if err != nil {
+			return err
+		}
+
+		teams, err := s.store.ListTeams(c.Context(), int64(assignment.ID))
+		if err != nil {
+			return errs.InternalServerError()
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{"teams": teams})
+	}
+}
+
+// Returns the current student's team on a group assignment, nil if they aren't on one.
+func (s *AssignmentService) getMyTeam() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		assignment, err := s.getAssignmentInClassroom(c, models.Student)
+		if err != nil {
+			return err
+		}
+
+		_, _, user, err := middleware.GetClientAndUser(c, s.store, s.userCfg)
+		if err != nil {
+			return errs.AuthenticationError()
+		}
+
+		team, err := s.store.GetTeamOfUser(c.Context(), int64(assignment.ID), *user.ID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.Status(http.StatusOK).JSON(fiber.Map{"team": nil})
+		}
+		if err != nil {
+			return errs.InternalServerError()
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{"team": team})
+	}
+}
+
+// Creates a team on a group assignment with the current student as its first member.
+// Teammates join with the returned team's join token.
+func (s *AssignmentService) createTeam() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		assignment, err := s.getAssignmentInClassroom(c, models.Student)
+		if err != nil {
+			return err
+		}
+		if !assignment.GroupAssignment {
+			return errs.BadRequest(errors.New("assignment is not a group assignment"))
+		}
+
+		_, _, user, err := middleware.GetClientAndUser(c, s.store, s.userCfg)
+		if err != nil {
+			return errs.AuthenticationError()
+		}
+
+		var requestBody models.CreateTeamRequest
+		if err := c.BodyParser(&requestBody); err != nil || strings.TrimSpace(requestBody.Name) == "" {
+			return errs.InvalidRequestBody(requestBody)
+		}
+		name := strings.TrimSpace(requestBody.Name)
+
+		if _, err := s.store.GetTeamOfUser(c.Context(), int64(assignment.ID), *user.ID); err == nil {
+			return errs.BadRequest(errors.New("already on a team for this assignment"))
+		}
+
+		team, err := s.createTeamWithToken(c.Context(), assignment, name, *user.ID, true)
+		if err != nil {
+			return err
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{"team": team})
+	}
+}
+
+// Helper function for creating a team with a fresh join token, as long as its name isn't taken.
+// A student creating their own team joins it along with creating it.
+func (s *AssignmentService) createTeamWithToken(ctx context.Context, assignment models.AssignmentOutline, name string, createdBy int64, joinAsCreator bool) (models.Team, error) {
+	teams, err := s.store.ListTeams(ctx, int64(assignment.ID))
+	if err != nil {
+		return models.Team{}, errs.InternalServerError()
+	}
+	for _, team := range teams {
+		if strings.EqualFold(team.Name, name) {
+			return models.Team{}, errs.Conflict("team", "name", name)
+		}
+	}
+
+	token, err := utils.GenerateToken(16)
+	if err != nil {
+		return models.Team{}, errs.InternalServerError()
+	}
+
+	var team models.Team
+	if joinAsCreator {
+		team, err = s.store.CreateTeamWithMember(ctx, int64(assignment.ID), name, token, createdBy)
+	} else {
+		team, err = s.store.CreateTeam(ctx, int64(assignment.ID), name, token, createdBy)
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.Team{}, errs.BadRequest(errors.New("already on a team for this assignment"))
+	}
+	if err != nil {
+		fmt.Println("Error creating team:", err)
+		return models.Team{}, errs.InternalServerError()
+	}
+	return team, nil
+}
+
+// Uses a team's join token to join it.
+func (s *AssignmentService) joinTeam() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		classroomID, err := strconv.ParseInt(c.Params("classroom_id"), 10, 64)
+		if err != nil {
+			return errs.BadRequest(err)
+		}
+
+		_, err = s.RequireAtLeastRole(c, classroomID, models.Student)
+		if err != nil {
+			return err
+		}
+
+		_, _, user, err := middleware.GetClientAndUser(c, s.store, s.userCfg)
+		if err != nil {
+			return errs.AuthenticationError()
+		}
+
+		team, err := s.store.GetTeamByToken(c.Context(), c.Params("token"))
+		if err != nil {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{"message": "Invalid token"})
+		}
+		assignment, err := s.store.GetAssignmentByID(c.Context(), int64(team.AssignmentOutlineID))
+		if err != nil || assignment.ClassroomID != classroomID {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{"message": "Invalid token"})
+		}
+
+		if isTeamMember(team, *user.ID) {
+			return c.Status(http.StatusOK).JSON(fiber.Map{"message": "Already on this team", "team": team})
+		}
+		if _, err := s.store.GetTeamOfUser(c.Context(), int64(assignment.ID), *user.ID); err == nil {
+			return errs.BadRequest(errors.New("already on another team for this assignment, leave it first"))
+		}
+
+		settings, err := s.getTeamSizes(c.Context(), int64(assignment.ID))
+		if err != nil {
+			return errs.InternalServerError()
+		}
+
+		err = s.store.AddTeamMember(c.Context(), team.ID, *user.ID, settings.MaxTeamSize)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return errs.BadRequest(fmt.Errorf("team is full, teams have at most %d members", settings.MaxTeamSize))
+		}
+		if err != nil {
+			fmt.Println("Error joining team:", err)
+			return errs.InternalServerError()
+		}
+
+		err = s.grantLateJoinerAccess(c.Context(), team, user.GithubUsername)
+		if err != nil {
+			fmt.Println("Error granting team member access to fork:", err)
+			return errs.GithubAPIError(err)
+		}
+
+		team, err = s.store.GetTeam(c.Context(), team.ID)
+		if err != nil {
+			return errs.InternalServerError()
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{"message": "Joined team", "team": team})
+	}
+}
+
+// Removes a student from a team, either themselves leaving or a TA removing them. Only possible before the team's fork exists.
+func (s *AssignmentService) removeTeamMember() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		assignment, err := s.getAssignmentInClassroom(c, models.Student)
+		if err != nil {
+			return err
+		}
+		team, err := s.getTeamInAssignment(c, assignment)
+		if err != nil {
+			return err
+		}
+		memberID, err := strconv.ParseInt(c.Params("user_id"), 10, 64)
+		if err != nil {
+			return errs.BadRequest(err)
+		}
+
+		_, _, user, err := middleware.GetClientAndUser(c, s.store, s.userCfg)
+		if err != nil {
+			return errs.AuthenticationError()
+		}
+		if *user.ID != memberID {
+			_, err = s.RequireAtLeastRole(c, assignment.ClassroomID, models.TA)
+			if err != nil {
+				return err
+			}
+		}
+
+		if _, err := s.store.GetForkJobByTeam(c.Context(), team.ID); err == nil {
+			return errs.BadRequest(errors.New("the team has already accepted the assignment"))
+		}
+
+		err = s.store.RemoveTeamMember(c.Context(), team.ID, memberID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return errs.NotFound("team member", "user_id", c.Params("user_id"))
+		}
+		if err != nil {
+			return errs.InternalServerError()
+		}
+
+		return c.SendStatus(http.StatusOK)
+	}
+}
+
+// Replaces a team's join token, so links shared before stop working.
+func (s *AssignmentService) regenerateTeamToken() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		assignment, err := s.getAssignmentInClassroom(c, models.Student)
+		if err != nil {
+			return err
+		}
+		team, err := s.getTeamInAssignment(c, assignment)
+		if err != nil {
+			return err
+		}
+
+		_, _, user, err := middleware.GetClientAndUser(c, s.store, s.userCfg)
+		if err != nil {
+			return errs.AuthenticationError()
+		}
+		if !isTeamMember(team, *user.ID) {
+			_, err = s.RequireAtLeastRole(c, assignment.ClassroomID, models.TA)
+			if err != nil {
+				return err
+			}
+		}
+
+		token, err := utils.GenerateToken(16)
+		if err != nil {
+			return errs.InternalServerError()
+		}
+		err = s.store.SetTeamJoinToken(c.Context(), team.ID, token)
+		if err != nil {
+			return errs.InternalServerError()
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{"token": token})
+	}
+}
+
+// Creates teams from a CSV with team name and GitHub username columns, one row per student.
+// Responds with what happened to each row.
+func (s *AssignmentService) importTeams() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		assignment, err := s.getAssignmentInClassroom(c, models.Professor)
+		if err != nil {
+			return err
+		}
+		if !assignment.GroupAssignment {
+			return errs.BadRequest(errors.New("assignment is not a group assignment"))
+		}
+
+		_, _, user, err := middleware.GetClientAndUser(c, s.store, s.userCfg)
+		if err != nil {
+			return errs.AuthenticationError()
+		}
+
+		data := c.Body()
+		if fileHeader, err := c.FormFile("teams"); err == nil {
+			file, err := fileHeader.Open()
+			if err != nil {
+				return errs.BadRequest(err)
+			}
+			defer file.Close()
+			data, err = io.ReadAll(file)
+			if err != nil {
+				return errs.BadRequest(err)
+			}
+		}
+
+		rows, err := parseTeamCSV(data)
+		if err != nil {
+			return errs.BadRequest(err)
+		}
+
+		settings, err := s.getTeamSizes(c.Context(), int64(assignment.ID))
+		if err != nil {
+			return errs.InternalServerError()
+		}
+		students, err := s.store.GetUsersInClassroom(c.Context(), assignment.ClassroomID)
+		if err != nil {
+			return errs.InternalServerError()
+		}
+		studentsByLogin := make(map[string]models.ClassroomUser)
+		for _, student := range students {
+			if student.Role == models.Student {
+				studentsByLogin[strings.ToLower(student.GithubUsername)] = student
+			}
+		}
+		teams, err := s.store.ListTeams(c.Context(), int64(assignment.ID))
+		if err != nil {
+			return errs.InternalServerError()
+		}
+		teamsByName := make(map[string]models.Team)
+		for _, team := range teams {
+			teamsByName[strings.ToLower(team.Name)] = team
+		}
+
+		results := []models.TeamImportResult{}
+		for _, row := range rows {
+			result := s.importTeamRow(c.Context(), assignment, settings, row, studentsByLogin, teamsByName, *user.ID)
+			results = append(results, result)
+		}
+
+		summary := make(map[models.TeamImportStatus]int)
+		for _, result := range results {
+			summary[result.Status]++
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"summary": summary,
+			"results": results,
+		})
+	}
+}
+
+// Helper function for putting the student of one row of a team CSV on their team, creating the team if needed
+func (s *AssignmentService) importTeamRow(ctx context.Context, assignment models.AssignmentOutline, settings models.GroupSettings, row models.TeamImportResult,
+	studentsByLogin map[string]models.ClassroomUser, teamsByName map[string]models.Team, createdBy int64) models.TeamImportResult {
+	if row.Status == models.TeamImportInvalid {
+		return row
+	}
+
+	student, ok := studentsByLogin[strings.ToLower(row.GithubUsername)]
+	if !ok {
+		row.Status = models.TeamImportUnknownStudent
+		row.Message = "not a student in the classroom"
+		return row
+	}
+
+	current, err := s.store.GetTeamOfUser(ctx, int64(assignment.ID), *student.ID)
+	if err == nil {
+		if strings.EqualFold(current.Name, row.TeamName) {
+			row.Status = models.TeamImportAlreadyOnTeam
+		} else {
+			row.Status = models.TeamImportConflict
+			row.Message = fmt.Sprintf("already on team %s", current.Name)
+		}
+		return row
+	}
+
+	team, ok := teamsByName[strings.ToLower(row.TeamName)]
+	if !ok {
+		team, err = s.createTeamWithToken(ctx, assignment, row.TeamName, createdBy, false)
+		if err != nil {
+			row.Status = models.TeamImportFailed
+			row.Message = "could not create the team"
+			return row
+		}
+		teamsByName[strings.ToLower(row.TeamName)] = team
+	}
+
+	err = s.store.AddTeamMember(ctx, team.ID, *student.ID, settings.MaxTeamSize)
+	if errors.Is(err, pgx.ErrNoRows) {
+		row.Status = models.TeamImportTeamFull
+		row.Message = fmt.Sprintf("teams have at most %d members", settings.MaxTeamSize)
+		return row
+	}
+	if err != nil {
+		fmt.Println("Error adding imported team member:", err)
+		row.Status = models.TeamImportFailed
+		row.Message = "could not add the student to the team"
+		return row
+	}
+
+	err = s.grantLateJoinerAccess(ctx, team, student.GithubUsername)
+	if err != nil {
+		fmt.Println("Error granting team member access to fork:", err)
+	}
+
+	row.Status = models.TeamImportAdded
+	return row
+}
+
+// Reads the rows of a team CSV, which needs "team" and "github_username" columns
+func parseTeamCSV(data []byte) ([]models.TeamImportResult, error) {
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid team CSV: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, errors.New("team CSV is empty")
+	}
+
+	teamColumn, loginColumn := -1, -1
+	for i, name := range records[0] {
+		switch strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), " ", "_") {
+		case "team", "team_name":
+			teamColumn = i
+		case "github_username", "github", "github_login":
+			loginColumn = i
+		}
+	}
+	if teamColumn < 0 || loginColumn < 0 {
+		return nil, errors.New("team CSV needs team and github_username columns")
+	}
+
+	var rows []models.TeamImportResult
+	for i, record := range records[1:] {
+		row := models.TeamImportResult{Row: i + 2}
+		if teamColumn < len(record) {
+			row.TeamName = strings.TrimSpace(record[teamColumn])
+		}
+		if loginColumn < len(record) {
+			row.GithubUsername = strings.TrimPrefix(strings.TrimSpace(record[loginColumn]), "@")
+		}
+		if row.TeamName == "" && row.GithubUsername == "" {
+			continue
+		}
+		if row.TeamName == "" || row.GithubUsername == "" {
+			row.Status = models.TeamImportInvalid
+			row.Message = "missing team name or GitHub username"
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
@@ -0,0 +1,225 @@
+package works
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/CamPlume1/khoury-classroom/internal/errs"
+	"github.com/CamPlume1/khoury-classroom/internal/handlers/common"
+	"github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/CamPlume1/khoury-classroom/internal/storage"
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+)
+
+// Helper function for getting a feedback comment on a work that hasn't been deleted
+func (s *WorkService) getFeedbackOnWork(c *fiber.Ctx, work *models.PaginatedStudentWorkWithContributors, feedbackCommentID *int) (models.FeedbackComment, error) {
+	if feedbackCommentID == nil {
+		return models.FeedbackComment{}, errs.BadRequest(errors.New("feedback_comment_id is required to edit or delete feedback"))
+	}
+
+	feedback, err := s.store.GetFeedbackComment(c.Context(), int64(*feedbackCommentID))
+	if err != nil || feedback.StudentWorkID != work.ID || feedback.DeletedAt != nil {
+		return models.FeedbackComment{}, errs.NotFound("feedback comment", "id", *feedbackCommentID)
+	}
+	return feedback, nil
+}
+
+// Helper function for checking that feedback given with a rubric item uses an item of the work's assignment's rubric
+func (s *WorkService) requireAssignmentRubricItem(c *fiber.Ctx, work *models.PaginatedStudentWorkWithContributors, rubricItemID *int) error {
+	if rubricItemID == nil {
+		return nil
+	}
+
+	assignment, err := s.store.GetAssignmentByID(c.Context(), int64(work.AssignmentOutlineID))
+	if err != nil {
+		return errs.InternalServerError()
+	}
+	item, err := s.store.GetRubricItem(c.Context(), int64(*rubricItemID))
+	if err != nil || assignment.RubricID == nil || item.RubricID != *assignment.RubricID {
+		return errs.BadRequest(fmt.Errorf("rubric item %d is not part of the assignment's rubric", *rubricItemID))
+	}
+	return nil
+}
+
+// Edits a feedback comment, updating its review comment on the student's PR if it was already published
+func (s *WorkService) editFeedbackComment(c *fiber.Ctx, work *models.PaginatedStudentWorkWithContributors, taUserID int64, edit models.PRReviewCommentResponse) (models.FeedbackComment, error) {
+	feedback, err := s.getFeedbackOnWork(c, work, edit.FeedbackCommentID)
+	if err != nil {
+		return models.FeedbackComment{}, err
+	}
+
+	if err := s.requireAssignmentRubricItem(c, work, edit.RubricItemID); err != nil {
+		return models.FeedbackComment{}, err
+	}
+
+	// GitHub can't move a review comment once it's posted
+	if feedback.PublishedAt != nil && !feedback.ReviewComment().SameLocation(edit.PRReviewComment) {
+		return models.FeedbackComment{}, errs.BadRequest(errors.New("published feedback can't be moved, delete it and leave a new comment instead"))
+	}
+
+	// the edit is only committed once it's on GitHub, so a failure there leaves the feedback as it was
+	before := feedback
+	err = s.store.WithTx(c.Context(), func(store storage.Storage) error {
+		feedback, err = store.EditFeedbackComment(c.Context(), int64(feedback.ID), taUserID, edit)
+		if err != nil {
+			return err
+		}
+		if err := auditFeedbackComment(c, store, work, taUserID, models.GradingAuditFeedbackEdited, before, &feedback); err != nil {
+			return err
+		}
+
+		if feedback.PublishedAt != nil && feedback.GitHubCommentID != nil {
+			body := common.FormatFeedbackBody(feedback.PointValue, feedback.Explanation)
+			_, err := s.appClient.EditPRComment(c.Context(), work.OrgName, work.RepoName, *feedback.GitHubCommentID, body)
+			if err != nil {
+				fmt.Println("Error editing feedback on GitHub:", err)
+				return errs.GithubAPIError(err)
+			}
+		}
+		return nil
+	})
+	var apiErr errs.APIError
+	if errors.As(err, &apiErr) {
+		return models.FeedbackComment{}, apiErr
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.FeedbackComment{}, errs.NotFound("feedback comment", "id", *edit.FeedbackCommentID)
+	}
+	if err != nil {
+		fmt.Println("Error editing feedback comment:", err)
+		return models.FeedbackComment{}, errs.InternalServerError()
+	}
+
+	return feedback, nil
+}
+
+// Deletes a feedback comment, removing its review comment from the student's PR if it was already published
+func (s *WorkService) deleteFeedbackComment(c *fiber.Ctx, work *models.PaginatedStudentWorkWithContributors, taUserID int64, feedbackCommentID *int) error {
+	feedback, err := s.getFeedbackOnWork(c, work, feedbackCommentID)
+	if err != nil {
+		return err
+	}
+
+	// remove it from GitHub first, so a failure there leaves the feedback to be deleted again
+	if feedback.PublishedAt != nil && feedback.GitHubCommentID != nil {
+		err = s.appClient.DeletePRComment(c.Context(), work.OrgName, work.RepoName, *feedback.GitHubCommentID)
+		if err != nil {
+			fmt.Println("Error deleting feedback on GitHub:", err)
+			return errs.GithubAPIError(err)
+		}
+	}
+
+	err = s.store.WithTx(c.Context(), func(store storage.Storage) error {
+		if err := store.DeleteFeedbackComment(c.Context(), int64(feedback.ID), taUserID); err != nil {
+			return err
+		}
+		return auditFeedbackComment(c, store, work, taUserID, models.GradingAuditFeedbackDeleted, feedback, nil)
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return errs.NotFound("feedback comment", "id", *feedbackCommentID)
+	}
+	if err != nil {
+		return errs.InternalServerError()
+	}
+	return nil
+}
+
+// Records an edit or deletion of a feedback comment in the grading audit log
+func auditFeedbackComment(c *fiber.Ctx, store storage.Storage, work *models.PaginatedStudentWorkWithContributors, taUserID int64, action models.GradingAuditAction, before models.FeedbackComment, after *models.FeedbackComment) error {
+	entry := common.WorkAuditEntry(action, &taUserID, work.ClassroomID, work.AssignmentOutlineID, work.ID)
+	subjectID := int64(before.ID)
+	entry.SubjectID = &subjectID
+	return common.RecordGradingAudit(c.Context(), store, entry, before, after)
+}
+
+// Helper function for getting the work and feedback comment in the route, and the TA changing it
+func (s *WorkService) getFeedbackRoute(c *fiber.Ctx) (*models.PaginatedStudentWorkWithContributors, int, int64, error) {
+	work, err := s.getWork(c)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	feedbackCommentID, err := strconv.Atoi(c.Params("feedback_id"))
+	if err != nil {
+		return nil, 0, 0, errs.BadRequest(err)
+	}
+
+	taUser, err := s.RequireAtLeastRole(c, int64(work.ClassroomID), models.TA)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	return work, feedbackCommentID, *taUser.ID, nil
+}
+
+// Edits a feedback comment on a student work, moving it onto another rubric item or changing its ad-hoc points and body.
+func (s *WorkService) updateFeedbackComment() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		work, feedbackCommentID, taUserID, err := s.getFeedbackRoute(c)
+		if err != nil {
+			return err
+		}
+
+		var edit models.PRReviewCommentResponse
+		if err := c.BodyParser(&edit); err != nil {
+			return errs.InvalidRequestBody(edit)
+		}
+		if err := edit.ValidateLocation(); err != nil {
+			return errs.BadRequest(err)
+		}
+		edit.Action = models.PRReviewCommentActionEdit
+		edit.FeedbackCommentID = &feedbackCommentID
+
+		feedback, err := s.editFeedbackComment(c, work, taUserID, edit)
+		if err != nil {
+			return err
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"feedback_comment": feedback,
+		})
+	}
+}
+
+// Deletes a feedback comment on a student work.
+func (s *WorkService) removeFeedbackComment() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		work, feedbackCommentID, taUserID, err := s.getFeedbackRoute(c)
+		if err != nil {
+			return err
+		}
+
+		err = s.deleteFeedbackComment(c, work, taUserID, &feedbackCommentID)
+		if err != nil {
+			return err
+		}
+
+		return c.SendStatus(http.StatusOK)
+	}
+}
+
+// Returns what a feedback comment was before each time it was edited or deleted.
+func (s *WorkService) getFeedbackCommentHistory() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		work, feedbackCommentID, _, err := s.getFeedbackRoute(c)
+		if err != nil {
+			return err
+		}
+
+		feedback, err := s.store.GetFeedbackComment(c.Context(), int64(feedbackCommentID))
+		if err != nil || feedback.StudentWorkID != work.ID {
+			return errs.NotFound("feedback comment", "id", feedbackCommentID)
+		}
+
+		history, err := s.store.GetFeedbackCommentHistory(c.Context(), int64(feedbackCommentID))
+		if err != nil {
+			return errs.InternalServerError()
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"feedback_comment": feedback,
+			"history":          history,
+		})
+	}
+}
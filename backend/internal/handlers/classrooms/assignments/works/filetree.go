@@ -5,9 +5,11 @@ import (
 	"net/http"
 
 	"github.com/CamPlume1/khoury-classroom/internal/errs"
+	"github.com/CamPlume1/khoury-classroom/internal/handlers/common"
 	"github.com/gofiber/fiber/v2"
 )
 
+// Returns the file tree of a student work at its submission snapshot, or the head of its submission branch if it has none.
 func (s *WorkService) GetFileTree() fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		work, err := s.getWork(c)
@@ -15,17 +17,31 @@ func (s *WorkService) GetFileTree() fiber.Handler {
 			return err
 		}
 
-		tree, err := s.appClient.GetFileTree(work.OrgName, work.RepoName)
+		commitSHA, err := common.GetSnapshotSHA(c.Context(), s.store, work.ID)
+		if err != nil {
+			return errs.InternalServerError()
+		}
+		if commitSHA == "" {
+			commitSHA, err = common.SubmissionBranchHead(c.Context(), s.appClient, s.store, work.StudentWork)
+			if err != nil {
+				return errs.GithubAPIError(err)
+			}
+		}
+
+		tree, err := s.appClient.GetFileTree(work.OrgName, work.RepoName, commitSHA)
 		if err != nil {
 			return errs.GithubAPIError(err)
 		}
 
 		return c.Status(http.StatusOK).JSON(fiber.Map{
-			"tree": tree,
+			"tree":       tree,
+			"commit_sha": commitSHA,
 		})
 	}
 }
 
+// Returns the content of a file in a student work. Blobs are addressed by their content, so the blobs
+// of a snapshot's tree stay the same whatever the student pushes after it.
 func (s *WorkService) GetFileBlob() fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		work, err := s.getWork(c)
@@ -0,0 +1,285 @@
+package works
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/CamPlume1/khoury-classroom/internal/errs"
+	"github.com/CamPlume1/khoury-classroom/internal/middleware"
+	"github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Pairs each work with a grader, dealing them out in turn or to whoever has the least left to grade
+func distributeWorks(strategy models.GradingStrategy, workIDs []int64, taUserIDs []int64, loads map[int64]int) []models.GraderPairing {
+	pairings := make([]models.GraderPairing, 0, len(workIDs))
+	for i, workID := range workIDs {
+		grader := taUserIDs[i%len(taUserIDs)]
+		if strategy == models.GradingStrategyBalanced {
+			grader = taUserIDs[0]
+			for _, taUserID := range taUserIDs {
+				if loads[taUserID] < loads[grader] {
+					grader = taUserID
+				}
+			}
+		}
+		loads[grader]++
+		pairings = append(pairings, models.GraderPairing{StudentWorkID: workID, TAUserID: grader})
+	}
+	return pairings
+}
+
+// Requests each grader's review on the feedback pull request of the work they were assigned. The assignments
+// stand even if GitHub fails, so failures are only logged.
+func (s *WorkService) requestGraderReviews(c *fiber.Ctx, pairings []models.GraderPairing, worksByID map[int64]models.StudentWork, graders map[int64]models.ClassroomUser) {
+	for _, pairing := range pairings {
+		work := worksByID[pairing.StudentWorkID]
+		err := s.appClient.RequestPRReviewers(c.Context(), work.OrgName, work.RepoName, 1, []string{graders[pairing.TAUserID].GithubUsername})
+		if err != nil {
+			fmt.Println("Error requesting grader review:", err)
+		}
+	}
+}
+
+// Returns the TAs and professors of a classroom, by user ID
+func (s *WorkService) getGraders(c *fiber.Ctx, classroomID int64) (map[int64]models.ClassroomUser, error) {
+	users, err := s.store.GetUsersInClassroom(c.Context(), classroomID)
+	if err != nil {
+		return nil, err
+	}
+
+	graders := make(map[int64]models.ClassroomUser)
+	for _, user := range users {
+		if user.Role.Compare(models.TA) >= 0 && user.ID != nil {
+			graders[*user.ID] = user
+		}
+	}
+	return graders, nil
+}
+
+// Returns who grades each work of an assignment.
+func (s *WorkService) getGradingAssignments() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		assignment, err := s.getAssignmentInClassroom(c, models.TA)
+		if err != nil {
+			return err
+		}
+
+		assignments, err := s.store.ListGradingAssignments(c.Context(), int64(assignment.ID), nil)
+		if err != nil {
+			return errs.InternalServerError()
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"grading_assignments": assignments,
+		})
+	}
+}
+
+// Returns the works of an assignment the current user has been assigned to grade, ungraded ones first.
+func (s *WorkService) getMyGradingQueue() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		assignment, err := s.getAssignmentInClassroom(c, models.TA)
+		if err != nil {
+			return err
+		}
+
+		_, _, user, err := middleware.GetClientAndUser(c, s.store, s.userCfg)
+		if err != nil {
+			return errs.AuthenticationError()
+		}
+
+		assignments, err := s.store.ListGradingAssignments(c.Context(), int64(assignment.ID), user.ID)
+		if err != nil {
+			return errs.InternalServerError()
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"grading_assignments": assignments,
+		})
+	}
+}
+
+// Distributes the works of an assignment among graders, manually, round-robin or balanced by their existing load.
+func (s *WorkService) assignGraders() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		classroomID, err := strconv.Atoi(c.Params("classroom_id"))
+		if err != nil {
+			return errs.BadRequest(err)
+		}
+		assignmentID, err := strconv.Atoi(c.Params("assignment_id"))
+		if err != nil {
+			return errs.BadRequest(err)
+		}
+
+		_, err = s.RequireAtLeastRole(c, int64(classroomID), models.Professor)
+		if err != nil {
+			return err
+		}
+
+		_, _, user, err := middleware.GetClientAndUser(c, s.store, s.userCfg)
+		if err != nil {
+			return errs.AuthenticationError()
+		}
+
+		var requestBody models.AssignGradersRequest
+		if err := c.BodyParser(&requestBody); err != nil {
+			return errs.InvalidRequestBody(requestBody)
+		}
+
+		graders, err := s.getGraders(c, int64(classroomID))
+		if err != nil {
+			return errs.InternalServerError()
+		}
+
+		works, err := s.store.GetWorks(c.Context(), classroomID, assignmentID)
+		if err != nil {
+			return errs.InternalServerError()
+		}
+		worksByID := make(map[int64]models.StudentWork)
+		for _, work := range works {
+			worksByID[int64(work.ID)] = work.StudentWork
+		}
+
+		var pairings []models.GraderPairing
+		switch requestBody.Strategy {
+		case models.GradingStrategyManual:
+			pairings = requestBody.Pairings
+		case models.GradingStrategyRoundRobin, models.GradingStrategyBalanced:
+			taUserIDs := requestBody.TAUserIDs
+			if len(taUserIDs) == 0 {
+				// default to the classroom's TAs, professors can still be chosen explicitly
+				for id, grader := range graders {
+					if grader.Role == models.TA {
+						taUserIDs = append(taUserIDs, id)
+					}
+				}
+				slices.Sort(taUserIDs)
+			}
+			if len(taUserIDs) == 0 {
+				return errs.BadRequest(errors.New("there are no TAs to assign works to"))
+			}
+
+			workIDs := requestBody.StudentWorkIDs
+			if len(workIDs) == 0 {
+				workIDs, err = s.getUnassignedWorkIDs(c, int64(assignmentID), worksByID)
+				if err != nil {
+					return errs.InternalServerError()
+				}
+			}
+
+			loads, err := s.store.GetGraderLoads(c.Context(), int64(classroomID))
+			if err != nil {
+				return errs.InternalServerError()
+			}
+			pairings = distributeWorks(requestBody.Strategy, workIDs, taUserIDs, loads)
+		default:
+			return errs.BadRequest(errors.New("strategy must be MANUAL, ROUND_ROBIN or BALANCED"))
+		}
+
+		// check everything before assigning anything
+		invalid := make(map[string]string)
+		paired := make(map[int64]bool)
+		for _, pairing := range pairings {
+			if paired[pairing.StudentWorkID] {
+				invalid[strconv.FormatInt(pairing.StudentWorkID, 10)] = "student work is paired with more than one grader"
+			} else if _, ok := worksByID[pairing.StudentWorkID]; !ok {
+				invalid[strconv.FormatInt(pairing.StudentWorkID, 10)] = "student work is not part of this assignment"
+			} else if _, ok := graders[pairing.TAUserID]; !ok {
+				invalid[strconv.FormatInt(pairing.StudentWorkID, 10)] = "grader is not a TA or professor in this classroom"
+			}
+			paired[pairing.StudentWorkID] = true
+		}
+		if len(invalid) > 0 {
+			return errs.InvalidRequestData(invalid)
+		}
+
+		assignments, err := s.store.AssignGraders(c.Context(), pairings, *user.ID)
+		if err != nil {
+			fmt.Println("Error assigning graders:", err)
+			return errs.InternalServerError()
+		}
+		if requestBody.RequestReview {
+			s.requestGraderReviews(c, pairings, worksByID, graders)
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"grading_assignments": assignments,
+		})
+	}
+}
+
+// Returns the works of an assignment that have no grader and haven't been graded, in the order they were accepted
+func (s *WorkService) getUnassignedWorkIDs(c *fiber.Ctx, assignmentID int64, worksByID map[int64]models.StudentWork) ([]int64, error) {
+	assignments, err := s.store.ListGradingAssignments(c.Context(), assignmentID, nil)
+	if err != nil {
+		return nil, err
+	}
+	assigned := make(map[int64]bool)
+	for _, assignment := range assignments {
+		assigned[assignment.StudentWorkID] = true
+	}
+
+	workIDs := []int64{}
+	for id, work := range worksByID {
+		if assigned[id] || work.WorkState == models.WorkStateGradingCompleted || work.WorkState == models.WorkStateGradePublished {
+			continue
+		}
+		workIDs = append(workIDs, id)
+	}
+	slices.Sort(workIDs)
+
+	return workIDs, nil
+}
+
+// Reassigns a student work to a different grader.
+func (s *WorkService) reassignGrader() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		work, err := s.getWork(c)
+		if err != nil {
+			return err
+		}
+
+		_, err = s.RequireAtLeastRole(c, int64(work.ClassroomID), models.Professor)
+		if err != nil {
+			return err
+		}
+
+		_, _, user, err := middleware.GetClientAndUser(c, s.store, s.userCfg)
+		if err != nil {
+			return errs.AuthenticationError()
+		}
+
+		var requestBody models.ReassignGraderRequest
+		if err := c.BodyParser(&requestBody); err != nil {
+			return errs.InvalidRequestBody(requestBody)
+		}
+
+		graders, err := s.getGraders(c, int64(work.ClassroomID))
+		if err != nil {
+			return errs.InternalServerError()
+		}
+		grader, ok := graders[requestBody.TAUserID]
+		if !ok {
+			return errs.BadRequest(errors.New("grader is not a TA or professor in this classroom"))
+		}
+
+		pairings := []models.GraderPairing{{StudentWorkID: int64(work.ID), TAUserID: *grader.ID}}
+		assignments, err := s.store.AssignGraders(c.Context(), pairings, *user.ID)
+		if err != nil {
+			fmt.Println("Error assigning grader:", err)
+			return errs.InternalServerError()
+		}
+		if requestBody.RequestReview {
+			worksByID := map[int64]models.StudentWork{int64(work.ID): work.StudentWork}
+			s.requestGraderReviews(c, pairings, worksByID, graders)
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"grading_assignment": assignments[0],
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package works
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/CamPlume1/khoury-classroom/internal/errs"
+	"github.com/CamPlume1/khoury-classroom/internal/middleware"
+	"github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+)
+
+// Helper function for getting the late policy of a work's assignment, nil if it has none
+func (s *WorkService) getLatePolicy(c *fiber.Ctx, work *models.PaginatedStudentWorkWithContributors) (*models.LatePolicy, error) {
+	policy, err := s.store.GetLatePolicy(c.Context(), int64(work.AssignmentOutlineID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// Helper function for applying a late policy to a work, as submitted or as if submitted now
+func (s *WorkService) evaluateLateness(c *fiber.Ctx, work *models.PaginatedStudentWorkWithContributors, policy *models.LatePolicy, lateTokens int) (models.LateOutcome, error) {
+	dueDate, err := s.store.GetDeadlineForRepo(c.Context(), work.RepoName)
+	if err != nil {
+		return models.LateOutcome{}, err
+	}
+
+	submittedAt := time.Now()
+	if work.SubmittedAt != nil {
+		submittedAt = *work.SubmittedAt
+	}
+	return models.EvaluateLateness(policy, dueDate, lateTokens, submittedAt), nil
+}
+
+// Returns how the late policy applies to a student work: to its submission, or to submitting now if it hasn't been submitted.
+func (s *WorkService) getWorkLateness() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		work, err := s.getWork(c)
+		if err != nil {
+			return err
+		}
+
+		_, githubUser, _, err := middleware.GetClientAndUser(c, s.store, s.userCfg)
+		if err != nil {
+			return errs.AuthenticationError()
+		}
+		if !isContributor(work, githubUser.Login) {
+			_, err = s.RequireAtLeastRole(c, int64(work.ClassroomID), models.TA)
+			if err != nil {
+				return err
+			}
+		}
+
+		policy, err := s.getLatePolicy(c, work)
+		if err != nil {
+			return errs.InternalServerError()
+		}
+		lateTokens, err := s.store.GetLateTokensUsedOnWork(c.Context(), int64(work.ID))
+		if err != nil {
+			return errs.InternalServerError()
+		}
+
+		outcome, err := s.evaluateLateness(c, work, policy, lateTokens)
+		if err != nil {
+			return errs.InternalServerError()
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"late_policy":  policy,
+			"submitted_at": work.SubmittedAt,
+			"outcome":      outcome,
+		})
+	}
+}
+
+// Spends some of the current student's late tokens on their work, pushing its deadline back a day per token.
+func (s *WorkService) spendLateTokens() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		work, err := s.getWork(c)
+		if err != nil {
+			return err
+		}
+
+		_, githubUser, user, err := middleware.GetClientAndUser(c, s.store, s.userCfg)
+		if err != nil {
+			return errs.AuthenticationError()
+		}
+		if !isContributor(work, githubUser.Login) {
+			return errs.InsufficientPermissionsError()
+		}
+
+		var requestBody models.SpendLateTokensRequest
+		if err := c.BodyParser(&requestBody); err != nil || requestBody.Tokens <= 0 {
+			return errs.InvalidRequestBody(requestBody)
+		}
+
+		policy, err := s.getLatePolicy(c, work)
+		if err != nil {
+			return errs.InternalServerError()
+		}
+		if policy == nil || !policy.AllowLateTokens {
+			return errs.BadRequest(errors.New("late tokens cannot be used on this assignment"))
+		}
+
+		err = s.store.SpendLateTokens(c.Context(), int64(work.ClassroomID), int64(work.ID), *user.ID, requestBody.Tokens)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return errs.BadRequest(errors.New("not enough late tokens left"))
+		}
+		if err != nil {
+			fmt.Println("Error spending late tokens:", err)
+			return errs.InternalServerError()
+		}
+
+		lateTokens, err := s.store.GetLateTokensUsedOnWork(c.Context(), int64(work.ID))
+		if err != nil {
+			return errs.InternalServerError()
+		}
+
+		outcome, err := s.evaluateLateness(c, work, policy, lateTokens)
+		if err != nil {
+			return errs.InternalServerError()
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"late_tokens_used": lateTokens,
+			"outcome":          outcome,
+		})
+	}
+}
@@ -0,0 +1,256 @@
+package works
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/CamPlume1/khoury-classroom/internal/errs"
+	"github.com/CamPlume1/khoury-classroom/internal/handlers/common"
+	"github.com/CamPlume1/khoury-classroom/internal/middleware"
+	"github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/CamPlume1/khoury-classroom/internal/storage"
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+)
+
+// Checks whether a GitHub user is one of the contributors of a student work
+func isContributor(work *models.PaginatedStudentWorkWithContributors, githubUsername string) bool {
+	for _, contributor := range work.Contributors {
+		if strings.EqualFold(contributor.GithubUsername, githubUsername) {
+			return true
+		}
+	}
+	return false
+}
+
+// Opens a regrade request against a feedback comment on the requesting student's work.
+func (s *WorkService) requestRegrade() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		work, err := s.getWork(c)
+		if err != nil {
+			return err
+		}
+		feedbackCommentID, err := strconv.ParseInt(c.Params("feedback_id"), 10, 64)
+		if err != nil {
+			return errs.BadRequest(err)
+		}
+
+		client, githubUser, user, err := middleware.GetClientAndUser(c, s.store, s.userCfg)
+		if err != nil {
+			return errs.AuthenticationError()
+		}
+		if !isContributor(work, githubUser.Login) {
+			return errs.InsufficientPermissionsError()
+		}
+
+		var requestBody models.RegradeRequestBody
+		if err := c.BodyParser(&requestBody); err != nil || strings.TrimSpace(requestBody.StudentComment) == "" {
+			return errs.InvalidRequestBody(requestBody)
+		}
+
+		feedback, err := s.store.GetFeedbackComment(c.Context(), feedbackCommentID)
+		// draft feedback hasn't been released to the student yet
+		if err != nil || feedback.StudentWorkID != work.ID || feedback.PublishedAt == nil || feedback.DeletedAt != nil {
+			return errs.NotFound("feedback comment", "id", c.Params("feedback_id"))
+		}
+
+		// Open the request before mirroring it, so the webhook for the mirrored reply finds it already open
+		regrade, err := s.store.CreateRegradeRequest(c.Context(), feedbackCommentID, *user.ID, requestBody.StudentComment, nil)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return errs.Conflict("open regrade request", "feedback_comment_id", c.Params("feedback_id"))
+		}
+		if err != nil {
+			fmt.Println("Error creating regrade request:", err)
+			return errs.InternalServerError()
+		}
+
+		// Mirror the request on GitHub so the discussion lives next to the feedback
+		if feedback.GitHubCommentID != nil {
+			reply, err := client.ReplyToPRComment(c.Context(), work.OrgName, work.RepoName, 1, *feedback.GitHubCommentID, requestBody.StudentComment)
+			if err != nil {
+				fmt.Println("Error replying to feedback comment:", err)
+			} else {
+				id := reply.GetID()
+				regrade.GitHubCommentID = &id
+				if err := s.store.SetRegradeGitHubCommentID(c.Context(), regrade.ID, id); err != nil {
+					fmt.Println("Error linking regrade request to its GitHub comment:", err)
+				}
+			}
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"regrade_request": regrade,
+		})
+	}
+}
+
+// Returns the regrade requests on a student work, to its contributors or the classroom's TAs.
+func (s *WorkService) getRegradesOnWork() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		work, err := s.getWork(c)
+		if err != nil {
+			return err
+		}
+
+		_, githubUser, _, err := middleware.GetClientAndUser(c, s.store, s.userCfg)
+		if err != nil {
+			return errs.AuthenticationError()
+		}
+		if !isContributor(work, githubUser.Login) {
+			_, err = s.RequireAtLeastRole(c, int64(work.ClassroomID), models.TA)
+			if err != nil {
+				return err
+			}
+		}
+
+		regrades, err := s.store.ListRegradeRequestsOnWork(c.Context(), int64(work.ID))
+		if err != nil {
+			return errs.InternalServerError()
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"regrade_requests": regrades,
+		})
+	}
+}
+
+// Returns the regrade requests on the works of an assignment, optionally filtered by state.
+func (s *WorkService) getRegrades() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		assignment, err := s.getAssignmentInClassroom(c, models.TA)
+		if err != nil {
+			return err
+		}
+
+		var state *models.RegradeState
+		if c.Query("state") != "" {
+			queryState := models.RegradeState(c.Query("state"))
+			switch queryState {
+			case models.RegradeStateNoRegradeRequested, models.RegradeStateRequested, models.RegradeStateFinalized:
+				state = &queryState
+			default:
+				return errs.BadRequest(fmt.Errorf("unknown regrade state %q", queryState))
+			}
+		}
+
+		regrades, err := s.store.ListRegradeRequests(c.Context(), int64(assignment.ID), state)
+		if err != nil {
+			return errs.InternalServerError()
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"regrade_requests": regrades,
+		})
+	}
+}
+
+// Accepts, adjusts or rejects a regrade request, finalizing it.
+func (s *WorkService) resolveRegrade() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		classroomID, err := strconv.Atoi(c.Params("classroom_id"))
+		if err != nil {
+			return errs.BadRequest(err)
+		}
+		assignmentID, err := strconv.Atoi(c.Params("assignment_id"))
+		if err != nil {
+			return errs.BadRequest(err)
+		}
+		regradeID, err := strconv.ParseInt(c.Params("regrade_id"), 10, 64)
+		if err != nil {
+			return errs.BadRequest(err)
+		}
+
+		_, err = s.RequireAtLeastRole(c, int64(classroomID), models.TA)
+		if err != nil {
+			return err
+		}
+
+		client, _, taUser, err := middleware.GetClientAndUser(c, s.store, s.userCfg)
+		if err != nil {
+			return errs.AuthenticationError()
+		}
+
+		var requestBody models.RegradeResolutionBody
+		if err := c.BodyParser(&requestBody); err != nil {
+			return errs.InvalidRequestBody(requestBody)
+		}
+
+		regrade, err := s.store.GetRegradeRequest(c.Context(), regradeID)
+		if err != nil {
+			return errs.NotFound("regrade request", "id", c.Params("regrade_id"))
+		}
+		work, err := s.store.GetWork(c.Context(), classroomID, assignmentID, int(regrade.StudentWorkID))
+		if err != nil {
+			return errs.NotFound("regrade request", "id", c.Params("regrade_id"))
+		}
+
+		var adjustedPointValue *int
+		switch requestBody.Outcome {
+		case models.RegradeOutcomeAccepted:
+			points := max(regrade.OriginalPointValue, 0)
+			adjustedPointValue = &points
+		case models.RegradeOutcomeAdjusted:
+			if requestBody.Points == nil {
+				return errs.BadRequest(errors.New("points are required to adjust a regrade"))
+			}
+			adjustedPointValue = requestBody.Points
+		case models.RegradeOutcomeRejected:
+		default:
+			return errs.BadRequest(errors.New("outcome must be ACCEPTED, ADJUSTED or REJECTED"))
+		}
+
+		requested := regrade
+		err = s.store.WithTx(c.Context(), func(store storage.Storage) error {
+			regrade, err = store.ResolveRegradeRequest(c.Context(), regradeID, *taUser.ID, requestBody.Outcome, adjustedPointValue, requestBody.TAComment)
+			if err != nil {
+				return err
+			}
+
+			entry := common.WorkAuditEntry(models.GradingAuditRegradeResolved, taUser.ID, work.ClassroomID, work.AssignmentOutlineID, work.ID)
+			entry.SubjectID = &regrade.ID
+			return common.RecordGradingAudit(c.Context(), store, entry, requested, regrade)
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			return errs.BadRequest(errors.New("regrade request has already been finalized"))
+		}
+		if err != nil {
+			fmt.Println("Error resolving regrade request:", err)
+			return errs.InternalServerError()
+		}
+
+		// Let the student know in the thread they opened the regrade from. GitHub only takes replies to the top-level
+		// comment of a thread, which is the feedback rather than the student's reply.
+		feedback, err := s.store.GetFeedbackComment(c.Context(), regrade.FeedbackCommentID)
+		if err != nil {
+			fmt.Println("Error getting regraded feedback comment:", err)
+		} else if feedback.GitHubCommentID != nil {
+			_, err = client.ReplyToPRComment(c.Context(), work.OrgName, work.RepoName, 1, *feedback.GitHubCommentID, formatRegradeReply(regrade))
+			if err != nil {
+				fmt.Println("Error replying to regrade request:", err)
+			}
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"regrade_request": regrade,
+		})
+	}
+}
+
+func formatRegradeReply(regrade models.RegradeRequest) string {
+	var reply string
+	switch *regrade.Outcome {
+	case models.RegradeOutcomeAccepted:
+		reply = "Regrade accepted, the deduction has been removed."
+	case models.RegradeOutcomeAdjusted:
+		reply = fmt.Sprintf("Regrade adjusted: this comment is now worth %d points (was %d).", *regrade.AdjustedPointValue, regrade.OriginalPointValue)
+	case models.RegradeOutcomeRejected:
+		reply = "Regrade rejected, the original points stand."
+	}
+	if regrade.TAComment != nil && *regrade.TAComment != "" {
+		reply += "\n\n" + *regrade.TAComment
+	}
+	return reply
+}
@@ -16,9 +16,63 @@ func WorkRoutes(router fiber.Router, service *WorkService) fiber.Router {
 	// Get the details of a student work
 	workRouter.Get("/work/:work_id", service.getWorkByID())
 
+	// Get the autograder results of a student work
+	workRouter.Get("/work/:work_id/autograder-results", service.getAutograderResults())
+
+	// Get who grades each student work of an assignment
+	workRouter.Get("/graders", service.getGradingAssignments())
+
+	// Distribute the student works of an assignment among graders
+	workRouter.Post("/graders", service.assignGraders())
+
+	// Get the student works the current user has been assigned to grade
+	workRouter.Get("/graders/mine", service.getMyGradingQueue())
+
+	// Reassign a student work to a different grader
+	workRouter.Put("/work/:work_id/grader", service.reassignGrader())
+
+	// Get how the late policy applies to a student work
+	workRouter.Get("/work/:work_id/lateness", service.getWorkLateness())
+
+	// Spend late tokens on a student work
+	workRouter.Post("/work/:work_id/late-tokens", service.spendLateTokens())
+
+	// Get the grading audit log of a student work
+	workRouter.Get("/work/:work_id/audit-log", service.getWorkAuditLog())
+
+	// Get the regrade requests on the works of an assignment
+	workRouter.Get("/regrades", service.getRegrades())
+
+	// Accept, adjust or reject a regrade request
+	workRouter.Post("/regrades/:regrade_id/resolve", service.resolveRegrade())
+
+	// Get the regrade requests on a student work
+	workRouter.Get("/work/:work_id/regrades", service.getRegradesOnWork())
+
+	// Edit a feedback comment on a student work
+	workRouter.Put("/work/:work_id/feedback/:feedback_id", service.updateFeedbackComment())
+
+	// Delete a feedback comment on a student work
+	workRouter.Delete("/work/:work_id/feedback/:feedback_id", service.removeFeedbackComment())
+
+	// Get the edit history of a feedback comment
+	workRouter.Get("/work/:work_id/feedback/:feedback_id/history", service.getFeedbackCommentHistory())
+
+	// Request a regrade of a feedback comment on a student work
+	workRouter.Post("/work/:work_id/feedback/:feedback_id/regrade", service.requestRegrade())
+
 	// Grade a student work (latest submitted PR)
 	workRouter.Post("/work/:work_id/grade", service.gradeWorkByID())
 
+	// Get the submission snapshot a student work is graded against and the commits pushed since
+	workRouter.Get("/work/:work_id/snapshot", service.getSubmissionSnapshot())
+
+	// Pin a commit of a student work for grading
+	workRouter.Post("/work/:work_id/snapshot", service.pinSubmissionSnapshot())
+
+	// Get the pull requests opened into the submission branch of a student work
+	workRouter.Get("/work/:work_id/submission-attempts", service.getSubmissionAttempts())
+
 	// Get the file tree of a student work
 	workRouter.Get("/work/:work_id/tree", service.GetFileTree())
 
@@ -34,5 +88,8 @@ func WorkRoutes(router fiber.Router, service *WorkService) fiber.Router {
 	//Get the number of commits per day in the student work repo
 	workRouter.Get("/work/:work_id/commits-per-day", service.GetCommitsPerDay())
 
+	// Get the number of commits each contributor made in the student work repo
+	workRouter.Get("/work/:work_id/commits-per-contributor", service.GetCommitsPerContributor())
+
 	return workRouter
 }
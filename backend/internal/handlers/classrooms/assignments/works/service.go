@@ -15,7 +15,9 @@ type WorkService struct {
 }
 
 func NewWorkService(store storage.Storage, userCfg *config.GitHubUserClient, appClient github.GitHubAppClient) *WorkService {
-	return &WorkService{store: store, userCfg: userCfg, appClient: appClient}
+	service := &WorkService{store: store, userCfg: userCfg, appClient: appClient}
+	service.RoleChecker = middleware.RoleChecker[WorkService]{Checkable: service}
+	return service
 }
 
 // Getter for store field
@@ -0,0 +1,131 @@
+package works
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/CamPlume1/khoury-classroom/internal/errs"
+	"github.com/CamPlume1/khoury-classroom/internal/handlers/common"
+	"github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/gofiber/fiber/v2"
+	gh "github.com/google/go-github/github"
+)
+
+// Helper function for getting the work in the route, checking the user is a TA in its classroom
+func (s *WorkService) getWorkAsTA(c *fiber.Ctx) (*models.PaginatedStudentWorkWithContributors, models.ClassroomUser, error) {
+	work, err := s.getWork(c)
+	if err != nil {
+		return nil, models.ClassroomUser{}, err
+	}
+
+	user, err := s.RequireAtLeastRole(c, int64(work.ClassroomID), models.TA)
+	if err != nil {
+		return nil, models.ClassroomUser{}, err
+	}
+	return work, user, nil
+}
+
+// Returns the snapshot a student work is graded against, its earlier snapshots, and the commits the student pushed since.
+func (s *WorkService) getSubmissionSnapshot() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		work, _, err := s.getWorkAsTA(c)
+		if err != nil {
+			return err
+		}
+
+		snapshots, err := s.store.ListSubmissionSnapshots(c.Context(), int64(work.ID))
+		if err != nil {
+			return errs.InternalServerError()
+		}
+		if len(snapshots) == 0 {
+			return c.Status(http.StatusOK).JSON(fiber.Map{
+				"snapshot":      nil,
+				"snapshots":     snapshots,
+				"newer_commits": []gh.RepositoryCommit{},
+			})
+		}
+		snapshot := snapshots[len(snapshots)-1]
+
+		head, err := common.SubmissionBranchHead(c.Context(), s.appClient, s.store, work.StudentWork)
+		if err != nil {
+			return errs.GithubAPIError(err)
+		}
+		comparison, err := s.appClient.CompareCommits(c.Context(), work.OrgName, work.RepoName, snapshot.CommitSHA, head)
+		if err != nil {
+			return errs.GithubAPIError(err)
+		}
+
+		newerCommits := comparison.Commits
+		if newerCommits == nil {
+			newerCommits = []gh.RepositoryCommit{}
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"snapshot":      snapshot,
+			"snapshots":     snapshots,
+			"head_sha":      head,
+			"newer_commits": newerCommits,
+		})
+	}
+}
+
+// Returns the pull requests the students opened or updated into the submission branch of a work, oldest first
+func (s *WorkService) getSubmissionAttempts() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		work, _, err := s.getWorkAsTA(c)
+		if err != nil {
+			return err
+		}
+
+		attempts, err := s.store.ListSubmissionAttempts(c.Context(), int64(work.ID))
+		if err != nil {
+			return errs.InternalServerError()
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{"submission_attempts": attempts})
+	}
+}
+
+// Pins a commit on a student work's submission branch for grading, by default its head, e.g. to grade a resubmission.
+// Draft feedback is posted against the new snapshot once grades are published.
+func (s *WorkService) pinSubmissionSnapshot() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		work, user, err := s.getWorkAsTA(c)
+		if err != nil {
+			return err
+		}
+
+		var requestBody models.SnapshotRequest
+		if len(c.Body()) > 0 {
+			if err := c.BodyParser(&requestBody); err != nil {
+				return errs.InvalidRequestBody(requestBody)
+			}
+		}
+
+		head, err := common.SubmissionBranchHead(c.Context(), s.appClient, s.store, work.StudentWork)
+		if err != nil {
+			return errs.GithubAPIError(err)
+		}
+		commitSHA := head
+		if requestBody.CommitSHA != nil && *requestBody.CommitSHA != "" {
+			commitSHA = *requestBody.CommitSHA
+
+			// review comments can only be left on commits of the feedback pull request
+			comparison, err := s.appClient.CompareCommits(c.Context(), work.OrgName, work.RepoName, commitSHA, head)
+			if err != nil || (comparison.GetStatus() != "ahead" && comparison.GetStatus() != "identical") {
+				return errs.BadRequest(errors.New("commit is not on the work's submission branch"))
+			}
+		}
+
+		snapshot, err := common.SnapshotWork(c.Context(), s.appClient, s.store, work.StudentWork, commitSHA, models.SnapshotReasonManual, user.ID)
+		if err != nil {
+			fmt.Println("Error pinning submission snapshot:", err)
+			return errs.InternalServerError()
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"snapshot": snapshot,
+		})
+	}
+}
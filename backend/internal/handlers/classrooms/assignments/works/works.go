@@ -1,16 +1,17 @@
 package works
 
 import (
-	"fmt"
 	"net/http"
+	"slices"
 	"strconv"
 	"time"
 
 	"github.com/CamPlume1/khoury-classroom/internal/errs"
+	"github.com/CamPlume1/khoury-classroom/internal/handlers/common"
 	"github.com/CamPlume1/khoury-classroom/internal/middleware"
 	"github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/CamPlume1/khoury-classroom/internal/storage"
 	"github.com/gofiber/fiber/v2"
-	"github.com/google/go-github/github"
 )
 
 // Helper function for getting a student work by ID
@@ -45,6 +46,56 @@ func (s *WorkService) getWork(c *fiber.Ctx) (*models.PaginatedStudentWorkWithCon
 	return work, nil
 }
 
+// Helper function for hiding what students can't see of a work until its grades are published: draft feedback,
+// and the scores that count it
+func (s *WorkService) hideUnpublishedGrades(c *fiber.Ctx, work *models.PaginatedStudentWorkWithContributors, feedback []models.PRReviewCommentResponse) ([]models.PRReviewCommentResponse, error) {
+	classroomUser, err := s.RequireAtLeastRole(c, int64(work.ClassroomID), models.Student)
+	if err != nil {
+		return nil, err
+	}
+	if classroomUser.Role.Compare(models.TA) >= 0 {
+		return feedback, nil
+	}
+
+	if work.GradesPublishedTimestamp == nil {
+		work.ManualFeedbackScore = nil
+		work.AutoGraderScore = nil
+	}
+
+	publishedFeedback := []models.PRReviewCommentResponse{}
+	for _, comment := range feedback {
+		if comment.PublishedAt != nil {
+			publishedFeedback = append(publishedFeedback, comment)
+		}
+	}
+	return publishedFeedback, nil
+}
+
+// Helper function for getting the assignment in the route, checking it belongs to the classroom in the route
+// and that the current user has at least the given role in it
+func (s *WorkService) getAssignmentInClassroom(c *fiber.Ctx, role models.ClassroomRole) (models.AssignmentOutline, error) {
+	classroomID, err := strconv.ParseInt(c.Params("classroom_id"), 10, 64)
+	if err != nil {
+		return models.AssignmentOutline{}, errs.BadRequest(err)
+	}
+	assignmentID, err := strconv.ParseInt(c.Params("assignment_id"), 10, 64)
+	if err != nil {
+		return models.AssignmentOutline{}, errs.BadRequest(err)
+	}
+
+	_, err = s.RequireAtLeastRole(c, classroomID, role)
+	if err != nil {
+		return models.AssignmentOutline{}, err
+	}
+
+	assignment, err := s.store.GetAssignmentByID(c.Context(), assignmentID)
+	if err != nil || assignment.ClassroomID != classroomID {
+		return models.AssignmentOutline{}, errs.NotFound("assignment", "id", c.Params("assignment_id"))
+	}
+
+	return assignment, nil
+}
+
 // Returns the student works for an assignment.
 func (s *WorkService) getWorksInAssignment() fiber.Handler {
 	return func(c *fiber.Ctx) error {
@@ -174,6 +225,11 @@ func (s *WorkService) getWorkByID() fiber.Handler {
 			return errs.InternalServerError()
 		}
 
+		feedback, err = s.hideUnpublishedGrades(c, work, feedback)
+		if err != nil {
+			return err
+		}
+
 		return c.Status(http.StatusOK).JSON(fiber.Map{
 			"student_work": work,
 			"feedback":     feedback,
@@ -181,40 +237,93 @@ func (s *WorkService) getWorkByID() fiber.Handler {
 	}
 }
 
-const LatexPositivePointPrefix = `$${\huge\color{limegreen}\textbf{[+%d]}}$$ `
-const LatexNegativePointPrefix = `$${\huge\color{WildStrawberry}\textbf{[%d]}}$$ `
+// Returns the autograder results of a student work for each graded commit.
+func (s *WorkService) getAutograderResults() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		work, err := s.getWork(c)
+		if err != nil {
+			return err
+		}
 
-func formatFeedbackForGitHub(comments []models.PRReviewCommentResponse) []models.PRReviewComment {
-	var formattedComments []models.PRReviewComment
-	for _, comment := range comments {
-		// format comment: body -> [pt value] body
-		prefix := ""
-		if comment.Points > 0 {
-			prefix = fmt.Sprintf(LatexPositivePointPrefix, comment.Points)
+		results, err := s.store.GetAutograderResults(c.Context(), int64(work.ID))
+		if err != nil {
+			return errs.InternalServerError()
 		}
-		if comment.Points < 0 {
-			prefix = fmt.Sprintf(LatexNegativePointPrefix, comment.Points)
+
+		_, err = s.hideUnpublishedGrades(c, work, nil)
+		if err != nil {
+			return err
 		}
-		comment.PRReviewComment.Body = prefix + comment.PRReviewComment.Body
-		formattedComments = append(formattedComments, comment.PRReviewComment)
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"work_id":            work.ID,
+			"auto_grader_score":  work.AutoGraderScore,
+			"autograder_results": results,
+		})
 	}
+}
+
+// Returns the grading audit log of a student work, including the assignment and rubric changes that affected it.
+func (s *WorkService) getWorkAuditLog() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		work, err := s.getWork(c)
+		if err != nil {
+			return err
+		}
+
+		_, err = s.RequireAtLeastRole(c, int64(work.ClassroomID), models.TA)
+		if err != nil {
+			return err
+		}
+
+		entries, err := s.store.GetWorkAuditLog(c.Context(), int64(work.ID))
+		if err != nil {
+			return errs.InternalServerError()
+		}
 
-	return formattedComments
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"audit_log": entries,
+		})
+	}
 }
 
-func insertFeedbackInDB(s *WorkService, c *fiber.Ctx, comments []models.PRReviewCommentResponse, taUserID int64, workID int) error {
+func insertFeedbackInDB(s *WorkService, c *fiber.Ctx, comments []models.PRReviewCommentResponse, taUserID int64, work *models.PaginatedStudentWorkWithContributors) error {
 	// insert into DB, remove points field and format the body to display the points
 	for _, comment := range comments {
-		// insert into DB
-		if comment.RubricItemID == nil {
-			// create new rubric item and then attach
-			err := s.store.CreateFeedbackComment(c.Context(), taUserID, workID, comment)
-			if err != nil {
-				return errs.InternalServerError()
+		switch comment.Action {
+		case models.PRReviewCommentActionEdit:
+			if _, err := s.editFeedbackComment(c, work, taUserID, comment); err != nil {
+				return err
+			}
+		case models.PRReviewCommentActionDelete:
+			if err := s.deleteFeedbackComment(c, work, taUserID, comment.FeedbackCommentID); err != nil {
+				return err
+			}
+		default:
+			if err := s.requireAssignmentRubricItem(c, work, comment.RubricItemID); err != nil {
+				return err
 			}
-		} else {
-			// attach rubric item
-			err := s.store.CreateFeedbackCommentFromRubricItem(c.Context(), taUserID, workID, comment)
+
+			// insert into DB
+			err := s.store.WithTx(c.Context(), func(store storage.Storage) error {
+				var feedback models.FeedbackComment
+				var err error
+				if comment.RubricItemID == nil {
+					// create new rubric item and then attach
+					feedback, err = store.CreateFeedbackComment(c.Context(), taUserID, work.ID, comment)
+				} else {
+					// attach rubric item
+					feedback, err = store.CreateFeedbackCommentFromRubricItem(c.Context(), taUserID, work.ID, comment)
+				}
+				if err != nil {
+					return err
+				}
+
+				entry := common.WorkAuditEntry(models.GradingAuditFeedbackCreated, &taUserID, work.ClassroomID, work.AssignmentOutlineID, work.ID)
+				subjectID := int64(feedback.ID)
+				entry.SubjectID = &subjectID
+				return common.RecordGradingAudit(c.Context(), store, entry, nil, feedback)
+			})
 			if err != nil {
 				return errs.InternalServerError()
 			}
@@ -223,6 +332,7 @@ func insertFeedbackInDB(s *WorkService, c *fiber.Ctx, comments []models.PRReview
 	return nil
 }
 
+// Saves a TA's feedback on a student work as a draft, to be posted once the assignment's grades are published.
 func (s *WorkService) gradeWorkByID() fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		// get the work first
@@ -249,27 +359,45 @@ func (s *WorkService) gradeWorkByID() fiber.Handler {
 		if err := c.BodyParser(&requestBody); err != nil {
 			return errs.InvalidRequestBody(requestBody)
 		}
-
-		// create PR review via github API
-		review, err := userClient.CreatePRReview(c.Context(), work.OrgName, work.RepoName, requestBody.Body, formatFeedbackForGitHub(requestBody.Comments))
-		if err != nil {
-			return errs.GithubAPIError(err)
+		for _, comment := range requestBody.Comments {
+			if comment.Action == models.PRReviewCommentActionDelete {
+				continue
+			}
+			if err := comment.ValidateLocation(); err != nil {
+				return errs.BadRequest(err)
+			}
 		}
 
-		// insert into DB
-		err = insertFeedbackInDB(s, c, requestBody.Comments, *taUser.ID, work.ID)
+		// new feedback stays a draft until the assignment's grades are published, while edits and
+		// deletions of published feedback are applied to the student's PR right away
+		err = insertFeedbackInDB(s, c, requestBody.Comments, *taUser.ID, work)
 		if err != nil {
 			return err
 		}
 
-		work.StudentWork.WorkState = models.WorkStateGradingCompleted
-		_, err = s.store.UpdateStudentWork(c.Context(), work.StudentWork)
+		hasNewFeedback := requestBody.Body != "" || slices.ContainsFunc(requestBody.Comments, func(comment models.PRReviewCommentResponse) bool {
+			return comment.Action != models.PRReviewCommentActionEdit && comment.Action != models.PRReviewCommentActionDelete
+		})
+		if hasNewFeedback {
+			err = s.store.UpsertReviewDraft(c.Context(), int64(work.ID), *taUser.ID, requestBody.Body)
+			if err != nil {
+				return errs.InternalServerError()
+			}
+
+			work.StudentWork.WorkState = models.WorkStateGradingCompleted
+			_, err = s.store.UpdateStudentWork(c.Context(), work.StudentWork)
+			if err != nil {
+				return errs.InternalServerError()
+			}
+		}
+
+		feedback, err := s.store.GetFeedbackOnWork(c.Context(), work.ID)
 		if err != nil {
 			return errs.InternalServerError()
 		}
 
 		return c.Status(http.StatusOK).JSON(fiber.Map{
-			"review": review,
+			"feedback": feedback,
 		})
 	}
 }
@@ -284,24 +412,10 @@ func (s *WorkService) GetCommitCount() fiber.Handler {
 		totalCount := work.CommitAmount
 		// Zero either implies bad data or no commits, double check to be safe
 		if totalCount == 0 {
-			var branchOpts github.ListOptions
-			branches, err := s.appClient.ListBranches(c.Context(), work.OrgName, work.RepoName, &branchOpts)
+			allCommits, err := common.ListWorkCommits(c.Context(), s.appClient, work.OrgName, work.RepoName, work.Contributors)
 			if err != nil {
 				return errs.GithubAPIError(err)
 			}
-			var allCommits []*github.RepositoryCommit
-
-			for _, branch := range branches {
-				var opts github.CommitsListOptions
-				// Assumes a single contirbutor, KHO-144
-				opts.Author = work.Contributors[0].GithubUsername
-				opts.SHA = *branch.Name
-				commits, err := s.appClient.ListCommits(c.Context(), work.OrgName, work.RepoName, &opts)
-				if err != nil {
-					return errs.GithubAPIError(err)
-				}
-				allCommits = append(allCommits, commits...)
-			}
 			totalCount = len(allCommits)
 
 			// If there were commits, update the student work
@@ -328,24 +442,10 @@ func (s *WorkService) GetCommitsPerDay() fiber.Handler {
 			return err
 		}
 
-		var branchOpts github.ListOptions
-		branches, err := s.appClient.ListBranches(c.Context(), work.OrgName, work.RepoName, &branchOpts)
+		allCommits, err := common.ListWorkCommits(c.Context(), s.appClient, work.OrgName, work.RepoName, work.Contributors)
 		if err != nil {
 			return errs.GithubAPIError(err)
 		}
-		var allCommits []*github.RepositoryCommit
-
-		for _, branch := range branches {
-			var opts github.CommitsListOptions
-			// Assumes a single contirbutor, KHO-144
-			opts.Author = work.Contributors[0].GithubUsername
-			opts.SHA = *branch.Name
-			commits, err := s.appClient.ListCommits(c.Context(), work.OrgName, work.RepoName, &opts)
-			if err != nil {
-				return errs.GithubAPIError(err)
-			}
-			allCommits = append(allCommits, commits...)
-		}
 
 		commitDatesMap := make(map[time.Time]int)
 		for _, commit := range allCommits {
@@ -363,6 +463,26 @@ func (s *WorkService) GetCommitsPerDay() fiber.Handler {
 	}
 }
 
+// Returns how many commits each contributor to a student work made
+func (s *WorkService) GetCommitsPerContributor() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		work, err := s.getWork(c)
+		if err != nil {
+			return err
+		}
+
+		allCommits, err := common.ListWorkCommits(c.Context(), s.appClient, work.OrgName, work.RepoName, work.Contributors)
+		if err != nil {
+			return errs.GithubAPIError(err)
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"work_id":                 work.ID,
+			"commits_per_contributor": common.CountCommitsByContributor(allCommits, work.Contributors),
+		})
+	}
+}
+
 func (s *WorkService) GetFirstCommitDate() fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		work, err := s.getWork(c)
@@ -373,25 +493,10 @@ func (s *WorkService) GetFirstCommitDate() fiber.Handler {
 		fcd := work.FirstCommitDate
 
 		if fcd == nil {
-			var branchOpts github.ListOptions
-			branches, err := s.appClient.ListBranches(c.Context(), work.OrgName, work.RepoName, &branchOpts)
+			allCommits, err := common.ListWorkCommits(c.Context(), s.appClient, work.OrgName, work.RepoName, work.Contributors)
 			if err != nil {
 				return errs.GithubAPIError(err)
 			}
-			fmt.Println(branches)
-			var allCommits []*github.RepositoryCommit
-
-			for _, branch := range branches {
-				var opts github.CommitsListOptions
-				// Assumes a single contirbutor, KHO-144
-				opts.Author = work.Contributors[0].GithubUsername
-				opts.SHA = *branch.Name
-				commits, err := s.appClient.ListCommits(c.Context(), work.OrgName, work.RepoName, &opts)
-				if err != nil {
-					return errs.GithubAPIError(err)
-				}
-				allCommits = append(allCommits, commits...)
-			}
 
 			if len(allCommits) > 0 {
 				fcd = allCommits[len(allCommits)-1].GetCommit().GetCommitter().Date
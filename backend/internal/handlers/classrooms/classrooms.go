@@ -267,6 +267,12 @@ func (s *ClassroomService) getRubricsInClassroom() fiber.Handler {
 			var fullRubric models.FullRubric
 			fullRubric.Rubric = rubric
 
+			sections, err := s.store.GetRubricSections(c.Context(), rubric.ID)
+			if err != nil {
+				return errs.InternalServerError()
+			}
+			fullRubric.Sections = sections
+
 			items, err := s.store.GetRubricItems(c.Context(), rubric.ID)
 			if err != nil {
 				return errs.InternalServerError()
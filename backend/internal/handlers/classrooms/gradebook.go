@@ -0,0 +1,92 @@
+package classrooms
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/CamPlume1/khoury-classroom/internal/errs"
+	"github.com/CamPlume1/khoury-classroom/internal/handlers/common"
+	"github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+)
+
+// Exports a classroom's gradebook, one row per student and one column per assignment, as CSV or an LMS import file.
+func (s *ClassroomService) exportGradebook() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		classroomID, err := strconv.ParseInt(c.Params("classroom_id"), 10, 64)
+		if err != nil {
+			return errs.BadRequest(err)
+		}
+
+		_, err = s.RequireAtLeastRole(c, classroomID, models.TA)
+		if err != nil {
+			return err
+		}
+
+		options, err := common.NewGradebookOptions(c.Query("format"), c.Query("identifier"), c.QueryBool("include_unpublished"))
+		if err != nil {
+			return errs.BadRequest(err)
+		}
+
+		classroom, err := s.store.GetClassroomByID(c.Context(), classroomID)
+		if err != nil {
+			return errs.NotFound("classroom", "id", classroomID)
+		}
+		assignments, err := s.store.GetAssignmentsInClassroom(c.Context(), classroomID)
+		if err != nil {
+			return errs.InternalServerError()
+		}
+
+		gradebook, err := common.ExportGradebook(c.Context(), s.store, classroomID, assignments, options)
+		if err != nil {
+			fmt.Println("Error exporting gradebook:", err)
+			return errs.InternalServerError()
+		}
+
+		c.Attachment(fmt.Sprintf("%s-gradebook.csv", classroom.Name))
+		return c.Status(http.StatusOK).Send(gradebook)
+	}
+}
+
+// Sets the institution's ID of a student, which keys them in LMS gradebook exports.
+func (s *ClassroomService) setStudentIdentifier() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		classroomID, err := strconv.ParseInt(c.Params("classroom_id"), 10, 64)
+		if err != nil {
+			return errs.BadRequest(err)
+		}
+		userID, err := strconv.ParseInt(c.Params("user_id"), 10, 64)
+		if err != nil {
+			return errs.BadRequest(err)
+		}
+
+		_, err = s.RequireAtLeastRole(c, classroomID, models.Professor)
+		if err != nil {
+			return err
+		}
+
+		var requestBody models.StudentIdentifierRequest
+		if err := c.BodyParser(&requestBody); err != nil {
+			return errs.InvalidRequestBody(requestBody)
+		}
+		if requestBody.StudentIdentifier != nil && *requestBody.StudentIdentifier == "" {
+			requestBody.StudentIdentifier = nil
+		}
+
+		err = s.store.SetStudentIdentifier(c.Context(), classroomID, userID, requestBody.StudentIdentifier)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return errs.UserNotFoundInClassroomError()
+		}
+		if err != nil {
+			return errs.InternalServerError()
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"user_id":            userID,
+			"student_identifier": requestBody.StudentIdentifier,
+		})
+	}
+}
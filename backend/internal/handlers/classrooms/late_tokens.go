@@ -0,0 +1,74 @@
+package classrooms
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/CamPlume1/khoury-classroom/internal/errs"
+	"github.com/CamPlume1/khoury-classroom/internal/middleware"
+	"github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Returns the late token budget of a classroom and how many tokens the current user has left.
+func (s *ClassroomService) getLateTokenBudget() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		classroomID, err := strconv.ParseInt(c.Params("classroom_id"), 10, 64)
+		if err != nil {
+			return errs.BadRequest(err)
+		}
+
+		_, err = s.RequireAtLeastRole(c, classroomID, models.Student)
+		if err != nil {
+			return err
+		}
+
+		_, _, user, err := middleware.GetClientAndUser(c, s.store, s.userCfg)
+		if err != nil {
+			return errs.AuthenticationError()
+		}
+
+		budget, err := s.store.GetLateTokenBudget(c.Context(), classroomID)
+		if err != nil {
+			return errs.InternalServerError()
+		}
+		spent, err := s.store.GetLateTokensSpent(c.Context(), classroomID, *user.ID)
+		if err != nil {
+			return errs.InternalServerError()
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"late_token_budget": budget,
+			"tokens_spent":      spent,
+			"tokens_remaining":  max(budget.TokensPerStudent-spent, 0),
+		})
+	}
+}
+
+// Sets how many late tokens each student in a classroom can spend.
+func (s *ClassroomService) updateLateTokenBudget() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		classroomID, err := strconv.ParseInt(c.Params("classroom_id"), 10, 64)
+		if err != nil {
+			return errs.BadRequest(err)
+		}
+
+		_, err = s.RequireAtLeastRole(c, classroomID, models.Professor)
+		if err != nil {
+			return err
+		}
+
+		var budget models.LateTokenBudget
+		if err := c.BodyParser(&budget); err != nil || budget.TokensPerStudent < 0 {
+			return errs.InvalidRequestBody(budget)
+		}
+		budget.ClassroomID = classroomID
+
+		err = s.store.SetLateTokenBudget(c.Context(), budget)
+		if err != nil {
+			return errs.InternalServerError()
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{"late_token_budget": budget})
+	}
+}
@@ -0,0 +1,118 @@
+package classrooms
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/CamPlume1/khoury-classroom/internal/errs"
+	"github.com/CamPlume1/khoury-classroom/internal/handlers/common"
+	"github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+)
+
+// Returns the alerts about a classroom's repositories, newest first. Resolved alerts are left out
+// unless include_resolved is set.
+func (s *ClassroomService) getRepositoryAlerts() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		classroomID, err := strconv.ParseInt(c.Params("classroom_id"), 10, 64)
+		if err != nil {
+			return errs.BadRequest(err)
+		}
+
+		_, err = s.RequireAtLeastRole(c, classroomID, models.TA)
+		if err != nil {
+			return err
+		}
+
+		alerts, err := s.store.ListRepositoryAlerts(c.Context(), classroomID, c.QueryBool("include_resolved"))
+		if err != nil {
+			return errs.InternalServerError()
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{"alerts": alerts})
+	}
+}
+
+// Marks a repository alert as dealt with
+func (s *ClassroomService) resolveRepositoryAlert() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		alert, user, err := s.getRepositoryAlertAsProfessor(c)
+		if err != nil {
+			return err
+		}
+
+		alert, err = s.store.ResolveRepositoryAlert(c.Context(), alert.ID, *user.ID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return errs.BadRequest(errors.New("the alert is already resolved"))
+		}
+		if err != nil {
+			return errs.InternalServerError()
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{"alert": alert})
+	}
+}
+
+// Puts back the protection ruleset a repository alert is about, and resolves the alert
+func (s *ClassroomService) reapplyRepositoryAlert() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		alert, user, err := s.getRepositoryAlertAsProfessor(c)
+		if err != nil {
+			return err
+		}
+		if !alert.Kind.IsRuleset() {
+			return errs.BadRequest(errors.New("only alerts about rulesets can be reapplied"))
+		}
+
+		classroom, err := s.store.GetClassroomByID(c.Context(), alert.ClassroomID)
+		if err != nil {
+			return errs.InternalServerError()
+		}
+
+		err = common.ReapplyRuleset(c.Context(), s.appClient, s.store, classroom.OrgName, alert)
+		if err != nil {
+			fmt.Println("Error reapplying ruleset:", err)
+			return errs.GithubAPIError(err)
+		}
+
+		_, err = s.store.MarkRepositoryAlertReapplied(c.Context(), alert.ID)
+		if err != nil {
+			return errs.InternalServerError()
+		}
+		alert, err = s.store.ResolveRepositoryAlert(c.Context(), alert.ID, *user.ID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			alert, err = s.store.GetRepositoryAlert(c.Context(), alert.ID)
+		}
+		if err != nil {
+			return errs.InternalServerError()
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{"alert": alert})
+	}
+}
+
+// Helper function for getting the repository alert in the route, checking the user is a professor in its classroom
+func (s *ClassroomService) getRepositoryAlertAsProfessor(c *fiber.Ctx) (models.RepositoryAlert, models.ClassroomUser, error) {
+	classroomID, err := strconv.ParseInt(c.Params("classroom_id"), 10, 64)
+	if err != nil {
+		return models.RepositoryAlert{}, models.ClassroomUser{}, errs.BadRequest(err)
+	}
+	alertID, err := strconv.ParseInt(c.Params("alert_id"), 10, 64)
+	if err != nil {
+		return models.RepositoryAlert{}, models.ClassroomUser{}, errs.BadRequest(err)
+	}
+
+	user, err := s.RequireAtLeastRole(c, classroomID, models.Professor)
+	if err != nil {
+		return models.RepositoryAlert{}, models.ClassroomUser{}, err
+	}
+
+	alert, err := s.store.GetRepositoryAlert(c.Context(), alertID)
+	if err != nil || alert.ClassroomID != classroomID {
+		return models.RepositoryAlert{}, models.ClassroomUser{}, errs.NotFound("repository alert", "id", alertID)
+	}
+	return alert, user, nil
+}
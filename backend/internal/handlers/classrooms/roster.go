@@ -0,0 +1,397 @@
+package classrooms
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/CamPlume1/khoury-classroom/internal/errs"
+	"github.com/CamPlume1/khoury-classroom/internal/handlers/common"
+	"github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/gofiber/fiber/v2"
+	gh "github.com/google/go-github/github"
+	"github.com/jackc/pgx/v5"
+)
+
+// The column names each roster field is read from, after lowercasing and replacing spaces with underscores
+var rosterColumns = map[string][]string{
+	"name":       {"name", "student", "full_name"},
+	"first_name": {"first_name", "first"},
+	"last_name":  {"last_name", "last"},
+	"email":      {"email", "email_address"},
+	"github":     {"github_username", "github", "github_login", "github_user"},
+	"section":    {"section", "sections"},
+	"role":       {"role", "roles"},
+	"student_id": {"student_id", "sis_user_id"},
+	"sis_login":  {"sis_login_id", "login_id"},
+}
+
+// Imports a roster of students and staff into a classroom. Their invitations to the classroom's organization are
+// sent in the background, the response reports what happened to each row so far and the import's ID to follow it by.
+func (s *ClassroomService) importRoster() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		classroomID, err := strconv.ParseInt(c.Params("classroom_id"), 10, 64)
+		if err != nil {
+			return errs.BadRequest(err)
+		}
+
+		// Only allow professors to invite people to org
+		_, err = s.RequireAtLeastRole(c, classroomID, models.Professor)
+		if err != nil {
+			return err
+		}
+
+		classroom, err := s.store.GetClassroomByID(c.Context(), classroomID)
+		if err != nil {
+			return errs.NotFound("classroom", "id", classroomID)
+		}
+
+		format := models.RosterFormatCSV
+		if c.Query("format") != "" {
+			format = models.RosterFormat(strings.ToUpper(c.Query("format")))
+		}
+		if format != models.RosterFormatCSV && format != models.RosterFormatCanvas {
+			return errs.BadRequest(fmt.Errorf("invalid roster format: %s", c.Query("format")))
+		}
+
+		data, err := readRosterUpload(c)
+		if err != nil {
+			return errs.BadRequest(err)
+		}
+
+		entries, results, err := parseRoster(data, format)
+		if err != nil {
+			return errs.BadRequest(err)
+		}
+
+		// Reject the roster before anyone is added if its students have nowhere to go
+		if classroom.StudentTeamName == nil {
+			for _, entry := range entries {
+				if entry.Role == models.Student {
+					return errs.BadRequest(errors.New("classroom has no student team to invite students to"))
+				}
+			}
+		}
+
+		seen := make(map[string]bool)
+		for _, entry := range entries {
+			if seen[strings.ToLower(entry.GithubUsername)] {
+				results = append(results, models.RosterImportResult{
+					Row:            entry.Row,
+					GithubUsername: entry.GithubUsername,
+					Status:         models.RosterImportConflict,
+					Message:        "listed more than once in the roster",
+				})
+				continue
+			}
+			seen[strings.ToLower(entry.GithubUsername)] = true
+
+			results = append(results, s.importRosterEntry(c.Context(), classroom, entry))
+		}
+		sort.Slice(results, func(i, j int) bool { return results[i].Row < results[j].Row })
+
+		rosterImport, err := s.store.CreateRosterImport(c.Context(), classroom.ID, results)
+		if err != nil {
+			fmt.Println("Error saving roster import:", err)
+			return errs.InternalServerError()
+		}
+
+		return c.Status(http.StatusAccepted).JSON(fiber.Map{"roster_import": rosterImport})
+	}
+}
+
+// Returns what importing a roster did so far, including which org invitations are still waiting to be sent.
+func (s *ClassroomService) getRosterImport() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		classroomID, err := strconv.ParseInt(c.Params("classroom_id"), 10, 64)
+		if err != nil {
+			return errs.BadRequest(err)
+		}
+		rosterImportID, err := strconv.ParseInt(c.Params("roster_import_id"), 10, 64)
+		if err != nil {
+			return errs.BadRequest(err)
+		}
+
+		_, err = s.RequireAtLeastRole(c, classroomID, models.Professor)
+		if err != nil {
+			return err
+		}
+
+		rosterImport, err := s.store.GetRosterImport(c.Context(), classroomID, rosterImportID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return errs.NotFound("roster import", "id", rosterImportID)
+		}
+		if err != nil {
+			fmt.Println("Error getting roster import:", err)
+			return errs.InternalServerError()
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{"roster_import": rosterImport})
+	}
+}
+
+// Reconciles a classroom's roster against its organization now, optionally re-sending expired invitations.
+func (s *ClassroomService) reconcileRoster() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		classroomID, err := strconv.ParseInt(c.Params("classroom_id"), 10, 64)
+		if err != nil {
+			return errs.BadRequest(err)
+		}
+
+		_, err = s.RequireAtLeastRole(c, classroomID, models.Professor)
+		if err != nil {
+			return err
+		}
+
+		classroom, err := s.store.GetClassroomByID(c.Context(), classroomID)
+		if err != nil {
+			return errs.NotFound("classroom", "id", classroomID)
+		}
+
+		report, err := common.ReconcileClassroomRoster(c.Context(), s.appClient, s.store, classroom, c.QueryBool("resend_expired"))
+		if err != nil {
+			fmt.Println("Error reconciling classroom roster:", err)
+			return errs.GithubAPIError(err)
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{"reconciliation": report})
+	}
+}
+
+// Returns the report of a classroom's latest roster reconciliation, nil if it hasn't been reconciled yet.
+func (s *ClassroomService) getRosterReconciliation() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		classroomID, err := strconv.ParseInt(c.Params("classroom_id"), 10, 64)
+		if err != nil {
+			return errs.BadRequest(err)
+		}
+
+		_, err = s.RequireAtLeastRole(c, classroomID, models.TA)
+		if err != nil {
+			return err
+		}
+
+		report, err := s.store.GetRosterReconciliation(c.Context(), classroomID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.Status(http.StatusOK).JSON(fiber.Map{"reconciliation": nil})
+		}
+		if err != nil {
+			return errs.InternalServerError()
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{"reconciliation": report})
+	}
+}
+
+// Adds a roster entry's user to the classroom. Returns PENDING_INVITE for users that still need an org invitation.
+func (s *ClassroomService) importRosterEntry(ctx context.Context, classroom models.Classroom, entry models.RosterEntry) models.RosterImportResult {
+	result := models.RosterImportResult{Row: entry.Row, GithubUsername: entry.GithubUsername}
+
+	githubUser, err := s.appClient.GetUser(ctx, entry.GithubUsername)
+	if err != nil {
+		var errResponse *gh.ErrorResponse
+		if errors.As(err, &errResponse) && errResponse.Response.StatusCode == http.StatusNotFound {
+			result.Status = models.RosterImportUnknownUser
+			result.Message = "no GitHub user has this username"
+			return result
+		}
+		fmt.Println("Error looking up rostered GitHub user:", err)
+		result.Status = models.RosterImportFailed
+		result.Message = "could not look up the GitHub user"
+		return result
+	}
+	entry.GithubUsername = githubUser.GetLogin()
+	result.GithubUsername = githubUser.GetLogin()
+
+	user, err := s.store.GetOrCreateRosterUser(ctx, entry, githubUser.GetID())
+	if err != nil {
+		fmt.Println("Error creating rostered user:", err)
+		result.Status = models.RosterImportFailed
+		result.Message = "could not create the user"
+		return result
+	}
+
+	classroomUser, err := s.store.GetUserInClassroom(ctx, classroom.ID, *user.ID)
+	if err != nil {
+		classroomUser, err = s.store.AddUserToClassroom(ctx, classroom.ID, string(entry.Role), models.UserStatusNotInOrg, *user.ID)
+		if err != nil {
+			fmt.Println("Error adding rostered user to classroom:", err)
+			result.Status = models.RosterImportFailed
+			result.Message = "could not add the user to the classroom"
+			return result
+		}
+	} else if classroomUser.Status == models.UserStatusRemoved {
+		result.Status = models.RosterImportConflict
+		result.Message = "previously removed from the classroom"
+		return result
+	} else if classroomUser.Role != entry.Role {
+		result.Status = models.RosterImportConflict
+		result.Message = fmt.Sprintf("already in the classroom as a %s", classroomUser.Role)
+		return result
+	}
+
+	err = s.store.SetRosterDetails(ctx, classroom.ID, *user.ID, entry.Section, entry.StudentIdentifier)
+	if err != nil {
+		fmt.Println("Error saving roster details:", err)
+	}
+
+	result.UserID = user.ID
+	result.Role = &classroomUser.Role
+	switch classroomUser.Status {
+	case models.UserStatusActive:
+		result.Status = models.RosterImportAlreadyMember
+	case models.UserStatusOrgInvited:
+		result.Status = models.RosterImportAlreadyInvited
+	default:
+		result.Status = models.RosterImportPendingInvite
+	}
+	return result
+}
+
+// Reads a roster from an uploaded "roster" file, or the raw request body
+func readRosterUpload(c *fiber.Ctx) ([]byte, error) {
+	fileHeader, err := c.FormFile("roster")
+	if err != nil {
+		if len(c.Body()) == 0 {
+			return nil, errors.New("no roster was uploaded")
+		}
+		return c.Body(), nil
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
+
+// Parses the entries of a roster, returning results for the rows that can't be imported
+func parseRoster(data []byte, format models.RosterFormat) ([]models.RosterEntry, []models.RosterImportResult, error) {
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid roster CSV: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, nil, errors.New("roster is empty")
+	}
+
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		name = strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), " ", "_")
+		for field, aliases := range rosterColumns {
+			for _, alias := range aliases {
+				if _, ok := columns[field]; !ok && name == alias {
+					columns[field] = i
+				}
+			}
+		}
+	}
+	if _, ok := columns["github"]; !ok {
+		return nil, nil, errors.New("roster has no GitHub username column")
+	}
+
+	value := func(record []string, field string) string {
+		i, ok := columns[field]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+	optional := func(s string) *string {
+		if s == "" {
+			return nil
+		}
+		return &s
+	}
+
+	var entries []models.RosterEntry
+	var invalid []models.RosterImportResult
+	for i, record := range records[1:] {
+		row := i + 2
+		name := value(record, "name")
+
+		// Canvas exports list points possible and a test student alongside the real students
+		if format == models.RosterFormatCanvas && (strings.TrimSpace(strings.Join(record, "")) == "" ||
+			strings.HasPrefix(name, "Points Possible") || name == "Student, Test") {
+			continue
+		}
+
+		entry := models.RosterEntry{
+			Row:               row,
+			FirstName:         value(record, "first_name"),
+			LastName:          value(record, "last_name"),
+			Email:             optional(value(record, "email")),
+			GithubUsername:    strings.TrimPrefix(value(record, "github"), "@"),
+			Section:           optional(value(record, "section")),
+			StudentIdentifier: optional(value(record, "student_id")),
+		}
+		if entry.FirstName == "" && entry.LastName == "" && name != "" {
+			entry.FirstName, entry.LastName = splitRosterName(name, format)
+		}
+		if login := value(record, "sis_login"); entry.Email == nil && strings.Contains(login, "@") {
+			entry.Email = &login
+		}
+
+		role, ok := parseRosterRole(value(record, "role"))
+		if !ok {
+			invalid = append(invalid, models.RosterImportResult{
+				Row:            row,
+				GithubUsername: entry.GithubUsername,
+				Status:         models.RosterImportInvalid,
+				Message:        fmt.Sprintf("unknown role: %s", value(record, "role")),
+			})
+			continue
+		}
+		entry.Role = role
+
+		if entry.GithubUsername == "" {
+			invalid = append(invalid, models.RosterImportResult{
+				Row:     row,
+				Status:  models.RosterImportInvalid,
+				Message: "missing GitHub username",
+			})
+			continue
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, invalid, nil
+}
+
+// Splits a full name into first and last names. Canvas lists names as "Last, First".
+func splitRosterName(name string, format models.RosterFormat) (string, string) {
+	if last, first, ok := strings.Cut(name, ","); ok && format == models.RosterFormatCanvas {
+		return strings.TrimSpace(first), strings.TrimSpace(last)
+	}
+	if i := strings.LastIndex(name, " "); i > 0 {
+		return strings.TrimSpace(name[:i]), strings.TrimSpace(name[i+1:])
+	}
+	return name, ""
+}
+
+// Maps the roles in a roster, including Canvas enrollment types, to classroom roles. Rows without a role are students.
+func parseRosterRole(role string) (models.ClassroomRole, bool) {
+	role = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(role), "Enrollment"))
+	switch role {
+	case "", "student":
+		return models.Student, true
+	case "ta", "teaching assistant", "teachingassistant":
+		return models.TA, true
+	case "professor", "teacher", "instructor":
+		return models.Professor, true
+	default:
+		return "", false
+	}
+}
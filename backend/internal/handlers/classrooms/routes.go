@@ -52,6 +52,39 @@ func classroomRoutes(router fiber.Router, service *ClassroomService) fiber.Route
 	// Get all rubrics assoricated with this classroom
 	classroomRouter.Get("/classroom/:classroom_id/rubrics", service.getRubricsInClassroom())
 
+	// Get the late token budget of this classroom and the current user's remaining tokens
+	classroomRouter.Get("/classroom/:classroom_id/late-tokens", service.getLateTokenBudget())
+
+	// Set how many late tokens each student in this classroom can spend
+	classroomRouter.Put("/classroom/:classroom_id/late-tokens", service.updateLateTokenBudget())
+
+	// Export the gradebook of this classroom
+	classroomRouter.Get("/classroom/:classroom_id/gradebook", service.exportGradebook())
+
+	// Set the institution's ID of a student in this classroom
+	classroomRouter.Put("/classroom/:classroom_id/students/:user_id/student-identifier", service.setStudentIdentifier())
+
+	// Import a roster of users into this classroom and invite them to the org
+	classroomRouter.Post("/classroom/:classroom_id/roster", service.importRoster())
+
+	// Get what importing a roster did, including the org invitations still waiting to be sent
+	classroomRouter.Get("/classroom/:classroom_id/roster/imports/:roster_import_id", service.getRosterImport())
+
+	// Get the report of this classroom's latest roster reconciliation
+	classroomRouter.Get("/classroom/:classroom_id/roster/reconciliation", service.getRosterReconciliation())
+
+	// Reconcile this classroom's roster against its org now
+	classroomRouter.Post("/classroom/:classroom_id/roster/reconciliation", service.reconcileRoster())
+
+	// Get the alerts about this classroom's repositories, e.g. deleted repositories or tampered rulesets
+	classroomRouter.Get("/classroom/:classroom_id/repository-alerts", service.getRepositoryAlerts())
+
+	// Resolve an alert about one of this classroom's repositories
+	classroomRouter.Post("/classroom/:classroom_id/repository-alerts/:alert_id/resolve", service.resolveRepositoryAlert())
+
+	// Put back the ruleset an alert is about
+	classroomRouter.Post("/classroom/:classroom_id/repository-alerts/:alert_id/reapply", service.reapplyRepositoryAlert())
+
 	// Send org invites to a specific user
 	classroomRouter.Put("/classroom/:classroom_id/invite/role/:classroom_role/user/:user_id", service.sendOrganizationInviteToUser())
 
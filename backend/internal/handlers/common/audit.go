@@ -0,0 +1,51 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/CamPlume1/khoury-classroom/internal/storage"
+)
+
+// Appends an entry to the grading audit log, with what the subject of the action was before and after it.
+// Callers record it with a store from Storage.WithTx, in the same transaction as the change it audits, so a grading
+// change never goes through unaudited.
+func RecordGradingAudit(ctx context.Context, store storage.Storage, entry models.GradingAuditEntry, before any, after any) error {
+	var err error
+	entry.Before, err = marshalAuditValue(before)
+	if err == nil {
+		entry.After, err = marshalAuditValue(after)
+	}
+	if err == nil {
+		err = store.RecordGradingAudit(ctx, entry)
+	}
+	if err != nil {
+		fmt.Println("Error recording grading audit entry:", err)
+	}
+	return err
+}
+
+// Creates an audit entry for an action on a student work
+func WorkAuditEntry(action models.GradingAuditAction, actorUserID *int64, classroomID int, assignmentID int, studentWorkID int) models.GradingAuditEntry {
+	assignmentOutlineID := int64(assignmentID)
+	workID := int64(studentWorkID)
+	return models.GradingAuditEntry{
+		Action:              action,
+		ActorUserID:         actorUserID,
+		ClassroomID:         int64(classroomID),
+		AssignmentOutlineID: &assignmentOutlineID,
+		StudentWorkID:       &workID,
+	}
+}
+
+// nil values (including nil pointers) are stored as SQL NULL rather than JSON null
+func marshalAuditValue(value any) (json.RawMessage, error) {
+	data, err := json.Marshal(value)
+	if err != nil || bytes.Equal(data, []byte("null")) {
+		return nil, err
+	}
+	return data, nil
+}
@@ -0,0 +1,64 @@
+package common
+
+import (
+	"context"
+	"sort"
+
+	"github.com/CamPlume1/khoury-classroom/internal/github"
+	"github.com/CamPlume1/khoury-classroom/internal/models"
+	gh "github.com/google/go-github/github"
+)
+
+// A student work's commit authored by one of its contributors
+type WorkCommit struct {
+	*gh.RepositoryCommit
+	Contributor string // GitHub username of the contributor who authored it
+}
+
+// Lists the commits the contributors of a student work made on any of its branches, newest first.
+// Commits reachable from several branches are only listed once, and template commits aren't listed.
+func ListWorkCommits(ctx context.Context, client github.GitHubBaseClient, orgName string, repoName string, contributors []models.IWorkContributor) ([]WorkCommit, error) {
+	var branchOpts gh.ListOptions
+	branches, err := client.ListBranches(ctx, orgName, repoName, &branchOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var allCommits []WorkCommit
+	for _, contributor := range contributors {
+		for _, branch := range branches {
+			var opts gh.CommitsListOptions
+			opts.Author = contributor.GithubUsername
+			opts.SHA = branch.GetName()
+			commits, err := client.ListCommits(ctx, orgName, repoName, &opts)
+			if err != nil {
+				return nil, err
+			}
+			for _, commit := range commits {
+				if seen[commit.GetSHA()] {
+					continue
+				}
+				seen[commit.GetSHA()] = true
+				allCommits = append(allCommits, WorkCommit{RepositoryCommit: commit, Contributor: contributor.GithubUsername})
+			}
+		}
+	}
+
+	sort.SliceStable(allCommits, func(i, j int) bool {
+		return allCommits[i].GetCommit().GetCommitter().GetDate().After(allCommits[j].GetCommit().GetCommitter().GetDate())
+	})
+	return allCommits, nil
+}
+
+// Counts the commits each contributor of a student work authored, including contributors without any
+func CountCommitsByContributor(commits []WorkCommit, contributors []models.IWorkContributor) map[string]int {
+	counts := make(map[string]int)
+	for _, contributor := range contributors {
+		counts[contributor.GithubUsername] = 0
+	}
+	for _, commit := range commits {
+		counts[commit.Contributor]++
+	}
+	return counts
+}
@@ -0,0 +1,165 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+
+	"github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/CamPlume1/khoury-classroom/internal/storage"
+)
+
+// How a gradebook should be exported
+type GradebookOptions struct {
+	Format             models.GradebookFormat
+	Identifier         models.StudentIdentifier
+	IncludeUnpublished bool // include manual scores that haven't been published to students yet
+}
+
+// Validates the requested gradebook options, defaulting to a CSV keyed by GitHub username
+func NewGradebookOptions(format string, identifier string, includeUnpublished bool) (GradebookOptions, error) {
+	options := GradebookOptions{
+		Format:             models.GradebookFormatCSV,
+		Identifier:         models.StudentIdentifierGitHubUsername,
+		IncludeUnpublished: includeUnpublished,
+	}
+
+	if format != "" {
+		options.Format = models.GradebookFormat(format)
+	}
+	switch options.Format {
+	case models.GradebookFormatCSV, models.GradebookFormatCanvas, models.GradebookFormatBlackboard:
+	default:
+		return GradebookOptions{}, fmt.Errorf("invalid gradebook format: %s", format)
+	}
+
+	if identifier != "" {
+		options.Identifier = models.StudentIdentifier(identifier)
+	}
+	switch options.Identifier {
+	case models.StudentIdentifierGitHubUsername, models.StudentIdentifierGitHubUserID, models.StudentIdentifierStudentID:
+	default:
+		return GradebookOptions{}, fmt.Errorf("invalid student identifier: %s", identifier)
+	}
+
+	return options, nil
+}
+
+// Exports the gradebook of a classroom's students on the given assignments as CSV.
+// Students who haven't accepted an assignment have empty cells for it.
+func ExportGradebook(ctx context.Context, store storage.Storage, classroomID int64, assignments []models.AssignmentOutline, options GradebookOptions) ([]byte, error) {
+	students, err := store.GetGradebookStudents(ctx, classroomID)
+	if err != nil {
+		return nil, err
+	}
+
+	var assignmentID *int64
+	if len(assignments) == 1 {
+		id := int64(assignments[0].ID)
+		assignmentID = &id
+	}
+	scores, err := store.GetGradebookScores(ctx, classroomID, assignmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	// scores by student, then by assignment
+	scoresByStudent := make(map[int64]map[int64]models.GradebookScore)
+	for _, score := range scores {
+		if !options.IncludeUnpublished && score.GradesPublishedTimestamp == nil {
+			score.ManualFeedbackScore = nil
+		}
+		if scoresByStudent[score.UserID] == nil {
+			scoresByStudent[score.UserID] = make(map[int64]models.GradebookScore)
+		}
+		scoresByStudent[score.UserID][score.AssignmentOutlineID] = score
+	}
+
+	records := [][]string{gradebookHeader(assignments, options)}
+	for _, student := range students {
+		row := gradebookStudentColumns(student, options)
+		for _, assignment := range assignments {
+			score := scoresByStudent[student.UserID][int64(assignment.ID)]
+			if options.Format == models.GradebookFormatCSV {
+				row = append(row, formatScore(score.ManualFeedbackScore), formatScore(score.AutoGraderScore))
+			}
+			row = append(row, formatScore(score.Total()))
+		}
+		records = append(records, row)
+	}
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	if err := writer.WriteAll(records); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func gradebookHeader(assignments []models.AssignmentOutline, options GradebookOptions) []string {
+	var header []string
+	switch options.Format {
+	case models.GradebookFormatCanvas:
+		header = []string{"Student", "ID", "SIS User ID", "SIS Login ID", "Section"}
+	case models.GradebookFormatBlackboard:
+		header = []string{"Last Name", "First Name", "Username", "Student ID"}
+	default:
+		header = []string{"Last Name", "First Name", "GitHub Username", "GitHub User ID", "Student ID"}
+	}
+
+	for _, assignment := range assignments {
+		if options.Format == models.GradebookFormatCSV {
+			header = append(header, assignment.Name+" (Manual)", assignment.Name+" (Autograder)", assignment.Name+" (Total)")
+		} else {
+			header = append(header, assignment.Name)
+		}
+	}
+	return header
+}
+
+func gradebookStudentColumns(student models.GradebookStudent, options GradebookOptions) []string {
+	firstName, lastName := derefString(student.FirstName), derefString(student.LastName)
+	studentID := derefString(student.StudentIdentifier)
+
+	switch options.Format {
+	case models.GradebookFormatCanvas:
+		// Canvas matches students by SIS user ID when it's set, otherwise by SIS login ID
+		if options.Identifier == models.StudentIdentifierStudentID {
+			return []string{lastName + ", " + firstName, "", studentID, "", ""}
+		}
+		return []string{lastName + ", " + firstName, "", "", studentKey(student, options.Identifier), ""}
+	case models.GradebookFormatBlackboard:
+		// Blackboard matches students by username
+		return []string{lastName, firstName, studentKey(student, options.Identifier), studentID}
+	default:
+		return []string{lastName, firstName, student.GithubUsername, strconv.FormatInt(student.GithubUserID, 10), studentID}
+	}
+}
+
+// The value identifying a student to an LMS
+func studentKey(student models.GradebookStudent, identifier models.StudentIdentifier) string {
+	switch identifier {
+	case models.StudentIdentifierGitHubUserID:
+		return strconv.FormatInt(student.GithubUserID, 10)
+	case models.StudentIdentifierStudentID:
+		return derefString(student.StudentIdentifier)
+	default:
+		return student.GithubUsername
+	}
+}
+
+func formatScore(score *int) string {
+	if score == nil {
+		return ""
+	}
+	return strconv.Itoa(*score)
+}
+
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,257 @@
+package common
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/CamPlume1/khoury-classroom/internal/github"
+	"github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/CamPlume1/khoury-classroom/internal/storage"
+	"github.com/jackc/pgx/v5"
+)
+
+const LatexPositivePointPrefix = `$${\huge\color{limegreen}\textbf{[+%d]}}$$ `
+const LatexNegativePointPrefix = `$${\huge\color{WildStrawberry}\textbf{[%d]}}$$ `
+
+// Hidden in what the app posts to pull requests, so the webhooks for it can tell it apart from reviews TAs leave on GitHub
+const AppCommentMarker = "<!-- gitmarks -->"
+
+// Identifies the publication a review was posted by, see publicationKey
+const publicationMarker = "<!-- gitmarks-publication: %s -->"
+
+// Publishes the draft grades of every work in an assignment, returning how many works were published.
+// A work that fails to publish doesn't stop the others, and is left as a draft to be published again.
+// publishedBy is nil when the grades are published on schedule.
+func PublishAssignmentGrades(ctx context.Context, client github.GitHubBaseClient, store storage.Storage, assignmentID int64, publishedBy *int64) (int, error) {
+	works, err := store.GetWorksWithUnpublishedGrades(ctx, assignmentID)
+	if err != nil {
+		return 0, err
+	}
+
+	published := 0
+	var errs []error
+	for _, work := range works {
+		err = PublishWorkGrades(ctx, client, store, work, publishedBy)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", work.RepoName, err))
+			continue
+		}
+		published++
+	}
+
+	return published, errors.Join(errs...)
+}
+
+// Posts the draft feedback of a work as a review on its feedback pull request and releases its grade
+func PublishWorkGrades(ctx context.Context, client github.GitHubBaseClient, store storage.Storage, work models.StudentWork, publishedBy *int64) error {
+	feedback, err := store.GetFeedbackOnWork(ctx, work.ID)
+	if err != nil {
+		return fmt.Errorf("error getting feedback: %v", err)
+	}
+	var drafts []models.PRReviewCommentResponse
+	for _, comment := range feedback {
+		if comment.PublishedAt == nil {
+			drafts = append(drafts, comment)
+		}
+	}
+
+	body := ""
+	var bodyUpdatedAt time.Time
+	draft, err := store.GetReviewDraft(ctx, int64(work.ID))
+	if err == nil {
+		body, bodyUpdatedAt = draft.Body, draft.UpdatedAt
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("error getting review draft: %v", err)
+	}
+
+	// a review can't hold comments on entire files, they're posted on their own
+	var lineDrafts, fileDrafts []models.PRReviewCommentResponse
+	for _, comment := range drafts {
+		if comment.IsFileComment() {
+			fileDrafts = append(fileDrafts, comment)
+		} else {
+			lineDrafts = append(lineDrafts, comment)
+		}
+	}
+
+	// feedback is left on the snapshot it was given on, even if the student pushed since
+	commitID, err := GetSnapshotSHA(ctx, store, work.ID)
+	if err != nil {
+		return fmt.Errorf("error getting submission snapshot: %v", err)
+	}
+
+	if len(lineDrafts) > 0 || body != "" {
+		formattedComments := formatFeedbackForGitHub(lineDrafts)
+
+		// A publication that posted its review but failed to be saved is retried, so reuse the review it posted
+		key := publicationKey(work.ID, lineDrafts, body, bodyUpdatedAt)
+		reviewID, reviewCommitID, err := findPublishedReview(ctx, client, work, key)
+		if err != nil {
+			return fmt.Errorf("error checking for an already published review: %v", err)
+		}
+		if reviewID == 0 {
+			review, err := client.CreatePRReview(ctx, work.OrgName, work.RepoName, commitID, markPublication(body, key), formattedComments)
+			if err != nil {
+				return err
+			}
+			reviewID, reviewCommitID = review.GetID(), review.GetCommitID()
+		}
+		commitID = reviewCommitID
+
+		// remember which review comment each piece of feedback became, so replies to it can be traced back
+		reviewComments, err := client.ListReviewComments(ctx, work.OrgName, work.RepoName, 1, reviewID)
+		if err != nil {
+			fmt.Println("Error listing review comments:", err)
+		} else {
+			attachGitHubCommentIDs(lineDrafts, formattedComments, reviewComments)
+		}
+	}
+
+	published, err := postFileComments(ctx, client, work, commitID, fileDrafts)
+	published = append(lineDrafts, published...)
+
+	publishErr := store.WithTx(ctx, func(store storage.Storage) error {
+		if err := store.PublishWorkGrades(ctx, int64(work.ID), published); err != nil {
+			return err
+		}
+
+		entry := WorkAuditEntry(models.GradingAuditGradesPublished, publishedBy, work.ClassroomID, work.AssignmentOutlineID, work.ID)
+		return RecordGradingAudit(ctx, store, entry, nil, map[string]any{
+			"feedback":    published,
+			"review_body": body,
+		})
+	})
+	if publishErr != nil {
+		return publishErr
+	}
+	return err
+}
+
+// Posts feedback on entire files to a work's feedback pull request, at the given commit (or its head).
+// Returns the feedback that was posted; feedback that fails to post is left out, to stay a draft and be posted again.
+func postFileComments(ctx context.Context, client github.GitHubBaseClient, work models.StudentWork, commitID string, comments []models.PRReviewCommentResponse) ([]models.PRReviewCommentResponse, error) {
+	if len(comments) == 0 {
+		return nil, nil
+	}
+	if commitID == "" {
+		pr, err := client.GetPullRequest(ctx, work.OrgName, work.RepoName, 1)
+		if err != nil {
+			return nil, fmt.Errorf("error getting feedback pull request: %v", err)
+		}
+		commitID = pr.GetHead().GetSHA()
+	}
+
+	var posted []models.PRReviewCommentResponse
+	var errs []error
+	for _, comment := range comments {
+		fileComment, err := client.CreateFileComment(ctx, work.OrgName, work.RepoName, 1, commitID, *comment.Path,
+			FormatFeedbackBody(comment.Points, comment.Body))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", *comment.Path, err))
+			continue
+		}
+		id := fileComment.GetID()
+		comment.GitHubCommentID = &id
+		posted = append(posted, comment)
+	}
+	return posted, errors.Join(errs...)
+}
+
+func formatFeedbackForGitHub(comments []models.PRReviewCommentResponse) []models.PRReviewComment {
+	var formattedComments []models.PRReviewComment
+	for _, comment := range comments {
+		comment.PRReviewComment.Body = FormatFeedbackBody(comment.Points, comment.PRReviewComment.Body)
+		formattedComments = append(formattedComments, comment.PRReviewComment)
+	}
+
+	return formattedComments
+}
+
+// Formats the body of a feedback comment as it's shown on GitHub: body -> [pt value] body
+func FormatFeedbackBody(points int, body string) string {
+	prefix := ""
+	if points > 0 {
+		prefix = fmt.Sprintf(LatexPositivePointPrefix, points)
+	}
+	if points < 0 {
+		prefix = fmt.Sprintf(LatexNegativePointPrefix, points)
+	}
+	return markAppComment(prefix + body)
+}
+
+// Whether a pull request comment or review was posted by the app
+func IsAppComment(body string) bool {
+	return strings.Contains(body, AppCommentMarker)
+}
+
+func markAppComment(body string) string {
+	if body == "" {
+		return body
+	}
+	return body + "\n\n" + AppCommentMarker
+}
+
+// Identifies the review a set of drafts is published as, so publishing the same drafts again can find it
+func publicationKey(workID int, drafts []models.PRReviewCommentResponse, body string, bodyUpdatedAt time.Time) string {
+	ids := make([]int, 0, len(drafts))
+	for _, draft := range drafts {
+		if draft.FeedbackCommentID != nil {
+			ids = append(ids, *draft.FeedbackCommentID)
+		}
+	}
+	sort.Ints(ids)
+
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%d|%v|%s|%d", workID, ids, body, bodyUpdatedAt.UnixNano())))
+	return hex.EncodeToString(hash[:8])
+}
+
+// Marks a review body as the app's, along with the key of the publication it belongs to
+func markPublication(body string, key string) string {
+	marker := AppCommentMarker + "\n" + fmt.Sprintf(publicationMarker, key)
+	if body == "" {
+		return marker
+	}
+	return body + "\n\n" + marker
+}
+
+// Returns the ID and commit of the review already posted for a publication, or a zero ID if there isn't one
+func findPublishedReview(ctx context.Context, client github.GitHubBaseClient, work models.StudentWork, key string) (int64, string, error) {
+	reviews, err := client.ListPRReviews(ctx, work.OrgName, work.RepoName, 1)
+	if err != nil {
+		return 0, "", err
+	}
+
+	marker := fmt.Sprintf(publicationMarker, key)
+	for _, review := range reviews {
+		if strings.Contains(review.GetBody(), marker) {
+			return review.GetID(), review.GetCommitID(), nil
+		}
+	}
+	return 0, "", nil
+}
+
+// matches each feedback comment to the review comment GitHub created for it
+func attachGitHubCommentIDs(comments []models.PRReviewCommentResponse, formattedComments []models.PRReviewComment, reviewComments []models.GitHubReviewComment) {
+	used := make(map[int64]bool)
+	for i, formatted := range formattedComments {
+		if formatted.Path == nil {
+			continue
+		}
+		for _, reviewComment := range reviewComments {
+			if used[reviewComment.ID] || reviewComment.Path != *formatted.Path ||
+				strings.TrimSpace(reviewComment.Body) != strings.TrimSpace(formatted.Body) {
+				continue
+			}
+			id := reviewComment.ID
+			comments[i].GitHubCommentID = &id
+			used[id] = true
+			break
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package common
+
+import (
+	"context"
+	"errors"
+
+	"github.com/CamPlume1/khoury-classroom/internal/github"
+	"github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/CamPlume1/khoury-classroom/internal/storage"
+	gh "github.com/google/go-github/github"
+	"github.com/jackc/pgx/v5"
+)
+
+// Records the installations of the app that installation webhooks haven't told us about, such as those made before
+// the app received them. Installations that are already known are left alone, since their webhooks are newer.
+func BackfillAppInstallations(ctx context.Context, client github.GitHubAppClient, store storage.Storage) error {
+	appInstallations, err := client.ListInstallations(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, appInstallation := range appInstallations {
+		_, err := store.CreateAppInstallation(ctx, InstallationFromGitHub(appInstallation))
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Converts an installation of the app from GitHub into the one we keep track of
+func InstallationFromGitHub(installation *gh.Installation) models.AppInstallation {
+	return models.AppInstallation{
+		InstallationID:      installation.GetID(),
+		AccountID:           installation.Account.GetID(),
+		AccountLogin:        installation.Account.GetLogin(),
+		AccountType:         installation.Account.GetType(),
+		RepositorySelection: installation.RepositorySelection,
+	}
+}
@@ -2,19 +2,28 @@ package common
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/CamPlume1/khoury-classroom/internal/errs"
 	"github.com/CamPlume1/khoury-classroom/internal/github"
+	"github.com/CamPlume1/khoury-classroom/internal/models"
 	"github.com/CamPlume1/khoury-classroom/internal/storage"
 	"github.com/CamPlume1/khoury-classroom/internal/utils"
 	gh "github.com/google/go-github/github"
+	"github.com/jackc/pgx/v5"
 )
 
-var (
-	MainRepoBranch    = "main"
-	OtherRepoBranches = []string{"development", "feedback"}
-)
+// Gets the branch layout of an assignment, which is the default layout until configured
+func GetBranchLayout(ctx context.Context, store storage.Storage, assignmentID int64) (models.BranchLayout, error) {
+	layout, err := store.GetBranchLayout(ctx, assignmentID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		layout = models.DefaultBranchLayout
+		layout.AssignmentOutlineID = int32(assignmentID)
+		return layout, nil
+	}
+	return layout, err
+}
 
 func InitializeRepo(ctx context.Context, client github.GitHubBaseClient, store storage.Storage, repoID int64, repoOwner, repoName, serverUrl string) error {
 	repo := gh.PushEventRepository{
@@ -38,20 +47,30 @@ func InitializePushEventRepo(ctx context.Context, client github.GitHubBaseClient
 		return err
 	}
 
-	if template.MainDueDate != nil {
-		// There is a deadline
-		err = client.CreateDeadlineEnforcement(ctx, template.MainDueDate, *repository.Organization, *repository.Name, MainRepoBranch, serverUrl)
+	layout, err := GetBranchLayout(ctx, store, int64(template.ID))
+	if err != nil {
+		fmt.Println("Error getting branch layout:", err)
+		return err
+	}
+
+	// Get the master branch name (use the submission branch if not specified)
+	mainBranch := layout.SubmissionBranch
+	if repository.MasterBranch != nil {
+		mainBranch = *repository.MasterBranch
+	}
+
+	// The workflows are committed to the submission branch, so it has to exist first
+	if mainBranch != layout.SubmissionBranch {
+		err = setUpSubmissionBranch(ctx, client, *repository.Organization, *repository.Name, mainBranch, layout)
 		if err != nil {
-			//@KHO-239
-			fmt.Println("Error creating deadline enforcement:", err)
-			return err
+			fmt.Println("Error setting up submission branch:", err)
+			return errs.InternalServerError()
 		}
 	}
 
-	// Create PR Enforcement Action
-	err = client.CreatePREnforcement(ctx, *repository.Organization, *repository.Name, MainRepoBranch)
+	// Create deadline, PR and autograder workflows
+	err = createWorkflows(ctx, client, store, template, *repository.Organization, *repository.Name, layout, serverUrl)
 	if err != nil {
-		fmt.Println("Error creating PR enforcement:", err)
 		return err
 	}
 
@@ -63,18 +82,12 @@ func InitializePushEventRepo(ctx context.Context, client github.GitHubBaseClient
 		return err
 	}
 
-	// Get the master branch name (use main if not specified)
-	mainBranch := MainRepoBranch
-	if repository.MasterBranch != nil {
-		mainBranch = *repository.MasterBranch
-	}
-
 	// Create necessary repo branches
-	for _, branch := range OtherRepoBranches {
+	for _, branch := range layout.CreatedBranches() {
 		_, err := client.CreateBranch(ctx,
 			*repository.Organization,
 			*repository.Name,
-			mainBranch,
+			layout.SubmissionBranch,
 			branch)
 		if err != nil {
 			fmt.Println("Error creating branch:", err)
@@ -83,7 +96,7 @@ func InitializePushEventRepo(ctx context.Context, client github.GitHubBaseClient
 	}
 
 	// Create empty commit (will create a diff that allows feedback PR to be created)
-	err = client.CreateEmptyCommit(ctx, *repository.Owner.Name, *repository.Name)
+	err = client.CreateEmptyCommit(ctx, *repository.Owner.Name, *repository.Name, layout.SubmissionBranch)
 	if err != nil {
 		fmt.Println("Error creating empty commit:", err)
 		return errs.InternalServerError()
@@ -120,6 +133,116 @@ func InitializePushEventRepo(ctx context.Context, client github.GitHubBaseClient
 	return nil
 }
 
+// Brings an initialized base repository in line with a changed branch layout, creating the branches it's missing
+// and recommitting the workflows that name them. Branches only the old layout had are left in place.
+func ApplyBranchLayout(ctx context.Context, client github.GitHubBaseClient, store storage.Storage, assignment models.AssignmentOutline, repoOwner, repoName, serverUrl string) error {
+	layout, err := GetBranchLayout(ctx, store, int64(assignment.ID))
+	if err != nil {
+		return err
+	}
+
+	ghRepo, err := client.GetRepository(ctx, repoOwner, repoName)
+	if err != nil {
+		return err
+	}
+	branches, err := listBranchNames(ctx, client, repoOwner, repoName)
+	if err != nil {
+		return err
+	}
+
+	if !utils.Contains(branches, layout.SubmissionBranch) || ghRepo.GetDefaultBranch() != layout.SubmissionBranch {
+		err = setUpSubmissionBranch(ctx, client, repoOwner, repoName, ghRepo.GetDefaultBranch(), layout)
+		if err != nil {
+			return err
+		}
+	}
+
+	createdFeedbackBranch := false
+	for _, branch := range layout.CreatedBranches() {
+		if utils.Contains(branches, branch) {
+			continue
+		}
+		if _, err := client.CreateBranch(ctx, repoOwner, repoName, layout.SubmissionBranch, branch); err != nil {
+			return err
+		}
+		createdFeedbackBranch = createdFeedbackBranch || branch == layout.FeedbackBranch
+	}
+
+	err = createWorkflows(ctx, client, store, &assignment, repoOwner, repoName, layout, serverUrl)
+	if err != nil {
+		return err
+	}
+
+	// A new feedback branch starts level with the submission branch, which needs a diff for the feedback PR
+	if createdFeedbackBranch {
+		return client.CreateEmptyCommit(ctx, repoOwner, repoName, layout.SubmissionBranch)
+	}
+	return nil
+}
+
+// Creates the submission branch from the repository's default branch if it's missing, and makes it the default
+// so forks of the repository start out on it
+func setUpSubmissionBranch(ctx context.Context, client github.GitHubBaseClient, repoOwner, repoName, defaultBranch string, layout models.BranchLayout) error {
+	branches, err := listBranchNames(ctx, client, repoOwner, repoName)
+	if err != nil {
+		return err
+	}
+	if !utils.Contains(branches, layout.SubmissionBranch) {
+		if _, err := client.CreateBranch(ctx, repoOwner, repoName, defaultBranch, layout.SubmissionBranch); err != nil {
+			return err
+		}
+	}
+
+	return client.SetDefaultBranch(ctx, repoOwner, repoName, layout.SubmissionBranch)
+}
+
+// Commits the deadline enforcement, PR enforcement and autograder workflows of an assignment to its submission branch
+func createWorkflows(ctx context.Context, client github.GitHubBaseClient, store storage.Storage, template *models.AssignmentOutline, repoOwner, repoName string, layout models.BranchLayout, serverUrl string) error {
+	if template.MainDueDate != nil {
+		// There is a deadline
+		err := client.CreateDeadlineEnforcement(ctx, template.MainDueDate, repoOwner, repoName, layout.SubmissionBranch, serverUrl)
+		if err != nil {
+			//@KHO-239
+			fmt.Println("Error creating deadline enforcement:", err)
+			return err
+		}
+	}
+
+	// Create PR Enforcement Action
+	err := client.CreatePREnforcement(ctx, repoOwner, repoName, layout.SubmissionBranch, layout.FeedbackBranch)
+	if err != nil {
+		fmt.Println("Error creating PR enforcement:", err)
+		return err
+	}
+
+	// Create the autograder workflow if the assignment is autograded
+	err = CreateAutograderWorkflow(ctx, client, store, int64(template.ID), repoOwner, repoName, serverUrl)
+	if err != nil {
+		fmt.Println("Error creating autograder workflow:", err)
+		return err
+	}
+
+	return nil
+}
+
+// Commits the autograder workflow of an assignment into its base repository, if the assignment has autograder tests
+func CreateAutograderWorkflow(ctx context.Context, client github.GitHubBaseClient, store storage.Storage, assignmentID int64, repoOwner, repoName, serverUrl string) error {
+	config, err := store.GetAutograderConfig(ctx, assignmentID)
+	if err != nil {
+		return err
+	}
+	if len(config.Tests) == 0 {
+		return nil
+	}
+
+	layout, err := GetBranchLayout(ctx, store, assignmentID)
+	if err != nil {
+		return err
+	}
+
+	return client.CreateAutograderWorkflow(ctx, config, repoOwner, repoName, layout.SubmissionBranch, serverUrl)
+}
+
 // Checks if a repository is initialized by checking if the initialized field in the database is true.
 func CheckBaseRepoInitialized(ctx context.Context, store storage.Storage, repoID int64) (bool, error) {
 	baseRepo, err := store.GetBaseRepoByID(ctx, repoID)
@@ -130,15 +253,13 @@ func CheckBaseRepoInitialized(ctx context.Context, store storage.Storage, repoID
 	return baseRepo.Initialized, nil
 }
 
-func CheckBranchesExist(ctx context.Context, client github.GitHubBaseClient, repoOwner string, repoName string) (bool, error) {
-	branches, err := client.ListBranches(ctx, repoOwner, repoName, &gh.ListOptions{})
+func CheckBranchesExist(ctx context.Context, client github.GitHubBaseClient, repoOwner string, repoName string, layout models.BranchLayout) (bool, error) {
+	branchNames, err := listBranchNames(ctx, client, repoOwner, repoName)
 	if err != nil {
 		return false, err
 	}
 
-	branchNames := utils.Map(branches, func(b *gh.Branch) string { return *b.Name })
-
-	for _, branch := range OtherRepoBranches {
+	for _, branch := range layout.CreatedBranches() {
 		if !utils.Contains(branchNames, branch) {
 			return false, nil
 		}
@@ -146,3 +267,12 @@ func CheckBranchesExist(ctx context.Context, client github.GitHubBaseClient, rep
 
 	return true, nil
 }
+
+func listBranchNames(ctx context.Context, client github.GitHubBaseClient, repoOwner string, repoName string) ([]string, error) {
+	branches, err := client.ListBranches(ctx, repoOwner, repoName, &gh.ListOptions{PerPage: 100})
+	if err != nil {
+		return nil, err
+	}
+
+	return utils.Map(branches, func(b *gh.Branch) string { return *b.Name }), nil
+}
@@ -0,0 +1,214 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/CamPlume1/khoury-classroom/internal/github"
+	"github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/CamPlume1/khoury-classroom/internal/storage"
+	gh "github.com/google/go-github/github"
+	"github.com/jackc/pgx/v5"
+)
+
+// Reconciles every member of a classroom against its organization's members, student team and invitations.
+// Corrects the statuses that have drifted, reports anyone the roster and org disagree about, and
+// optionally re-sends the invitations of classroom members whose invites expired. The report is saved.
+func ReconcileClassroomRoster(ctx context.Context, client github.GitHubBaseClient, store storage.Storage, classroom models.Classroom, resendExpired bool) (models.RosterReconciliation, error) {
+	report := models.RosterReconciliation{
+		ClassroomID:  classroom.ID,
+		Corrections:  []models.RosterStatusCorrection{},
+		Drift:        []models.RosterDrift{},
+		ReconciledAt: time.Now().UTC(),
+	}
+
+	classroomUsers, err := store.GetUsersInClassroom(ctx, classroom.ID)
+	if err != nil {
+		return report, err
+	}
+
+	orgMembers, err := client.GetOrgMembers(ctx, classroom.OrgName)
+	if err != nil {
+		return report, err
+	}
+	invitations, err := client.GetOrgInvitations(ctx, classroom.OrgName)
+	if err != nil {
+		return report, err
+	}
+	failedInvitations, err := client.GetFailedOrgInvitations(ctx, classroom.OrgName)
+	if err != nil {
+		return report, err
+	}
+
+	// members are matched by their GitHub user ID, which stays the same when they rename their account.
+	// Invitations only name the invitee's login, which is case insensitive
+	inOrg := make(map[int64]bool)
+	for _, member := range orgMembers {
+		inOrg[member.GetID()] = true
+	}
+	invited := make(map[string]bool)
+	for _, invitation := range invitations {
+		invited[strings.ToLower(invitation.GetLogin())] = true
+	}
+	failed := make(map[string]models.FailedOrgInvitation)
+	for _, invitation := range failedInvitations {
+		if invitation.Login != nil {
+			failed[strings.ToLower(*invitation.Login)] = invitation
+		}
+	}
+
+	var teamMembers []*gh.User
+	if classroom.StudentTeamName != nil {
+		studentTeam, err := client.GetTeamByName(ctx, classroom.OrgName, *classroom.StudentTeamName)
+		if err != nil {
+			return report, err
+		}
+		teamMembers, err = client.GetTeamMembers(ctx, *studentTeam.ID)
+		if err != nil {
+			return report, err
+		}
+	}
+	onTeam := make(map[int64]bool)
+	for _, member := range teamMembers {
+		onTeam[member.GetID()] = true
+	}
+
+	inClassroom := make(map[int64]bool)
+	for _, classroomUser := range classroomUsers {
+		login := strings.ToLower(classroomUser.GithubUsername)
+		inClassroom[classroomUser.GithubUserID] = true
+
+		// requests to join are waiting on a professor, not on GitHub
+		if classroomUser.Status == models.UserStatusRequested {
+			continue
+		}
+
+		status := models.UserStatusNotInOrg
+		if inOrg[classroomUser.GithubUserID] {
+			status = models.UserStatusActive
+		} else if invited[login] {
+			status = models.UserStatusOrgInvited
+		}
+
+		if status != classroomUser.Status {
+			_, err = store.ModifyUserStatus(ctx, classroom.ID, status, *classroomUser.ID)
+			if err != nil {
+				return report, err
+			}
+			report.Corrections = append(report.Corrections, models.RosterStatusCorrection{
+				UserID:         *classroomUser.ID,
+				GithubUsername: classroomUser.GithubUsername,
+				PreviousStatus: classroomUser.Status,
+				Status:         status,
+			})
+		}
+
+		if status == models.UserStatusActive && classroomUser.Role == models.Student && classroom.StudentTeamName != nil && !onTeam[classroomUser.GithubUserID] {
+			report.Drift = append(report.Drift, notOnStudentTeam(classroomUser))
+		}
+
+		if failedInvitation, ok := failed[login]; ok && status == models.UserStatusNotInOrg {
+			drift := models.RosterDrift{
+				Kind:           models.RosterDriftExpiredInvitation,
+				GithubUsername: classroomUser.GithubUsername,
+				GithubUserID:   classroomUser.GithubUserID,
+				UserID:         classroomUser.ID,
+				FailedAt:       &failedInvitation.FailedAt,
+			}
+			if resendExpired && (classroomUser.Role != models.Student || classroom.StudentTeamName != nil) {
+				_, err = InviteUserToOrganization(ctx, client, store, classroom, classroomUser.Role, classroomUser.User)
+				drift.Resent = err == nil
+			}
+			report.Drift = append(report.Drift, drift)
+		}
+	}
+
+	for _, member := range teamMembers {
+		if !inClassroom[member.GetID()] {
+			report.Drift = append(report.Drift, onTeamNotInClassroom(member))
+		}
+	}
+
+	return report, store.SaveRosterReconciliation(ctx, report)
+}
+
+// Updates a classroom's roster for one member added to or removed from its student team, rather than reconciling
+// the whole roster. Members of the team are in the org, so a classroom member added to it is active. What the latest
+// reconciliation report said about the member's place on the team is replaced, the rest is left to the next
+// full reconciliation.
+func ReconcileStudentTeamMember(ctx context.Context, store storage.Storage, classroom models.Classroom, member *gh.User, onTeam bool) error {
+	report, err := store.GetRosterReconciliation(ctx, classroom.ID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// the classroom is reconciled in full soon, nothing to report against until then
+		report = models.RosterReconciliation{ClassroomID: classroom.ID, Corrections: []models.RosterStatusCorrection{}}
+	} else if err != nil {
+		return err
+	}
+
+	var classroomUser *models.ClassroomUser
+	user, err := store.GetUserByGitHubID(ctx, member.GetID())
+	if err == nil {
+		found, err := store.GetUserInClassroom(ctx, classroom.ID, *user.ID)
+		if err == nil {
+			classroomUser = &found
+		} else if !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		return err
+	}
+
+	drift := []models.RosterDrift{}
+	for _, reported := range report.Drift {
+		if reported.GithubUserID == member.GetID() && (reported.Kind == models.RosterDriftOnTeamNotInClassroom || reported.Kind == models.RosterDriftNotOnStudentTeam) {
+			continue
+		}
+		drift = append(drift, reported)
+	}
+	report.Drift = drift
+
+	switch {
+	case classroomUser == nil:
+		if onTeam {
+			report.Drift = append(report.Drift, onTeamNotInClassroom(member))
+		}
+	case onTeam && (classroomUser.Status == models.UserStatusNotInOrg || classroomUser.Status == models.UserStatusOrgInvited):
+		_, err = store.ModifyUserStatus(ctx, classroom.ID, models.UserStatusActive, *classroomUser.ID)
+		if err != nil {
+			return err
+		}
+		report.Corrections = append(report.Corrections, models.RosterStatusCorrection{
+			UserID:         *classroomUser.ID,
+			GithubUsername: classroomUser.GithubUsername,
+			PreviousStatus: classroomUser.Status,
+			Status:         models.UserStatusActive,
+		})
+	case !onTeam && classroomUser.Status == models.UserStatusActive && classroomUser.Role == models.Student:
+		report.Drift = append(report.Drift, notOnStudentTeam(*classroomUser))
+	}
+
+	// a report that was never saved keeps the classroom due for its first full reconciliation
+	if report.ReconciledAt.IsZero() {
+		return nil
+	}
+	return store.SaveRosterReconciliation(ctx, report)
+}
+
+func onTeamNotInClassroom(member *gh.User) models.RosterDrift {
+	return models.RosterDrift{
+		Kind:           models.RosterDriftOnTeamNotInClassroom,
+		GithubUsername: member.GetLogin(),
+		GithubUserID:   member.GetID(),
+	}
+}
+
+func notOnStudentTeam(classroomUser models.ClassroomUser) models.RosterDrift {
+	return models.RosterDrift{
+		Kind:           models.RosterDriftNotOnStudentTeam,
+		GithubUsername: classroomUser.GithubUsername,
+		GithubUserID:   classroomUser.GithubUserID,
+		UserID:         classroomUser.ID,
+	}
+}
@@ -0,0 +1,49 @@
+package common
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/CamPlume1/khoury-classroom/internal/github"
+	"github.com/CamPlume1/khoury-classroom/internal/github/sharedclient"
+	"github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/CamPlume1/khoury-classroom/internal/storage"
+)
+
+// Whether a ruleset is one the app protects classroom repositories with
+func IsProtectionRuleset(name string) bool {
+	return name == sharedclient.BranchRulesetName || name == sharedclient.PushRulesetName
+}
+
+// Puts a protection ruleset back the way the app creates it, replacing whatever is left of the tampered one
+func ReapplyRuleset(ctx context.Context, client github.GitHubBaseClient, store storage.Storage, owner string, alert models.RepositoryAlert) error {
+	if alert.RulesetName == nil || !IsProtectionRuleset(*alert.RulesetName) {
+		return fmt.Errorf("alert %d isn't about a protection ruleset", alert.ID)
+	}
+
+	rulesets, err := client.ListRulesets(ctx, owner, alert.RepoName)
+	if err != nil {
+		return fmt.Errorf("error listing rulesets: %v", err)
+	}
+	for _, ruleset := range rulesets {
+		if ruleset.Name == *alert.RulesetName || (alert.RulesetID != nil && ruleset.ID == *alert.RulesetID) {
+			err = client.DeleteRuleset(ctx, owner, alert.RepoName, ruleset.ID)
+			if err != nil {
+				return fmt.Errorf("error deleting tampered ruleset: %v", err)
+			}
+		}
+	}
+
+	if *alert.RulesetName == sharedclient.PushRulesetName {
+		return client.CreatePushRuleset(ctx, owner, alert.RepoName)
+	}
+
+	if alert.AssignmentOutlineID == nil {
+		return fmt.Errorf("alert %d has no assignment", alert.ID)
+	}
+	layout, err := GetBranchLayout(ctx, store, *alert.AssignmentOutlineID)
+	if err != nil {
+		return err
+	}
+	return client.CreateBranchRuleset(ctx, owner, alert.RepoName, layout.SubmissionBranch, layout.FeedbackBranch)
+}
@@ -0,0 +1,122 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/CamPlume1/khoury-classroom/internal/github"
+	"github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/CamPlume1/khoury-classroom/internal/storage"
+	gh "github.com/google/go-github/github"
+	"github.com/jackc/pgx/v5"
+)
+
+// Pins a commit of a student work for grading and tags it in the student's repository.
+// The head of the work's submission branch is pinned when commitSHA is empty.
+func SnapshotWork(ctx context.Context, client github.GitHubBaseClient, store storage.Storage, work models.StudentWork, commitSHA string, reason models.SnapshotReason, createdBy *int64) (models.SubmissionSnapshot, error) {
+	if commitSHA == "" {
+		head, err := SubmissionBranchHead(ctx, client, store, work)
+		if err != nil {
+			return models.SubmissionSnapshot{}, err
+		}
+		commitSHA = head
+	}
+
+	snapshot := models.SubmissionSnapshot{
+		StudentWorkID: int64(work.ID),
+		CommitSHA:     commitSHA,
+		Reason:        reason,
+		CreatedBy:     createdBy,
+	}
+
+	// the tag only helps people browsing the repository find the graded commit, the snapshot stands without it.
+	// Pins in the same second are told apart by the commit they pin.
+	tagName := fmt.Sprintf("submission-%s-%.7s", time.Now().UTC().Format("20060102T150405Z"), commitSHA)
+	_, err := client.CreateTag(ctx, work.OrgName, work.RepoName, tagName, commitSHA)
+	if err != nil {
+		fmt.Println("Error tagging submission snapshot:", err)
+	} else {
+		snapshot.TagName = &tagName
+	}
+
+	return store.CreateSubmissionSnapshot(ctx, snapshot)
+}
+
+// Pins a student's submission for grading, unless its late policy no longer accepts submissions or grading already
+// started on an earlier snapshot. TAs see the newer commits and can pin them themselves.
+// A merged pull request is seen both as a push and as a merge, so a commit that's already pinned isn't pinned again.
+func SnapshotSubmission(ctx context.Context, client github.GitHubBaseClient, store storage.Storage, work models.StudentWork, commitSHA string) error {
+	outcome, err := EvaluateWorkLateness(ctx, store, work, time.Now().UTC())
+	if err != nil || !outcome.AcceptsSubmissions {
+		return err
+	}
+
+	started, err := gradingStarted(ctx, store, work.ID)
+	if err != nil || started {
+		return err
+	}
+
+	pinned, err := GetSnapshotSHA(ctx, store, work.ID)
+	if err != nil || (commitSHA != "" && pinned == commitSHA) {
+		return err
+	}
+
+	_, err = SnapshotWork(ctx, client, store, work, commitSHA, models.SnapshotReasonSubmitted, nil)
+	return err
+}
+
+// Gets the commit a student work is graded against, or an empty string if it hasn't been snapshotted
+func GetSnapshotSHA(ctx context.Context, store storage.Storage, studentWorkID int) (string, error) {
+	snapshot, err := store.GetSubmissionSnapshot(ctx, int64(studentWorkID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return snapshot.CommitSHA, nil
+}
+
+// Gets the SHA of the latest commit on a student work's submission branch
+func SubmissionBranchHead(ctx context.Context, client github.GitHubBaseClient, store storage.Storage, work models.StudentWork) (string, error) {
+	layout, err := GetBranchLayout(ctx, store, int64(work.AssignmentOutlineID))
+	if err != nil {
+		return "", err
+	}
+
+	return BranchHead(ctx, client, work.OrgName, work.RepoName, layout.SubmissionBranch)
+}
+
+// Gets the SHA of the latest commit on a branch of a repository
+func BranchHead(ctx context.Context, client github.GitHubBaseClient, owner string, repo string, branch string) (string, error) {
+	var opts gh.CommitsListOptions
+	opts.SHA = branch
+	opts.PerPage = 1
+	commits, err := client.ListCommits(ctx, owner, repo, &opts)
+	if err != nil {
+		return "", err
+	}
+	if len(commits) == 0 {
+		return "", fmt.Errorf("branch %s has no commits", branch)
+	}
+	return commits[0].GetSHA(), nil
+}
+
+// grading has started once a work has any feedback, draft or published
+func gradingStarted(ctx context.Context, store storage.Storage, studentWorkID int) (bool, error) {
+	feedback, err := store.GetFeedbackOnWork(ctx, studentWorkID)
+	if err != nil {
+		return false, err
+	}
+	if len(feedback) > 0 {
+		return true, nil
+	}
+
+	_, err = store.GetReviewDraft(ctx, int64(studentWorkID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
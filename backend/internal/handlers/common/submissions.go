@@ -0,0 +1,52 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/CamPlume1/khoury-classroom/internal/storage"
+	"github.com/jackc/pgx/v5"
+)
+
+// Applies the late policy of a student work's assignment to a submission made at the given time
+func EvaluateWorkLateness(ctx context.Context, store storage.Storage, work models.StudentWork, submittedAt time.Time) (models.LateOutcome, error) {
+	var latePolicy *models.LatePolicy
+	policy, err := store.GetLatePolicy(ctx, int64(work.AssignmentOutlineID))
+	if err == nil {
+		latePolicy = &policy
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		return models.LateOutcome{}, err
+	}
+
+	dueDate, err := store.GetDeadlineForRepo(ctx, work.RepoName)
+	if err != nil {
+		return models.LateOutcome{}, err
+	}
+
+	lateTokens, err := store.GetLateTokensUsedOnWork(ctx, int64(work.ID))
+	if err != nil {
+		return models.LateOutcome{}, err
+	}
+
+	return models.EvaluateLateness(latePolicy, dueDate, lateTokens, submittedAt), nil
+}
+
+// Whether a commit that reached the submission branch at the given time becomes a student work's submission.
+// Graded works keep the submission they were graded on, redelivered webhooks can't move a submission back to an
+// older commit, and commits the late policy no longer accepts don't move the time the work's penalty is based on.
+func AcceptsSubmission(ctx context.Context, store storage.Storage, work models.StudentWork, submittedAt time.Time) (bool, error) {
+	if work.WorkState == models.WorkStateGradingCompleted || work.WorkState == models.WorkStateGradePublished {
+		return false, nil
+	}
+	if work.SubmittedAt != nil && submittedAt.Before(*work.SubmittedAt) {
+		return false, nil
+	}
+
+	outcome, err := EvaluateWorkLateness(ctx, store, work, submittedAt)
+	if err != nil {
+		return false, err
+	}
+	return outcome.AcceptsSubmissions, nil
+}
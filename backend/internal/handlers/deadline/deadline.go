@@ -1,10 +1,13 @@
 package deadline
 
 import (
+	"errors"
 	"fmt"
 	"time"
 
+	"github.com/CamPlume1/khoury-classroom/internal/models"
 	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
 )
 
 func (s *DeadlineService) DeadlineHandler(c *fiber.Ctx) error {
@@ -20,7 +23,38 @@ func (s *DeadlineService) DeadlineHandler(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).JSON("Error Retrieving Assignment Deadline")
 	}
 
-	// return true if the repo is overdue, false if not
-	return c.Status(fiber.StatusOK).JSON(map[string]bool{"overdue": due.Before(time.Now())})
+	outcome, err := s.evaluateSubmission(c, repo, due)
+	if err != nil {
+		fmt.Println("Error evaluating late policy:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON("Error Retrieving Assignment Deadline")
+	}
+
+	// overdue once submissions are no longer accepted, late submissions a policy allows only carry a penalty
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"overdue": !outcome.AcceptsSubmissions,
+		"outcome": outcome,
+	})
+}
+
+// Applies the assignment's late policy to a submission made now
+func (s *DeadlineService) evaluateSubmission(c *fiber.Ctx, repo string, due *time.Time) (models.LateOutcome, error) {
+	work, err := s.store.GetWorkByRepoName(c.Context(), repo)
+	if err != nil {
+		return models.LateOutcome{}, err
+	}
+
+	var latePolicy *models.LatePolicy
+	policy, err := s.store.GetLatePolicy(c.Context(), int64(work.AssignmentOutlineID))
+	if err == nil {
+		latePolicy = &policy
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		return models.LateOutcome{}, err
+	}
+
+	lateTokens, err := s.store.GetLateTokensUsedOnWork(c.Context(), int64(work.ID))
+	if err != nil {
+		return models.LateOutcome{}, err
+	}
 
-}
\ No newline at end of file
+	return models.EvaluateLateness(latePolicy, due, lateTokens, time.Now()), nil
+}
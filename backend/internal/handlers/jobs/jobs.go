@@ -0,0 +1,96 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/CamPlume1/khoury-classroom/internal/errs"
+	"github.com/CamPlume1/khoury-classroom/internal/middleware"
+	"github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+)
+
+const maxListedJobs = 100
+
+// Helper function for checking the current user is one of the configured admins of the deployment
+func (s *JobService) requireAdmin(c *fiber.Ctx) error {
+	_, currentGitHubUser, _, err := middleware.GetClientAndUser(c, s.store, s.userCfg)
+	if err != nil {
+		return err
+	}
+
+	if !s.admins.IsAdmin(currentGitHubUser.ID) {
+		return errs.InsufficientPermissionsError()
+	}
+	return nil
+}
+
+// Returns the latest scheduled jobs, e.g. ?state=FAILED&job_type=snapshot_deadlines to find the ones to retry.
+func (s *JobService) getScheduledJobs() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if err := s.requireAdmin(c); err != nil {
+			return err
+		}
+
+		var state *models.ScheduledJobState
+		if c.Query("state") != "" {
+			queryState := models.ScheduledJobState(c.Query("state"))
+			switch queryState {
+			case models.ScheduledJobStatePending, models.ScheduledJobStateRunning,
+				models.ScheduledJobStateSucceeded, models.ScheduledJobStateFailed:
+				state = &queryState
+			default:
+				return errs.BadRequest(fmt.Errorf("unknown job state %q", queryState))
+			}
+		}
+
+		var jobType *string
+		if c.Query("job_type") != "" {
+			queryJobType := c.Query("job_type")
+			jobType = &queryJobType
+		}
+
+		jobs, err := s.store.ListScheduledJobs(c.Context(), jobType, state, maxListedJobs)
+		if err != nil {
+			return errs.InternalServerError()
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{"jobs": jobs})
+	}
+}
+
+// Puts a failed scheduled job back on the schedule to run right away, with a fresh set of retries.
+// Recurring jobs aren't retried, their next run is already scheduled.
+func (s *JobService) retryScheduledJob() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if err := s.requireAdmin(c); err != nil {
+			return err
+		}
+
+		jobID, err := strconv.ParseInt(c.Params("job_id"), 10, 64)
+		if err != nil {
+			return errs.BadRequest(err)
+		}
+
+		job, err := s.store.GetScheduledJob(c.Context(), jobID)
+		if err != nil {
+			return errs.NotFound("scheduled job", "id", c.Params("job_id"))
+		}
+		if job.Recurring {
+			return errs.BadRequest(errors.New("recurring jobs run again on their own schedule"))
+		}
+
+		job, err = s.store.RequeueScheduledJob(c.Context(), job.ID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return errs.BadRequest(errors.New("only failed jobs can be retried"))
+		}
+		if err != nil {
+			return errs.InternalServerError()
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{"job": job})
+	}
+}
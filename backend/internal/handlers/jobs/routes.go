@@ -0,0 +1,19 @@
+package jobs
+
+import (
+	"github.com/CamPlume1/khoury-classroom/internal/middleware"
+	"github.com/CamPlume1/khoury-classroom/internal/types"
+	"github.com/gofiber/fiber/v2"
+)
+
+func Routes(router fiber.Router, params types.Params) {
+	service := newJobService(params.Store, &params.UserCfg, params.Admins)
+
+	protected := router.Group("/jobs").Use(middleware.Protected(service.userCfg.JWTSecret))
+
+	// Get the latest scheduled jobs
+	protected.Get("/", service.getScheduledJobs())
+
+	// Run a failed scheduled job again
+	protected.Post("/:job_id/retry", service.retryScheduledJob())
+}
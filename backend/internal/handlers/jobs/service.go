@@ -0,0 +1,16 @@
+package jobs
+
+import (
+	"github.com/CamPlume1/khoury-classroom/internal/config"
+	"github.com/CamPlume1/khoury-classroom/internal/storage"
+)
+
+type JobService struct {
+	store   storage.Storage
+	userCfg *config.GitHubUserClient
+	admins  config.Admins
+}
+
+func newJobService(store storage.Storage, userCfg *config.GitHubUserClient, admins config.Admins) *JobService {
+	return &JobService{store: store, userCfg: userCfg, admins: admins}
+}
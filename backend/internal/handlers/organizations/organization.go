@@ -34,8 +34,6 @@ func (service *OrganizationService) GetInstalledOrgs() fiber.Handler {
 		if err != nil {
 			return errs.GithubClientError(err)
 		}
-		// Get the app client
-		appClient := service.appClient
 
 		// Get the list of organizations the user is part of
 		userOrgs, err := userClient.GetUserOrgs(c.Context())
@@ -43,19 +41,19 @@ func (service *OrganizationService) GetInstalledOrgs() fiber.Handler {
 			return errs.GithubAPIError(err)
 		}
 
-		// Get the list of installations of the GitHub app
-		appInstallations, err := appClient.ListInstallations(c.Context())
+		// Get the installations of the GitHub app
+		appInstallations, err := service.store.ListAppInstallations(c.Context())
 		if err != nil {
-			return errs.GithubAPIError(err)
+			return err
 		}
 
-		// Filter the organizations to include only those with the app installed
+		// Filter the organizations to include only those with the app installed (and not suspended)
 		var orgsWithAppInstalled []models.Organization
 		var orgsWithoutAppInstalled []models.Organization
 		for _, org := range userOrgs {
 			found := false
 			for _, installation := range appInstallations {
-				if *installation.Account.Login == org.Login {
+				if installation.AccountID == org.ID && installation.IsActive() {
 					orgsWithAppInstalled = append(orgsWithAppInstalled, org)
 					found = true
 					break
@@ -7,7 +7,7 @@ import (
 )
 
 func Routes(router fiber.Router, params types.Params) {
-	service := newRubricService(params.Store, &params.UserCfg)
+	service := newRubricService(params.Store, &params.UserCfg, params.GitHubApp)
 
 	RubricRoutes(router, service)
 }
@@ -20,5 +20,41 @@ func RubricRoutes(router fiber.Router, service *RubricService) fiber.Router {
 	route.Get("/rubric/:rubric_id", service.GetRubricByID())
 	route.Put("/rubric/:rubric_id", service.UpdateRubric())
 
+	// Soft delete a rubric
+	route.Delete("/rubric/:rubric_id", service.DeleteRubric())
+
+	// Order a rubric's sections and items
+	route.Put("/rubric/:rubric_id/order", service.ReorderRubric())
+
+	// Add a section to a rubric
+	route.Post("/rubric/:rubric_id/sections", service.CreateRubricSection())
+
+	// Update the name and caps of a rubric section
+	route.Put("/rubric/:rubric_id/sections/:section_id", service.UpdateRubricSection())
+
+	// Remove a section from a rubric, keeping its items
+	route.Delete("/rubric/:rubric_id/sections/:section_id", service.DeleteRubricSection())
+
+	// Get the versions of a rubric
+	route.Get("/rubric/:rubric_id/versions", service.GetRubricVersions())
+
+	// Get every version of a rubric item
+	route.Get("/rubric/:rubric_id/items/:item_id/history", service.GetRubricItemHistory())
+
+	// Preview how propagating a rubric item's current points to past feedback changes scores
+	route.Get("/rubric/:rubric_id/items/:item_id/propagate", service.PreviewRubricItemPropagation())
+
+	// Propagate a rubric item's current points to past feedback
+	route.Post("/rubric/:rubric_id/items/:item_id/propagate", service.PropagateRubricItem())
+
+	// Copy a rubric into another classroom of the same organization
+	route.Post("/rubric/:rubric_id/clone", service.CloneRubric())
+
+	// Export a rubric as JSON or CSV
+	route.Get("/rubric/:rubric_id/export", service.ExportRubric())
+
+	// Import a rubric into a classroom from JSON or CSV
+	route.Post("/classroom/:classroom_id/import", service.ImportRubric())
+
 	return route
 }
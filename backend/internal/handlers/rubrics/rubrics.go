@@ -1,12 +1,20 @@
 package rubrics
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"slices"
 	"strconv"
 
 	"github.com/CamPlume1/khoury-classroom/internal/errs"
+	"github.com/CamPlume1/khoury-classroom/internal/handlers/common"
+	"github.com/CamPlume1/khoury-classroom/internal/middleware"
 	"github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/CamPlume1/khoury-classroom/internal/storage"
 	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
 )
 
 func (s *RubricService) CreateRubric() fiber.Handler {
@@ -61,6 +69,11 @@ func (s *RubricService) GetRubricByID() fiber.Handler {
 			return errs.InternalServerError()
 		}
 
+		sections, err := s.store.GetRubricSections(c.Context(), rubricID)
+		if err != nil {
+			return errs.InternalServerError()
+		}
+
 		rubricItems, err := s.store.GetRubricItems(c.Context(), rubricID)
 		if err != nil {
 			return errs.InternalServerError()
@@ -68,6 +81,7 @@ func (s *RubricService) GetRubricByID() fiber.Handler {
 
 		fullRubric := models.FullRubric{
 			Rubric:      rubric,
+			Sections:    sections,
 			RubricItems: rubricItems,
 		}
 
@@ -85,39 +99,105 @@ func (s *RubricService) UpdateRubric() fiber.Handler {
 		}
 
 		var newRubricData models.FullRubric
-		error := c.BodyParser(&newRubricData)
-		if error != nil {
+		if err := c.BodyParser(&newRubricData); err != nil {
 			return errs.InvalidRequestBody(models.FullRubric{})
 		}
 
-		updatedRubric, err := s.store.UpdateRubric(c.Context(), rubricID, newRubricData.Rubric)
+		rubric, err := s.store.GetRubric(c.Context(), rubricID)
 		if err != nil {
-			return errs.InternalServerError()
+			return errs.NotFound("rubric", "id", rubricID)
+		}
+		if rubric.DeletedAt != nil {
+			return errs.BadRequest(errors.New("cannot update a deleted rubric"))
 		}
 
-		var updatedItems []models.RubricItem
+		// items can only be placed in the rubric's own sections
+		sections, err := s.store.GetRubricSections(c.Context(), rubricID)
+		if err != nil {
+			return errs.InternalServerError()
+		}
 		for _, item := range newRubricData.RubricItems {
-			if item.ID == 0 {
-				item.RubricID = updatedRubric.ID
-				newItem, err := s.store.AddItemToRubric(c.Context(), item)
-				if err != nil {
-					return errs.InternalServerError()
+			if item.SectionID != nil && !slices.ContainsFunc(sections, func(section models.RubricSection) bool { return section.ID == *item.SectionID }) {
+				return errs.BadRequest(fmt.Errorf("rubric has no section %d", *item.SectionID))
+			}
+		}
+
+		// items that feedback was given with are revised in a new version instead of edited in place
+		currentItems, err := s.store.GetRubricItems(c.Context(), rubricID)
+		if err != nil {
+			return errs.InternalServerError()
+		}
+		gradedItemIDs, err := s.store.GetGradedRubricItemIDs(c.Context(), rubricID)
+		if err != nil {
+			return errs.InternalServerError()
+		}
+
+		_, _, user, err := middleware.GetClientAndUser(c, s.store, s.userCfg)
+		if err != nil {
+			return errs.AuthenticationError()
+		}
+
+		var updatedFullRubric models.FullRubric
+		err = s.store.WithTx(c.Context(), func(store storage.Storage) error {
+			updatedRubric, err := store.UpdateRubric(c.Context(), rubricID, newRubricData.Rubric)
+			if err != nil {
+				return err
+			}
+
+			var updatedItems []models.RubricItem
+			var revisedItems []models.RubricItem
+			for _, item := range newRubricData.RubricItems {
+				if item.ID == 0 {
+					item.RubricID = updatedRubric.ID
+					newItem, err := store.AddItemToRubric(c.Context(), item)
+					if err != nil {
+						return err
+					}
+					updatedItems = append(updatedItems, newItem)
+
+				} else {
+					index := slices.IndexFunc(currentItems, func(current models.RubricItem) bool { return current.ID == item.ID })
+					if index < 0 {
+						return errs.BadRequest(fmt.Errorf("rubric item %d is not part of the current version of the rubric", item.ID))
+					}
+					if !item.Deleted && slices.Contains(gradedItemIDs, item.ID) && currentItems[index].RevisedBy(item) {
+						revisedItems = append(revisedItems, item)
+						continue
+					}
+
+					item.RubricID = rubricID
+					updatedItem, err := store.UpdateRubricItem(c.Context(), item)
+					if err != nil {
+						return err
+					}
+					updatedItems = append(updatedItems, updatedItem)
 				}
-				updatedItems = append(updatedItems, newItem)
+			}
 
-			} else {
-				item.RubricID = rubricID
-				updatedItem, err := s.store.UpdateRubricItem(c.Context(), item)
+			if len(revisedItems) > 0 {
+				version, newItems, err := store.ReviseRubricItems(c.Context(), rubricID, *user.ID, revisedItems)
 				if err != nil {
-					return errs.InternalServerError()
+					return err
 				}
-				updatedItems = append(updatedItems, updatedItem)
+				updatedRubric.Version = version
+				updatedItems = append(updatedItems, newItems...)
 			}
-		}
 
-		updatedFullRubric := models.FullRubric{
-			Rubric:      updatedRubric,
-			RubricItems: updatedItems,
+			updatedFullRubric = models.FullRubric{
+				Rubric:      updatedRubric,
+				Sections:    sections,
+				RubricItems: updatedItems,
+			}
+			return auditRubric(c, store, rubric, models.GradingAuditRubricUpdated, nil,
+				models.FullRubric{Rubric: rubric, Sections: sections, RubricItems: currentItems}, updatedFullRubric)
+		})
+		var apiErr errs.APIError
+		if errors.As(err, &apiErr) {
+			return apiErr
+		}
+		if err != nil {
+			fmt.Println("Error updating rubric:", err)
+			return errs.InternalServerError()
 		}
 
 		return c.Status(http.StatusOK).JSON(fiber.Map{
@@ -125,3 +205,334 @@ func (s *RubricService) UpdateRubric() fiber.Handler {
 		})
 	}
 }
+
+// Helper function for getting the rubric in the route, checking the user's role in its classroom
+func (s *RubricService) getRubric(c *fiber.Ctx, role models.ClassroomRole) (models.Rubric, error) {
+	rubricID, err := strconv.ParseInt(c.Params("rubric_id"), 10, 64)
+	if err != nil {
+		return models.Rubric{}, errs.BadRequest(err)
+	}
+
+	rubric, err := s.store.GetRubric(c.Context(), rubricID)
+	if err != nil || rubric.DeletedAt != nil {
+		return models.Rubric{}, errs.NotFound("rubric", "id", rubricID)
+	}
+
+	_, err = s.RequireAtLeastRole(c, rubric.ClassroomID, role)
+	if err != nil {
+		return models.Rubric{}, err
+	}
+	return rubric, nil
+}
+
+// Helper function for getting a rubric with its sections and items
+func getFullRubric(ctx context.Context, store storage.Storage, rubric models.Rubric) (models.FullRubric, error) {
+	sections, err := store.GetRubricSections(ctx, rubric.ID)
+	if err != nil {
+		return models.FullRubric{}, err
+	}
+	items, err := store.GetRubricItems(ctx, rubric.ID)
+	if err != nil {
+		return models.FullRubric{}, err
+	}
+	return models.FullRubric{Rubric: rubric, Sections: sections, RubricItems: items}, nil
+}
+
+// Records a change to a rubric in the grading audit log
+func auditRubric(c *fiber.Ctx, store storage.Storage, rubric models.Rubric, action models.GradingAuditAction, subjectID *int64, before any, after any) error {
+	return recordAudit(c, store, models.GradingAuditEntry{
+		Action:      action,
+		ClassroomID: rubric.ClassroomID,
+		RubricID:    &rubric.ID,
+		SubjectID:   subjectID,
+	}, before, after)
+}
+
+// Records a grading audit entry on behalf of the user making the request
+func recordAudit(c *fiber.Ctx, store storage.Storage, entry models.GradingAuditEntry, before any, after any) error {
+	if userID, ok := c.Locals("userID").(int64); ok {
+		entry.ActorUserID = &userID
+	}
+	return common.RecordGradingAudit(c.Context(), store, entry, before, after)
+}
+
+// Helper function for finding one of a rubric's sections
+func (s *RubricService) getRubricSection(c *fiber.Ctx, rubric models.Rubric, sectionID int64) (models.RubricSection, error) {
+	sections, err := s.store.GetRubricSections(c.Context(), rubric.ID)
+	if err != nil {
+		return models.RubricSection{}, errs.InternalServerError()
+	}
+	index := slices.IndexFunc(sections, func(section models.RubricSection) bool { return section.ID == sectionID })
+	if index < 0 {
+		return models.RubricSection{}, errs.NotFound("rubric section", "id", sectionID)
+	}
+	return sections[index], nil
+}
+
+// Soft deletes a rubric. Assignments and feedback already using it keep it, but it's no longer listed in its classroom.
+func (s *RubricService) DeleteRubric() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		rubric, err := s.getRubric(c, models.Professor)
+		if err != nil {
+			return err
+		}
+
+		err = s.store.WithTx(c.Context(), func(store storage.Storage) error {
+			if err := store.DeleteRubric(c.Context(), rubric.ID); err != nil {
+				return err
+			}
+			return auditRubric(c, store, rubric, models.GradingAuditRubricDeleted, nil, rubric, nil)
+		})
+		if err != nil {
+			return errs.InternalServerError()
+		}
+
+		return c.SendStatus(http.StatusOK)
+	}
+}
+
+// Orders a rubric's sections and items by their position in the request, moving items between sections.
+// Graded items can be reordered but not moved out of their sections.
+func (s *RubricService) ReorderRubric() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		rubric, err := s.getRubric(c, models.TA)
+		if err != nil {
+			return err
+		}
+
+		var requestBody models.RubricOrderRequest
+		if err := c.BodyParser(&requestBody); err != nil {
+			return errs.InvalidRequestBody(requestBody)
+		}
+
+		// moving items between capped sections can change scores, so graded items stay in their sections
+		before, err := getFullRubric(c.Context(), s.store, rubric)
+		if err != nil {
+			return errs.InternalServerError()
+		}
+		gradedItemSections, err := s.getGradedItemSections(c.Context(), rubric.ID)
+		if err != nil {
+			return errs.InternalServerError()
+		}
+		for _, item := range requestBody.Items {
+			if current, ok := gradedItemSections[item.ID]; ok && !sameSection(current, item.SectionID) {
+				return errs.BadRequest(fmt.Errorf("rubric item %d has been graded with and can't move between sections", item.ID))
+			}
+		}
+
+		var fullRubric models.FullRubric
+		err = s.store.WithTx(c.Context(), func(store storage.Storage) error {
+			if err := store.ReorderRubric(c.Context(), rubric.ID, requestBody); err != nil {
+				return err
+			}
+
+			fullRubric, err = getFullRubric(c.Context(), store, rubric)
+			if err != nil {
+				return err
+			}
+			return auditRubric(c, store, rubric, models.GradingAuditRubricUpdated, nil, before, fullRubric)
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			return errs.BadRequest(errors.New("every section and item must belong to the rubric"))
+		}
+		if err != nil {
+			return errs.InternalServerError()
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"full_rubric": fullRubric,
+		})
+	}
+}
+
+// Helper function for finding the section each graded item of a rubric is in. Section caps and membership aren't
+// versioned, so changing them for graded items would silently change the scores of feedback already given.
+func (s *RubricService) getGradedItemSections(ctx context.Context, rubricID int64) (map[int64]*int64, error) {
+	items, err := s.store.GetRubricItems(ctx, rubricID)
+	if err != nil {
+		return nil, err
+	}
+	gradedItemIDs, err := s.store.GetGradedRubricItemIDs(ctx, rubricID)
+	if err != nil {
+		return nil, err
+	}
+
+	sections := make(map[int64]*int64)
+	for _, item := range items {
+		if slices.Contains(gradedItemIDs, item.ID) {
+			sections[item.ID] = item.SectionID
+		}
+	}
+	return sections, nil
+}
+
+// Helper function for checking whether any graded item is in a section
+func hasGradedItems(gradedItemSections map[int64]*int64, sectionID int64) bool {
+	for _, itemSectionID := range gradedItemSections {
+		if itemSectionID != nil && *itemSectionID == sectionID {
+			return true
+		}
+	}
+	return false
+}
+
+// Helper function for comparing optional caps
+func sameCap(a *int, b *int) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
+
+// Helper function for comparing the sections items are in, nil for items outside of any section
+func sameSection(a *int64, b *int64) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
+
+// Helper function for checking that a section's caps can be met
+func validateSectionCaps(maxPoints *int, minPoints *int) error {
+	if maxPoints != nil && minPoints != nil && *minPoints > *maxPoints {
+		return errs.InvalidRequestData(map[string]string{"min_points": "must not be more than max_points"})
+	}
+	return nil
+}
+
+// Adds a section to the end of a rubric.
+func (s *RubricService) CreateRubricSection() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		rubric, err := s.getRubric(c, models.TA)
+		if err != nil {
+			return err
+		}
+
+		var section models.RubricSection
+		if err := c.BodyParser(&section); err != nil || section.Name == "" {
+			return errs.InvalidRequestBody(models.RubricSection{})
+		}
+		if err := validateSectionCaps(section.MaxPoints, section.MinPoints); err != nil {
+			return err
+		}
+
+		sections, err := s.store.GetRubricSections(c.Context(), rubric.ID)
+		if err != nil {
+			return errs.InternalServerError()
+		}
+		section.RubricID = rubric.ID
+		section.Position = len(sections)
+
+		err = s.store.WithTx(c.Context(), func(store storage.Storage) error {
+			section, err = store.CreateRubricSection(c.Context(), section)
+			if err != nil {
+				return err
+			}
+			return auditRubric(c, store, rubric, models.GradingAuditRubricSectionCreated, &section.ID, nil, section)
+		})
+		if err != nil {
+			return errs.InternalServerError()
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"section": section,
+		})
+	}
+}
+
+// Updates the name and caps of a rubric section. The caps of sections with graded items can't change.
+func (s *RubricService) UpdateRubricSection() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		rubric, err := s.getRubric(c, models.TA)
+		if err != nil {
+			return err
+		}
+		sectionID, err := strconv.ParseInt(c.Params("section_id"), 10, 64)
+		if err != nil {
+			return errs.BadRequest(err)
+		}
+
+		var section models.RubricSection
+		if err := c.BodyParser(&section); err != nil || section.Name == "" {
+			return errs.InvalidRequestBody(models.RubricSection{})
+		}
+		if err := validateSectionCaps(section.MaxPoints, section.MinPoints); err != nil {
+			return err
+		}
+		section.ID = sectionID
+		section.RubricID = rubric.ID
+
+		before, err := s.getRubricSection(c, rubric, sectionID)
+		if err != nil {
+			return err
+		}
+
+		if !sameCap(before.MaxPoints, section.MaxPoints) || !sameCap(before.MinPoints, section.MinPoints) {
+			gradedItemSections, err := s.getGradedItemSections(c.Context(), rubric.ID)
+			if err != nil {
+				return errs.InternalServerError()
+			}
+			if hasGradedItems(gradedItemSections, sectionID) {
+				return errs.BadRequest(errors.New("the section's items have been graded with, so its caps can't change"))
+			}
+		}
+
+		err = s.store.WithTx(c.Context(), func(store storage.Storage) error {
+			section, err = store.UpdateRubricSection(c.Context(), section)
+			if err != nil {
+				return err
+			}
+			return auditRubric(c, store, rubric, models.GradingAuditRubricSectionUpdated, &section.ID, before, section)
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			return errs.NotFound("rubric section", "id", sectionID)
+		}
+		if err != nil {
+			return errs.InternalServerError()
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"section": section,
+		})
+	}
+}
+
+// Removes a section from a rubric. Its items stay on the rubric, outside of any section.
+// Capped sections with graded items can't be removed.
+func (s *RubricService) DeleteRubricSection() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		rubric, err := s.getRubric(c, models.TA)
+		if err != nil {
+			return err
+		}
+		sectionID, err := strconv.ParseInt(c.Params("section_id"), 10, 64)
+		if err != nil {
+			return errs.BadRequest(err)
+		}
+
+		before, err := s.getRubricSection(c, rubric, sectionID)
+		if err != nil {
+			return err
+		}
+
+		// the section's items would no longer be capped
+		if before.MaxPoints != nil || before.MinPoints != nil {
+			gradedItemSections, err := s.getGradedItemSections(c.Context(), rubric.ID)
+			if err != nil {
+				return errs.InternalServerError()
+			}
+			if hasGradedItems(gradedItemSections, sectionID) {
+				return errs.BadRequest(errors.New("the section's items have been graded with, so it can't be removed while it has caps"))
+			}
+		}
+
+		err = s.store.WithTx(c.Context(), func(store storage.Storage) error {
+			if err := store.DeleteRubricSection(c.Context(), rubric.ID, sectionID); err != nil {
+				return err
+			}
+			return auditRubric(c, store, rubric, models.GradingAuditRubricSectionDeleted, &sectionID, before, nil)
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			return errs.NotFound("rubric section", "id", sectionID)
+		}
+		if err != nil {
+			return errs.InternalServerError()
+		}
+
+		return c.SendStatus(http.StatusOK)
+	}
+}
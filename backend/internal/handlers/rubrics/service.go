@@ -2,14 +2,35 @@ package rubrics
 
 import (
 	"github.com/CamPlume1/khoury-classroom/internal/config"
+	"github.com/CamPlume1/khoury-classroom/internal/github"
+	"github.com/CamPlume1/khoury-classroom/internal/middleware"
 	"github.com/CamPlume1/khoury-classroom/internal/storage"
 )
 
 type RubricService struct {
-	store   storage.Storage
-	userCfg *config.GitHubUserClient
+	store     storage.Storage
+	userCfg   *config.GitHubUserClient
+	appClient github.GitHubAppClient
+	middleware.RoleChecker[RubricService]
 }
 
-func newRubricService(store storage.Storage, userCfg *config.GitHubUserClient) *RubricService {
-	return &RubricService{store: store, userCfg: userCfg}
+func newRubricService(store storage.Storage, userCfg *config.GitHubUserClient, appClient github.GitHubAppClient) *RubricService {
+	service := &RubricService{store: store, userCfg: userCfg, appClient: appClient}
+	service.RoleChecker = middleware.RoleChecker[RubricService]{Checkable: service}
+	return service
+}
+
+// Getter for store field
+func (s *RubricService) GetStore() storage.Storage {
+	return s.store
+}
+
+// Getter for appClient field
+func (s *RubricService) GetAppClient() github.GitHubAppClient {
+	return s.appClient
+}
+
+// Getter for userCfg field
+func (s *RubricService) GetUserCfg() *config.GitHubUserClient {
+	return s.userCfg
 }
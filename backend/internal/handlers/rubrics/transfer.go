@@ -0,0 +1,359 @@
+package rubrics
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/CamPlume1/khoury-classroom/internal/errs"
+	"github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/gofiber/fiber/v2"
+)
+
+// The columns of a rubric CSV, one row per item
+var rubricCSVHeader = []string{"section", "section_max_points", "section_min_points", "point_value", "explanation"}
+
+// Copies a rubric into a classroom of the same organization. Only reusable rubrics can be copied out of their own classroom.
+func (s *RubricService) CloneRubric() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		rubricID, err := strconv.ParseInt(c.Params("rubric_id"), 10, 64)
+		if err != nil {
+			return errs.BadRequest(err)
+		}
+
+		var requestBody models.CloneRubricRequest
+		if err := c.BodyParser(&requestBody); err != nil || requestBody.ClassroomID == 0 {
+			return errs.InvalidRequestBody(models.CloneRubricRequest{})
+		}
+
+		_, err = s.RequireAtLeastRole(c, requestBody.ClassroomID, models.TA)
+		if err != nil {
+			return err
+		}
+
+		rubric, err := s.store.GetRubric(c.Context(), rubricID)
+		if err != nil || rubric.DeletedAt != nil {
+			return errs.NotFound("rubric", "id", rubricID)
+		}
+		classroom, err := s.store.GetClassroomByID(c.Context(), requestBody.ClassroomID)
+		if err != nil {
+			return errs.NotFound("classroom", "id", requestBody.ClassroomID)
+		}
+		if classroom.OrgID != rubric.OrgID {
+			return errs.BadRequest(errors.New("rubrics can only be copied within their organization"))
+		}
+		if !rubric.Reusable && rubric.ClassroomID != classroom.ID {
+			return errs.BadRequest(errors.New("only reusable rubrics can be copied to another classroom"))
+		}
+
+		fullRubric, err := getFullRubric(c.Context(), s.store, rubric)
+		if err != nil {
+			return errs.InternalServerError()
+		}
+		export := toRubricExport(fullRubric)
+		if requestBody.Name != nil && strings.TrimSpace(*requestBody.Name) != "" {
+			export.Name = strings.TrimSpace(*requestBody.Name)
+		}
+
+		clone, err := s.store.CreateFullRubric(c.Context(), models.Rubric{
+			Name:        export.Name,
+			OrgID:       classroom.OrgID,
+			ClassroomID: classroom.ID,
+			Reusable:    export.Reusable,
+		}, export)
+		if err != nil {
+			fmt.Println("Error cloning rubric:", err)
+			return errs.InternalServerError()
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"full_rubric": clone,
+		})
+	}
+}
+
+// Exports a rubric as JSON or CSV, so it can be kept alongside course materials and imported again.
+func (s *RubricService) ExportRubric() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		rubric, err := s.getRubric(c, models.TA)
+		if err != nil {
+			return err
+		}
+
+		format := models.RubricFormat(c.Query("format", string(models.RubricFormatJSON)))
+		if format != models.RubricFormatJSON && format != models.RubricFormatCSV {
+			return errs.BadRequest(fmt.Errorf("invalid rubric format: %s", format))
+		}
+
+		fullRubric, err := getFullRubric(c.Context(), s.store, rubric)
+		if err != nil {
+			return errs.InternalServerError()
+		}
+		export := toRubricExport(fullRubric)
+
+		var data []byte
+		if format == models.RubricFormatCSV {
+			data, err = writeRubricCSV(export)
+		} else {
+			data, err = json.MarshalIndent(export, "", "  ")
+		}
+		if err != nil {
+			return errs.InternalServerError()
+		}
+
+		c.Attachment(fmt.Sprintf("%s.%s", rubric.Name, format))
+		return c.Status(http.StatusOK).Send(data)
+	}
+}
+
+// Creates a rubric in a classroom from a JSON or CSV export. CSV rubrics are named by the name query parameter.
+func (s *RubricService) ImportRubric() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		classroomID, err := strconv.ParseInt(c.Params("classroom_id"), 10, 64)
+		if err != nil {
+			return errs.BadRequest(err)
+		}
+
+		_, err = s.RequireAtLeastRole(c, classroomID, models.TA)
+		if err != nil {
+			return err
+		}
+
+		classroom, err := s.store.GetClassroomByID(c.Context(), classroomID)
+		if err != nil {
+			return errs.NotFound("classroom", "id", classroomID)
+		}
+
+		data, err := readRubricUpload(c)
+		if err != nil {
+			return errs.BadRequest(err)
+		}
+
+		var export models.RubricExport
+		switch models.RubricFormat(c.Query("format", string(models.RubricFormatJSON))) {
+		case models.RubricFormatJSON:
+			if err := json.Unmarshal(data, &export); err != nil {
+				return errs.BadRequest(fmt.Errorf("invalid rubric JSON: %w", err))
+			}
+		case models.RubricFormatCSV:
+			export, err = parseRubricCSV(data)
+			if err != nil {
+				return errs.BadRequest(err)
+			}
+			export.Reusable = c.QueryBool("reusable")
+		default:
+			return errs.BadRequest(fmt.Errorf("invalid rubric format: %s", c.Query("format")))
+		}
+		if name := strings.TrimSpace(c.Query("name")); name != "" {
+			export.Name = name
+		}
+
+		if err := validateRubricExport(export); err != nil {
+			return err
+		}
+
+		fullRubric, err := s.store.CreateFullRubric(c.Context(), models.Rubric{
+			Name:        export.Name,
+			OrgID:       classroom.OrgID,
+			ClassroomID: classroom.ID,
+			Reusable:    export.Reusable,
+		}, export)
+		if err != nil {
+			fmt.Println("Error importing rubric:", err)
+			return errs.InternalServerError()
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"full_rubric": fullRubric,
+		})
+	}
+}
+
+// Reads an uploaded rubric, either a multipart file or the raw request body
+func readRubricUpload(c *fiber.Ctx) ([]byte, error) {
+	fileHeader, err := c.FormFile("rubric")
+	if err != nil {
+		if len(c.Body()) == 0 {
+			return nil, errors.New("no rubric was uploaded")
+		}
+		return c.Body(), nil
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
+
+// Converts a rubric to its classroom independent form, keeping the order of its sections and items
+func toRubricExport(fullRubric models.FullRubric) models.RubricExport {
+	export := models.RubricExport{
+		Name:     fullRubric.Rubric.Name,
+		Reusable: fullRubric.Rubric.Reusable,
+		Sections: []models.RubricSectionExport{},
+		Items:    []models.RubricItemExport{},
+	}
+
+	sectionIndex := make(map[int64]int)
+	for i, section := range fullRubric.Sections {
+		sectionIndex[section.ID] = i
+		export.Sections = append(export.Sections, models.RubricSectionExport{
+			Name:      section.Name,
+			MaxPoints: section.MaxPoints,
+			MinPoints: section.MinPoints,
+			Items:     []models.RubricItemExport{},
+		})
+	}
+
+	for _, item := range fullRubric.RubricItems {
+		itemExport := models.RubricItemExport{PointValue: item.PointValue, Explanation: item.Explanation}
+		if item.SectionID != nil {
+			if i, ok := sectionIndex[*item.SectionID]; ok {
+				export.Sections[i].Items = append(export.Sections[i].Items, itemExport)
+				continue
+			}
+		}
+		export.Items = append(export.Items, itemExport)
+	}
+	return export
+}
+
+// Checks that an imported rubric can be created
+func validateRubricExport(export models.RubricExport) error {
+	if strings.TrimSpace(export.Name) == "" {
+		return errs.InvalidRequestData(map[string]string{"name": "rubrics need a name"})
+	}
+
+	checkItems := func(items []models.RubricItemExport) error {
+		for _, item := range items {
+			if item.Explanation == "" || len(item.Explanation) > 255 {
+				return errs.InvalidRequestData(map[string]string{"explanation": "rubric items need an explanation of at most 255 characters"})
+			}
+		}
+		return nil
+	}
+
+	for _, section := range export.Sections {
+		if section.Name == "" {
+			return errs.InvalidRequestData(map[string]string{"sections": "rubric sections need a name"})
+		}
+		if err := validateSectionCaps(section.MaxPoints, section.MinPoints); err != nil {
+			return err
+		}
+		if err := checkItems(section.Items); err != nil {
+			return err
+		}
+	}
+	return checkItems(export.Items)
+}
+
+// Writes a rubric as CSV, one row per item. Items outside of any section have an empty section.
+func writeRubricCSV(export models.RubricExport) ([]byte, error) {
+	records := [][]string{rubricCSVHeader}
+	for _, section := range export.Sections {
+		for _, item := range section.Items {
+			records = append(records, []string{section.Name, formatCap(section.MaxPoints), formatCap(section.MinPoints),
+				strconv.FormatInt(item.PointValue, 10), item.Explanation})
+		}
+	}
+	for _, item := range export.Items {
+		records = append(records, []string{"", "", "", strconv.FormatInt(item.PointValue, 10), item.Explanation})
+	}
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	if err := writer.WriteAll(records); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Reads a rubric CSV, grouping items into sections in the order the sections first appear
+func parseRubricCSV(data []byte) (models.RubricExport, error) {
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return models.RubricExport{}, fmt.Errorf("invalid rubric CSV: %w", err)
+	}
+	if len(records) == 0 {
+		return models.RubricExport{}, errors.New("rubric CSV is empty")
+	}
+
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"point_value", "explanation"} {
+		if _, ok := columns[required]; !ok {
+			return models.RubricExport{}, fmt.Errorf("rubric CSV needs a %s column", required)
+		}
+	}
+	field := func(record []string, column string) string {
+		i, ok := columns[column]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	export := models.RubricExport{Sections: []models.RubricSectionExport{}, Items: []models.RubricItemExport{}}
+	sectionIndex := make(map[string]int)
+	for row, record := range records[1:] {
+		pointValue, err := strconv.ParseInt(field(record, "point_value"), 10, 64)
+		if err != nil {
+			return models.RubricExport{}, fmt.Errorf("row %d: invalid point_value", row+2)
+		}
+		item := models.RubricItemExport{PointValue: pointValue, Explanation: field(record, "explanation")}
+
+		sectionName := field(record, "section")
+		if sectionName == "" {
+			export.Items = append(export.Items, item)
+			continue
+		}
+
+		i, ok := sectionIndex[sectionName]
+		if !ok {
+			i = len(export.Sections)
+			sectionIndex[sectionName] = i
+			export.Sections = append(export.Sections, models.RubricSectionExport{Name: sectionName, Items: []models.RubricItemExport{}})
+		}
+		section := &export.Sections[i]
+		if section.MaxPoints, err = parseCap(field(record, "section_max_points"), section.MaxPoints); err != nil {
+			return models.RubricExport{}, fmt.Errorf("row %d: invalid section_max_points", row+2)
+		}
+		if section.MinPoints, err = parseCap(field(record, "section_min_points"), section.MinPoints); err != nil {
+			return models.RubricExport{}, fmt.Errorf("row %d: invalid section_min_points", row+2)
+		}
+		section.Items = append(section.Items, item)
+	}
+	return export, nil
+}
+
+func formatCap(points *int) string {
+	if points == nil {
+		return ""
+	}
+	return strconv.Itoa(*points)
+}
+
+// Parses a section cap, keeping the cap from an earlier row of the section when the cell is empty
+func parseCap(value string, current *int) (*int, error) {
+	if value == "" {
+		return current, nil
+	}
+	points, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, err
+	}
+	return &points, nil
+}
@@ -0,0 +1,136 @@
+package rubrics
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/CamPlume1/khoury-classroom/internal/errs"
+	"github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/CamPlume1/khoury-classroom/internal/storage"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Helper function for getting the rubric item in the route, checking it belongs to the rubric
+func (s *RubricService) getRubricItem(c *fiber.Ctx, rubric models.Rubric) (models.RubricItem, error) {
+	itemID, err := strconv.ParseInt(c.Params("item_id"), 10, 64)
+	if err != nil {
+		return models.RubricItem{}, errs.BadRequest(err)
+	}
+
+	item, err := s.store.GetRubricItem(c.Context(), itemID)
+	if err != nil || item.RubricID != rubric.ID {
+		return models.RubricItem{}, errs.NotFound("rubric item", "id", itemID)
+	}
+	return item, nil
+}
+
+// Lists the versions of a rubric. Every version after the first was created by editing items that had been graded with.
+func (s *RubricService) GetRubricVersions() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		rubric, err := s.getRubric(c, models.TA)
+		if err != nil {
+			return err
+		}
+
+		versions, err := s.store.GetRubricVersions(c.Context(), rubric.ID)
+		if err != nil {
+			return errs.InternalServerError()
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"current_version": rubric.Version,
+			"versions":        versions,
+		})
+	}
+}
+
+// Lists every version of a rubric item, oldest first.
+func (s *RubricService) GetRubricItemHistory() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		rubric, err := s.getRubric(c, models.TA)
+		if err != nil {
+			return err
+		}
+		item, err := s.getRubricItem(c, rubric)
+		if err != nil {
+			return err
+		}
+
+		history, err := s.store.GetRubricItemHistory(c.Context(), item.ID)
+		if err != nil {
+			return errs.InternalServerError()
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{
+			"rubric_items": history,
+		})
+	}
+}
+
+// Previews how propagating the current version of a rubric item to feedback given with its earlier versions
+// would change the scores of the affected works.
+func (s *RubricService) PreviewRubricItemPropagation() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return s.propagateRubricItem(c, false)
+	}
+}
+
+// Propagates the current version of a rubric item to the feedback given with its earlier versions, re-scoring it.
+func (s *RubricService) PropagateRubricItem() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return s.propagateRubricItem(c, true)
+	}
+}
+
+func (s *RubricService) propagateRubricItem(c *fiber.Ctx, apply bool) error {
+	rubric, err := s.getRubric(c, models.Professor)
+	if err != nil {
+		return err
+	}
+	item, err := s.getRubricItem(c, rubric)
+	if err != nil {
+		return err
+	}
+	if item.SupersededBy != nil || item.Deleted {
+		return errs.BadRequest(errors.New("only the current version of an item can be propagated"))
+	}
+
+	var works []models.RubricPropagationWork
+	err = s.store.WithTx(c.Context(), func(store storage.Storage) error {
+		works, err = store.PropagateRubricItem(c.Context(), item.ID, apply)
+		if err != nil || !apply {
+			return err
+		}
+
+		for _, work := range works {
+			if work.GradesPublished {
+				continue
+			}
+			err := recordAudit(c, store, models.GradingAuditEntry{
+				Action:        models.GradingAuditRubricItemPropagated,
+				ClassroomID:   rubric.ClassroomID,
+				StudentWorkID: &work.StudentWorkID,
+				RubricID:      &rubric.ID,
+				SubjectID:     &item.ID,
+			},
+				map[string]any{"manual_feedback_score": work.PreviousScore},
+				map[string]any{"manual_feedback_score": work.NewScore, "rubric_item": item, "feedback_count": work.FeedbackCount})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		fmt.Println("Error propagating rubric item:", err)
+		return errs.InternalServerError()
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"rubric_item": item,
+		"applied":     apply,
+		"works":       works,
+	})
+}
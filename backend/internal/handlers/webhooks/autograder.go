@@ -0,0 +1,141 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/CamPlume1/khoury-classroom/internal/errs"
+	"github.com/CamPlume1/khoury-classroom/internal/handlers/common"
+	"github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/google/go-github/github"
+	"github.com/jackc/pgx/v5"
+)
+
+// Summary line reported by GitHub Classroom style autograders, e.g. "Points 8/10"
+var autograderPointsPattern = regexp.MustCompile(`Points\s+(\d+(?:\.\d+)?)\s*/\s*(\d+(?:\.\d+)?)`)
+
+func (s *WebHookService) CheckRunEvent(ctx context.Context, payload []byte) error {
+	checkRunEvent := github.CheckRunEvent{}
+	if err := json.Unmarshal(payload, &checkRunEvent); err != nil {
+		return err
+	}
+
+	if checkRunEvent.GetAction() != "completed" || checkRunEvent.CheckRun == nil {
+		return nil
+	}
+
+	repo := checkRunEvent.GetRepo()
+	err := s.recordAutograderCheckRuns(ctx, repo.GetOwner().GetLogin(), repo.GetName(),
+		checkRunEvent.CheckRun.GetCheckSuite().GetHeadBranch(), []*github.CheckRun{checkRunEvent.CheckRun})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *WebHookService) WorkflowRunEvent(ctx context.Context, payload []byte) error {
+	workflowRunEvent := models.WorkflowRunEvent{}
+	if err := json.Unmarshal(payload, &workflowRunEvent); err != nil {
+		return err
+	}
+
+	if workflowRunEvent.Action != "completed" {
+		return nil
+	}
+
+	// The results are reported by the jobs of the run, which are the check runs of its check suite
+	checkRuns, err := s.appClient.ListCheckRunsForCheckSuite(ctx,
+		workflowRunEvent.Repository.Owner.Login,
+		workflowRunEvent.Repository.Name,
+		workflowRunEvent.WorkflowRun.CheckSuiteID)
+	if err != nil {
+		return errs.GithubAPIError(err)
+	}
+
+	err = s.recordAutograderCheckRuns(ctx, workflowRunEvent.Repository.Owner.Login, workflowRunEvent.Repository.Name,
+		workflowRunEvent.WorkflowRun.HeadBranch, checkRuns)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Records the autograder results among completed check runs on the submission branch of a student work, for
+// display. Anything the student's workflows can print could be in them, so they're unverified and don't count
+// towards the grade, only the reports the provisioned autograder workflow sends to the server do.
+func (s *WebHookService) recordAutograderCheckRuns(ctx context.Context, orgName string, repoName string, branch string, checkRuns []*github.CheckRun) error {
+	testsBySHA := make(map[string][]models.AutograderTestResult)
+	for _, checkRun := range checkRuns {
+		tests, ok := parseAutograderCheckRun(checkRun)
+		if ok {
+			testsBySHA[checkRun.GetHeadSHA()] = append(testsBySHA[checkRun.GetHeadSHA()], tests...)
+		}
+	}
+	if len(testsBySHA) == 0 {
+		return nil
+	}
+
+	studentWork, err := s.store.GetWorkByRepoName(ctx, repoName)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && !strings.EqualFold(studentWork.OrgName, orgName)) {
+		return nil // not a student work, e.g. an assignment base repository
+	}
+	if err != nil {
+		return err
+	}
+
+	layout, err := common.GetBranchLayout(ctx, s.store, int64(studentWork.AssignmentOutlineID))
+	if err != nil {
+		return err
+	}
+	if branch != layout.SubmissionBranch {
+		return nil
+	}
+
+	for sha, tests := range testsBySHA {
+		_, err = s.store.RecordAutograderResult(ctx, int64(studentWork.ID), sha, false, tests)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Extracts the test results of a completed check run, preferring per-test JSON in the output text
+// and falling back to a "Points x/y" summary. Returns false if the check run isn't from an autograder.
+func parseAutograderCheckRun(checkRun *github.CheckRun) ([]models.AutograderTestResult, bool) {
+	if checkRun.GetStatus() != "completed" || checkRun.Output == nil {
+		return nil, false
+	}
+
+	// Autograders can report per-test results as JSON in their output text
+	report := models.AutograderReport{}
+	text := strings.TrimSpace(checkRun.Output.GetText())
+	if err := json.Unmarshal([]byte(text), &report); err == nil && len(report.Tests) > 0 {
+		return report.TestResults(), true
+	}
+
+	match := autograderPointsPattern.FindStringSubmatch(checkRun.Output.GetSummary())
+	if match == nil {
+		match = autograderPointsPattern.FindStringSubmatch(checkRun.Output.GetTitle())
+	}
+	if match == nil {
+		return nil, false
+	}
+
+	score, _ := strconv.ParseFloat(match[1], 64)
+	maxScore, _ := strconv.ParseFloat(match[2], 64)
+	return []models.AutograderTestResult{{
+		Name:     checkRun.GetName(),
+		Status:   checkRun.GetConclusion(),
+		Score:    int(math.Round(score)),
+		MaxScore: int(math.Round(maxScore)),
+	}}, true
+}
@@ -0,0 +1,139 @@
+package webhooks
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/CamPlume1/khoury-classroom/internal/errs"
+	"github.com/CamPlume1/khoury-classroom/internal/middleware"
+	"github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+)
+
+const maxListedDeliveries = 100
+
+// The fields every webhook payload may have, to tell what a delivery is about without parsing it fully
+type webhookEnvelope struct {
+	Action       *string `json:"action"`
+	Organization *struct {
+		Login string `json:"login"`
+	} `json:"organization"`
+	Repository *struct {
+		FullName string `json:"full_name"`
+		Owner    struct {
+			Login string `json:"login"`
+		} `json:"owner"`
+	} `json:"repository"`
+	Installation *struct {
+		Account *struct {
+			Login string `json:"login"`
+		} `json:"account"`
+	} `json:"installation"`
+}
+
+// The organization an event happened in, falling back to the owner of its repository or installation
+func (e webhookEnvelope) orgName() *string {
+	switch {
+	case e.Organization != nil && e.Organization.Login != "":
+		return &e.Organization.Login
+	case e.Repository != nil && e.Repository.Owner.Login != "":
+		return &e.Repository.Owner.Login
+	case e.Installation != nil && e.Installation.Account != nil && e.Installation.Account.Login != "":
+		return &e.Installation.Account.Login
+	}
+	return nil
+}
+
+// The owner/name of the repository an event happened in, if any
+func (e webhookEnvelope) repoName() *string {
+	if e.Repository != nil && e.Repository.FullName != "" {
+		return &e.Repository.FullName
+	}
+	return nil
+}
+
+// Helper function for checking the current user administers the organization in the route
+func (s *WebHookService) requireOrgAdmin(c *fiber.Ctx) (string, error) {
+	client, _, _, err := middleware.GetClientAndUser(c, s.store, s.userCfg)
+	if err != nil {
+		return "", err
+	}
+
+	orgName := c.Params("org_name")
+	membership, err := client.GetCurrUserOrgMembership(c.Context(), orgName)
+	if err != nil || membership.GetRole() != "admin" || membership.GetState() != "active" {
+		return "", errs.InsufficientPermissionsError()
+	}
+	return orgName, nil
+}
+
+// Returns the latest webhook deliveries of an organization's events, e.g. ?state=FAILED to find the ones to replay.
+func (s *WebHookService) getWebhookDeliveries() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		orgName, err := s.requireOrgAdmin(c)
+		if err != nil {
+			return err
+		}
+
+		var state *models.WebhookDeliveryState
+		if c.Query("state") != "" {
+			queryState := models.WebhookDeliveryState(c.Query("state"))
+			switch queryState {
+			case models.WebhookDeliveryStatePending, models.WebhookDeliveryStateProcessing,
+				models.WebhookDeliveryStateProcessed, models.WebhookDeliveryStateFailed:
+				state = &queryState
+			default:
+				return errs.BadRequest(fmt.Errorf("unknown delivery state %q", queryState))
+			}
+		}
+
+		deliveries, err := s.store.ListWebhookDeliveries(c.Context(), orgName, state, maxListedDeliveries)
+		if err != nil {
+			return errs.InternalServerError()
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{"deliveries": deliveries})
+	}
+}
+
+// Processes a failed webhook delivery again, with a fresh set of retries.
+func (s *WebHookService) replayWebhookDelivery() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		orgName, err := s.requireOrgAdmin(c)
+		if err != nil {
+			return err
+		}
+
+		delivery, err := s.store.GetWebhookDelivery(c.Context(), c.Params("delivery_id"))
+		if err != nil || delivery.OrgName == nil || !strings.EqualFold(*delivery.OrgName, orgName) {
+			return errs.NotFound("webhook delivery", "id", c.Params("delivery_id"))
+		}
+
+		_, err = s.store.ReplayWebhookDelivery(c.Context(), delivery.DeliveryID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return errs.BadRequest(errors.New("only failed deliveries can be replayed"))
+		}
+		if err != nil {
+			return errs.InternalServerError()
+		}
+
+		// Replay synchronously, so the admin sees whether it worked this time. A delivery waiting on an earlier
+		// delivery of its repository is left for the queue to process in order.
+		claimed, err := s.store.ClaimWebhookDelivery(c.Context(), delivery.DeliveryID)
+		if err == nil {
+			s.deliveries.Run(c.Context(), claimed)
+		} else if !errors.Is(err, pgx.ErrNoRows) {
+			return errs.InternalServerError()
+		}
+
+		delivery, err = s.store.GetWebhookDelivery(c.Context(), delivery.DeliveryID)
+		if err != nil {
+			return errs.InternalServerError()
+		}
+
+		return c.Status(http.StatusOK).JSON(fiber.Map{"delivery": delivery})
+	}
+}
@@ -0,0 +1,204 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/CamPlume1/khoury-classroom/internal/handlers/common"
+	models "github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/google/go-github/github"
+	"github.com/jackc/pgx/v5"
+)
+
+// Keeps the known installations of the app in sync, suspending the classrooms of organizations that uninstall
+// or suspend the app and resuming them when it's reinstalled
+func (s *WebHookService) InstallationEvent(ctx context.Context, body []byte) error {
+	payload := github.InstallationEvent{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return err
+	}
+	if payload.Installation == nil || payload.Installation.Account == nil {
+		return errors.New("installation event without an installation")
+	}
+
+	installation := common.InstallationFromGitHub(payload.Installation)
+	now := time.Now().UTC()
+	switch payload.GetAction() {
+	case "created", "new_permissions_accepted", "unsuspend":
+	case "suspend":
+		installation.SuspendedAt = &now
+	case "deleted":
+		installation.UninstalledAt = &now
+	default:
+		return nil
+	}
+
+	installation, err := s.store.UpsertAppInstallation(ctx, installation)
+	if err != nil {
+		return err
+	}
+
+	// The app can be installed on users too, but classrooms only live in organizations
+	if installation.AccountType != "Organization" {
+		return nil
+	}
+	classrooms, err := s.store.SetOrgClassroomsSuspended(ctx, installation.AccountID, !installation.IsActive())
+	if err != nil {
+		return err
+	}
+	for _, classroom := range classrooms {
+		slog.Info("Changed classroom suspension", "classroom_id", classroom.ID, "org", classroom.OrgName, "suspended", classroom.SuspendedAt != nil)
+	}
+
+	return nil
+}
+
+// Keeps the repositories an installation of the app can access in sync
+func (s *WebHookService) InstallationRepositoriesEvent(ctx context.Context, body []byte) error {
+	payload := github.InstallationRepositoriesEvent{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return err
+	}
+	if payload.Installation == nil || payload.Installation.Account == nil {
+		return errors.New("installation_repositories event without an installation")
+	}
+
+	installation := common.InstallationFromGitHub(payload.Installation)
+	if payload.RepositorySelection != nil {
+		installation.RepositorySelection = payload.RepositorySelection
+	}
+	_, err := s.store.UpsertAppInstallation(ctx, installation)
+	if err != nil {
+		return err
+	}
+
+	// Classroom repositories the app can no longer access can't be set up, graded or published to
+	for _, repo := range payload.RepositoriesRemoved {
+		if _, err := s.store.GetWorkByRepoName(ctx, repo.GetName()); err == nil {
+			slog.Warn("App lost access to a student work repository", "org", installation.AccountLogin, "repo", repo.GetName())
+		}
+	}
+
+	return nil
+}
+
+// Keeps the statuses of classroom members in sync with their organization membership, including invitations
+// students accept on GitHub rather than through the app
+func (s *WebHookService) OrganizationEvent(ctx context.Context, body []byte) error {
+	payload := github.OrganizationEvent{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return err
+	}
+
+	var login string
+	var status models.UserStatus
+	var user models.User
+	var err error
+	switch payload.GetAction() {
+	case "member_added", "member_removed":
+		// members are looked up by their GitHub ID, which unlike their login can't change
+		login = payload.Membership.GetUser().GetLogin()
+		status = models.UserStatusActive
+		if payload.GetAction() == "member_removed" {
+			status = models.UserStatusNotInOrg
+		}
+		user, err = s.store.GetUserByGitHubID(ctx, payload.Membership.GetUser().GetID())
+	case "member_invited":
+		// invitations only carry the invitee's login, and invitations by email have none until they're accepted
+		login, status = payload.Invitation.GetLogin(), models.UserStatusOrgInvited
+		if login == "" {
+			return nil
+		}
+		user, err = s.store.GetUserByGitHubUsername(ctx, login)
+	default:
+		return nil
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	memberships, err := s.store.GetUserMembershipsInOrg(ctx, payload.Organization.GetID(), *user.ID)
+	if err != nil {
+		return err
+	}
+	for _, membership := range memberships {
+		// requests to join are waiting on a professor, and removed members stay removed
+		if membership.Status == models.UserStatusRequested || membership.Status == models.UserStatusRemoved {
+			continue
+		}
+		// inviting a member again doesn't take them out of the org
+		if status == models.UserStatusOrgInvited && membership.Status != models.UserStatusNotInOrg {
+			continue
+		}
+
+		if membership.Status != status {
+			_, err = s.store.ModifyUserStatus(ctx, membership.ClassroomID, status, *user.ID)
+			if err != nil {
+				return err
+			}
+		}
+
+		if status == models.UserStatusActive && membership.Role == models.Student {
+			err = s.addToStudentTeam(ctx, membership.ClassroomID, login)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Adds a student who joined the organization to their classroom's student team, in case they joined some other way
+// than through the team's invitation
+func (s *WebHookService) addToStudentTeam(ctx context.Context, classroomID int64, login string) error {
+	classroom, err := s.store.GetClassroomByID(ctx, classroomID)
+	if err != nil {
+		return err
+	}
+	if classroom.StudentTeamName == nil {
+		return nil
+	}
+
+	studentTeam, err := s.appClient.GetTeamByName(ctx, classroom.OrgName, *classroom.StudentTeamName)
+	if err != nil {
+		return err
+	}
+	return s.appClient.AddTeamMember(ctx, studentTeam.GetID(), login, nil)
+}
+
+// Updates the roster of a classroom when someone is added to or removed from its student team on GitHub,
+// so the drift between the team and the classroom is reported as it happens
+func (s *WebHookService) MembershipEvent(ctx context.Context, body []byte) error {
+	payload := github.MembershipEvent{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return err
+	}
+	if payload.GetScope() != "team" || payload.Team == nil || payload.Member == nil {
+		return nil
+	}
+
+	classrooms, err := s.store.GetClassroomsInOrg(ctx, payload.Org.GetID())
+	if err != nil {
+		return err
+	}
+	for _, classroom := range classrooms {
+		if classroom.StudentTeamName == nil || !strings.EqualFold(*classroom.StudentTeamName, payload.Team.GetName()) || classroom.SuspendedAt != nil {
+			continue
+		}
+
+		err = common.ReconcileStudentTeamMember(ctx, s.store, classroom, payload.Member, payload.GetAction() == "added")
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
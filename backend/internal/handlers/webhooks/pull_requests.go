@@ -0,0 +1,236 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/CamPlume1/khoury-classroom/internal/handlers/common"
+	models "github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/CamPlume1/khoury-classroom/internal/storage"
+	"github.com/google/go-github/github"
+	"github.com/jackc/pgx/v5"
+)
+
+// The pull request the app opens from the submission branch into the feedback branch of each work
+const feedbackPullNumber = 1
+
+// Records the pull requests students open or update into the submission branch of their work as submission attempts,
+// and submits the work when one of them is merged
+func (s *WebHookService) PR(ctx context.Context, body []byte) error {
+	payload := github.PullRequestEvent{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return err
+	}
+
+	work, err := s.store.GetWorkByRepoName(ctx, payload.Repo.GetName())
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Not a student work, e.g. a template or base repository
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	layout, err := common.GetBranchLayout(ctx, s.store, int64(work.AssignmentOutlineID))
+	if err != nil {
+		return err
+	}
+	if payload.PullRequest.GetBase().GetRef() != layout.SubmissionBranch {
+		return nil
+	}
+
+	switch payload.GetAction() {
+	case "opened", "reopened", "synchronize":
+		attempt := models.SubmissionAttempt{
+			StudentWorkID: int64(work.ID),
+			PullNumber:    payload.GetNumber(),
+			HeadSHA:       payload.PullRequest.GetHead().GetSHA(),
+			Action:        payload.GetAction(),
+		}
+		if user, err := s.store.GetUserByGitHubID(ctx, payload.Sender.GetID()); err == nil {
+			attempt.OpenedBy = user.ID
+		}
+		_, err = s.store.CreateSubmissionAttempt(ctx, attempt)
+		return err
+
+	case "closed":
+		if !payload.PullRequest.GetMerged() {
+			return nil
+		}
+		return s.submitMergedPR(ctx, work, payload.PullRequest)
+	}
+
+	return nil
+}
+
+// Marks a work as submitted when a pull request into its submission branch is merged, and pins the merge for grading.
+// Merges the work no longer accepts as a submission are left alone, like pushes.
+func (s *WebHookService) submitMergedPR(ctx context.Context, work models.StudentWork, pr *github.PullRequest) error {
+	submittedAt := pr.GetMergedAt().UTC()
+	if submittedAt.IsZero() {
+		submittedAt = time.Now().UTC()
+	}
+	// the merge is pushed too, both are checked the same way so neither undoes the other
+	accepted, err := s.acceptSubmission(ctx, &work, submittedAt)
+	if err != nil || !accepted {
+		return err
+	}
+
+	_, err = s.store.UpdateStudentWork(ctx, work)
+	if err != nil {
+		return err
+	}
+
+	err = common.SnapshotSubmission(ctx, s.appClient, s.store, work, pr.GetMergeCommitSHA())
+	if err != nil {
+		fmt.Println("Error snapshotting submission:", err)
+	}
+
+	return nil
+}
+
+// Imports reviews that TAs leave on a work's feedback pull request through GitHub rather than through the app.
+// Each review comment becomes a published feedback comment worth no points, which TAs can then grade in the app.
+func (s *WebHookService) PRReview(ctx context.Context, body []byte) error {
+	payload := github.PullRequestReviewEvent{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return err
+	}
+	if payload.GetAction() != "submitted" || payload.PullRequest.GetNumber() != feedbackPullNumber {
+		return nil
+	}
+
+	work, err := s.store.GetWorkByRepoName(ctx, payload.Repo.GetName())
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// Only reviews by the classroom's TAs and professors count as feedback
+	reviewer, err := s.store.GetUserByGitHubID(ctx, payload.Review.GetUser().GetID())
+	if err != nil {
+		return nil
+	}
+	classroomUser, err := s.store.GetUserInClassroom(ctx, int64(work.ClassroomID), *reviewer.ID)
+	if err != nil || classroomUser.Role.Compare(models.TA) < 0 || classroomUser.Status != models.UserStatusActive {
+		return nil
+	}
+
+	var imported []models.PRReviewCommentResponse
+	reviewBody := strings.TrimSpace(payload.Review.GetBody())
+	if reviewBody != "" && !common.IsAppComment(reviewBody) {
+		imported = append(imported, models.PRReviewCommentResponse{
+			PRReviewComment: models.PRReviewComment{Body: reviewBody},
+		})
+	}
+
+	comments, err := s.appClient.ListReviewComments(ctx, work.OrgName, work.RepoName, feedbackPullNumber, payload.Review.GetID())
+	if err != nil {
+		return err
+	}
+	for _, comment := range comments {
+		if comment.InReplyTo != nil || common.IsAppComment(comment.Body) {
+			continue
+		}
+
+		commentID := comment.ID
+		imported = append(imported, models.PRReviewCommentResponse{
+			PRReviewComment: comment.ReviewComment(),
+			GitHubCommentID: &commentID,
+		})
+	}
+	if len(imported) == 0 {
+		return nil
+	}
+
+	err = s.store.WithTx(ctx, func(store storage.Storage) error {
+		feedback, err := store.ImportReviewFeedback(ctx, *reviewer.ID, work.ID, payload.Review.GetID(), imported)
+		if err != nil {
+			return err
+		}
+
+		for _, comment := range feedback {
+			feedbackID := int64(comment.ID)
+			entry := common.WorkAuditEntry(models.GradingAuditFeedbackCreated, reviewer.ID, work.ClassroomID, work.AssignmentOutlineID, work.ID)
+			entry.SubjectID = &feedbackID
+			if err := common.RecordGradingAudit(ctx, store, entry, nil, comment); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Redeliveries of the review are already imported
+		return nil
+	}
+	return err
+}
+
+// Marks feedback as acknowledged when a student resolves its review thread, and as no longer acknowledged when
+// they unresolve it
+func (s *WebHookService) PRThread(ctx context.Context, body []byte) error {
+	payload := models.PullRequestReviewThreadEvent{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return err
+	}
+	if payload.Action != "resolved" && payload.Action != "unresolved" {
+		return nil
+	}
+
+	work, err := s.store.GetWorkByRepoName(ctx, payload.Repository.Name)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// TAs resolving threads doesn't mean the student read the feedback
+	student, ok := s.workContributor(ctx, work, payload.Sender.ID)
+	if !ok {
+		return nil
+	}
+	var acknowledgedBy *int64
+	if payload.Action == "resolved" {
+		acknowledgedBy = student.ID
+	}
+
+	for _, comment := range payload.Thread.Comments {
+		feedback, err := s.store.GetFeedbackCommentByGitHubID(ctx, comment.ID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if feedback.StudentWorkID != work.ID {
+			continue
+		}
+
+		err = s.store.SetFeedbackAcknowledged(ctx, int64(feedback.ID), acknowledgedBy)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Gets the user with the given GitHub ID if they're one of the students a work belongs to
+func (s *WebHookService) workContributor(ctx context.Context, work models.StudentWork, gitHubUserID int64) (models.User, bool) {
+	user, err := s.store.GetUserByGitHubID(ctx, gitHubUserID)
+	if err != nil {
+		return models.User{}, false
+	}
+	contributorWork, err := s.store.GetWorkByGitHubUserID(ctx, work.ClassroomID, work.AssignmentOutlineID, gitHubUserID)
+	if err != nil || contributorWork.ID != work.ID {
+		return models.User{}, false
+	}
+	return user, true
+}
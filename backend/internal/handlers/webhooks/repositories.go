@@ -0,0 +1,230 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/CamPlume1/khoury-classroom/internal/handlers/common"
+	models "github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/google/go-github/github"
+	"github.com/jackc/pgx/v5"
+)
+
+// What changed about a repository, which go-github doesn't model
+type repositoryChanges struct {
+	Changes struct {
+		Repository struct {
+			Name struct {
+				From string `json:"from"`
+			} `json:"name"`
+		} `json:"repository"`
+	} `json:"changes"`
+}
+
+// A classroom repository an event happened to: a student work, or the base repository of an assignment
+type classroomRepo struct {
+	work       *models.StudentWork
+	assignment *models.AssignmentOutline
+	baseRepo   *models.AssignmentBaseRepo
+}
+
+// Finishes setting up accepted assignments, and keeps track of what happens to classroom repositories on GitHub:
+// renames are followed, and deleting, transferring, archiving or publicizing them raises an alert
+func (s *WebHookService) RepositoryEvent(ctx context.Context, body []byte) error {
+	repositoryEvent := github.RepositoryEvent{}
+	if err := json.Unmarshal(body, &repositoryEvent); err != nil {
+		return err
+	}
+	repo := repositoryEvent.GetRepo()
+
+	// A new fork may be an accepted assignment, finish setting it up without waiting for the queue to poll.
+	// Deliveries are already processed in the background, and the job records its own outcome and retries.
+	if repositoryEvent.GetAction() == "created" {
+		if !repo.GetFork() {
+			return nil
+		}
+		forkJob, err := s.store.ClaimForkJobByRepoName(ctx, repo.GetOwner().GetLogin(), repo.GetName())
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+		if err == nil {
+			s.forkQueue.Run(ctx, forkJob)
+		}
+		return nil
+	}
+
+	repoName := repo.GetName()
+	if repositoryEvent.GetAction() == "renamed" {
+		changes := repositoryChanges{}
+		if err := json.Unmarshal(body, &changes); err != nil {
+			return err
+		}
+		repoName = changes.Changes.Repository.Name.From
+	}
+
+	classroomRepo, found, err := s.findClassroomRepo(ctx, repoName, repo.GetID())
+	if err != nil || !found {
+		return err
+	}
+
+	var kind models.RepositoryAlertKind
+	switch repositoryEvent.GetAction() {
+	case "renamed":
+		return s.followRename(ctx, classroomRepo, repo)
+	case "deleted":
+		kind = models.RepositoryAlertRepoDeleted
+	case "transferred":
+		// only transfers out of the classroom's organization take the repository away
+		if strings.EqualFold(repo.GetOwner().GetLogin(), classroomRepo.orgName()) {
+			return nil
+		}
+		kind = models.RepositoryAlertRepoTransferred
+	case "archived", "unarchived":
+		kind = models.RepositoryAlertRepoArchived
+	case "publicized", "privatized":
+		kind = models.RepositoryAlertRepoPublicized
+	default:
+		return nil
+	}
+
+	err = s.flagRepo(ctx, classroomRepo, repositoryEvent.GetAction())
+	if err != nil {
+		return err
+	}
+
+	// undoing an archive or publicizing is nothing to be alerted about
+	if repositoryEvent.GetAction() == "unarchived" || repositoryEvent.GetAction() == "privatized" || isBot(repositoryEvent.GetSender()) {
+		return nil
+	}
+	alert := classroomRepo.alert(repo.GetName(), kind)
+	login := repositoryEvent.GetSender().GetLogin()
+	alert.ActorGHUsername = &login
+	_, err = s.store.CreateRepositoryAlert(ctx, alert)
+	return err
+}
+
+// Raises an alert when the rulesets protecting a classroom repository are edited or deleted by someone else than the app
+func (s *WebHookService) RepositoryRulesetEvent(ctx context.Context, body []byte) error {
+	payload := models.RepositoryRulesetEvent{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return err
+	}
+
+	var kind models.RepositoryAlertKind
+	switch payload.Action {
+	case "edited":
+		kind = models.RepositoryAlertRulesetEdited
+	case "deleted":
+		kind = models.RepositoryAlertRulesetDeleted
+	default:
+		return nil
+	}
+	// a protection ruleset may have been renamed by the very edit being reported
+	rulesetName := payload.RulesetName()
+	if !common.IsProtectionRuleset(rulesetName) || payload.Sender.Type == "Bot" {
+		return nil
+	}
+
+	classroomRepo, found, err := s.findClassroomRepo(ctx, payload.Repository.Name, payload.Repository.ID)
+	if err != nil || !found {
+		return err
+	}
+
+	alert := classroomRepo.alert(payload.Repository.Name, kind)
+	alert.ActorGHUsername = &payload.Sender.Login
+	alert.RulesetID = &payload.RepositoryRuleset.ID
+	alert.RulesetName = &rulesetName
+	alert, err = s.store.CreateRepositoryAlert(ctx, alert)
+	if err != nil {
+		return err
+	}
+
+	slog.Warn("Protection ruleset tampered with", "repo", alert.RepoName, "ruleset", rulesetName,
+		"action", payload.Action, "actor", payload.Sender.Login)
+	return nil
+}
+
+// Finds the student work or assignment a repository belongs to. Student works are found by name, since
+// their IDs aren't stored, and base repositories by ID.
+func (s *WebHookService) findClassroomRepo(ctx context.Context, repoName string, repoID int64) (classroomRepo, bool, error) {
+	work, err := s.store.GetWorkByRepoName(ctx, repoName)
+	if err == nil {
+		return classroomRepo{work: &work}, true, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return classroomRepo{}, false, err
+	}
+
+	assignment, err := s.store.GetAssignmentByBaseRepoID(ctx, repoID)
+	if err != nil {
+		// Not a classroom repository, e.g. a template
+		return classroomRepo{}, false, nil
+	}
+	baseRepo, err := s.store.GetBaseRepoByID(ctx, repoID)
+	if err != nil {
+		return classroomRepo{}, false, err
+	}
+	return classroomRepo{assignment: &assignment, baseRepo: &baseRepo}, true, nil
+}
+
+// Keeps the stored name of a renamed repository up to date, so the events that follow can still be traced to it
+func (s *WebHookService) followRename(ctx context.Context, classroomRepo classroomRepo, repo *github.Repository) error {
+	if classroomRepo.work != nil {
+		return s.store.RenameWorkRepo(ctx, classroomRepo.work.ID, repo.GetName())
+	}
+	return s.store.RenameBaseRepo(ctx, repo.GetID(), repo.GetName())
+}
+
+// Records what happened to a classroom repository
+func (s *WebHookService) flagRepo(ctx context.Context, classroomRepo classroomRepo, action string) error {
+	if classroomRepo.work == nil {
+		if action == "deleted" || action == "transferred" {
+			return s.store.SetBaseRepoDeleted(ctx, classroomRepo.baseRepo.BaseID, true)
+		}
+		return nil
+	}
+
+	work := *classroomRepo.work
+	switch action {
+	case "deleted", "transferred":
+		now := time.Now().UTC()
+		work.RepoDeletedAt = &now
+	case "archived", "unarchived":
+		work.RepoArchived = action == "archived"
+	case "publicized", "privatized":
+		work.RepoPublic = action == "publicized"
+	}
+	return s.store.UpdateWorkRepoFlags(ctx, work)
+}
+
+func (r classroomRepo) orgName() string {
+	if r.work != nil {
+		return r.work.OrgName
+	}
+	return r.baseRepo.BaseRepoOwner
+}
+
+// An alert about the repository, attributed to its classroom, assignment and student work
+func (r classroomRepo) alert(repoName string, kind models.RepositoryAlertKind) models.RepositoryAlert {
+	alert := models.RepositoryAlert{RepoName: repoName, Kind: kind}
+	if r.work != nil {
+		assignmentID := int64(r.work.AssignmentOutlineID)
+		workID := int64(r.work.ID)
+		alert.ClassroomID = int64(r.work.ClassroomID)
+		alert.AssignmentOutlineID = &assignmentID
+		alert.StudentWorkID = &workID
+	} else {
+		assignmentID := int64(r.assignment.ID)
+		alert.ClassroomID = r.assignment.ClassroomID
+		alert.AssignmentOutlineID = &assignmentID
+	}
+	return alert
+}
+
+func isBot(user *github.User) bool {
+	return user.GetType() == "Bot"
+}
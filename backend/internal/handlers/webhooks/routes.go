@@ -1,14 +1,33 @@
 package webhooks
 
 import (
+	"github.com/CamPlume1/khoury-classroom/internal/forkqueue"
 	"github.com/CamPlume1/khoury-classroom/internal/middleware"
 	"github.com/CamPlume1/khoury-classroom/internal/types"
+	"github.com/CamPlume1/khoury-classroom/internal/webhookqueue"
 	"github.com/gofiber/fiber/v2"
 )
 
+func newService(params types.Params) *WebHookService {
+	return newWebHookService(params.Store, params.GitHubApp, &params.UserCfg, params.Domains, forkqueue.New(params.Store, params.GitHubApp, &params.UserCfg))
+}
+
+// Creates the queue that processes stored webhook deliveries, to run in the background
+func NewDeliveryQueue(params types.Params) *webhookqueue.Queue {
+	return newService(params).deliveries
+}
+
 func Routes(app *fiber.App, params types.Params) {
-	service := newWebHookService(params.Store, params.GitHubApp, params.Domains)
+	service := newService(params)
 	baseRouter := app.Group("")
 
 	baseRouter.Post("/webhook", middleware.ProtectedWebhook(params.GitHubApp.GetWebhookSecret()), service.WebhookHandler)
+
+	deliveryRouter := baseRouter.Group("/orgs/org/:org_name/webhook-deliveries").Use(middleware.Protected(params.UserCfg.JWTSecret))
+
+	// Get the latest webhook deliveries of an organization's events
+	deliveryRouter.Get("/", service.getWebhookDeliveries())
+
+	// Process a failed webhook delivery again
+	deliveryRouter.Post("/:delivery_id/replay", service.replayWebhookDelivery())
 }
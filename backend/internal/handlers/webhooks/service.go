@@ -2,25 +2,35 @@ package webhooks
 
 import (
 	"github.com/CamPlume1/khoury-classroom/internal/config"
+	"github.com/CamPlume1/khoury-classroom/internal/forkqueue"
 	"github.com/CamPlume1/khoury-classroom/internal/github"
 	"github.com/CamPlume1/khoury-classroom/internal/storage"
+	"github.com/CamPlume1/khoury-classroom/internal/webhookqueue"
 )
 
 type WebHookService struct {
 	store     storage.Storage
 	appClient github.GitHubAppClient
+	userCfg   *config.GitHubUserClient
 	domains   config.Domains
+	forkQueue *forkqueue.Queue
+	deliveries *webhookqueue.Queue
 }
 
 func newWebHookService(
 	store storage.Storage,
 	appClient github.GitHubAppClient,
+	userCfg *config.GitHubUserClient,
 	domains config.Domains,
-
+	forkQueue *forkqueue.Queue,
 ) *WebHookService {
-	return &WebHookService{
+	service := &WebHookService{
 		store:     store,
 		appClient: appClient,
+		userCfg:   userCfg,
 		domains: domains,
+		forkQueue: forkQueue,
 	}
+	service.deliveries = webhookqueue.New(store, service.processDelivery)
+	return service
 }
@@ -1,65 +1,158 @@
 package webhooks
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/CamPlume1/khoury-classroom/internal/errs"
 	"github.com/CamPlume1/khoury-classroom/internal/handlers/common"
 	models "github.com/CamPlume1/khoury-classroom/internal/models"
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/go-github/github"
+	"github.com/jackc/pgx/v5"
 )
 
-func (s *WebHookService) WebhookHandler(c *fiber.Ctx) error {
-	var dispatch = map[string]func(c *fiber.Ctx) error{
+// The handlers of the webhook events the app subscribes to
+func (s *WebHookService) eventHandlers() map[string]func(ctx context.Context, payload []byte) error {
+	return map[string]func(ctx context.Context, payload []byte) error{
+		"check_run":                   s.CheckRunEvent,
+		"installation":                s.InstallationEvent,
+		"installation_repositories":   s.InstallationRepositoriesEvent,
+		"membership":                  s.MembershipEvent,
+		"organization":                s.OrganizationEvent,
 		"pull_request":                s.PR,
+		"pull_request_review":         s.PRReview,
 		"pull_request_review_comment": s.PRComment,
 		"pull_request_review_thread":  s.PRThread,
 		"push":                        s.PushEvent,
+		"repository":                  s.RepositoryEvent,
+		"repository_ruleset":          s.RepositoryRulesetEvent,
+		"workflow_run":                s.WorkflowRunEvent,
 	}
-	event := c.Get("X-GitHub-Event", "")
+}
 
-	handler, exists := dispatch[event]
-	if !exists {
+// Stores a webhook delivery and acknowledges it, then processes it in the background so a failure can be retried
+// instead of being lost. GitHub redelivers events with the same delivery ID, so redeliveries are only processed
+// again if the original delivery failed.
+func (s *WebHookService) WebhookHandler(c *fiber.Ctx) error {
+	event := c.Get("X-GitHub-Event", "")
+	if _, exists := s.eventHandlers()[event]; !exists {
 		return c.SendStatus(fiber.StatusBadRequest)
 	}
 
-	return handler(c)
+	deliveryID := c.Get("X-GitHub-Delivery", "")
+	if deliveryID == "" {
+		return errs.BadRequest(errors.New("missing X-GitHub-Delivery header"))
+	}
+
+	// fiber reuses the request buffer once the handler returns, and the delivery outlives it
+	payload := slices.Clone(c.Body())
+	var envelope webhookEnvelope
+	if err := json.Unmarshal(payload, &envelope); err != nil {
+		return errs.BadRequest(err)
+	}
+
+	delivery, err := s.store.RecordWebhookDelivery(c.Context(), models.WebhookDelivery{
+		DeliveryID: deliveryID,
+		Event:      event,
+		Action:     envelope.Action,
+		OrgName:    envelope.orgName(),
+		RepoName:   envelope.repoName(),
+		Payload:    payload,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		delivery, err = s.store.ReplayWebhookDelivery(c.Context(), deliveryID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.Status(fiber.StatusOK).JSON(fiber.Map{"message": "Delivery already received"})
+		}
+	}
+	if err != nil {
+		fmt.Println("Error recording webhook delivery:", err)
+		return errs.InternalServerError()
+	}
+
+	// Process the delivery right away rather than waiting for the queue to poll, unless it has to wait its turn
+	delivery, err = s.store.ClaimWebhookDelivery(c.Context(), delivery.DeliveryID)
+	if err == nil {
+		go s.deliveries.Run(context.Background(), delivery)
+	}
+
+	return c.SendStatus(fiber.StatusAccepted)
 }
 
-func (s *WebHookService) PR(c *fiber.Ctx) error {
-	println("PR webhook event")
-	return c.SendStatus(fiber.StatusOK)
+// Processes a stored webhook delivery with the handler of its event
+func (s *WebHookService) processDelivery(ctx context.Context, delivery models.WebhookDelivery) error {
+	handler, exists := s.eventHandlers()[delivery.Event]
+	if !exists {
+		return fmt.Errorf("no handler for %s events", delivery.Event)
+	}
+
+	return handler(ctx, delivery.Payload)
 }
 
-// todo: finish regrade request handling
-func (s *WebHookService) PRComment(c *fiber.Ctx) error {
-	payload := models.WebHookPRComment{}
-	if err := c.BodyParser(&payload); err != nil {
+// Opens a regrade request when a student replies to one of the feedback comments on their work
+func (s *WebHookService) PRComment(ctx context.Context, body []byte) error {
+	payload := github.PullRequestReviewCommentEvent{}
+	if err := json.Unmarshal(body, &payload); err != nil {
 		return err
 	}
-	if payload.Comment.AuthorAssociation == "COLLABORATOR" {
-		println("regrade request")
+	if payload.GetAction() != "created" || payload.Comment.GetInReplyTo() == 0 {
+		return nil
+	}
+
+	feedback, err := s.store.GetFeedbackCommentByGitHubID(ctx, payload.Comment.GetInReplyTo())
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Not a reply to feedback left through the app
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	work, err := s.store.GetWorkByRepoName(ctx, payload.Repo.GetName())
+	if err != nil || work.ID != feedback.StudentWorkID || feedback.DeletedAt != nil {
+		return nil
+	}
+
+	// Only the students the work belongs to can request a regrade
+	user, ok := s.workContributor(ctx, work, payload.Comment.User.GetID())
+	if !ok {
+		return nil
 	}
-	return c.SendStatus(fiber.StatusOK)
-}
 
-func (s *WebHookService) PRThread(c *fiber.Ctx) error {
-	println("PR thread webhook event")
-	return c.SendStatus(fiber.StatusOK)
+	regrades, err := s.store.ListRegradeRequestsOnWork(ctx, int64(work.ID))
+	if err != nil {
+		return err
+	}
+	for _, regrade := range regrades {
+		if regrade.FeedbackCommentID == int64(feedback.ID) && regrade.RegradeState == models.RegradeStateRequested {
+			return nil
+		}
+	}
+
+	commentID := payload.Comment.GetID()
+	_, err = s.store.CreateRegradeRequest(ctx, int64(feedback.ID), *user.ID, payload.Comment.GetBody(), &commentID)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return err
+	}
+
+	return nil
 }
 
-func (s *WebHookService) PushEvent(c *fiber.Ctx) error {
-	// Extract the 'payload' form value
+func (s *WebHookService) PushEvent(ctx context.Context, payload []byte) error {
 	pushEvent := github.PushEvent{}
-	if err := c.BodyParser(&pushEvent); err != nil {
+	if err := json.Unmarshal(payload, &pushEvent); err != nil {
 		return err
 	}
 
 	// If app bot triggered the initial commit, initialize the base repository
 	if isInitialCommit(pushEvent) && isBotPushEvent(pushEvent) {
-		err := s.baseRepoInitialization(c, pushEvent)
+		err := s.baseRepoInitialization(ctx, pushEvent)
 		if err != nil {
 			return err
 		}
@@ -67,7 +160,7 @@ func (s *WebHookService) PushEvent(c *fiber.Ctx) error {
 
 	// If students pushed commits, update the work state accordingly
 	if !isBotPushEvent(pushEvent) && pushEvent.Commits != nil && len(pushEvent.Commits) > 0 {
-		err := s.updateWorkStateOnStudentCommit(c, pushEvent)
+		err := s.updateWorkStateOnStudentCommit(ctx, pushEvent)
 		if err != nil {
 			return err
 		}
@@ -76,27 +169,40 @@ func (s *WebHookService) PushEvent(c *fiber.Ctx) error {
 	return nil
 }
 
-func (s *WebHookService) baseRepoInitialization(c *fiber.Ctx, pushEvent github.PushEvent) error {
+func (s *WebHookService) baseRepoInitialization(ctx context.Context, pushEvent github.PushEvent) error {
 	if pushEvent.Repo == nil || pushEvent.Repo.Organization == nil || pushEvent.Repo.Name == nil || pushEvent.Repo.MasterBranch == nil {
 		return errs.BadRequest(errors.New("invalid repository data"))
 	}
 
 	// Initialize the repository with branches, empty commit, and deadline enforcement
-	err := common.InitializePushEventRepo(c.Context(), s.appClient, s.store, pushEvent.Repo, s.domains.BACKEND_URL)
+	err := common.InitializePushEventRepo(ctx, s.appClient, s.store, pushEvent.Repo, s.domains.BACKEND_URL)
 	if err != nil {
 		return err
 	}
 
-	return c.SendStatus(fiber.StatusOK)
+	return nil
 }
 
-func (s *WebHookService) updateWorkStateOnStudentCommit(c *fiber.Ctx, pushEvent github.PushEvent) error {
+func (s *WebHookService) updateWorkStateOnStudentCommit(ctx context.Context, pushEvent github.PushEvent) error {
 	// Find the associated student work
-	studentWork, err := s.store.GetWorkByRepoName(c.Context(), *pushEvent.Repo.Name)
+	studentWork, err := s.store.GetWorkByRepoName(ctx, *pushEvent.Repo.Name)
+	if err != nil {
+		return err
+	}
+
+	layout, err := common.GetBranchLayout(ctx, s.store, int64(studentWork.AssignmentOutlineID))
 	if err != nil {
 		return err
 	}
 
+	// late policies judge the work by when it reached GitHub, commit dates can be made up
+	pushedAt := pushEvent.Repo.GetPushedAt().Time.UTC()
+	if pushedAt.IsZero() {
+		pushedAt = time.Now().UTC()
+	}
+
+	branch := strings.TrimPrefix(pushEvent.GetRef(), "refs/heads/")
+
 	// Mark the project as started if this is our first student commit
 	if studentWork.WorkState == models.WorkStateAccepted {
 		studentWork.WorkState = models.WorkStateStarted
@@ -104,30 +210,61 @@ func (s *WebHookService) updateWorkStateOnStudentCommit(c *fiber.Ctx, pushEvent
 		studentWork.FirstCommitDate = &firstCommitDate
 	}
 
+	submitted := false
 	if pushEvent.Ref != nil {
 		// Update the last commit date
 		if len(pushEvent.Commits) > 0 {
 			lastCommitDate := pushEvent.Commits[0].Timestamp.Time.UTC()
-			studentWork.LastCommitDate = &lastCommitDate
+			if studentWork.LastCommitDate == nil || lastCommitDate.After(*studentWork.LastCommitDate) {
+				studentWork.LastCommitDate = &lastCommitDate
+			}
 		}
 
-		// TODO: Dynamically determine branch names once parameterized
-		// If commiting to main branch, mark as submitted
-		if *pushEvent.Ref == "refs/heads/"+*pushEvent.Repo.DefaultBranch {
-			studentWork.WorkState = models.WorkStateSubmitted
-		} else if *pushEvent.Ref != "refs/heads/feedback" {
-			// If not committing to main/ or feedback/ branch, increment commit amount
+		// If commiting to the submission branch, mark as submitted while submissions are still accepted
+		if branch == layout.SubmissionBranch {
+			submitted, err = s.acceptSubmission(ctx, &studentWork, pushedAt)
+			if err != nil {
+				return err
+			}
+			if !submitted {
+				fmt.Println("Not taking push as the submission:", pushEvent.GetAfter())
+			}
+		} else if layout.IsWorkBranch(branch) {
+			// If not committing to the submission or feedback branch, increment commit amount
 			studentWork.CommitAmount += len(pushEvent.Commits)
 		}
 	}
 
 	// Store updated student work locally
-	_, err = s.store.UpdateStudentWork(c.Context(), studentWork)
+	_, err = s.store.UpdateStudentWork(ctx, studentWork)
 	if err != nil {
-		return errs.InternalServerError()
+		return err
 	}
 
-	return c.SendStatus(fiber.StatusOK)
+	// Pin the submission for grading
+	if submitted {
+		err = common.SnapshotSubmission(ctx, s.appClient, s.store, studentWork, pushEvent.GetAfter())
+		if err != nil {
+			fmt.Println("Error snapshotting submission:", err)
+		}
+	}
+
+	return nil
+}
+
+// Marks a work as submitted at the given time if it still accepts the submission, returning whether it did
+func (s *WebHookService) acceptSubmission(ctx context.Context, work *models.StudentWork, submittedAt time.Time) (bool, error) {
+	accepted, err := common.AcceptsSubmission(ctx, s.store, *work, submittedAt)
+	if err != nil || !accepted {
+		return false, err
+	}
+
+	// a grader may already be assigned, they grade the new submission
+	if work.WorkState != models.WorkStateGradingAssigned {
+		work.WorkState = models.WorkStateSubmitted
+	}
+	work.SubmittedAt = &submittedAt
+	return true, nil
 }
 
 func isInitialCommit(pushEvent github.PushEvent) bool {
@@ -0,0 +1,149 @@
+package middleware
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt"
+)
+
+const (
+	actionsOIDCIssuer  = "https://token.actions.githubusercontent.com"
+	actionsOIDCKeysURL = actionsOIDCIssuer + "/.well-known/jwks"
+)
+
+// Claims of the OIDC token GitHub issues to a workflow run
+type WorkflowClaims struct {
+	jwt.StandardClaims
+	Repository      string `json:"repository"`       // e.g. "org/repo"
+	RepositoryOwner string `json:"repository_owner"` // e.g. "org"
+	SHA             string `json:"sha"`
+	Ref             string `json:"ref"`
+	WorkflowRef     string `json:"workflow_ref"` // e.g. "org/repo/.github/workflows/autograder.yml@refs/heads/main"
+	RunID           string `json:"run_id"`
+}
+
+// Caches GitHub's OIDC signing keys, refetching them when a token is signed with an unknown key
+var actionsKeys = struct {
+	sync.Mutex
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time
+}{}
+
+// Middleware to protect routes called from GitHub Actions workflows, which authenticate with the
+// OIDC token of their run. The token must have been requested with the given audience.
+func ProtectedWorkflow(audience string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		token, found := strings.CutPrefix(c.Get("Authorization", ""), "Bearer ")
+		if !found || token == "" {
+			return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "missing or invalid workflow token"})
+		}
+
+		claims := &WorkflowClaims{}
+		parsed, err := jwt.ParseWithClaims(token, claims, func(token *jwt.Token) (interface{}, error) {
+			if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+				return nil, errors.New("unexpected signing method")
+			}
+			kid, _ := token.Header["kid"].(string)
+			return getActionsKey(kid)
+		})
+		if err != nil || !parsed.Valid {
+			return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "invalid workflow token"})
+		}
+
+		if !claims.VerifyIssuer(actionsOIDCIssuer, true) || !claims.VerifyAudience(audience, true) {
+			return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "invalid workflow token"})
+		}
+
+		c.Locals("workflowClaims", claims)
+
+		return c.Next()
+	}
+}
+
+/* Warning: Usage of ProtectedWorkflow Middleware is a prerequisite to the use of this function */
+func GetWorkflowClaims(c *fiber.Ctx) (*WorkflowClaims, error) {
+	claims, ok := c.Locals("workflowClaims").(*WorkflowClaims)
+	if !ok {
+		return nil, errors.New("failed to retrieve workflow claims from context")
+	}
+	return claims, nil
+}
+
+func getActionsKey(kid string) (*rsa.PublicKey, error) {
+	actionsKeys.Lock()
+	defer actionsKeys.Unlock()
+
+	if key, ok := actionsKeys.keys[kid]; ok {
+		return key, nil
+	}
+
+	// Don't let tokens with made up key IDs hammer GitHub
+	if time.Since(actionsKeys.fetchedAt) < time.Minute {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	keys, err := fetchActionsKeys()
+	if err != nil {
+		return nil, err
+	}
+	actionsKeys.keys = keys
+	actionsKeys.fetchedAt = time.Now()
+
+	key, ok := keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+func fetchActionsKeys() (map[string]*rsa.PublicKey, error) {
+	client := http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(actionsOIDCKeysURL)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching OIDC keys: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var jwks struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&jwks); err != nil {
+		return nil, fmt.Errorf("error decoding OIDC keys: %v", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, jwk := range jwks.Keys {
+		if jwk.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	return keys, nil
+}
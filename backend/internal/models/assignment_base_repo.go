@@ -5,9 +5,10 @@ import (
 )
 
 type AssignmentBaseRepo struct {
-	BaseRepoOwner string    `json:"base_repo_owner"`
-	BaseRepoName  string    `json:"base_repo_name"`
-	BaseID        int64     `json:"base_repo_id"`
-	CreatedAt     time.Time `json:"created_at,omitempty"`
-	Initialized   bool      `json:"initialized"`
+	BaseRepoOwner string     `json:"base_repo_owner"`
+	BaseRepoName  string     `json:"base_repo_name"`
+	BaseID        int64      `json:"base_repo_id"`
+	CreatedAt     time.Time  `json:"created_at,omitempty"`
+	Initialized   bool       `json:"initialized"`
+	DeletedAt     *time.Time `json:"deleted_at"` // deleted or transferred out of the org
 }
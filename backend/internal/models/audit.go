@@ -0,0 +1,44 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// An action that changed a grade, or how grades are computed
+type GradingAuditAction string
+
+const (
+	GradingAuditFeedbackCreated           GradingAuditAction = "FEEDBACK_CREATED"
+	GradingAuditFeedbackEdited            GradingAuditAction = "FEEDBACK_EDITED"
+	GradingAuditFeedbackDeleted           GradingAuditAction = "FEEDBACK_DELETED"
+	GradingAuditRubricUpdated             GradingAuditAction = "RUBRIC_UPDATED"
+	GradingAuditRubricDeleted             GradingAuditAction = "RUBRIC_DELETED"
+	GradingAuditRubricSectionCreated      GradingAuditAction = "RUBRIC_SECTION_CREATED"
+	GradingAuditRubricSectionUpdated      GradingAuditAction = "RUBRIC_SECTION_UPDATED"
+	GradingAuditRubricSectionDeleted      GradingAuditAction = "RUBRIC_SECTION_DELETED"
+	GradingAuditRubricItemPropagated      GradingAuditAction = "RUBRIC_ITEM_PROPAGATED"
+	GradingAuditRegradeResolved           GradingAuditAction = "REGRADE_RESOLVED"
+	GradingAuditExtensionGranted          GradingAuditAction = "DEADLINE_EXTENSION_GRANTED"
+	GradingAuditExtensionRevoked          GradingAuditAction = "DEADLINE_EXTENSION_REVOKED"
+	GradingAuditGradesPublished           GradingAuditAction = "GRADES_PUBLISHED"
+	GradingAuditGradePublicationScheduled GradingAuditAction = "GRADE_PUBLICATION_SCHEDULED"
+	GradingAuditGradePublicationCancelled GradingAuditAction = "GRADE_PUBLICATION_CANCELLED"
+)
+
+// An entry of the append-only grading audit log
+type GradingAuditEntry struct {
+	ID                  int64              `json:"id"`
+	Action              GradingAuditAction `json:"action"`
+	ActorUserID         *int64             `json:"actor_user_id"` // nil for actions the system took, e.g. scheduled grade publication
+	ActorGHUsername     *string            `json:"actor_gh_username" db:"actor_gh_username"`
+	ClassroomID         int64              `json:"classroom_id"`
+	AssignmentOutlineID *int64             `json:"assignment_outline_id"`
+	StudentWorkID       *int64             `json:"student_work_id"`
+	StudentUserID       *int64             `json:"student_user_id"` // the student an assignment-wide action applies to, e.g. a deadline extension
+	RubricID            *int64             `json:"rubric_id"`
+	SubjectID           *int64             `json:"subject_id"` // the feedback comment, rubric section or item, regrade request or extension acted on
+	Before              json.RawMessage    `json:"before"`
+	After               json.RawMessage    `json:"after"`
+	CreatedAt           time.Time          `json:"created_at"`
+}
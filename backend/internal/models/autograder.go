@@ -0,0 +1,105 @@
+package models
+
+import (
+	"math"
+	"time"
+)
+
+// Which of a student work's autograder results counts as its score
+type AutograderScorePolicy string
+
+const (
+	AutograderScorePolicyMax    AutograderScorePolicy = "MAX"
+	AutograderScorePolicyLatest AutograderScorePolicy = "LATEST"
+)
+
+// How an assignment is autograded: the tests rendered into its autograder workflow and how results are scored
+type AutograderConfig struct {
+	AssignmentOutlineID int32                 `json:"assignment_outline_id"`
+	ScorePolicy         AutograderScorePolicy `json:"score_policy"`
+	SetupCommand        *string               `json:"setup_command"`
+	Tests               []AutograderTest      `json:"tests"`
+	TimeoutMinutes      int                   `json:"timeout_minutes"`
+}
+
+// A command run by the autograder workflow, worth its points if it exits successfully
+type AutograderTest struct {
+	Name           string `json:"name"`
+	Run            string `json:"run"`
+	Points         int    `json:"points"`
+	TimeoutMinutes *int   `json:"timeout_minutes,omitempty"`
+}
+
+// Machine-readable test results, as reported by the autograder workflow or a check run
+type AutograderReport struct {
+	Tests []AutograderReportTest `json:"tests"`
+}
+
+// Converts reported tests to results, rounding scores to whole points
+func (r AutograderReport) TestResults() []AutograderTestResult {
+	tests := make([]AutograderTestResult, 0, len(r.Tests))
+	for _, test := range r.Tests {
+		tests = append(tests, AutograderTestResult{
+			Name:     test.Name,
+			Status:   test.Status,
+			Score:    int(math.Round(test.Score)),
+			MaxScore: int(math.Round(test.MaxScore)),
+		})
+	}
+	return tests
+}
+
+// Scores the outcomes reported by an assignment's autograder workflow against its config, so the points come from
+// the config rather than the report. Configured tests missing from the report score nothing, unknown ones are ignored.
+func (config AutograderConfig) ScoreReport(report AutograderReport) []AutograderTestResult {
+	statuses := make(map[string]string, len(report.Tests))
+	for _, test := range report.Tests {
+		if _, ok := statuses[test.Name]; !ok {
+			statuses[test.Name] = test.Status
+		}
+	}
+
+	tests := make([]AutograderTestResult, 0, len(config.Tests))
+	for _, test := range config.Tests {
+		status, ok := statuses[test.Name]
+		if !ok {
+			status = "skipped"
+		}
+		score := 0
+		if status == "success" {
+			score = test.Points
+		}
+		tests = append(tests, AutograderTestResult{Name: test.Name, Status: status, Score: score, MaxScore: test.Points})
+	}
+	return tests
+}
+
+type AutograderReportTest struct {
+	Name     string  `json:"name"`
+	Status   string  `json:"status"`
+	Score    float64 `json:"score"`
+	MaxScore float64 `json:"max_score"`
+}
+
+// The autograder outcome of a single commit of a student work
+type AutograderResult struct {
+	ID            int64                  `json:"id"`
+	StudentWorkID int64                  `json:"student_work_id"`
+	CommitSHA     string                 `json:"commit_sha"`
+	Score         int                    `json:"score"`
+	MaxScore      int                    `json:"max_score"`
+	Verified      bool                   `json:"verified"` // only verified results count towards the grade
+	CreatedAt     time.Time              `json:"created_at"`
+	UpdatedAt     time.Time              `json:"updated_at"`
+	Tests         []AutograderTestResult `json:"tests" db:"-"`
+}
+
+type AutograderTestResult struct {
+	ID                 int64     `json:"id"`
+	AutograderResultID int64     `json:"autograder_result_id"`
+	Name               string    `json:"name"`
+	Status             string    `json:"status"`
+	Score              int       `json:"score"`
+	MaxScore           int       `json:"max_score"`
+	CreatedAt          time.Time `json:"created_at"`
+}
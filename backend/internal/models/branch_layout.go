@@ -0,0 +1,84 @@
+package models
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// The branch created alongside the submission branch for students to work on, when the layout has one
+const DevelopmentBranchName = "development"
+
+// The branches of an assignment's repositories and what each one is for
+type BranchLayout struct {
+	AssignmentOutlineID int32     `json:"assignment_outline_id"`
+	SubmissionBranch    string    `json:"submission_branch"` // pushing here submits the work, and it is the head of the feedback PR
+	FeedbackBranch      string    `json:"feedback_branch"`   // the base of the feedback PR, which students can't open pull requests into
+	DevelopmentBranch   bool      `json:"development_branch"`
+	ExtraBranches       []string  `json:"extra_branches"`
+	UpdatedAt           time.Time `json:"updated_at"`
+}
+
+// Assignments that aren't configured submit on main, with development and feedback branches
+var DefaultBranchLayout = BranchLayout{
+	SubmissionBranch:  "main",
+	FeedbackBranch:    "feedback",
+	DevelopmentBranch: true,
+	ExtraBranches:     []string{},
+}
+
+// The branches created from the submission branch when a repository is set up
+func (l BranchLayout) CreatedBranches() []string {
+	branches := []string{}
+	if l.DevelopmentBranch {
+		branches = append(branches, DevelopmentBranchName)
+	}
+	branches = append(branches, l.FeedbackBranch)
+	return append(branches, l.ExtraBranches...)
+}
+
+// Whether commits pushed to a branch count as the student's work in progress, rather than a submission or feedback
+func (l BranchLayout) IsWorkBranch(branch string) bool {
+	return branch != l.SubmissionBranch && branch != l.FeedbackBranch
+}
+
+var branchNamePattern = regexp.MustCompile(`^[A-Za-z0-9._/-]+$`)
+
+// A conservative subset of the names git accepts for branches
+func validBranchName(name string) bool {
+	return branchNamePattern.MatchString(name) &&
+		!strings.HasPrefix(name, "/") && !strings.HasPrefix(name, "-") && !strings.HasPrefix(name, ".") &&
+		!strings.HasSuffix(name, "/") && !strings.HasSuffix(name, ".") && !strings.HasSuffix(name, ".lock") &&
+		!strings.Contains(name, "..") && !strings.Contains(name, "//")
+}
+
+// Returns the invalid fields of a branch layout, keyed by their JSON names
+func (l BranchLayout) Validate() map[string]string {
+	invalid := make(map[string]string)
+	if !validBranchName(l.SubmissionBranch) {
+		invalid["submission_branch"] = "must be a valid branch name"
+	}
+	if !validBranchName(l.FeedbackBranch) {
+		invalid["feedback_branch"] = "must be a valid branch name"
+	} else if l.FeedbackBranch == l.SubmissionBranch {
+		invalid["feedback_branch"] = "must differ from the submission branch"
+	}
+
+	seen := []string{l.SubmissionBranch, l.FeedbackBranch}
+	if l.DevelopmentBranch {
+		seen = append(seen, DevelopmentBranchName)
+	}
+	for _, branch := range l.ExtraBranches {
+		if !validBranchName(branch) {
+			invalid["extra_branches"] = "must all be valid branch names"
+			break
+		}
+		if slices.Contains(seen, branch) {
+			invalid["extra_branches"] = "must not repeat another branch of the layout"
+			break
+		}
+		seen = append(seen, branch)
+	}
+	return invalid
+}
@@ -6,12 +6,13 @@ import (
 )
 
 type Classroom struct {
-	ID              int64     `json:"id"`
-	Name            string    `json:"name"`
-	OrgID           int64     `json:"org_id"`
-	OrgName         string    `json:"org_name"`
-	CreatedAt       time.Time `json:"created_at"`
-	StudentTeamName *string   `json:"student_team_name,omitempty"`
+	ID              int64      `json:"id"`
+	Name            string     `json:"name"`
+	OrgID           int64      `json:"org_id"`
+	OrgName         string     `json:"org_name"`
+	CreatedAt       time.Time  `json:"created_at"`
+	StudentTeamName *string    `json:"student_team_name,omitempty"`
+	SuspendedAt     *time.Time `json:"suspended_at"` // set while the organization has the app uninstalled or suspended
 }
 
 type ClassroomRole string
@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// A student's personal deadline for an assignment
+type DeadlineExtension struct {
+	ID                  int64      `json:"id"`
+	AssignmentOutlineID int64      `json:"assignment_outline_id"`
+	UserID              int64      `json:"user_id"`
+	GithubUsername      string     `json:"github_username"`
+	DueDate             time.Time  `json:"due_date"`
+	Reason              *string    `json:"reason"`
+	GrantedBy           int64      `json:"granted_by"`
+	CreatedAt           time.Time  `json:"created_at"`
+	RevokedAt           *time.Time `json:"revoked_at"`
+	RevokedBy           *int64     `json:"revoked_by"`
+}
+
+// Extends one student's deadline, either to a new due date or by a number of hours past the assignment's
+type DeadlineExtensionRequest struct {
+	UserID         int64      `json:"user_id"`
+	DueDate        *time.Time `json:"due_date"`
+	ExtensionHours *int       `json:"extension_hours"`
+	Reason         *string    `json:"reason"`
+}
+
+// Extends the deadlines of several students by the same number of hours, e.g. for an accommodations list
+type BulkDeadlineExtensionRequest struct {
+	UserIDs        []int64 `json:"user_ids"`
+	AssignmentIDs  []int64 `json:"assignment_ids"` // every assignment in the classroom if omitted
+	ExtensionHours int     `json:"extension_hours"`
+	Reason         *string `json:"reason"`
+}
+
+// The extensions a bulk grant gave one of its students
+type BulkDeadlineExtensionResult struct {
+	UserID             int64               `json:"user_id"`
+	DeadlineExtensions []DeadlineExtension `json:"deadline_extensions"`
+}
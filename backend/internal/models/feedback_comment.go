@@ -3,12 +3,51 @@ package models
 import "time"
 
 type FeedbackComment struct {
-	StudentWorkID int       `json:"student_work_id"`
-	RubricItemID  int       `json:"rubric_item_id"`
-	TAUsername    string    `json:"github_username" db:"github_username"`
-	PointValue    int       `json:"point_value"`
-	Explanation   string    `json:"explanation"`
-	FilePath      *string   `json:"file_path"`
-	FileLine      *int      `json:"file_line"`
-	CreatedAt     time.Time `json:"created_at"`
+	ID              int        `json:"id"`
+	StudentWorkID   int        `json:"student_work_id"`
+	RubricItemID    int        `json:"rubric_item_id"`
+	TAUsername      string     `json:"github_username" db:"github_username"`
+	PointValue      int        `json:"point_value"`
+	Explanation     string     `json:"explanation"`
+	FilePath        *string    `json:"file_path"`
+	FileLine        *int       `json:"file_line"`
+	FileStartLine   *int       `json:"file_start_line"`
+	FileSide        *DiffSide  `json:"file_side"`
+	FileStartSide   *DiffSide  `json:"file_start_side"`
+	GitHubCommentID *int64     `json:"github_comment_id" db:"github_comment_id"`
+	PublishedAt     *time.Time `json:"published_at"`
+	AcknowledgedAt  *time.Time `json:"acknowledged_at"` // when a student resolved the feedback's review thread
+	AcknowledgedBy  *int64     `json:"acknowledged_by"`
+	CreatedAt       time.Time  `json:"created_at"`
+	DeletedAt       *time.Time `json:"deleted_at,omitempty"`
+}
+
+// The feedback as a comment on the student's pull request
+func (feedback FeedbackComment) ReviewComment() PRReviewComment {
+	return PRReviewComment{
+		Path:      feedback.FilePath,
+		Line:      feedback.FileLine,
+		StartLine: feedback.FileStartLine,
+		Side:      feedback.FileSide,
+		StartSide: feedback.FileStartSide,
+		Body:      feedback.Explanation,
+	}
+}
+
+// What a feedback comment was before it was edited or deleted
+type FeedbackCommentRevision struct {
+	ID                int64                 `json:"id"`
+	FeedbackCommentID int64                 `json:"feedback_comment_id"`
+	Action            PRReviewCommentAction `json:"action"`
+	RubricItemID      int64                 `json:"rubric_item_id"`
+	PointValue        int                   `json:"point_value"`
+	Explanation       string                `json:"explanation"`
+	FilePath          *string               `json:"file_path"`
+	FileLine          *int                  `json:"file_line"`
+	FileStartLine     *int                  `json:"file_start_line"`
+	FileSide          *DiffSide             `json:"file_side"`
+	FileStartSide     *DiffSide             `json:"file_start_side"`
+	ChangedBy         int64                 `json:"changed_by"`
+	ChangedByUsername string                `json:"changed_by_username"`
+	ChangedAt         time.Time             `json:"changed_at"`
 }
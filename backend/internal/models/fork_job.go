@@ -0,0 +1,59 @@
+package models
+
+import "time"
+
+type ForkJobState string
+
+const (
+	ForkJobStatePending    ForkJobState = "PENDING"
+	ForkJobStateInProgress ForkJobState = "IN_PROGRESS"
+	ForkJobStateCompleted  ForkJobState = "COMPLETED"
+	ForkJobStateFailed     ForkJobState = "FAILED"
+)
+
+// The named steps of accepting an assignment, in the order they run.
+// Each step is idempotent, so a retried job resumes at the first step not yet checkpointed.
+type ForkJobStep string
+
+const (
+	ForkJobStepCreateFork          ForkJobStep = "CREATE_FORK"
+	ForkJobStepAwaitFork           ForkJobStep = "AWAIT_FORK"
+	ForkJobStepResetToFirstCommit  ForkJobStep = "RESET_TO_FIRST_COMMIT"
+	ForkJobStepCreateFeedbackPR    ForkJobStep = "CREATE_FEEDBACK_PR"
+	ForkJobStepCreateBranchRuleset ForkJobStep = "CREATE_BRANCH_RULESET"
+	ForkJobStepCreatePushRuleset   ForkJobStep = "CREATE_PUSH_RULESET"
+	ForkJobStepRemoveTeamAccess    ForkJobStep = "REMOVE_TEAM_ACCESS"
+	ForkJobStepGrantTeamAccess     ForkJobStep = "GRANT_TEAM_ACCESS"
+	ForkJobStepCreateStudentWork   ForkJobStep = "CREATE_STUDENT_WORK"
+)
+
+var ForkJobSteps = []ForkJobStep{
+	ForkJobStepCreateFork,
+	ForkJobStepAwaitFork,
+	ForkJobStepResetToFirstCommit,
+	ForkJobStepCreateFeedbackPR,
+	ForkJobStepCreateBranchRuleset,
+	ForkJobStepCreatePushRuleset,
+	ForkJobStepRemoveTeamAccess,
+	ForkJobStepGrantTeamAccess,
+	ForkJobStepCreateStudentWork,
+}
+
+// A student's acceptance of an assignment, set up in the background once GitHub finishes copying their fork
+type ForkJob struct {
+	ID                  int64         `json:"id"`
+	AssignmentOutlineID int32         `json:"assignment_outline_id"`
+	UserID              int64         `json:"user_id"`
+	GitHubUserID        int64         `json:"github_user_id"`
+	OrgName             string        `json:"org_name"`
+	RepoName            string        `json:"repo_name"`
+	FirstCommitSHA      string        `json:"first_commit_sha"`
+	TeamID              *int64        `json:"team_id"`
+	State               ForkJobState  `json:"state"`
+	CompletedSteps      []ForkJobStep `json:"completed_steps"`
+	Attempts            int           `json:"attempts"`
+	LastError           *string       `json:"last_error"`
+	NextAttemptAt       time.Time     `json:"next_attempt_at"`
+	CreatedAt           time.Time     `json:"created_at"`
+	UpdatedAt           time.Time     `json:"updated_at"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// The release of an assignment's draft grades to students, either immediate or scheduled
+type GradePublication struct {
+	AssignmentOutlineID int32      `json:"assignment_outline_id"`
+	PublishAt           time.Time  `json:"publish_at"`
+	PublishedAt         *time.Time `json:"published_at"`
+	ScheduledBy         int64      `json:"scheduled_by"`
+	LastError           *string    `json:"last_error"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+}
+
+// The body of the review a work's draft feedback will be posted with
+type ReviewDraft struct {
+	StudentWorkID int64     `json:"student_work_id"`
+	TAUserID      int64     `json:"ta_user_id"`
+	Body          string    `json:"body"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+type PublishGradesRequest struct {
+	PublishAt *time.Time `json:"publish_at"` // publish immediately if omitted or in the past
+}
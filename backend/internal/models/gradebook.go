@@ -0,0 +1,60 @@
+package models
+
+import "time"
+
+// The layout of an exported gradebook
+type GradebookFormat string
+
+const (
+	GradebookFormatCSV        GradebookFormat = "CSV"        // one column per score of each assignment
+	GradebookFormatCanvas     GradebookFormat = "CANVAS"     // Canvas gradebook import
+	GradebookFormatBlackboard GradebookFormat = "BLACKBOARD" // Blackboard Grade Center upload
+)
+
+// Which value identifies a student to the LMS a gradebook is imported into
+type StudentIdentifier string
+
+const (
+	StudentIdentifierGitHubUsername StudentIdentifier = "GITHUB_USERNAME"
+	StudentIdentifierGitHubUserID   StudentIdentifier = "GITHUB_USER_ID"
+	StudentIdentifierStudentID      StudentIdentifier = "STUDENT_ID" // the institution's ID, set per classroom membership
+)
+
+// A student with a row in a classroom's gradebook
+type GradebookStudent struct {
+	UserID            int64   `json:"user_id"`
+	FirstName         *string `json:"first_name"`
+	LastName          *string `json:"last_name"`
+	GithubUsername    string  `json:"github_username"`
+	GithubUserID      int64   `json:"github_user_id"`
+	StudentIdentifier *string `json:"student_identifier"`
+}
+
+// A student's scores on one assignment
+type GradebookScore struct {
+	UserID                   int64      `json:"user_id"`
+	AssignmentOutlineID      int64      `json:"assignment_outline_id"`
+	ManualFeedbackScore      *int       `json:"manual_feedback_score"`
+	AutoGraderScore          *int       `json:"auto_grader_score"`
+	GradesPublishedTimestamp *time.Time `json:"grades_published_timestamp"`
+}
+
+// The sum of the manual and autograder scores, nil if neither has been given
+func (s GradebookScore) Total() *int {
+	if s.ManualFeedbackScore == nil && s.AutoGraderScore == nil {
+		return nil
+	}
+
+	total := 0
+	if s.ManualFeedbackScore != nil {
+		total += *s.ManualFeedbackScore
+	}
+	if s.AutoGraderScore != nil {
+		total += *s.AutoGraderScore
+	}
+	return &total
+}
+
+type StudentIdentifierRequest struct {
+	StudentIdentifier *string `json:"student_identifier"`
+}
@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// How student works are distributed among graders
+type GradingStrategy string
+
+const (
+	GradingStrategyManual     GradingStrategy = "MANUAL"      // each work goes to the TA it is paired with
+	GradingStrategyRoundRobin GradingStrategy = "ROUND_ROBIN" // works are dealt out to TAs in turn
+	GradingStrategyBalanced   GradingStrategy = "BALANCED"    // works go to whichever TA has the fewest left to grade
+)
+
+// The TA responsible for grading a student work
+type GradingAssignment struct {
+	StudentWorkID       int64     `json:"student_work_id"`
+	AssignmentOutlineID int64     `json:"assignment_outline_id"`
+	RepoName            string    `json:"repo_name"`
+	WorkState           WorkState `json:"work_state"`
+	TAUserID            int64     `json:"ta_user_id"`
+	TAGHUsername        string    `json:"ta_gh_username" db:"ta_gh_username"`
+	AssignedBy          int64     `json:"assigned_by"`
+	CreatedAt           time.Time `json:"created_at"`
+	UpdatedAt           time.Time `json:"updated_at"`
+}
+
+type GraderPairing struct {
+	StudentWorkID int64 `json:"student_work_id"`
+	TAUserID      int64 `json:"ta_user_id"`
+}
+
+type AssignGradersRequest struct {
+	Strategy       GradingStrategy `json:"strategy"`
+	TAUserIDs      []int64         `json:"ta_user_ids"`      // the graders to distribute among, all TAs in the classroom if omitted
+	StudentWorkIDs []int64         `json:"student_work_ids"` // the works to distribute, all ungraded works without a grader if omitted
+	Pairings       []GraderPairing `json:"pairings"`         // the assignments to make with the MANUAL strategy
+	RequestReview  bool            `json:"request_review"`   // request the grader's review on the feedback pull request
+}
+
+type ReassignGraderRequest struct {
+	TAUserID      int64 `json:"ta_user_id"`
+	RequestReview bool  `json:"request_review"`
+}
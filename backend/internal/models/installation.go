@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// An account the GitHub app is installed on
+type AppInstallation struct {
+	InstallationID      int64      `json:"installation_id"`
+	AccountID           int64      `json:"account_id"`
+	AccountLogin        string     `json:"account_login"`
+	AccountType         string     `json:"account_type"`         // Organization or User
+	RepositorySelection *string    `json:"repository_selection"` // all or selected
+	SuspendedAt         *time.Time `json:"suspended_at"`
+	UninstalledAt       *time.Time `json:"uninstalled_at"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+}
+
+// Whether the app can currently act on the account
+func (installation AppInstallation) IsActive() bool {
+	return installation.SuspendedAt == nil && installation.UninstalledAt == nil
+}
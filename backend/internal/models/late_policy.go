@@ -0,0 +1,93 @@
+package models
+
+import (
+	"math"
+	"time"
+)
+
+// How late submissions to an assignment are penalized
+type LatePolicy struct {
+	AssignmentOutlineID  int32 `json:"assignment_outline_id"`
+	GracePeriodMinutes   int   `json:"grace_period_minutes"`
+	PenaltyPercentPerDay int   `json:"penalty_percent_per_day"`
+	MaxPenaltyPercent    int   `json:"max_penalty_percent"`
+	HardCutoffHours      *int  `json:"hard_cutoff_hours"` // after the deadline, when submissions stop being accepted
+	AllowLateTokens      bool  `json:"allow_late_tokens"`
+}
+
+// Where a submission falls relative to its deadline
+type LateStatus string
+
+const (
+	LateStatusNoDeadline  LateStatus = "NO_DEADLINE"
+	LateStatusOnTime      LateStatus = "ON_TIME"
+	LateStatusGracePeriod LateStatus = "GRACE_PERIOD" // late, but forgiven
+	LateStatusLate        LateStatus = "LATE"         // accepted with a penalty
+	LateStatusClosed      LateStatus = "CLOSED"       // no longer accepted
+)
+
+// The outcome of applying a late policy to a submission
+type LateOutcome struct {
+	Status             LateStatus `json:"status"`
+	AcceptsSubmissions bool       `json:"accepts_submissions"`
+	Deadline           *time.Time `json:"deadline"`
+	GraceDeadline      *time.Time `json:"grace_deadline"`
+	Cutoff             *time.Time `json:"cutoff"`
+	LateTokensUsed     int        `json:"late_tokens_used"`
+	DaysLate           int        `json:"days_late"`
+	PenaltyPercent     int        `json:"penalty_percent"`
+}
+
+// Each late token pushes a student's deadline back by this much
+const LateTokenDuration = 24 * time.Hour
+
+// Applies a late policy to a submission made at the given time. A nil policy accepts late submissions
+// without a penalty.
+// The student_work_lateness view makes the same calculation for recorded submissions, keep them in sync.
+func EvaluateLateness(policy *LatePolicy, dueDate *time.Time, lateTokensUsed int, submittedAt time.Time) LateOutcome {
+	if dueDate == nil {
+		return LateOutcome{Status: LateStatusNoDeadline, AcceptsSubmissions: true}
+	}
+
+	deadline := dueDate.Add(time.Duration(lateTokensUsed) * LateTokenDuration)
+	outcome := LateOutcome{Deadline: &deadline, LateTokensUsed: lateTokensUsed}
+
+	if policy == nil {
+		policy = &LatePolicy{}
+	}
+
+	graceDeadline := deadline.Add(time.Duration(policy.GracePeriodMinutes) * time.Minute)
+	outcome.GraceDeadline = &graceDeadline
+	if policy.HardCutoffHours != nil {
+		cutoff := deadline.Add(time.Duration(*policy.HardCutoffHours) * time.Hour)
+		outcome.Cutoff = &cutoff
+	}
+
+	switch {
+	case !submittedAt.After(deadline):
+		outcome.Status = LateStatusOnTime
+	case !submittedAt.After(graceDeadline):
+		outcome.Status = LateStatusGracePeriod
+	default:
+		outcome.Status = LateStatusLate
+		outcome.DaysLate = int(math.Ceil(submittedAt.Sub(deadline).Hours() / 24))
+		outcome.PenaltyPercent = min(outcome.DaysLate*policy.PenaltyPercentPerDay, policy.MaxPenaltyPercent)
+	}
+
+	if outcome.Cutoff != nil && submittedAt.After(*outcome.Cutoff) {
+		outcome.Status = LateStatusClosed
+		outcome.PenaltyPercent = 100
+	}
+	outcome.AcceptsSubmissions = outcome.Status != LateStatusClosed
+
+	return outcome
+}
+
+type LateTokenBudget struct {
+	ClassroomID      int64 `json:"classroom_id"`
+	TokensPerStudent int   `json:"tokens_per_student"`
+}
+
+type SpendLateTokensRequest struct {
+	Tokens int `json:"tokens"`
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEvaluateLateness(t *testing.T) {
+	due := time.Date(2024, time.October, 1, 23, 59, 0, 0, time.UTC)
+	cutoffHours := 72
+	policy := &LatePolicy{
+		GracePeriodMinutes:   15,
+		PenaltyPercentPerDay: 10,
+		MaxPenaltyPercent:    30,
+		HardCutoffHours:      &cutoffHours,
+		AllowLateTokens:      true,
+	}
+	uncapped := &LatePolicy{PenaltyPercentPerDay: 10, MaxPenaltyPercent: 100}
+
+	tests := []struct {
+		name        string
+		policy      *LatePolicy
+		dueDate     *time.Time
+		lateTokens  int
+		submittedAt time.Time
+		status      LateStatus
+		accepts     bool
+		daysLate    int
+		penalty     int
+	}{
+		{"no deadline", policy, nil, 0, due.Add(1000 * time.Hour), LateStatusNoDeadline, true, 0, 0},
+		{"no policy, on time", nil, &due, 0, due.Add(-time.Minute), LateStatusOnTime, true, 0, 0},
+		{"no policy, at the deadline", nil, &due, 0, due, LateStatusOnTime, true, 0, 0},
+		{"no policy, late", nil, &due, 0, due.Add(time.Minute), LateStatusLate, true, 1, 0},
+		{"no policy, late tokens move the deadline", nil, &due, 1, due.Add(23 * time.Hour), LateStatusOnTime, true, 0, 0},
+		{"on time", policy, &due, 0, due, LateStatusOnTime, true, 0, 0},
+		{"within the grace period", policy, &due, 0, due.Add(15 * time.Minute), LateStatusGracePeriod, true, 0, 0},
+		{"past the grace period", policy, &due, 0, due.Add(16 * time.Minute), LateStatusLate, true, 1, 10},
+		{"partial days round up", policy, &due, 0, due.Add(25 * time.Hour), LateStatusLate, true, 2, 20},
+		{"penalty is capped", policy, &due, 0, due.Add(71 * time.Hour), LateStatusLate, true, 3, 30},
+		{"at the cutoff", policy, &due, 0, due.Add(72 * time.Hour), LateStatusLate, true, 3, 30},
+		{"past the cutoff", policy, &due, 0, due.Add(73 * time.Hour), LateStatusClosed, false, 4, 100},
+		{"late tokens move the cutoff", policy, &due, 2, due.Add(73 * time.Hour), LateStatusLate, true, 2, 20},
+		{"no cutoff", uncapped, &due, 0, due.Add(30 * 24 * time.Hour), LateStatusLate, true, 30, 100},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			outcome := EvaluateLateness(test.policy, test.dueDate, test.lateTokens, test.submittedAt)
+
+			if outcome.Status != test.status {
+				t.Errorf("status = %s, want %s", outcome.Status, test.status)
+			}
+			if outcome.AcceptsSubmissions != test.accepts {
+				t.Errorf("accepts submissions = %t, want %t", outcome.AcceptsSubmissions, test.accepts)
+			}
+			if outcome.DaysLate != test.daysLate {
+				t.Errorf("days late = %d, want %d", outcome.DaysLate, test.daysLate)
+			}
+			if outcome.PenaltyPercent != test.penalty {
+				t.Errorf("penalty = %d%%, want %d%%", outcome.PenaltyPercent, test.penalty)
+			}
+			if outcome.LateTokensUsed != test.lateTokens && test.dueDate != nil {
+				t.Errorf("late tokens used = %d, want %d", outcome.LateTokensUsed, test.lateTokens)
+			}
+		})
+	}
+}
@@ -1,14 +1,72 @@
 package models
 
+import (
+	"errors"
+	"time"
+)
+
 type PRReviewRequest struct {
 	Body     string                    `json:"body"`
 	Comments []PRReviewCommentResponse `json:"comments"`
 }
 
+// A comment on the entire work, an entire file (a path without a line), a line, or a range of lines
 type PRReviewComment struct {
-	Path *string `json:"path"`
-	Line *int    `json:"line"`
-	Body string  `json:"body"`
+	Path      *string   `json:"path"`
+	Line      *int      `json:"line"` // the last line of a multi-line comment
+	StartLine *int      `json:"start_line,omitempty"`
+	Side      *DiffSide `json:"side,omitempty"` // GitHub assumes RIGHT when omitted
+	StartSide *DiffSide `json:"start_side,omitempty"`
+	Body      string    `json:"body"`
+}
+
+// The side of a pull request's diff a comment is on: LEFT for the old version, RIGHT for the new one
+type DiffSide string
+
+const (
+	DiffSideLeft  DiffSide = "LEFT"
+	DiffSideRight DiffSide = "RIGHT"
+)
+
+// Whether the comment is on an entire file rather than some of its lines
+func (comment PRReviewComment) IsFileComment() bool {
+	return comment.Path != nil && comment.Line == nil
+}
+
+// Checks that the comment's location is one GitHub can post a comment on
+func (comment PRReviewComment) ValidateLocation() error {
+	switch {
+	case comment.Line != nil && comment.Path == nil:
+		return errors.New("comments on lines need the path of their file")
+	case comment.StartLine != nil && comment.Line == nil:
+		return errors.New("multi-line comments need their last line")
+	case comment.StartLine != nil && *comment.StartLine >= *comment.Line:
+		return errors.New("start_line must be before line")
+	case comment.Side != nil && comment.Line == nil:
+		return errors.New("only comments on lines have a side")
+	case comment.StartSide != nil && comment.StartLine == nil:
+		return errors.New("only multi-line comments have a start_side")
+	}
+	for _, side := range []*DiffSide{comment.Side, comment.StartSide} {
+		if side != nil && *side != DiffSideLeft && *side != DiffSideRight {
+			return errors.New("side must be LEFT or RIGHT")
+		}
+	}
+	return nil
+}
+
+// Whether two comments are in the same place
+func (comment PRReviewComment) SameLocation(other PRReviewComment) bool {
+	return equalPtr(comment.Path, other.Path) && equalPtr(comment.Line, other.Line) &&
+		equalPtr(comment.StartLine, other.StartLine) && equalPtr(comment.Side, other.Side) &&
+		equalPtr(comment.StartSide, other.StartSide)
+}
+
+func equalPtr[T comparable](a *T, b *T) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
 }
 
 type PRReviewCommentAction string
@@ -24,6 +82,40 @@ type PRReviewCommentResponse struct {
 	Action            PRReviewCommentAction `json:"action"`
 	RubricItemID      *int                  `json:"rubric_item_id"`
 	FeedbackCommentID *int                  `json:"feedback_comment_id"`
+	GitHubCommentID   *int64                `json:"github_comment_id"`
+	PublishedAt       *time.Time            `json:"published_at"`
+	AcknowledgedAt    *time.Time            `json:"acknowledged_at,omitempty"`
 	Points            int                   `json:"points"`
 	TAUsername        string                `json:"ta_username"`
 }
+
+// A pull request review comment as GitHub returns it. go-github doesn't know about comments on multiple lines
+// or on entire files, so only the fields we use are here.
+type GitHubReviewComment struct {
+	ID          int64      `json:"id"`
+	InReplyTo   *int64     `json:"in_reply_to_id"`
+	Body        string     `json:"body"`
+	Path        string     `json:"path"`
+	Line        *int       `json:"line"`
+	StartLine   *int       `json:"start_line"`
+	Side        *DiffSide  `json:"side"`
+	StartSide   *DiffSide  `json:"start_side"`
+	SubjectType string     `json:"subject_type"` // "file" for comments on an entire file
+	User        GitHubUser `json:"user"`
+}
+
+// Where the comment was left, and what it says
+func (comment GitHubReviewComment) ReviewComment() PRReviewComment {
+	path := comment.Path
+	if comment.SubjectType == "file" {
+		return PRReviewComment{Path: &path, Body: comment.Body}
+	}
+	return PRReviewComment{
+		Path:      &path,
+		Line:      comment.Line,
+		StartLine: comment.StartLine,
+		Side:      comment.Side,
+		StartSide: comment.StartSide,
+		Body:      comment.Body,
+	}
+}
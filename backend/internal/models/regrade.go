@@ -1,7 +1,50 @@
 package models
 
-type Regrade struct {
-	StudentGHUsername string `json:"student_gh_username" db:"student_gh_username"`
-	TAGHUsername      string `json:"ta_gh_username" db:"ta_gh_username"`
-	DueDateID         int32  `json:"due_date_id"`
+import "time"
+
+type RegradeState string
+
+const (
+	RegradeStateNoRegradeRequested RegradeState = "NO_REGRADE_REQUESTED"
+	RegradeStateRequested          RegradeState = "REGRADE_REQUESTED"
+	RegradeStateFinalized          RegradeState = "REGRADE_FINALIZED"
+)
+
+// How a TA resolved a regrade request
+type RegradeOutcome string
+
+const (
+	RegradeOutcomeAccepted RegradeOutcome = "ACCEPTED" // the deduction is removed
+	RegradeOutcomeAdjusted RegradeOutcome = "ADJUSTED" // the comment is worth a new number of points
+	RegradeOutcomeRejected RegradeOutcome = "REJECTED" // the original points stand
+)
+
+// A student's dispute of a feedback comment on their work
+type RegradeRequest struct {
+	ID                 int64           `json:"id"`
+	FeedbackCommentID  int64           `json:"feedback_comment_id"`
+	StudentWorkID      int64           `json:"student_work_id"`
+	StudentUserID      int64           `json:"student_user_id"`
+	StudentGHUsername  string          `json:"student_gh_username" db:"student_gh_username"`
+	RegradeState       RegradeState    `json:"regrade_state"`
+	StudentComment     string          `json:"student_comment"`
+	GitHubCommentID    *int64          `json:"github_comment_id" db:"github_comment_id"`
+	OriginalPointValue int             `json:"original_point_value"`
+	Explanation        string          `json:"explanation"`
+	Outcome            *RegradeOutcome `json:"outcome"`
+	AdjustedPointValue *int            `json:"adjusted_point_value"`
+	TAUserID           *int64          `json:"ta_user_id"`
+	TAComment          *string         `json:"ta_comment"`
+	ResolvedAt         *time.Time      `json:"resolved_at"`
+	CreatedAt          time.Time       `json:"created_at"`
+}
+
+type RegradeRequestBody struct {
+	StudentComment string `json:"student_comment"`
+}
+
+type RegradeResolutionBody struct {
+	Outcome   RegradeOutcome `json:"outcome"`
+	Points    *int           `json:"points"` // required when adjusting
+	TAComment *string        `json:"ta_comment"`
 }
@@ -0,0 +1,57 @@
+package models
+
+import "time"
+
+// Something that happened to a classroom repository on GitHub without going through the app
+type RepositoryAlertKind string
+
+const (
+	RepositoryAlertRepoDeleted     RepositoryAlertKind = "REPO_DELETED"
+	RepositoryAlertRepoTransferred RepositoryAlertKind = "REPO_TRANSFERRED"
+	RepositoryAlertRepoArchived    RepositoryAlertKind = "REPO_ARCHIVED"
+	RepositoryAlertRepoPublicized  RepositoryAlertKind = "REPO_PUBLICIZED"
+	RepositoryAlertRulesetEdited   RepositoryAlertKind = "RULESET_EDITED"
+	RepositoryAlertRulesetDeleted  RepositoryAlertKind = "RULESET_DELETED"
+)
+
+// Whether the alert is about one of the rulesets the app protects repositories with
+func (kind RepositoryAlertKind) IsRuleset() bool {
+	return kind == RepositoryAlertRulesetEdited || kind == RepositoryAlertRulesetDeleted
+}
+
+type RepositoryAlert struct {
+	ID                  int64               `json:"id"`
+	ClassroomID         int64               `json:"classroom_id"`
+	AssignmentOutlineID *int64              `json:"assignment_outline_id"`
+	StudentWorkID       *int64              `json:"student_work_id"` // nil for alerts on an assignment's base repository
+	RepoName            string              `json:"repo_name"`
+	Kind                RepositoryAlertKind `json:"kind"`
+	ActorGHUsername     *string             `json:"actor_gh_username" db:"actor_gh_username"`
+	RulesetID           *int64              `json:"ruleset_id"`
+	RulesetName         *string             `json:"ruleset_name"`
+	ReappliedAt         *time.Time          `json:"reapplied_at"`
+	ResolvedAt          *time.Time          `json:"resolved_at"`
+	ResolvedBy          *int64              `json:"resolved_by"`
+	CreatedAt           time.Time           `json:"created_at"`
+}
+
+// go-github doesn't model repository_ruleset events, so only the fields we use are here
+type RepositoryRulesetEvent struct {
+	Action            string            `json:"action"` // created, edited or deleted
+	RepositoryRuleset Ruleset           `json:"repository_ruleset"`
+	Repository        WebHookRepository `json:"repository"`
+	Sender            GitHubUser        `json:"sender"`
+	Changes           struct {
+		Name struct {
+			From string `json:"from"`
+		} `json:"name"`
+	} `json:"changes"` // only set on edits
+}
+
+// The name the ruleset had before the event, which edits that rename it change
+func (event RepositoryRulesetEvent) RulesetName() string {
+	if event.Changes.Name.From != "" {
+		return event.Changes.Name.From
+	}
+	return event.RepositoryRuleset.Name
+}
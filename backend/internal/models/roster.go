@@ -0,0 +1,119 @@
+package models
+
+import "time"
+
+// The layout of an imported roster
+type RosterFormat string
+
+const (
+	RosterFormatCSV    RosterFormat = "CSV"    // name, email, github_username, section and role columns
+	RosterFormatCanvas RosterFormat = "CANVAS" // a Canvas roster or gradebook export, with a GitHub username column added
+)
+
+// A student or staff member listed in an imported roster
+type RosterEntry struct {
+	Row               int           `json:"row"` // the entry's line in the file, counting the header
+	FirstName         string        `json:"first_name"`
+	LastName          string        `json:"last_name"`
+	Email             *string       `json:"email"`
+	GithubUsername    string        `json:"github_username"`
+	Section           *string       `json:"section"`
+	StudentIdentifier *string       `json:"student_identifier"`
+	Role              ClassroomRole `json:"classroom_role"`
+}
+
+// What importing a roster entry did
+type RosterImportStatus string
+
+const (
+	RosterImportPendingInvite  RosterImportStatus = "PENDING_INVITE"  // added to the classroom, the org invitation hasn't been sent yet
+	RosterImportInvited        RosterImportStatus = "INVITED"         // added to the classroom and invited to the org
+	RosterImportAlreadyInvited RosterImportStatus = "ALREADY_INVITED" // already has a pending invitation
+	RosterImportAlreadyMember  RosterImportStatus = "ALREADY_MEMBER"  // already in the org
+	RosterImportUnknownUser    RosterImportStatus = "UNKNOWN_GITHUB_USERNAME"
+	RosterImportConflict       RosterImportStatus = "CONFLICT"      // in the classroom already with a different role, removed, or listed twice
+	RosterImportInvalid        RosterImportStatus = "INVALID"       // the row is missing a GitHub username or has an unknown role
+	RosterImportInviteFailed   RosterImportStatus = "INVITE_FAILED" // added to the classroom but the org invitation failed, it can be re-sent
+	RosterImportFailed         RosterImportStatus = "FAILED"        // the row couldn't be imported, importing it again may succeed
+)
+
+type RosterImportResult struct {
+	Row            int                `json:"row" db:"row_number"`
+	GithubUsername string             `json:"github_username" db:"github_username"`
+	Status         RosterImportStatus `json:"status" db:"status"`
+	Message        string             `json:"message,omitempty" db:"message"`
+	UserID         *int64             `json:"user_id,omitempty" db:"user_id"`
+	Role           *ClassroomRole     `json:"classroom_role,omitempty" db:"classroom_role"`
+}
+
+// An imported roster. Its org invitations are sent in the background, rows waiting on theirs are PENDING_INVITE.
+type RosterImport struct {
+	ID          int64                      `json:"id"`
+	ClassroomID int64                      `json:"classroom_id"`
+	Summary     map[RosterImportStatus]int `json:"summary"`
+	Results     []RosterImportResult       `json:"results"`
+	Finished    bool                       `json:"finished"` // whether every org invitation has been sent
+	CreatedAt   time.Time                  `json:"created_at"`
+}
+
+// A rostered user whose org invitation is waiting to be sent
+type PendingRosterInvitation struct {
+	RosterImportID int64         `db:"roster_import_id"`
+	ClassroomID    int64         `db:"classroom_id"`
+	Row            int           `db:"row_number"`
+	UserID         int64         `db:"user_id"`
+	Role           ClassroomRole `db:"classroom_role"`
+}
+
+// An org invitation that expired or otherwise failed before it was accepted
+type FailedOrgInvitation struct {
+	ID           int64     `json:"id"`
+	Login        *string   `json:"login"`
+	Email        *string   `json:"email"`
+	CreatedAt    time.Time `json:"created_at"`
+	FailedAt     time.Time `json:"failed_at"`
+	FailedReason string    `json:"failed_reason"`
+}
+
+// A way a classroom's roster disagrees with its organization
+type RosterDriftKind string
+
+const (
+	RosterDriftOnTeamNotInClassroom RosterDriftKind = "ON_TEAM_NOT_IN_CLASSROOM" // on the student team without being a student in the classroom
+	RosterDriftNotOnStudentTeam     RosterDriftKind = "NOT_ON_STUDENT_TEAM"      // a student in the org who isn't on the student team
+	RosterDriftExpiredInvitation    RosterDriftKind = "EXPIRED_INVITATION"       // a classroom member whose org invitation expired or failed
+)
+
+type RosterDrift struct {
+	Kind           RosterDriftKind `json:"kind"`
+	GithubUsername string          `json:"github_username"`
+	GithubUserID   int64           `json:"github_user_id"`
+	UserID         *int64          `json:"user_id,omitempty"`
+	FailedAt       *time.Time      `json:"failed_at,omitempty"`
+	Resent         bool            `json:"resent"` // whether the expired invitation was sent again
+}
+
+// A classroom member whose status didn't match their org membership
+type RosterStatusCorrection struct {
+	UserID         int64      `json:"user_id"`
+	GithubUsername string     `json:"github_username"`
+	PreviousStatus UserStatus `json:"previous_status"`
+	Status         UserStatus `json:"status"`
+}
+
+// The outcome of reconciling a classroom's roster against its organization
+type RosterReconciliation struct {
+	ClassroomID  int64                    `json:"classroom_id"`
+	Corrections  []RosterStatusCorrection `json:"corrections"`
+	Drift        []RosterDrift            `json:"drift"`
+	ReconciledAt time.Time                `json:"reconciled_at"`
+}
+
+// Tallies the import's results by status
+func (rosterImport *RosterImport) Summarize() {
+	rosterImport.Summary = make(map[RosterImportStatus]int)
+	for _, result := range rosterImport.Results {
+		rosterImport.Summary[result.Status]++
+	}
+	rosterImport.Finished = rosterImport.Summary[RosterImportPendingInvite] == 0
+}
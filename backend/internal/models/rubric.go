@@ -3,24 +3,115 @@ package models
 import "time"
 
 type Rubric struct {
-	ID          int64     `json:"id,omitempty"`
-	Name        string    `json:"name"`
-	OrgID       int64     `json:"org_id"`
-	ClassroomID int64     `json:"classroom_id"`
-	Reusable    bool      `json:"resuable"`
-	CreatedAt   time.Time `json:"created_at"`
+	ID          int64      `json:"id,omitempty"`
+	Name        string     `json:"name"`
+	OrgID       int64      `json:"org_id"`
+	ClassroomID int64      `json:"classroom_id"`
+	Reusable    bool       `json:"resuable"`
+	CreatedAt   time.Time  `json:"created_at"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+	Version     int        `json:"version"`
 }
 
 type RubricItem struct {
 	ID          int64     `json:"id,omitempty"`
 	RubricID    int64     `json:"rubric_id"`
+	SectionID   *int64    `json:"section_id"`
+	Position    int       `json:"position"`
 	PointValue  int64     `json:"point_value"`
 	Explanation string    `json:"explanation"`
 	CreatedAt   time.Time `json:"created_at"`
-    Deleted     bool      `json:"deleted"`
+	Deleted     bool      `json:"deleted"`
+	// Feedback stays pinned to the version of an item it was given with
+	Version        int    `json:"version"`
+	OriginalItemID *int64 `json:"original_item_id"`
+	SupersededBy   *int64 `json:"superseded_by"`
+}
+
+// Whether an edit to an item changes how feedback given with it is scored or explained
+func (item RubricItem) RevisedBy(edit RubricItem) bool {
+	return item.PointValue != edit.PointValue || item.Explanation != edit.Explanation ||
+		(item.SectionID == nil) != (edit.SectionID == nil) ||
+		(item.SectionID != nil && edit.SectionID != nil && *item.SectionID != *edit.SectionID)
+}
+
+type RubricVersion struct {
+	RubricID  int64     `json:"rubric_id"`
+	Version   int       `json:"version"`
+	CreatedBy *int64    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// How propagating the current version of a rubric item to feedback given with its earlier versions
+// changes the score of a work
+type RubricPropagationWork struct {
+	StudentWorkID   int64  `json:"student_work_id"`
+	RepoName        string `json:"repo_name"`
+	FeedbackCount   int    `json:"feedback_count"` // the work's feedback given with earlier versions of the item
+	PreviousScore   *int   `json:"previous_score"`
+	NewScore        *int   `json:"new_score"`
+	ScoreDelta      int    `json:"score_delta"`
+	GradesPublished bool   `json:"grades_published"` // published grades are left as they were graded
+}
+
+// A group of rubric items whose points together are capped. Without a cap, a section is only for display.
+type RubricSection struct {
+	ID        int64     `json:"id,omitempty"`
+	RubricID  int64     `json:"rubric_id"`
+	Name      string    `json:"name"`
+	Position  int       `json:"position"`
+	MaxPoints *int      `json:"max_points"` // the most the section's items can add together
+	MinPoints *int      `json:"min_points"` // the most the section's items can take off together
+	CreatedAt time.Time `json:"created_at"`
+	Deleted   bool      `json:"deleted"`
 }
 
 type FullRubric struct {
-	Rubric      Rubric       `json:"rubric"`
-	RubricItems []RubricItem `json:"rubric_items"`
+	Rubric      Rubric          `json:"rubric"`
+	Sections    []RubricSection `json:"sections"`
+	RubricItems []RubricItem    `json:"rubric_items"`
+}
+
+// The order of a rubric's sections and items, and which section each item is in
+type RubricOrderRequest struct {
+	SectionIDs []int64               `json:"section_ids"`
+	Items      []RubricItemPlacement `json:"items"`
+}
+
+type RubricItemPlacement struct {
+	ID        int64  `json:"id"`
+	SectionID *int64 `json:"section_id"`
+}
+
+type CloneRubricRequest struct {
+	ClassroomID int64   `json:"classroom_id"`
+	Name        *string `json:"name"` // defaults to the name of the rubric being cloned
+}
+
+// The file format rubrics are imported from and exported to
+type RubricFormat string
+
+const (
+	RubricFormatJSON RubricFormat = "json"
+	RubricFormatCSV  RubricFormat = "csv"
+)
+
+// A rubric independent of any classroom, as it's imported and exported
+type RubricExport struct {
+	Name     string                `json:"name"`
+	Reusable bool                  `json:"reusable"`
+	Sections []RubricSectionExport `json:"sections"`
+	Items    []RubricItemExport    `json:"items"` // items outside of any section
+}
+
+type RubricSectionExport struct {
+	Name      string             `json:"name"`
+	MaxPoints *int               `json:"max_points,omitempty"`
+	MinPoints *int               `json:"min_points,omitempty"`
+	Items     []RubricItemExport `json:"items"`
+}
+
+type RubricItemExport struct {
+	PointValue  int64  `json:"point_value"`
+	Explanation string `json:"explanation"`
 }
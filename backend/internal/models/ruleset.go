@@ -0,0 +1,9 @@
+package models
+
+// Ruleset represents a GitHub repository ruleset.
+type Ruleset struct {
+	ID          int64  `json:"id"`
+	Name        string `json:"name"`
+	Target      string `json:"target"`
+	Enforcement string `json:"enforcement"`
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type ScheduledJobState string
+
+const (
+	ScheduledJobStatePending   ScheduledJobState = "PENDING"
+	ScheduledJobStateRunning   ScheduledJobState = "RUNNING"
+	ScheduledJobStateSucceeded ScheduledJobState = "SUCCEEDED"
+	ScheduledJobStateFailed    ScheduledJobState = "FAILED"
+)
+
+// A run of a time-based job, e.g. snapshotting the works past their deadline
+type ScheduledJob struct {
+	ID          int64             `json:"id"`
+	JobType     string            `json:"job_type"`
+	Payload     json.RawMessage   `json:"payload"`
+	Recurring   bool              `json:"recurring"` // the next run is scheduled when this one finishes
+	State       ScheduledJobState `json:"state"`
+	Attempts    int               `json:"attempts"`
+	MaxAttempts int               `json:"max_attempts"`
+	LastError   *string           `json:"last_error"`
+	RunAt       time.Time         `json:"run_at"`
+	StartedAt   *time.Time        `json:"started_at"`
+	FinishedAt  *time.Time        `json:"finished_at"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
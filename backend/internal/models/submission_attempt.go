@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// A pull request a student opened or updated into the submission branch of their work
+type SubmissionAttempt struct {
+	ID              int64     `json:"id"`
+	StudentWorkID   int64     `json:"student_work_id"`
+	PullNumber      int       `json:"pull_number"`
+	HeadSHA         string    `json:"head_sha"`
+	Action          string    `json:"action"` // opened, reopened or synchronize
+	OpenedBy        *int64    `json:"opened_by"`
+	OpenedByGHLogin *string   `json:"opened_by_gh_login" db:"opened_by_gh_login"`
+	CreatedAt       time.Time `json:"created_at"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Why a student work's commit was pinned for grading
+type SnapshotReason string
+
+const (
+	SnapshotReasonSubmitted SnapshotReason = "SUBMITTED" // the student pushed to the default branch
+	SnapshotReasonDeadline  SnapshotReason = "DEADLINE"  // the deadline passed without a submission
+	SnapshotReasonManual    SnapshotReason = "MANUAL"    // a TA pinned a commit
+)
+
+// A commit of a student work pinned for grading
+type SubmissionSnapshot struct {
+	ID            int64          `json:"id"`
+	StudentWorkID int64          `json:"student_work_id"`
+	CommitSHA     string         `json:"commit_sha"`
+	TagName       *string        `json:"tag_name"`
+	Reason        SnapshotReason `json:"reason"`
+	CreatedBy     *int64         `json:"created_by"`
+	CreatedAt     time.Time      `json:"created_at"`
+}
+
+type SnapshotRequest struct {
+	CommitSHA *string `json:"commit_sha"` // the head of the default branch if omitted
+}
@@ -0,0 +1,59 @@
+package models
+
+import "time"
+
+// How large the teams of a group assignment can be
+type GroupSettings struct {
+	AssignmentOutlineID int32     `json:"assignment_outline_id"`
+	MinTeamSize         int       `json:"min_team_size"`
+	MaxTeamSize         int       `json:"max_team_size"`
+	UpdatedAt           time.Time `json:"updated_at"`
+}
+
+// Assignments that aren't configured fit any number of students on a team
+var DefaultGroupSettings = GroupSettings{MinTeamSize: 1, MaxTeamSize: 0}
+
+// Students sharing one fork of a group assignment
+type Team struct {
+	ID                  int64        `json:"id"`
+	AssignmentOutlineID int32        `json:"assignment_outline_id"`
+	Name                string       `json:"name"`
+	JoinToken           string       `json:"join_token,omitempty"`
+	CreatedBy           int64        `json:"created_by"`
+	CreatedAt           time.Time    `json:"created_at"`
+	Members             []TeamMember `json:"members" db:"-"`
+}
+
+type TeamMember struct {
+	TeamID         int64     `json:"team_id"`
+	UserID         int64     `json:"user_id"`
+	FirstName      *string   `json:"first_name"`
+	LastName       *string   `json:"last_name"`
+	GithubUsername string    `json:"github_username"`
+	JoinedAt       time.Time `json:"joined_at"`
+}
+
+type CreateTeamRequest struct {
+	Name string `json:"name"`
+}
+
+// What importing a row of a team CSV did
+type TeamImportStatus string
+
+const (
+	TeamImportAdded          TeamImportStatus = "ADDED"
+	TeamImportAlreadyOnTeam  TeamImportStatus = "ALREADY_ON_TEAM"
+	TeamImportUnknownStudent TeamImportStatus = "UNKNOWN_STUDENT" // not a student in the classroom
+	TeamImportConflict       TeamImportStatus = "CONFLICT"        // already on a different team
+	TeamImportTeamFull       TeamImportStatus = "TEAM_FULL"
+	TeamImportInvalid        TeamImportStatus = "INVALID" // the row is missing a team name or GitHub username
+	TeamImportFailed         TeamImportStatus = "FAILED"
+)
+
+type TeamImportResult struct {
+	Row            int              `json:"row"`
+	TeamName       string           `json:"team_name"`
+	GithubUsername string           `json:"github_username"`
+	Status         TeamImportStatus `json:"status"`
+	Message        string           `json:"message,omitempty"`
+}
@@ -59,4 +59,5 @@ type GitHubUser struct {
 	URL       string  `json:"url"`
 	Name      *string `json:"name"`
 	Email     *string `json:"email"`
+	Type      string  `json:"type"` // User, Organization or Bot
 }
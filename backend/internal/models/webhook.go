@@ -12,3 +12,45 @@ type WebHookComment struct {
 	Author            string `json:"user.name"`
 	Body              string `json:"body"`
 }
+
+// go-github doesn't model workflow_run events, so only the fields we use are here
+type WorkflowRunEvent struct {
+	Action      string            `json:"action"`
+	WorkflowRun WorkflowRun       `json:"workflow_run"`
+	Repository  WebHookRepository `json:"repository"`
+}
+
+type WorkflowRun struct {
+	ID           int64   `json:"id"`
+	Name         string  `json:"name"`
+	Path         string  `json:"path"`
+	HeadSHA      string  `json:"head_sha"`
+	HeadBranch   string  `json:"head_branch"`
+	Status       string  `json:"status"`
+	Conclusion   *string `json:"conclusion"`
+	CheckSuiteID int64   `json:"check_suite_id"`
+}
+
+type WebHookRepository struct {
+	ID    int64  `json:"id"`
+	Name  string `json:"name"`
+	Owner struct {
+		Login string `json:"login"`
+	} `json:"owner"`
+}
+
+// go-github doesn't model pull_request_review_thread events, so only the fields we use are here
+type PullRequestReviewThreadEvent struct {
+	Action      string                  `json:"action"` // resolved or unresolved
+	Thread      PullRequestReviewThread `json:"thread"`
+	PullRequest struct {
+		Number int `json:"number"`
+	} `json:"pull_request"`
+	Repository WebHookRepository `json:"repository"`
+	Sender     GitHubUser        `json:"sender"`
+}
+
+type PullRequestReviewThread struct {
+	NodeID   string                `json:"node_id"`
+	Comments []GitHubReviewComment `json:"comments"` // the comment that started the thread, then its replies
+}
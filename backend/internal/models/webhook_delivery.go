@@ -0,0 +1,44 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type WebhookDeliveryState string
+
+const (
+	WebhookDeliveryStatePending    WebhookDeliveryState = "PENDING"
+	WebhookDeliveryStateProcessing WebhookDeliveryState = "PROCESSING"
+	WebhookDeliveryStateProcessed  WebhookDeliveryState = "PROCESSED"
+	WebhookDeliveryStateFailed     WebhookDeliveryState = "FAILED"
+)
+
+// A webhook event GitHub delivered, stored so it can be processed in the background, retried and replayed
+type WebhookDelivery struct {
+	DeliveryID    string               `json:"delivery_id"` // the X-GitHub-Delivery GUID, which stays the same when GitHub redelivers it
+	Event         string               `json:"event"`
+	Action        *string              `json:"action"`
+	OrgName       *string              `json:"org_name"`  // the organization the event happened in, if any
+	RepoName      *string              `json:"repo_name"` // the owner/name of the repository the event happened in, if any
+	Payload       json.RawMessage      `json:"payload"`
+	State         WebhookDeliveryState `json:"state"`
+	Attempts      int                  `json:"attempts"`
+	LastError     *string              `json:"last_error"`
+	NextAttemptAt time.Time            `json:"next_attempt_at"`
+	ReceivedAt    time.Time            `json:"received_at"`
+	ProcessedAt   *time.Time           `json:"processed_at"`
+	UpdatedAt     time.Time            `json:"updated_at"`
+}
+
+// An attempt GitHub made at delivering one of the app's webhook events, from the app's delivery log
+type HookDelivery struct {
+	ID          int64     `json:"id"`
+	GUID        string    `json:"guid"` // the X-GitHub-Delivery of the event, shared by its redeliveries
+	DeliveredAt time.Time `json:"delivered_at"`
+	Redelivery  bool      `json:"redelivery"`
+	Status      string    `json:"status"`
+	StatusCode  int       `json:"status_code"` // 0 if the delivery never got a response
+	Event       string    `json:"event"`
+	Action      *string   `json:"action"`
+}
@@ -13,11 +13,16 @@ type StudentWork struct {
 	ManualFeedbackScore      *int       `json:"manual_feedback_score" db:"manual_feedback_score"`
 	AutoGraderScore          *int       `json:"auto_grader_score" db:"auto_grader_score"`
 	GradesPublishedTimestamp *time.Time `json:"grades_published_timestamp" db:"grades_published_timestamp"`
+	SubmittedAt              *time.Time `json:"submitted_at" db:"submitted_at"`
+	LatePenaltyPercent       int        `json:"late_penalty_percent" db:"late_penalty_percent"`
 	WorkState                WorkState  `json:"work_state" db:"work_state"`
 	CreatedAt                time.Time  `json:"created_at" db:"created_at"`
 	CommitAmount             int        `json:"commit_amount" db:"commit_amount"`
 	FirstCommitDate          *time.Time `json:"first_commit_date" db:"first_commit_date"`
 	LastCommitDate           *time.Time `json:"last_commit_date" db:"last_commit_date"`
+	RepoDeletedAt            *time.Time `json:"repo_deleted_at" db:"repo_deleted_at"` // deleted or transferred out of the org
+	RepoArchived             bool       `json:"repo_archived" db:"repo_archived"`
+	RepoPublic               bool       `json:"repo_public" db:"repo_public"`
 }
 
 type WorkState string
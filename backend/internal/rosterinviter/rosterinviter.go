@@ -0,0 +1,83 @@
+package rosterinviter
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/CamPlume1/khoury-classroom/internal/github"
+	"github.com/CamPlume1/khoury-classroom/internal/handlers/common"
+	"github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/CamPlume1/khoury-classroom/internal/scheduler"
+	"github.com/CamPlume1/khoury-classroom/internal/storage"
+)
+
+// The scheduled job type that sends imported rosters' org invitations
+const JobName = "send_roster_invitations"
+
+// Org invitations count against GitHub's secondary rate limit on content creation (80 a minute),
+// so they are sent in batches with a pause in between
+const (
+	batchSize  = 25
+	batchDelay = 20 * time.Second
+)
+
+// Sends the org invitations of imported rosters in the background, a batch at a time
+type Inviter struct {
+	store     storage.Storage
+	appClient github.GitHubAppClient
+}
+
+func New(store storage.Storage, appClient github.GitHubAppClient) *Inviter {
+	return &Inviter{store: store, appClient: appClient}
+}
+
+// The recurring job that sends the next batch of invitations. An invitation that fails is reported on its roster
+// import as INVITE_FAILED rather than retried, so the job itself isn't retried.
+func (i *Inviter) Job() scheduler.JobType {
+	return scheduler.JobType{Name: JobName, Run: i.sendBatch, Every: batchDelay, MaxAttempts: 1}
+}
+
+func (i *Inviter) sendBatch(ctx context.Context, _ models.ScheduledJob) error {
+	invitations, err := i.store.ListPendingRosterInvitations(ctx, batchSize)
+	if err != nil {
+		return fmt.Errorf("failed to list pending roster invitations: %w", err)
+	}
+
+	for _, invitation := range invitations {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		status, message := models.RosterImportInvited, ""
+		if err := i.invite(ctx, invitation); err != nil {
+			slog.Error("Failed to send roster invitation", "roster_import_id", invitation.RosterImportID, "row", invitation.Row, "error", err)
+			status, message = models.RosterImportInviteFailed, "could not send the organization invitation"
+		}
+
+		err := i.store.FinishRosterInvitation(ctx, invitation.RosterImportID, invitation.Row, status, message)
+		if err != nil {
+			return fmt.Errorf("failed to record roster invitation: %w", err)
+		}
+	}
+	return nil
+}
+
+func (i *Inviter) invite(ctx context.Context, invitation models.PendingRosterInvitation) error {
+	classroom, err := i.store.GetClassroomByID(ctx, invitation.ClassroomID)
+	if err != nil {
+		return err
+	}
+	if invitation.Role == models.Student && classroom.StudentTeamName == nil {
+		return fmt.Errorf("classroom %d has no student team", classroom.ID)
+	}
+
+	user, err := i.store.GetUserByID(ctx, invitation.UserID)
+	if err != nil {
+		return err
+	}
+
+	_, err = common.InviteUserToOrganization(ctx, i.appClient, i.store, classroom, invitation.Role, user)
+	return err
+}
@@ -0,0 +1,62 @@
+package rostersync
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/CamPlume1/khoury-classroom/internal/github"
+	"github.com/CamPlume1/khoury-classroom/internal/handlers/common"
+	"github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/CamPlume1/khoury-classroom/internal/scheduler"
+	"github.com/CamPlume1/khoury-classroom/internal/storage"
+)
+
+// The scheduled job type that reconciles rosters
+const JobName = "reconcile_rosters"
+
+const (
+	pollInterval = time.Hour
+	// how stale a classroom's last reconciliation can get before it's reconciled again
+	reconcileInterval = 24 * time.Hour
+)
+
+// Periodically reconciles classroom rosters against their organizations, so statuses don't wait on someone
+// visiting the classroom to be corrected. Expired invitations are only reported, professors choose to re-send them.
+type Reconciler struct {
+	store     storage.Storage
+	appClient github.GitHubAppClient
+}
+
+func New(store storage.Storage, appClient github.GitHubAppClient) *Reconciler {
+	return &Reconciler{store: store, appClient: appClient}
+}
+
+// The recurring job that reconciles the classrooms that are due. A classroom that fails is picked up again on the
+// next run, so the job itself isn't retried.
+func (r *Reconciler) Job() scheduler.JobType {
+	return scheduler.JobType{Name: JobName, Run: r.drain, Every: pollInterval, MaxAttempts: 1}
+}
+
+func (r *Reconciler) drain(ctx context.Context, _ models.ScheduledJob) error {
+	classrooms, err := r.store.GetClassroomsDueForReconciliation(ctx, time.Now().UTC().Add(-reconcileInterval))
+	if err != nil {
+		return fmt.Errorf("failed to list classrooms to reconcile: %w", err)
+	}
+
+	for _, classroom := range classrooms {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		report, err := common.ReconcileClassroomRoster(ctx, r.appClient, r.store, classroom, false)
+		if err != nil {
+			slog.Error("Failed to reconcile classroom roster", "classroom_id", classroom.ID, "error", err)
+			continue
+		}
+
+		slog.Info("Reconciled classroom roster", "classroom_id", classroom.ID, "corrections", len(report.Corrections), "drift", len(report.Drift))
+	}
+	return nil
+}
@@ -0,0 +1,274 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/CamPlume1/khoury-classroom/internal/storage"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	pollInterval       = 5 * time.Second
+	heartbeatInterval  = 30 * time.Second
+	defaultMaxAttempts = 5
+	maxBackoff         = time.Hour
+	// the advisory lock whoever runs the jobs holds, so only one replica does at a time
+	leaderLockKey int64 = 0x676d6b73
+)
+
+// Runs a claimed job. Returning an error retries the job until it runs out of attempts.
+type Handler func(ctx context.Context, job models.ScheduledJob) error
+
+// A kind of job the scheduler knows how to run
+type JobType struct {
+	Name string
+	Run  Handler
+	// how long after a run finishes the next one is due, zero for jobs that only run when enqueued
+	Every time.Duration
+	// how many times a run is attempted before it's marked as failed, defaultMaxAttempts if zero
+	MaxAttempts int
+}
+
+// Runs time-based jobs stored in the database. Every replica runs a scheduler, but only the one holding the
+// leader lock runs jobs, the others take over if it goes away.
+type Scheduler struct {
+	store    storage.Storage
+	jobTypes map[string]JobType
+}
+
+func New(store storage.Storage) *Scheduler {
+	return &Scheduler{store: store, jobTypes: map[string]JobType{}}
+}
+
+// Registers a job type, which must happen before the scheduler is started
+func (s *Scheduler) Register(jobType JobType) {
+	if _, ok := s.jobTypes[jobType.Name]; ok {
+		panic(fmt.Sprintf("scheduler: job type %q registered twice", jobType.Name))
+	}
+	if jobType.MaxAttempts == 0 {
+		jobType.MaxAttempts = defaultMaxAttempts
+	}
+	s.jobTypes[jobType.Name] = jobType
+}
+
+// Schedules a run of a registered job type, with a payload its handler reads from the job
+func (s *Scheduler) Enqueue(ctx context.Context, jobTypeName string, payload any, runAt time.Time) (models.ScheduledJob, error) {
+	jobType, ok := s.jobTypes[jobTypeName]
+	if !ok {
+		return models.ScheduledJob{}, fmt.Errorf("unknown job type %q", jobTypeName)
+	}
+
+	encoded := json.RawMessage(`{}`)
+	if payload != nil {
+		var err error
+		encoded, err = json.Marshal(payload)
+		if err != nil {
+			return models.ScheduledJob{}, err
+		}
+	}
+
+	return s.store.EnqueueScheduledJob(ctx, models.ScheduledJob{
+		JobType:     jobType.Name,
+		Payload:     encoded,
+		MaxAttempts: jobType.MaxAttempts,
+		RunAt:       runAt,
+	})
+}
+
+// Campaigns for the leader lock and runs due jobs while holding it, until the context is cancelled
+func (s *Scheduler) Start(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	var lock storage.Lock
+	defer func() {
+		if lock != nil {
+			lock.Release(context.Background())
+		}
+	}()
+
+	for {
+		if lock != nil && lock.Check(ctx) != nil {
+			slog.Warn("Lost the scheduler lock")
+			lock.Release(ctx)
+			lock = nil
+		}
+
+		if lock == nil {
+			var err error
+			lock, err = s.store.TryAdvisoryLock(ctx, leaderLockKey)
+			switch {
+			case errors.Is(err, pgx.ErrNoRows):
+				lock = nil
+			case err != nil:
+				lock = nil
+				slog.Error("Failed to take the scheduler lock", "error", err)
+			default:
+				slog.Info("Took the scheduler lock, running scheduled jobs")
+				s.resetRunning(ctx)
+				s.scheduleRecurring(ctx, time.Now())
+			}
+		}
+
+		if lock != nil {
+			s.drain(ctx, lock)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Reschedules the jobs a crashed leader was running. A leader that lost the lock may still be finishing a job,
+// which keeps sending heartbeats until it's done and is left alone.
+func (s *Scheduler) resetRunning(ctx context.Context) {
+	count, err := s.store.ResetStaleScheduledJobs(ctx)
+	if err != nil {
+		slog.Error("Failed to reset running scheduled jobs", "error", err)
+		return
+	}
+	if count > 0 {
+		slog.Info("Rescheduled jobs left running by the previous leader", "count", count)
+	}
+}
+
+// Makes sure every recurring job has a run scheduled, e.g. when the job type is new or a replica crashed between runs
+func (s *Scheduler) scheduleRecurring(ctx context.Context, runAt time.Time) {
+	for _, jobType := range s.jobTypes {
+		if jobType.Every > 0 {
+			s.scheduleNext(ctx, jobType, runAt)
+		}
+	}
+}
+
+func (s *Scheduler) scheduleNext(ctx context.Context, jobType JobType, runAt time.Time) {
+	_, err := s.store.EnqueueScheduledJob(ctx, models.ScheduledJob{
+		JobType:     jobType.Name,
+		Payload:     json.RawMessage(`{}`),
+		Recurring:   true,
+		MaxAttempts: jobType.MaxAttempts,
+		RunAt:       runAt,
+	})
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		slog.Error("Failed to schedule recurring job", "job_type", jobType.Name, "error", err)
+	}
+}
+
+// Runs claimed jobs until there are none left that are due, or the lock is lost
+func (s *Scheduler) drain(ctx context.Context, lock storage.Lock) {
+	jobTypes := make([]string, 0, len(s.jobTypes))
+	for name := range s.jobTypes {
+		jobTypes = append(jobTypes, name)
+	}
+
+	for ctx.Err() == nil {
+		// another replica may have taken over while the last job ran
+		if lock.Check(ctx) != nil {
+			return
+		}
+
+		job, err := s.store.ClaimNextScheduledJob(ctx, jobTypes)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return
+		}
+		if err != nil {
+			slog.Error("Failed to claim scheduled job", "error", err)
+			return
+		}
+
+		s.run(ctx, job)
+	}
+}
+
+// Runs a claimed job and records the outcome
+func (s *Scheduler) run(ctx context.Context, job models.ScheduledJob) {
+	jobType := s.jobTypes[job.JobType]
+
+	heartbeatCtx, stopHeartbeat := context.WithCancel(ctx)
+	heartbeatDone := make(chan struct{})
+	go func() {
+		defer close(heartbeatDone)
+		s.heartbeat(heartbeatCtx, job.ID)
+	}()
+	err := runHandler(ctx, jobType.Run, job)
+	stopHeartbeat()
+	<-heartbeatDone
+
+	// The outcome is recorded even when shutting down, so the job isn't left running until it goes stale
+	interrupted := ctx.Err() != nil
+	ctx = context.WithoutCancel(ctx)
+
+	finished := true
+	switch {
+	case interrupted:
+		slog.Info("Scheduled job interrupted, rescheduling", "job_id", job.ID, "job_type", job.JobType)
+		finished = false
+		message := "interrupted by shutdown"
+		err = s.store.RetryScheduledJob(ctx, job.ID, time.Now(), &message)
+	case err == nil:
+		err = s.store.FinishScheduledJob(ctx, job.ID, models.ScheduledJobStateSucceeded, nil)
+	case job.Attempts < job.MaxAttempts:
+		slog.Warn("Scheduled job failed, retrying", "job_id", job.ID, "job_type", job.JobType, "attempts", job.Attempts, "error", err)
+		finished = false
+		message := err.Error()
+		err = s.store.RetryScheduledJob(ctx, job.ID, time.Now().Add(backoff(job.Attempts)), &message)
+	default:
+		slog.Error("Scheduled job failed", "job_id", job.ID, "job_type", job.JobType, "error", err)
+		message := err.Error()
+		err = s.store.FinishScheduledJob(ctx, job.ID, models.ScheduledJobStateFailed, &message)
+	}
+
+	if err != nil {
+		slog.Error("Failed to record scheduled job outcome", "job_id", job.ID, "error", err)
+		return
+	}
+
+	if finished && job.Recurring && jobType.Every > 0 {
+		s.scheduleNext(ctx, jobType, time.Now().Add(jobType.Every))
+	}
+}
+
+// Keeps a running job from being taken for abandoned by the next leader, however long it runs
+func (s *Scheduler) heartbeat(ctx context.Context, jobID int64) {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.store.HeartbeatScheduledJob(ctx, jobID); err != nil && ctx.Err() == nil {
+				slog.Error("Failed to record scheduled job heartbeat", "job_id", jobID, "error", err)
+			}
+		}
+	}
+}
+
+// Runs a job's handler, turning a panic into an error so one bad job can't take down the replica
+func runHandler(ctx context.Context, handler Handler, job models.ScheduledJob) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return handler(ctx, job)
+}
+
+// Exponential backoff between attempts, capped at maxBackoff
+func backoff(attempts int) time.Duration {
+	delay := 30 * time.Second
+	for i := 1; i < attempts && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxBackoff)
+}
@@ -3,9 +3,11 @@ package server
 import (
 	"github.com/CamPlume1/khoury-classroom/internal/errs"
 	"github.com/CamPlume1/khoury-classroom/internal/handlers/auth"
+	"github.com/CamPlume1/khoury-classroom/internal/handlers/autograder"
 	"github.com/CamPlume1/khoury-classroom/internal/handlers/classrooms"
 	"github.com/CamPlume1/khoury-classroom/internal/handlers/deadline"
 	"github.com/CamPlume1/khoury-classroom/internal/handlers/hello"
+	"github.com/CamPlume1/khoury-classroom/internal/handlers/jobs"
 	"github.com/CamPlume1/khoury-classroom/internal/handlers/organizations"
 	"github.com/CamPlume1/khoury-classroom/internal/handlers/rubrics"
 	"github.com/CamPlume1/khoury-classroom/internal/handlers/test"
@@ -29,12 +31,14 @@ func New(params types.Params) *fiber.App {
     // Route Groupings
     hello.Routes(app, params)
 	deadline.Routes(app, params)
+	autograder.Routes(app, params)
 	auth.Routes(app, params)
 	organizations.Routes(app, params)
 	classrooms.Routes(app, params)
 	test.Routes(app, params)
 	webhooks.Routes(app, params)
 	users.Routes(app, params)
+	jobs.Routes(app, params)
     rubrics.Routes(app, params)
 
 	// heartbeat route
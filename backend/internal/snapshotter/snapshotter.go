@@ -0,0 +1,62 @@
+package snapshotter
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/CamPlume1/khoury-classroom/internal/github"
+	"github.com/CamPlume1/khoury-classroom/internal/handlers/common"
+	"github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/CamPlume1/khoury-classroom/internal/scheduler"
+	"github.com/CamPlume1/khoury-classroom/internal/storage"
+)
+
+// The scheduled job type that snapshots works at their deadline
+const JobName = "snapshot_deadlines"
+
+const (
+	pollInterval = time.Minute
+	batchSize    = 50
+)
+
+// Pins the works that reach their deadline without a submission, so they're graded on what was there at the deadline
+type Snapshotter struct {
+	store     storage.Storage
+	appClient github.GitHubAppClient
+}
+
+func New(store storage.Storage, appClient github.GitHubAppClient) *Snapshotter {
+	return &Snapshotter{store: store, appClient: appClient}
+}
+
+// The recurring job that snapshots the works past their deadline
+func (s *Snapshotter) Job() scheduler.JobType {
+	return scheduler.JobType{Name: JobName, Run: s.drain, Every: pollInterval, MaxAttempts: 1}
+}
+
+// Snapshots every work past its deadline. A work that fails is skipped until the next run, so it can't hold up the rest.
+func (s *Snapshotter) drain(ctx context.Context, _ models.ScheduledJob) error {
+	afterID := 0
+	for ctx.Err() == nil {
+		works, err := s.store.GetWorksDueForSnapshot(ctx, afterID, batchSize)
+		if err != nil {
+			return fmt.Errorf("failed to get works due for a snapshot: %w", err)
+		}
+		if len(works) == 0 {
+			return nil
+		}
+
+		for _, work := range works {
+			afterID = work.ID
+			snapshot, err := common.SnapshotWork(ctx, s.appClient, s.store, work, "", models.SnapshotReasonDeadline, nil)
+			if err != nil {
+				slog.Error("Failed to snapshot work", "student_work_id", work.ID, "repo", work.RepoName, "error", err)
+				continue
+			}
+			slog.Info("Snapshotted work at its deadline", "student_work_id", work.ID, "commit_sha", snapshot.CommitSHA)
+		}
+	}
+	return ctx.Err()
+}
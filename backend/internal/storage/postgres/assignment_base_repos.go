@@ -27,7 +27,7 @@ func (db *DB) GetBaseRepoByID(ctx context.Context, id int64) (models.AssignmentB
 	var baseRepo models.AssignmentBaseRepo
 
 	err := db.connPool.QueryRow(ctx, `
-			SELECT base_repo_owner, base_repo_name, base_repo_id, created_at, initialized
+			SELECT base_repo_owner, base_repo_name, base_repo_id, created_at, initialized, deleted_at
 			FROM assignment_base_repos
 			WHERE base_repo_id = $1
 		`,
@@ -36,7 +36,8 @@ func (db *DB) GetBaseRepoByID(ctx context.Context, id int64) (models.AssignmentB
 		&baseRepo.BaseRepoName,
 		&baseRepo.BaseID,
 		&baseRepo.CreatedAt,
-		&baseRepo.Initialized)
+		&baseRepo.Initialized,
+		&baseRepo.DeletedAt)
 
 	if err != nil {
 		return baseRepo, errs.NewDBError(err)
@@ -60,3 +61,37 @@ func (db *DB) UpdateBaseRepoInitialized(ctx context.Context, id int64, initializ
 
 	return nil
 }
+
+// Keeps track of a base repository being renamed on GitHub
+func (db *DB) RenameBaseRepo(ctx context.Context, id int64, name string) error {
+	_, err := db.connPool.Exec(ctx, `
+			UPDATE assignment_base_repos
+			SET base_repo_name = $1
+			WHERE base_repo_id = $2
+		`,
+		name,
+		id)
+
+	if err != nil {
+		return errs.NewDBError(err)
+	}
+
+	return nil
+}
+
+// Marks a base repository as deleted (or transferred out of its org), or as restored if deleted is false
+func (db *DB) SetBaseRepoDeleted(ctx context.Context, id int64, deleted bool) error {
+	_, err := db.connPool.Exec(ctx, `
+			UPDATE assignment_base_repos
+			SET deleted_at = CASE WHEN $1 THEN (NOW() AT TIME ZONE 'UTC') ELSE NULL END
+			WHERE base_repo_id = $2
+		`,
+		deleted,
+		id)
+
+	if err != nil {
+		return errs.NewDBError(err)
+	}
+
+	return nil
+}
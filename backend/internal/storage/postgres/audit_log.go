@@ -0,0 +1,70 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/CamPlume1/khoury-classroom/internal/errs"
+	"github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+const gradingAuditFields = `
+	gal.id, gal.action, gal.actor_user_id, u.github_username AS actor_gh_username, gal.classroom_id,
+	gal.assignment_outline_id, gal.student_work_id, gal.student_user_id, gal.rubric_id, gal.subject_id,
+	gal.before, gal.after, gal.created_at
+	FROM grading_audit_log gal
+	LEFT JOIN users u ON u.id = gal.actor_user_id`
+
+// Appends an entry to the grading audit log
+func (db *DB) RecordGradingAudit(ctx context.Context, entry models.GradingAuditEntry) error {
+	_, err := db.connPool.Exec(ctx, `
+	INSERT INTO grading_audit_log
+		(action, actor_user_id, classroom_id, assignment_outline_id, student_work_id, student_user_id, rubric_id, subject_id, before, after)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		entry.Action, entry.ActorUserID, entry.ClassroomID, entry.AssignmentOutlineID, entry.StudentWorkID,
+		entry.StudentUserID, entry.RubricID, entry.SubjectID, entry.Before, entry.After)
+	if err != nil {
+		return errs.NewDBError(err)
+	}
+
+	return nil
+}
+
+// Gets the grading history of a student work, oldest first: actions on the work itself, and the actions on
+// its assignment and rubric that apply to it, e.g. its contributors' deadline extensions or rubric edits
+func (db *DB) GetWorkAuditLog(ctx context.Context, studentWorkID int64) ([]models.GradingAuditEntry, error) {
+	rows, err := db.connPool.Query(ctx, fmt.Sprintf(`
+	SELECT %s
+	JOIN student_works sw ON sw.id = $1
+	JOIN assignment_outlines ao ON ao.id = sw.assignment_outline_id
+	WHERE gal.student_work_id = sw.id
+		OR (gal.student_work_id IS NULL AND gal.assignment_outline_id = ao.id AND (gal.student_user_id IS NULL
+			OR gal.student_user_id IN (SELECT user_id FROM work_contributors WHERE student_work_id = sw.id)))
+		OR (gal.student_work_id IS NULL AND gal.rubric_id = ao.rubric_id)
+	ORDER BY gal.id`, gradingAuditFields), studentWorkID)
+	if err != nil {
+		return nil, errs.NewDBError(err)
+	}
+	defer rows.Close()
+
+	return pgx.CollectRows(rows, pgx.RowToStructByName[models.GradingAuditEntry])
+}
+
+// Gets the grading history of an assignment, oldest first: actions on the assignment, on any of its works,
+// and on its rubric
+func (db *DB) GetAssignmentAuditLog(ctx context.Context, assignmentID int64) ([]models.GradingAuditEntry, error) {
+	rows, err := db.connPool.Query(ctx, fmt.Sprintf(`
+	SELECT %s
+	JOIN assignment_outlines ao ON ao.id = $1
+	WHERE gal.assignment_outline_id = ao.id
+		OR gal.student_work_id IN (SELECT id FROM student_works WHERE assignment_outline_id = ao.id)
+		OR (gal.student_work_id IS NULL AND gal.rubric_id = ao.rubric_id)
+	ORDER BY gal.id`, gradingAuditFields), assignmentID)
+	if err != nil {
+		return nil, errs.NewDBError(err)
+	}
+	defer rows.Close()
+
+	return pgx.CollectRows(rows, pgx.RowToStructByName[models.GradingAuditEntry])
+}
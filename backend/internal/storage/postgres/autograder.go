@@ -0,0 +1,167 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/CamPlume1/khoury-classroom/internal/errs"
+	"github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// matches the column default of autograder_configs.timeout_minutes
+const defaultAutograderTimeoutMinutes = 10
+
+// Gets the autograder config of an assignment, falling back to the defaults if it has none
+func (db *DB) GetAutograderConfig(ctx context.Context, assignmentID int64) (models.AutograderConfig, error) {
+	rows, err := db.connPool.Query(ctx, `
+	SELECT assignment_outline_id, score_policy, setup_command, tests, timeout_minutes
+	FROM autograder_configs
+	WHERE assignment_outline_id = $1`, assignmentID)
+	if err != nil {
+		return models.AutograderConfig{}, errs.NewDBError(err)
+	}
+	defer rows.Close()
+
+	config, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[models.AutograderConfig])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.AutograderConfig{
+			AssignmentOutlineID: int32(assignmentID),
+			ScorePolicy:         models.AutograderScorePolicyMax,
+			Tests:               []models.AutograderTest{},
+			TimeoutMinutes:      defaultAutograderTimeoutMinutes,
+		}, nil
+	}
+	if err != nil {
+		return models.AutograderConfig{}, errs.NewDBError(err)
+	}
+
+	return config, nil
+}
+
+func (db *DB) UpsertAutograderConfig(ctx context.Context, config models.AutograderConfig) (models.AutograderConfig, error) {
+	rows, err := db.connPool.Query(ctx, `
+	INSERT INTO autograder_configs (assignment_outline_id, score_policy, setup_command, tests, timeout_minutes)
+	VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT (assignment_outline_id) DO UPDATE
+	SET score_policy = EXCLUDED.score_policy,
+		setup_command = EXCLUDED.setup_command,
+		tests = EXCLUDED.tests,
+		timeout_minutes = EXCLUDED.timeout_minutes,
+		updated_at = (NOW() AT TIME ZONE 'UTC')
+	RETURNING assignment_outline_id, score_policy, setup_command, tests, timeout_minutes`,
+		config.AssignmentOutlineID, config.ScorePolicy, config.SetupCommand, config.Tests, config.TimeoutMinutes)
+	if err != nil {
+		return models.AutograderConfig{}, errs.NewDBError(err)
+	}
+	defer rows.Close()
+
+	config, err = pgx.CollectOneRow(rows, pgx.RowToStructByName[models.AutograderConfig])
+	if err != nil {
+		return models.AutograderConfig{}, errs.NewDBError(err)
+	}
+
+	return config, nil
+}
+
+// Records test results for a commit of a student work. Tests that were already recorded for the commit
+// are overwritten, and the commit's score is recomputed from all of its tests. Verified and unverified results
+// of a commit are kept apart, so check runs can't overwrite what the autograder reported.
+func (db *DB) RecordAutograderResult(ctx context.Context, studentWorkID int64, commitSHA string, verified bool, tests []models.AutograderTestResult) (models.AutograderResult, error) {
+	tx, err := db.connPool.Begin(ctx)
+	if err != nil {
+		return models.AutograderResult{}, errs.NewDBError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	var resultID int64
+	err = tx.QueryRow(ctx, `
+	INSERT INTO autograder_results (student_work_id, commit_sha, verified)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (student_work_id, commit_sha, verified) DO UPDATE
+	SET updated_at = (NOW() AT TIME ZONE 'UTC')
+	RETURNING id`,
+		studentWorkID, commitSHA, verified).Scan(&resultID)
+	if err != nil {
+		return models.AutograderResult{}, errs.NewDBError(err)
+	}
+
+	for _, test := range tests {
+		_, err = tx.Exec(ctx, `
+		INSERT INTO autograder_test_results (autograder_result_id, name, status, score, max_score)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (autograder_result_id, name) DO UPDATE
+		SET status = EXCLUDED.status, score = EXCLUDED.score, max_score = EXCLUDED.max_score`,
+			resultID, test.Name, test.Status, test.Score, test.MaxScore)
+		if err != nil {
+			return models.AutograderResult{}, errs.NewDBError(err)
+		}
+	}
+
+	rows, err := tx.Query(ctx, `
+	UPDATE autograder_results ar
+	SET score = t.score, max_score = t.max_score
+	FROM (
+		SELECT COALESCE(SUM(score), 0) AS score, COALESCE(SUM(max_score), 0) AS max_score
+		FROM autograder_test_results
+		WHERE autograder_result_id = $1
+	) t
+	WHERE ar.id = $1
+	RETURNING ar.*`, resultID)
+	if err != nil {
+		return models.AutograderResult{}, errs.NewDBError(err)
+	}
+
+	result, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[models.AutograderResult])
+	if err != nil {
+		return models.AutograderResult{}, errs.NewDBError(err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return models.AutograderResult{}, errs.NewDBError(err)
+	}
+
+	return result, nil
+}
+
+// Gets the autograder results of a student work with their tests, most recent commit first
+func (db *DB) GetAutograderResults(ctx context.Context, studentWorkID int64) ([]models.AutograderResult, error) {
+	rows, err := db.connPool.Query(ctx, `
+	SELECT * FROM autograder_results
+	WHERE student_work_id = $1
+	ORDER BY created_at DESC`, studentWorkID)
+	if err != nil {
+		return nil, errs.NewDBError(err)
+	}
+	defer rows.Close()
+
+	results, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.AutograderResult])
+	if err != nil {
+		return nil, errs.NewDBError(err)
+	}
+
+	testRows, err := db.connPool.Query(ctx, `
+	SELECT t.* FROM autograder_test_results t
+	JOIN autograder_results ar ON ar.id = t.autograder_result_id
+	WHERE ar.student_work_id = $1
+	ORDER BY t.name`, studentWorkID)
+	if err != nil {
+		return nil, errs.NewDBError(err)
+	}
+	defer testRows.Close()
+
+	tests, err := pgx.CollectRows(testRows, pgx.RowToStructByName[models.AutograderTestResult])
+	if err != nil {
+		return nil, errs.NewDBError(err)
+	}
+
+	testsByResult := make(map[int64][]models.AutograderTestResult)
+	for _, test := range tests {
+		testsByResult[test.AutograderResultID] = append(testsByResult[test.AutograderResultID], test)
+	}
+	for i := range results {
+		results[i].Tests = testsByResult[results[i].ID]
+	}
+
+	return results, nil
+}
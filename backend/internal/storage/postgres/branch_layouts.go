@@ -0,0 +1,46 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/CamPlume1/khoury-classroom/internal/errs"
+	"github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+const branchLayoutFields = `assignment_outline_id, submission_branch, feedback_branch, development_branch, extra_branches, updated_at`
+
+// Returns the branch layout of an assignment, pgx.ErrNoRows if it hasn't been configured
+func (db *DB) GetBranchLayout(ctx context.Context, assignmentID int64) (models.BranchLayout, error) {
+	rows, err := db.connPool.Query(ctx, `
+	SELECT `+branchLayoutFields+`
+	FROM branch_layouts WHERE assignment_outline_id = $1`, assignmentID)
+	if err != nil {
+		return models.BranchLayout{}, errs.NewDBError(err)
+	}
+	defer rows.Close()
+
+	return pgx.CollectOneRow(rows, pgx.RowToStructByName[models.BranchLayout])
+}
+
+func (db *DB) UpsertBranchLayout(ctx context.Context, layout models.BranchLayout) (models.BranchLayout, error) {
+	rows, err := db.connPool.Query(ctx, `
+	INSERT INTO branch_layouts (assignment_outline_id, submission_branch, feedback_branch, development_branch, extra_branches)
+	VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT (assignment_outline_id) DO UPDATE
+	SET submission_branch = EXCLUDED.submission_branch, feedback_branch = EXCLUDED.feedback_branch,
+		development_branch = EXCLUDED.development_branch, extra_branches = EXCLUDED.extra_branches,
+		updated_at = (NOW() AT TIME ZONE 'UTC')
+	RETURNING `+branchLayoutFields,
+		layout.AssignmentOutlineID, layout.SubmissionBranch, layout.FeedbackBranch, layout.DevelopmentBranch, layout.ExtraBranches)
+	if err != nil {
+		return models.BranchLayout{}, errs.NewDBError(err)
+	}
+	defer rows.Close()
+
+	layout, err = pgx.CollectOneRow(rows, pgx.RowToStructByName[models.BranchLayout])
+	if err != nil {
+		return models.BranchLayout{}, errs.NewDBError(err)
+	}
+	return layout, nil
+}
@@ -12,7 +12,7 @@ func (db *DB) CreateClassroom(ctx context.Context, classroomData models.Classroo
 	err := db.connPool.QueryRow(ctx, `
 	INSERT INTO classrooms (name, org_id, org_name, student_team_name)
 	VALUES ($1, $2, $3, $4)
-	RETURNING id, name, org_id, org_name, created_at, student_team_name`,
+	RETURNING id, name, org_id, org_name, created_at, student_team_name, suspended_at`,
 		classroomData.Name,
 		classroomData.OrgID,
 		classroomData.OrgName,
@@ -22,7 +22,8 @@ func (db *DB) CreateClassroom(ctx context.Context, classroomData models.Classroo
 		&classroomData.OrgID,
 		&classroomData.OrgName,
 		&classroomData.CreatedAt,
-		&classroomData.StudentTeamName)
+		&classroomData.StudentTeamName,
+		&classroomData.SuspendedAt)
 
 	if err != nil {
 		return models.Classroom{}, errs.NewDBError(err)
@@ -36,7 +37,7 @@ func (db *DB) UpdateClassroom(ctx context.Context, classroomData models.Classroo
 	UPDATE classrooms
 	SET name = $1, org_id = $2, org_name = $3, student_team_name = $4
 	WHERE id = $5
-	RETURNING id, name, org_id, org_name, created_at, student_team_name`,
+	RETURNING id, name, org_id, org_name, created_at, student_team_name, suspended_at`,
 		classroomData.Name,
 		classroomData.OrgID,
 		classroomData.OrgName,
@@ -46,7 +47,8 @@ func (db *DB) UpdateClassroom(ctx context.Context, classroomData models.Classroo
 		&classroomData.OrgID,
 		&classroomData.OrgName,
 		&classroomData.CreatedAt,
-		&classroomData.StudentTeamName)
+		&classroomData.StudentTeamName,
+		&classroomData.SuspendedAt)
 
 	if err != nil {
 		return models.Classroom{}, errs.NewDBError(err)
@@ -58,7 +60,7 @@ func (db *DB) UpdateClassroom(ctx context.Context, classroomData models.Classroo
 func (db *DB) GetClassroomByID(ctx context.Context, classroomID int64) (models.Classroom, error) {
 	var classroomData models.Classroom
 	err := db.connPool.QueryRow(ctx, `
-	SELECT id, name, org_id, org_name, created_at, student_team_name
+	SELECT id, name, org_id, org_name, created_at, student_team_name, suspended_at
 	FROM classrooms
 	WHERE id = $1`, classroomID).Scan(
 		&classroomData.ID,
@@ -67,6 +69,7 @@ func (db *DB) GetClassroomByID(ctx context.Context, classroomID int64) (models.C
 		&classroomData.OrgName,
 		&classroomData.CreatedAt,
 		&classroomData.StudentTeamName,
+		&classroomData.SuspendedAt,
 	)
 
 	if err != nil {
@@ -79,7 +82,7 @@ func (db *DB) GetClassroomByID(ctx context.Context, classroomID int64) (models.C
 func (db *DB) GetClassroomByName(ctx context.Context, classroomName string) (models.Classroom, error) {
 	var classroomData models.Classroom
 	err := db.connPool.QueryRow(ctx, `
-	SELECT id, name, org_id, org_name, created_at, student_team_name
+	SELECT id, name, org_id, org_name, created_at, student_team_name, suspended_at
 	FROM classrooms
 	WHERE name = $1`, classroomName).Scan(
 		&classroomData.ID,
@@ -88,6 +91,7 @@ func (db *DB) GetClassroomByName(ctx context.Context, classroomName string) (mod
 		&classroomData.OrgName,
 		&classroomData.CreatedAt,
 		&classroomData.StudentTeamName,
+		&classroomData.SuspendedAt,
 	)
 
 	if err != nil {
@@ -253,7 +257,7 @@ func (db *DB) GetUserInClassroom(ctx context.Context, classroomID int64, userID
 
 func (db *DB) GetClassroomsInOrg(ctx context.Context, orgID int64) ([]models.Classroom, error) {
 	rows, err := db.connPool.Query(ctx, `
-	SELECT id, name, org_id, org_name, created_at, student_team_name
+	SELECT id, name, org_id, org_name, created_at, student_team_name, suspended_at
 	FROM classrooms
 	WHERE org_id = $1`, orgID)
 	if err != nil {
@@ -263,6 +267,20 @@ func (db *DB) GetClassroomsInOrg(ctx context.Context, orgID int64) ([]models.Cla
 	return pgx.CollectRows(rows, pgx.RowToStructByName[models.Classroom])
 }
 
+// Suspends every classroom in an organization, or resumes them, returning the classrooms that changed
+func (db *DB) SetOrgClassroomsSuspended(ctx context.Context, orgID int64, suspended bool) ([]models.Classroom, error) {
+	rows, err := db.connPool.Query(ctx, `
+	UPDATE classrooms
+	SET suspended_at = CASE WHEN $2 THEN (NOW() AT TIME ZONE 'UTC') ELSE NULL END
+	WHERE org_id = $1 AND (suspended_at IS NULL) = $2
+	RETURNING id, name, org_id, org_name, created_at, student_team_name, suspended_at`, orgID, suspended)
+	if err != nil {
+		return nil, errs.NewDBError(err)
+	}
+
+	return pgx.CollectRows(rows, pgx.RowToStructByName[models.Classroom])
+}
+
 func (db *DB) GetUserClassroomsInOrg(ctx context.Context, orgID int64, userID int64) ([]models.ClassroomUser, error) {
 	rows, err := db.connPool.Query(ctx, `
 	SELECT u.id, u.first_name, u.last_name, u.github_username, u.github_user_id, cm.classroom_id, cm.classroom_role, cm.status, c.name as classroom_name, c.created_at as classroom_created_at, c.org_id, c.org_name
@@ -278,6 +296,21 @@ func (db *DB) GetUserClassroomsInOrg(ctx context.Context, orgID int64, userID in
 	return pgx.CollectRows(rows, pgx.RowToStructByName[models.ClassroomUser])
 }
 
+// Gets the user's membership in every classroom of an organization, whatever its status
+func (db *DB) GetUserMembershipsInOrg(ctx context.Context, orgID int64, userID int64) ([]models.ClassroomUser, error) {
+	rows, err := db.connPool.Query(ctx, `
+	SELECT u.id, u.first_name, u.last_name, u.github_username, u.github_user_id, cm.classroom_id, cm.classroom_role, cm.status, c.name as classroom_name, c.created_at as classroom_created_at, c.org_id, c.org_name
+	FROM users u
+	JOIN classroom_membership cm ON u.id = cm.user_id
+	JOIN classrooms c ON c.id = cm.classroom_id
+	WHERE c.org_id = $1 AND u.id = $2`, orgID, userID)
+	if err != nil {
+		return nil, errs.NewDBError(err)
+	}
+
+	return pgx.CollectRows(rows, pgx.RowToStructByName[models.ClassroomUser])
+}
+
 func (db *DB) CreateClassroomToken(ctx context.Context, tokenData models.ClassroomToken) (models.ClassroomToken, error) {
 	err := db.connPool.QueryRow(ctx, `
 	INSERT INTO classroom_tokens (classroom_id, classroom_role, token, expires_at)
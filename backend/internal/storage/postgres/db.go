@@ -7,11 +7,25 @@ import (
 	"os"
 
 	"github.com/CamPlume1/khoury-classroom/internal/config"
+	"github.com/CamPlume1/khoury-classroom/internal/errs"
+	"github.com/CamPlume1/khoury-classroom/internal/storage"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// What a store runs its queries on: the connection pool, or the transaction of a store returned by WithTx.
+// Beginning a transaction inside a transaction makes a savepoint, so store methods work the same on either.
+type queryer interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
 type DB struct {
-	connPool *pgxpool.Pool
+	connPool queryer
+	pool     *pgxpool.Pool
 }
 
 // Establishes a postgres connection pool and returns it for querying
@@ -23,12 +37,31 @@ func New(ctx context.Context, config config.Database) (*DB, error) {
 	}
 
 	fmt.Println("Successfully connected to the database!")
-	return &DB{connPool: connPool}, nil
+	return &DB{connPool: connPool, pool: connPool}, nil
 }
 
 // Closes the connection pool
 func (db *DB) Close(ctx context.Context) {
-	db.connPool.Close()
+	db.pool.Close()
+}
+
+// Runs fn with a store whose queries all run in one transaction, which is committed if fn succeeds and
+// rolled back otherwise
+func (db *DB) WithTx(ctx context.Context, fn func(store storage.Storage) error) error {
+	tx, err := db.connPool.Begin(ctx)
+	if err != nil {
+		return errs.NewDBError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err = fn(&DB{connPool: tx, pool: db.pool}); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return errs.NewDBError(err)
+	}
+	return nil
 }
 
 // Loads and executes a SQL file
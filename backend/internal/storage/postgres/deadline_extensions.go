@@ -0,0 +1,161 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/CamPlume1/khoury-classroom/internal/errs"
+	"github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+const deadlineExtensionFields = `
+	de.id,
+	de.assignment_outline_id,
+	de.user_id,
+	u.github_username,
+	de.due_date,
+	de.reason,
+	de.granted_by,
+	de.created_at,
+	de.revoked_at,
+	de.revoked_by
+`
+
+// points the works a student contributes to in an assignment at their personal deadline, or back at the
+// assignment's once they have none. A team work is due at the earliest deadline of its contributors, so the team
+// only gets more time once every teammate has been granted it.
+const syncWorkDeadlines = `
+	UPDATE student_works sw
+	SET unique_due_date = (
+		SELECT MIN(COALESCE(de.due_date, ao.main_due_date))
+		FROM work_contributors wc
+		JOIN assignment_outlines ao ON ao.id = sw.assignment_outline_id
+		LEFT JOIN deadline_extensions de ON de.assignment_outline_id = sw.assignment_outline_id
+			AND de.user_id = wc.user_id AND de.revoked_at IS NULL
+		WHERE wc.student_work_id = sw.id
+	)
+	WHERE sw.assignment_outline_id = $1
+		AND sw.id IN (SELECT wc.student_work_id FROM work_contributors wc WHERE wc.user_id = $2)
+`
+
+func collectDeadlineExtension(rows pgx.Rows) (models.DeadlineExtension, error) {
+	defer rows.Close()
+	extension, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[models.DeadlineExtension])
+	if err != nil {
+		return models.DeadlineExtension{}, err
+	}
+	return extension, nil
+}
+
+// Grants a student a personal deadline for an assignment, replacing any extension they already had,
+// and moves the deadline of their work to match
+func (db *DB) GrantDeadlineExtension(ctx context.Context, extension models.DeadlineExtension) (models.DeadlineExtension, error) {
+	tx, err := db.connPool.Begin(ctx)
+	if err != nil {
+		return models.DeadlineExtension{}, errs.NewDBError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+	UPDATE deadline_extensions
+	SET revoked_at = (NOW() AT TIME ZONE 'UTC'), revoked_by = $3
+	WHERE assignment_outline_id = $1 AND user_id = $2 AND revoked_at IS NULL`,
+		extension.AssignmentOutlineID, extension.UserID, extension.GrantedBy)
+	if err != nil {
+		return models.DeadlineExtension{}, errs.NewDBError(err)
+	}
+
+	rows, err := tx.Query(ctx, fmt.Sprintf(`
+	WITH de AS (
+		INSERT INTO deadline_extensions (assignment_outline_id, user_id, due_date, reason, granted_by)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING *
+	)
+	SELECT %s FROM de JOIN users u ON u.id = de.user_id`, deadlineExtensionFields),
+		extension.AssignmentOutlineID, extension.UserID, extension.DueDate.UTC(), extension.Reason, extension.GrantedBy)
+	if err != nil {
+		return models.DeadlineExtension{}, errs.NewDBError(err)
+	}
+	extension, err = collectDeadlineExtension(rows)
+	if err != nil {
+		return models.DeadlineExtension{}, errs.NewDBError(err)
+	}
+
+	_, err = tx.Exec(ctx, syncWorkDeadlines, extension.AssignmentOutlineID, extension.UserID)
+	if err != nil {
+		return models.DeadlineExtension{}, errs.NewDBError(err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return models.DeadlineExtension{}, errs.NewDBError(err)
+	}
+
+	return extension, nil
+}
+
+// Revokes an active extension on an assignment, moving the student's work back to the assignment's deadline.
+// Returns pgx.ErrNoRows if the assignment has no such active extension.
+func (db *DB) RevokeDeadlineExtension(ctx context.Context, assignmentID int64, extensionID int64, revokedBy int64) (models.DeadlineExtension, error) {
+	tx, err := db.connPool.Begin(ctx)
+	if err != nil {
+		return models.DeadlineExtension{}, errs.NewDBError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, fmt.Sprintf(`
+	WITH de AS (
+		UPDATE deadline_extensions
+		SET revoked_at = (NOW() AT TIME ZONE 'UTC'), revoked_by = $1
+		WHERE id = $2 AND assignment_outline_id = $3 AND revoked_at IS NULL
+		RETURNING *
+	)
+	SELECT %s FROM de JOIN users u ON u.id = de.user_id`, deadlineExtensionFields),
+		revokedBy, extensionID, assignmentID)
+	if err != nil {
+		return models.DeadlineExtension{}, errs.NewDBError(err)
+	}
+	extension, err := collectDeadlineExtension(rows)
+	if err != nil {
+		return models.DeadlineExtension{}, err
+	}
+
+	_, err = tx.Exec(ctx, syncWorkDeadlines, extension.AssignmentOutlineID, extension.UserID)
+	if err != nil {
+		return models.DeadlineExtension{}, errs.NewDBError(err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return models.DeadlineExtension{}, errs.NewDBError(err)
+	}
+
+	return extension, nil
+}
+
+// Lists the extensions on an assignment, optionally including revoked ones to see its full history
+func (db *DB) ListDeadlineExtensions(ctx context.Context, assignmentID int64, includeRevoked bool) ([]models.DeadlineExtension, error) {
+	rows, err := db.connPool.Query(ctx, fmt.Sprintf(`
+	SELECT %s FROM deadline_extensions de JOIN users u ON u.id = de.user_id
+	WHERE de.assignment_outline_id = $1 AND ($2 OR de.revoked_at IS NULL)
+	ORDER BY de.created_at DESC`, deadlineExtensionFields),
+		assignmentID, includeRevoked)
+	if err != nil {
+		return nil, errs.NewDBError(err)
+	}
+	defer rows.Close()
+
+	return pgx.CollectRows(rows, pgx.RowToStructByName[models.DeadlineExtension])
+}
+
+// Gets a student's active extension on an assignment. Returns pgx.ErrNoRows if they have none.
+func (db *DB) GetActiveDeadlineExtension(ctx context.Context, assignmentID int64, userID int64) (models.DeadlineExtension, error) {
+	rows, err := db.connPool.Query(ctx, fmt.Sprintf(`
+	SELECT %s FROM deadline_extensions de JOIN users u ON u.id = de.user_id
+	WHERE de.assignment_outline_id = $1 AND de.user_id = $2 AND de.revoked_at IS NULL`, deadlineExtensionFields),
+		assignmentID, userID)
+	if err != nil {
+		return models.DeadlineExtension{}, errs.NewDBError(err)
+	}
+
+	return collectDeadlineExtension(rows)
+}
@@ -7,20 +7,23 @@ import (
 )
 
 
+// Gets the deadline of a student work: the student's own if they have an extension, otherwise the assignment's.
+// Returns nil if neither has a deadline.
 func (db *DB) GetDeadlineForRepo(ctx context.Context, repoName string) (*time.Time, error){
 	query := `
-SELECT sw.unique_due_date FROM student_works as sw
+SELECT COALESCE(sw.unique_due_date, ao.main_due_date) FROM student_works as sw
+JOIN assignment_outlines AS ao ON ao.id = sw.assignment_outline_id
 WHERE sw.repo_name = $1;
 `
-	var uniqueDueDate time.Time
+	var dueDate *time.Time
 
 
-	err := db.connPool.QueryRow(ctx, query, repoName).Scan(&uniqueDueDate)
+	err := db.connPool.QueryRow(ctx, query, repoName).Scan(&dueDate)
 	if err != nil {
 		return nil, fmt.Errorf("Error Retrieving Deadline: %s \n", err.Error())
 	}
 
-	return &uniqueDueDate, nil
+	return dueDate, nil
 }
 
 // Sets a due date for a specific student
@@ -5,17 +5,16 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/CamPlume1/khoury-classroom/internal/errs"
 	"github.com/CamPlume1/khoury-classroom/internal/models"
 	"github.com/jackc/pgx/v5"
 )
 
 // gets all feedback comments on a student work
 func (db *DB) GetFeedbackOnWork(ctx context.Context, studentWorkID int) ([]models.PRReviewCommentResponse, error) {
-	query := `SELECT student_work_id, rubric_item_id, github_username, file_path, file_line, fc.created_at, point_value, explanation
-	FROM feedback_comment fc
-	JOIN rubric_items ri ON fc.rubric_item_id = ri.id
-	JOIN users u ON fc.ta_user_id = u.id 
-	WHERE student_work_id = $1`
+	query := `SELECT ` + feedbackCommentFields + `
+	WHERE fc.student_work_id = $1 AND fc.deleted_at IS NULL
+	ORDER BY fc.id`
 
 	rows, err := db.connPool.Query(ctx, query, studentWorkID)
 
@@ -34,13 +33,14 @@ func (db *DB) GetFeedbackOnWork(ctx context.Context, studentWorkID int) ([]model
 	var formattedFeedback []models.PRReviewCommentResponse
 	for _, feedback := range rawFeedback {
 		formattedFeedback = append(formattedFeedback, models.PRReviewCommentResponse{
-			PRReviewComment: models.PRReviewComment{
-				Path: feedback.FilePath,
-				Line: feedback.FileLine,
-				Body: feedback.Explanation,
-			},
-			Points:     feedback.PointValue,
-			TAUsername: feedback.TAUsername,
+			PRReviewComment:   feedback.ReviewComment(),
+			RubricItemID:      &feedback.RubricItemID,
+			FeedbackCommentID: &feedback.ID,
+			GitHubCommentID:   feedback.GitHubCommentID,
+			PublishedAt:       feedback.PublishedAt,
+			AcknowledgedAt:    feedback.AcknowledgedAt,
+			Points:            feedback.PointValue,
+			TAUsername:        feedback.TAUsername,
 		})
 	}
 
@@ -48,40 +48,293 @@ func (db *DB) GetFeedbackOnWork(ctx context.Context, studentWorkID int) ([]model
 }
 
 // create a new feedback comment (ad-hoc: also create a rubric item simultaneously)
-func (db *DB) CreateFeedbackComment(ctx context.Context, TAUserID int64, studentWorkID int, comment models.PRReviewCommentResponse) error {
-	_, err := db.connPool.Exec(ctx,
+func (db *DB) CreateFeedbackComment(ctx context.Context, TAUserID int64, studentWorkID int, comment models.PRReviewCommentResponse) (models.FeedbackComment, error) {
+	var feedbackCommentID int64
+	err := db.connPool.QueryRow(ctx,
 		`WITH ri AS
 			(INSERT INTO rubric_items (point_value, explanation) VALUES ($1, $2) RETURNING id)
 		INSERT INTO feedback_comment
-			(rubric_item_id, file_path, file_line, student_work_id, ta_user_id)
-			VALUES ((SELECT id FROM ri), $3, $4, $5, $6)`,
+			(rubric_item_id, file_path, file_line, file_start_line, file_side, file_start_side, student_work_id, ta_user_id, github_comment_id)
+			VALUES ((SELECT id FROM ri), $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id`,
 		comment.Points,
 		comment.Body,
 		comment.Path,
 		comment.Line,
+		comment.StartLine,
+		comment.Side,
+		comment.StartSide,
 		studentWorkID,
 		TAUserID,
-	)
+		comment.GitHubCommentID,
+	).Scan(&feedbackCommentID)
+	if err != nil {
+		return models.FeedbackComment{}, err
+	}
 
-	return err
+	return db.GetFeedbackComment(ctx, feedbackCommentID)
 }
 
 // create a new feedback comment (attach existing rubric item)
-func (db *DB) CreateFeedbackCommentFromRubricItem(ctx context.Context, TAUserID int64, studentWorkID int, comment models.PRReviewCommentResponse) error {
+func (db *DB) CreateFeedbackCommentFromRubricItem(ctx context.Context, TAUserID int64, studentWorkID int, comment models.PRReviewCommentResponse) (models.FeedbackComment, error) {
 	if comment.RubricItemID == nil {
-		return errors.New("no rubric item id given")
+		return models.FeedbackComment{}, errors.New("no rubric item id given")
 	}
 
-	_, err := db.connPool.Exec(ctx,
+	// feedback is given with the current version of the item, even if the grader's rubric is out of date
+	var feedbackCommentID int64
+	err := db.connPool.QueryRow(ctx,
 		`INSERT INTO feedback_comment
-				(rubric_item_id, file_path, file_line, student_work_id, ta_user_id)
-				VALUES ($1, $2, $3, $4, $5)`,
+				(rubric_item_id, file_path, file_line, file_start_line, file_side, file_start_side, student_work_id, ta_user_id, github_comment_id)
+				VALUES ((SELECT COALESCE(cur.id, ri.id) FROM rubric_items ri
+					LEFT JOIN rubric_items cur ON COALESCE(cur.original_item_id, cur.id) = COALESCE(ri.original_item_id, ri.id)
+						AND cur.superseded_by IS NULL
+					WHERE ri.id = $1), $2, $3, $4, $5, $6, $7, $8, $9)
+			RETURNING id`,
 		comment.RubricItemID,
 		comment.Path,
 		comment.Line,
+		comment.StartLine,
+		comment.Side,
+		comment.StartSide,
 		studentWorkID,
 		TAUserID,
-	)
+		comment.GitHubCommentID,
+	).Scan(&feedbackCommentID)
+	if err != nil {
+		return models.FeedbackComment{}, err
+	}
+
+	return db.GetFeedbackComment(ctx, feedbackCommentID)
+}
+
+// records the feedback of a review a TA left on GitHub rather than through the app, each comment on an ad-hoc rubric
+// item of its own. It's already visible to the student, so it's stored as published. A review is imported all at once.
+// Returns pgx.ErrNoRows if the review was already imported, e.g. when GitHub redelivers it.
+func (db *DB) ImportReviewFeedback(ctx context.Context, TAUserID int64, studentWorkID int, reviewID int64, comments []models.PRReviewCommentResponse) ([]models.FeedbackComment, error) {
+	tx, err := db.connPool.Begin(ctx)
+	if err != nil {
+		return nil, errs.NewDBError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	var imported bool
+	err = tx.QueryRow(ctx, `
+	SELECT EXISTS (SELECT 1 FROM feedback_comment WHERE github_review_id = $1)`, reviewID).Scan(&imported)
+	if err != nil {
+		return nil, errs.NewDBError(err)
+	}
+	if imported {
+		return nil, pgx.ErrNoRows
+	}
+
+	var feedbackCommentIDs []int64
+	for _, comment := range comments {
+		var feedbackCommentID int64
+		err := tx.QueryRow(ctx,
+			`WITH ri AS
+				(INSERT INTO rubric_items (point_value, explanation) VALUES ($1, $2) RETURNING id)
+			INSERT INTO feedback_comment
+				(rubric_item_id, file_path, file_line, file_start_line, file_side, file_start_side, student_work_id, ta_user_id, github_comment_id, github_review_id, published_at)
+				VALUES ((SELECT id FROM ri), $3, $4, $5, $6, $7, $8, $9, $10, $11, (NOW() AT TIME ZONE 'UTC'))
+			RETURNING id`,
+			comment.Points,
+			comment.Body,
+			comment.Path,
+			comment.Line,
+			comment.StartLine,
+			comment.Side,
+			comment.StartSide,
+			studentWorkID,
+			TAUserID,
+			comment.GitHubCommentID,
+			reviewID,
+		).Scan(&feedbackCommentID)
+		if err != nil {
+			return nil, errs.NewDBError(err)
+		}
+		feedbackCommentIDs = append(feedbackCommentIDs, feedbackCommentID)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, errs.NewDBError(err)
+	}
+
+	feedback := make([]models.FeedbackComment, 0, len(feedbackCommentIDs))
+	for _, feedbackCommentID := range feedbackCommentIDs {
+		comment, err := db.GetFeedbackComment(ctx, feedbackCommentID)
+		if err != nil {
+			return nil, errs.NewDBError(err)
+		}
+		feedback = append(feedback, comment)
+	}
+	return feedback, nil
+}
+
+const feedbackCommentFields = `
+	fc.id, fc.student_work_id, fc.rubric_item_id, u.github_username, fc.file_path, fc.file_line,
+	fc.file_start_line, fc.file_side, fc.file_start_side, fc.github_comment_id, fc.published_at, fc.acknowledged_at, fc.acknowledged_by,
+	fc.created_at, fc.deleted_at, ri.point_value, ri.explanation
+	FROM feedback_comment fc
+	JOIN rubric_items ri ON fc.rubric_item_id = ri.id
+	JOIN users u ON fc.ta_user_id = u.id`
+
+// gets a single feedback comment, even if it was deleted
+func (db *DB) GetFeedbackComment(ctx context.Context, feedbackCommentID int64) (models.FeedbackComment, error) {
+	rows, err := db.connPool.Query(ctx, "SELECT "+feedbackCommentFields+" WHERE fc.id = $1", feedbackCommentID)
+	if err != nil {
+		return models.FeedbackComment{}, err
+	}
+	defer rows.Close()
+
+	return pgx.CollectOneRow(rows, pgx.RowToStructByName[models.FeedbackComment])
+}
+
+// gets the feedback comment posted to GitHub as the given review comment
+func (db *DB) GetFeedbackCommentByGitHubID(ctx context.Context, githubCommentID int64) (models.FeedbackComment, error) {
+	rows, err := db.connPool.Query(ctx, "SELECT "+feedbackCommentFields+" WHERE fc.github_comment_id = $1", githubCommentID)
+	if err != nil {
+		return models.FeedbackComment{}, err
+	}
+	defer rows.Close()
+
+	return pgx.CollectOneRow(rows, pgx.RowToStructByName[models.FeedbackComment])
+}
+
+// marks a feedback comment as acknowledged by the given student, or as no longer acknowledged if acknowledgedBy is nil.
+// Returns pgx.ErrNoRows if the comment doesn't exist or was deleted.
+func (db *DB) SetFeedbackAcknowledged(ctx context.Context, feedbackCommentID int64, acknowledgedBy *int64) error {
+	result, err := db.connPool.Exec(ctx, `
+	UPDATE feedback_comment
+	SET acknowledged_by = $2,
+		acknowledged_at = CASE WHEN $2::INTEGER IS NULL THEN NULL ELSE (NOW() AT TIME ZONE 'UTC') END
+	WHERE id = $1 AND deleted_at IS NULL`, feedbackCommentID, acknowledgedBy)
+	if err != nil {
+		return errs.NewDBError(err)
+	}
+	if result.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// records what a feedback comment is before it's edited or deleted, returning pgx.ErrNoRows if it's already deleted
+func recordFeedbackCommentRevision(ctx context.Context, tx pgx.Tx, feedbackCommentID int64, action models.PRReviewCommentAction, changedBy int64) error {
+	result, err := tx.Exec(ctx, `
+	INSERT INTO feedback_comment_history
+		(feedback_comment_id, action, rubric_item_id, point_value, explanation,
+		file_path, file_line, file_start_line, file_side, file_start_side, changed_by)
+	SELECT fc.id, $2, fc.rubric_item_id, ri.point_value, ri.explanation,
+		fc.file_path, fc.file_line, fc.file_start_line, fc.file_side, fc.file_start_side, $3
+	FROM feedback_comment fc
+	JOIN rubric_items ri ON ri.id = fc.rubric_item_id
+	WHERE fc.id = $1 AND fc.deleted_at IS NULL`, feedbackCommentID, action, changedBy)
+	if err != nil {
+		return errs.NewDBError(err)
+	}
+	if result.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// edits a feedback comment, keeping what it was before in its history. Feedback is either moved onto
+// another rubric item, or given ad-hoc points and body on a new item of its own.
+// Returns pgx.ErrNoRows if the comment doesn't exist or was deleted.
+func (db *DB) EditFeedbackComment(ctx context.Context, feedbackCommentID int64, changedBy int64, edit models.PRReviewCommentResponse) (models.FeedbackComment, error) {
+	tx, err := db.connPool.Begin(ctx)
+	if err != nil {
+		return models.FeedbackComment{}, errs.NewDBError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	err = recordFeedbackCommentRevision(ctx, tx, feedbackCommentID, models.PRReviewCommentActionEdit, changedBy)
+	if err != nil {
+		return models.FeedbackComment{}, err
+	}
+
+	// items of a rubric are given with their current version, anything else is ad-hoc
+	var rubricItemID *int64
+	if edit.RubricItemID != nil {
+		err = tx.QueryRow(ctx, `
+		SELECT COALESCE(cur.id, ri.id) FROM rubric_items ri
+		LEFT JOIN rubric_items cur ON COALESCE(cur.original_item_id, cur.id) = COALESCE(ri.original_item_id, ri.id)
+			AND cur.superseded_by IS NULL
+		WHERE ri.id = $1 AND ri.rubric_id IS NOT NULL`, *edit.RubricItemID).Scan(&rubricItemID)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return models.FeedbackComment{}, errs.NewDBError(err)
+		}
+	}
+	if rubricItemID == nil {
+		err = tx.QueryRow(ctx, `
+		INSERT INTO rubric_items (point_value, explanation) VALUES ($1, $2)
+		RETURNING id`, edit.Points, edit.Body).Scan(&rubricItemID)
+		if err != nil {
+			return models.FeedbackComment{}, errs.NewDBError(err)
+		}
+	}
+
+	_, err = tx.Exec(ctx, `
+	UPDATE feedback_comment SET rubric_item_id = $1, file_path = $2, file_line = $3,
+		file_start_line = $4, file_side = $5, file_start_side = $6
+	WHERE id = $7`, rubricItemID, edit.Path, edit.Line, edit.StartLine, edit.Side, edit.StartSide, feedbackCommentID)
+	if err != nil {
+		return models.FeedbackComment{}, errs.NewDBError(err)
+	}
+
+	rows, err := tx.Query(ctx, "SELECT "+feedbackCommentFields+" WHERE fc.id = $1", feedbackCommentID)
+	if err != nil {
+		return models.FeedbackComment{}, errs.NewDBError(err)
+	}
+	feedback, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[models.FeedbackComment])
+	if err != nil {
+		return models.FeedbackComment{}, errs.NewDBError(err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return models.FeedbackComment{}, errs.NewDBError(err)
+	}
+	return feedback, nil
+}
+
+// deletes a feedback comment so it no longer counts towards its work's score, keeping it in its history.
+// Returns pgx.ErrNoRows if the comment doesn't exist or was already deleted.
+func (db *DB) DeleteFeedbackComment(ctx context.Context, feedbackCommentID int64, changedBy int64) error {
+	tx, err := db.connPool.Begin(ctx)
+	if err != nil {
+		return errs.NewDBError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	err = recordFeedbackCommentRevision(ctx, tx, feedbackCommentID, models.PRReviewCommentActionDelete, changedBy)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE feedback_comment SET deleted_at = (NOW() AT TIME ZONE 'UTC') WHERE id = $1`, feedbackCommentID)
+	if err != nil {
+		return errs.NewDBError(err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return errs.NewDBError(err)
+	}
+	return nil
+}
+
+// gets what a feedback comment was before each of its edits, oldest first
+func (db *DB) GetFeedbackCommentHistory(ctx context.Context, feedbackCommentID int64) ([]models.FeedbackCommentRevision, error) {
+	rows, err := db.connPool.Query(ctx, `
+	SELECT fch.id, fch.feedback_comment_id, fch.action, fch.rubric_item_id, fch.point_value, fch.explanation,
+		fch.file_path, fch.file_line, fch.file_start_line, fch.file_side, fch.file_start_side, fch.changed_by, u.github_username AS changed_by_username, fch.changed_at
+	FROM feedback_comment_history fch
+	JOIN users u ON u.id = fch.changed_by
+	WHERE fch.feedback_comment_id = $1
+	ORDER BY fch.changed_at, fch.id`, feedbackCommentID)
+	if err != nil {
+		return nil, errs.NewDBError(err)
+	}
+	defer rows.Close()
 
-	return err
+	return pgx.CollectRows(rows, pgx.RowToStructByName[models.FeedbackCommentRevision])
 }
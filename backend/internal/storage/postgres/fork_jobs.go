@@ -0,0 +1,261 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/CamPlume1/khoury-classroom/internal/errs"
+	"github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// jobs stuck in progress for longer than this are assumed to belong to a crashed worker
+const staleForkJobTimeout = 10 * time.Minute
+
+const forkJobFields = `
+	fj.id,
+	fj.assignment_outline_id,
+	fj.user_id,
+	u.github_user_id,
+	fj.org_name,
+	fj.repo_name,
+	fj.first_commit_sha,
+	fj.team_id,
+	fj.state,
+	ARRAY(
+		SELECT fjs.step::TEXT FROM fork_job_steps fjs
+		WHERE fjs.fork_job_id = fj.id
+		ORDER BY fjs.completed_at
+	) AS completed_steps,
+	fj.attempts,
+	fj.last_error,
+	fj.next_attempt_at,
+	fj.created_at,
+	fj.updated_at
+`
+
+func collectForkJob(rows pgx.Rows) (models.ForkJob, error) {
+	defer rows.Close()
+	job, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[models.ForkJob])
+	if err != nil {
+		return models.ForkJob{}, err
+	}
+	return job, nil
+}
+
+// Queues a fork. Returns pgx.ErrNoRows if the fork is for a team whose fork is already queued, e.g. when two
+// teammates accept the assignment at once.
+func (db *DB) CreateForkJob(ctx context.Context, jobData models.ForkJob) (models.ForkJob, error) {
+	rows, err := db.connPool.Query(ctx, fmt.Sprintf(`
+	WITH fj AS (
+		INSERT INTO fork_jobs (assignment_outline_id, user_id, org_name, repo_name, first_commit_sha, team_id)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (team_id) DO NOTHING
+		RETURNING *
+	)
+	SELECT %s FROM fj JOIN users u ON u.id = fj.user_id`, forkJobFields),
+		jobData.AssignmentOutlineID,
+		jobData.UserID,
+		jobData.OrgName,
+		jobData.RepoName,
+		jobData.FirstCommitSHA,
+		jobData.TeamID,
+	)
+	if err != nil {
+		return models.ForkJob{}, errs.NewDBError(err)
+	}
+
+	return collectForkJob(rows)
+}
+
+func (db *DB) GetForkJob(ctx context.Context, jobID int64) (models.ForkJob, error) {
+	rows, err := db.connPool.Query(ctx, fmt.Sprintf(`
+	SELECT %s FROM fork_jobs fj JOIN users u ON u.id = fj.user_id
+	WHERE fj.id = $1`, forkJobFields), jobID)
+	if err != nil {
+		return models.ForkJob{}, errs.NewDBError(err)
+	}
+
+	return collectForkJob(rows)
+}
+
+// Gets the fork job of a user for an assignment, whatever state it is in
+func (db *DB) GetForkJobByAssignmentAndUser(ctx context.Context, assignmentID int64, userID int64) (models.ForkJob, error) {
+	rows, err := db.connPool.Query(ctx, fmt.Sprintf(`
+	SELECT %s FROM fork_jobs fj JOIN users u ON u.id = fj.user_id
+	WHERE fj.assignment_outline_id = $1 AND fj.user_id = $2`, forkJobFields),
+		assignmentID, userID)
+	if err != nil {
+		return models.ForkJob{}, errs.NewDBError(err)
+	}
+
+	return collectForkJob(rows)
+}
+
+// Gets the fork job of a team, whichever member accepted the assignment
+func (db *DB) GetForkJobByTeam(ctx context.Context, teamID int64) (models.ForkJob, error) {
+	rows, err := db.connPool.Query(ctx, fmt.Sprintf(`
+	SELECT %s FROM fork_jobs fj JOIN users u ON u.id = fj.user_id
+	WHERE fj.team_id = $1`, forkJobFields), teamID)
+	if err != nil {
+		return models.ForkJob{}, errs.NewDBError(err)
+	}
+
+	return collectForkJob(rows)
+}
+
+// Lists the fork jobs in a classroom that have not completed
+func (db *DB) ListUnfinishedForkJobs(ctx context.Context, classroomID int64) ([]models.ForkJob, error) {
+	rows, err := db.connPool.Query(ctx, fmt.Sprintf(`
+	SELECT %s FROM fork_jobs fj
+	JOIN users u ON u.id = fj.user_id
+	JOIN assignment_outlines ao ON ao.id = fj.assignment_outline_id
+	WHERE ao.classroom_id = $1 AND fj.state != $2
+	ORDER BY fj.created_at`, forkJobFields),
+		classroomID, models.ForkJobStateCompleted)
+	if err != nil {
+		return nil, errs.NewDBError(err)
+	}
+	defer rows.Close()
+
+	return pgx.CollectRows(rows, pgx.RowToStructByName[models.ForkJob])
+}
+
+// Whether any student (or team) has started accepting an assignment
+func (db *DB) AssignmentHasForkJobs(ctx context.Context, assignmentID int64) (bool, error) {
+	var exists bool
+	err := db.connPool.QueryRow(ctx, `
+	SELECT EXISTS (SELECT 1 FROM fork_jobs WHERE assignment_outline_id = $1)`, assignmentID).Scan(&exists)
+	if err != nil {
+		return false, errs.NewDBError(err)
+	}
+	return exists, nil
+}
+
+// Whether a queued fork has reserved a repository name, whether or not GitHub has created the repository yet
+func (db *DB) ForkJobRepoNameExists(ctx context.Context, repoName string) (bool, error) {
+	var exists bool
+	err := db.connPool.QueryRow(ctx, `
+	SELECT EXISTS (SELECT 1 FROM fork_jobs WHERE LOWER(repo_name) = LOWER($1))`, repoName).Scan(&exists)
+	if err != nil {
+		return false, errs.NewDBError(err)
+	}
+	return exists, nil
+}
+
+// Claims the next runnable fork job (pending and due, or abandoned by a crashed worker).
+// Returns pgx.ErrNoRows if there is nothing to do.
+func (db *DB) ClaimNextForkJob(ctx context.Context) (models.ForkJob, error) {
+	rows, err := db.connPool.Query(ctx, fmt.Sprintf(`
+	WITH fj AS (
+		UPDATE fork_jobs
+		SET state = $1, attempts = attempts + 1, updated_at = (NOW() AT TIME ZONE 'UTC')
+		WHERE id = (
+			SELECT id FROM fork_jobs
+			WHERE (state = $2 AND next_attempt_at <= (NOW() AT TIME ZONE 'UTC'))
+				OR (state = $1 AND updated_at < (NOW() AT TIME ZONE 'UTC') - $3::INTERVAL)
+			ORDER BY next_attempt_at
+			FOR UPDATE SKIP LOCKED
+			LIMIT 1
+		)
+		RETURNING *
+	)
+	SELECT %s FROM fj JOIN users u ON u.id = fj.user_id`, forkJobFields),
+		models.ForkJobStateInProgress, models.ForkJobStatePending, staleForkJobTimeout)
+	if err != nil {
+		return models.ForkJob{}, errs.NewDBError(err)
+	}
+
+	return collectForkJob(rows)
+}
+
+// Claims a pending fork job by its repository, regardless of when it is next due.
+// Returns pgx.ErrNoRows if the repository has no pending job.
+func (db *DB) ClaimForkJobByRepoName(ctx context.Context, orgName string, repoName string) (models.ForkJob, error) {
+	rows, err := db.connPool.Query(ctx, fmt.Sprintf(`
+	WITH fj AS (
+		UPDATE fork_jobs
+		SET state = $1, attempts = attempts + 1, updated_at = (NOW() AT TIME ZONE 'UTC')
+		WHERE id = (
+			SELECT id FROM fork_jobs
+			WHERE LOWER(org_name) = LOWER($2) AND LOWER(repo_name) = LOWER($3) AND state = $4
+			ORDER BY created_at
+			FOR UPDATE SKIP LOCKED
+			LIMIT 1
+		)
+		RETURNING *
+	)
+	SELECT %s FROM fj JOIN users u ON u.id = fj.user_id`, forkJobFields),
+		models.ForkJobStateInProgress, orgName, repoName, models.ForkJobStatePending)
+	if err != nil {
+		return models.ForkJob{}, errs.NewDBError(err)
+	}
+
+	return collectForkJob(rows)
+}
+
+// Puts a claimed fork job back on the queue to be retried later
+func (db *DB) RetryForkJob(ctx context.Context, jobID int64, nextAttemptAt time.Time, lastError *string) error {
+	_, err := db.connPool.Exec(ctx, `
+	UPDATE fork_jobs
+	SET state = $1, next_attempt_at = $2, last_error = $3, updated_at = (NOW() AT TIME ZONE 'UTC')
+	WHERE id = $4`,
+		models.ForkJobStatePending, nextAttemptAt.UTC(), lastError, jobID)
+	if err != nil {
+		return errs.NewDBError(err)
+	}
+
+	return nil
+}
+
+// Puts a failed or waiting fork job back on the queue to run immediately with a fresh set of attempts.
+// Completed steps are kept, so the job resumes where it left off. Jobs a worker is running are left alone,
+// unless the worker crashed. Returns pgx.ErrNoRows if the job has completed or is being run.
+func (db *DB) ResetForkJob(ctx context.Context, jobID int64) (models.ForkJob, error) {
+	rows, err := db.connPool.Query(ctx, fmt.Sprintf(`
+	WITH fj AS (
+		UPDATE fork_jobs
+		SET state = $1, attempts = 0, last_error = NULL,
+			next_attempt_at = (NOW() AT TIME ZONE 'UTC'), updated_at = (NOW() AT TIME ZONE 'UTC')
+		WHERE id = $2
+			AND (state IN ($1, $3)
+				OR (state = $4 AND updated_at < (NOW() AT TIME ZONE 'UTC') - $5::INTERVAL))
+		RETURNING *
+	)
+	SELECT %s FROM fj JOIN users u ON u.id = fj.user_id`, forkJobFields),
+		models.ForkJobStatePending, jobID, models.ForkJobStateFailed, models.ForkJobStateInProgress, staleForkJobTimeout)
+	if err != nil {
+		return models.ForkJob{}, errs.NewDBError(err)
+	}
+
+	return collectForkJob(rows)
+}
+
+// Records that a fork job has completed a step
+func (db *DB) CompleteForkJobStep(ctx context.Context, jobID int64, step models.ForkJobStep) error {
+	_, err := db.connPool.Exec(ctx, `
+	INSERT INTO fork_job_steps (fork_job_id, step)
+	VALUES ($1, $2)
+	ON CONFLICT DO NOTHING`,
+		jobID, step)
+	if err != nil {
+		return errs.NewDBError(err)
+	}
+
+	return nil
+}
+
+// Marks a fork job as finished, either successfully or not
+func (db *DB) FinishForkJob(ctx context.Context, jobID int64, state models.ForkJobState, lastError *string) error {
+	_, err := db.connPool.Exec(ctx, `
+	UPDATE fork_jobs
+	SET state = $1, last_error = $2, updated_at = (NOW() AT TIME ZONE 'UTC')
+	WHERE id = $3`,
+		state, lastError, jobID)
+	if err != nil {
+		return errs.NewDBError(err)
+	}
+
+	return nil
+}
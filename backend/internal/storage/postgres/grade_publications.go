@@ -0,0 +1,212 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/CamPlume1/khoury-classroom/internal/errs"
+	"github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+const gradePublicationFields = `
+	assignment_outline_id, publish_at, published_at, scheduled_by, last_error, created_at, updated_at
+`
+
+func collectGradePublication(rows pgx.Rows) (models.GradePublication, error) {
+	defer rows.Close()
+	publication, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[models.GradePublication])
+	if err != nil {
+		return models.GradePublication{}, err
+	}
+	return publication, nil
+}
+
+// Saves the body of the review a work's draft feedback will be posted with
+func (db *DB) UpsertReviewDraft(ctx context.Context, studentWorkID int64, taUserID int64, body string) error {
+	_, err := db.connPool.Exec(ctx, `
+	INSERT INTO review_drafts (student_work_id, ta_user_id, body)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (student_work_id) DO UPDATE
+	SET ta_user_id = EXCLUDED.ta_user_id, body = EXCLUDED.body, updated_at = (NOW() AT TIME ZONE 'UTC')`,
+		studentWorkID, taUserID, body)
+	if err != nil {
+		return errs.NewDBError(err)
+	}
+
+	return nil
+}
+
+// Gets the review draft of a work. Returns pgx.ErrNoRows if it has none.
+func (db *DB) GetReviewDraft(ctx context.Context, studentWorkID int64) (models.ReviewDraft, error) {
+	rows, err := db.connPool.Query(ctx, `
+	SELECT student_work_id, ta_user_id, body, updated_at
+	FROM review_drafts
+	WHERE student_work_id = $1`, studentWorkID)
+	if err != nil {
+		return models.ReviewDraft{}, errs.NewDBError(err)
+	}
+	defer rows.Close()
+
+	return pgx.CollectOneRow(rows, pgx.RowToStructByName[models.ReviewDraft])
+}
+
+// Gets the works of an assignment that have draft feedback waiting to be published
+func (db *DB) GetWorksWithUnpublishedGrades(ctx context.Context, assignmentID int64) ([]models.StudentWork, error) {
+	rows, err := db.connPool.Query(ctx, fmt.Sprintf(`
+	SELECT %s FROM %s
+	WHERE sw.assignment_outline_id = $1 AND (
+		EXISTS (SELECT 1 FROM feedback_comment fc WHERE fc.student_work_id = sw.id AND fc.published_at IS NULL AND fc.deleted_at IS NULL)
+		OR EXISTS (SELECT 1 FROM review_drafts rd WHERE rd.student_work_id = sw.id)
+	)
+	ORDER BY sw.id`, DesiredFields, JoinedTable), assignmentID)
+	if err != nil {
+		return nil, errs.NewDBError(err)
+	}
+	defer rows.Close()
+
+	rawWorks, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.RawStudentWork])
+	if err != nil {
+		return nil, errs.NewDBError(err)
+	}
+
+	// works with several contributors appear once per contributor
+	works := []models.StudentWork{}
+	for _, rawWork := range rawWorks {
+		if len(works) > 0 && works[len(works)-1].ID == rawWork.ID {
+			continue
+		}
+		works = append(works, rawWork.StudentWork)
+	}
+
+	return works, nil
+}
+
+// Marks the given feedback of a work as published, recording the review comments they were posted as,
+// and releases the work's grade to its students
+func (db *DB) PublishWorkGrades(ctx context.Context, studentWorkID int64, comments []models.PRReviewCommentResponse) error {
+	tx, err := db.connPool.Begin(ctx)
+	if err != nil {
+		return errs.NewDBError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	for _, comment := range comments {
+		if comment.FeedbackCommentID == nil {
+			continue
+		}
+		_, err = tx.Exec(ctx, `
+		UPDATE feedback_comment
+		SET published_at = (NOW() AT TIME ZONE 'UTC'), github_comment_id = COALESCE($1, github_comment_id)
+		WHERE id = $2 AND student_work_id = $3`,
+			comment.GitHubCommentID, *comment.FeedbackCommentID, studentWorkID)
+		if err != nil {
+			return errs.NewDBError(err)
+		}
+	}
+
+	_, err = tx.Exec(ctx, `
+	UPDATE student_works
+	SET grades_published_timestamp = (NOW() AT TIME ZONE 'UTC'), work_state = $1
+	WHERE id = $2`,
+		models.WorkStateGradePublished, studentWorkID)
+	if err != nil {
+		return errs.NewDBError(err)
+	}
+
+	_, err = tx.Exec(ctx, `DELETE FROM review_drafts WHERE student_work_id = $1`, studentWorkID)
+	if err != nil {
+		return errs.NewDBError(err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return errs.NewDBError(err)
+	}
+
+	return nil
+}
+
+// Gets when the grades of an assignment are scheduled to be, or were last, published.
+// Returns pgx.ErrNoRows if they never have been.
+func (db *DB) GetGradePublication(ctx context.Context, assignmentID int64) (models.GradePublication, error) {
+	rows, err := db.connPool.Query(ctx, fmt.Sprintf(`
+	SELECT %s FROM grade_publications
+	WHERE assignment_outline_id = $1`, gradePublicationFields), assignmentID)
+	if err != nil {
+		return models.GradePublication{}, errs.NewDBError(err)
+	}
+
+	return collectGradePublication(rows)
+}
+
+// Schedules the grades of an assignment to be published, replacing any earlier schedule.
+// A publishedAt time records a publication that has already run.
+func (db *DB) UpsertGradePublication(ctx context.Context, assignmentID int64, publishAt time.Time, publishedAt *time.Time, userID int64) (models.GradePublication, error) {
+	rows, err := db.connPool.Query(ctx, fmt.Sprintf(`
+	INSERT INTO grade_publications (assignment_outline_id, publish_at, published_at, scheduled_by)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT (assignment_outline_id) DO UPDATE
+	SET publish_at = EXCLUDED.publish_at,
+		published_at = EXCLUDED.published_at,
+		scheduled_by = EXCLUDED.scheduled_by,
+		last_error = NULL,
+		updated_at = (NOW() AT TIME ZONE 'UTC')
+	RETURNING %s`, gradePublicationFields),
+		assignmentID, publishAt.UTC(), publishedAt, userID)
+	if err != nil {
+		return models.GradePublication{}, errs.NewDBError(err)
+	}
+
+	return collectGradePublication(rows)
+}
+
+// Cancels a scheduled publication that has not run yet. Returns pgx.ErrNoRows if there is none.
+func (db *DB) CancelGradePublication(ctx context.Context, assignmentID int64) error {
+	tag, err := db.connPool.Exec(ctx, `
+	DELETE FROM grade_publications
+	WHERE assignment_outline_id = $1 AND published_at IS NULL`, assignmentID)
+	if err != nil {
+		return errs.NewDBError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+// Claims the next scheduled publication that is due by marking it published.
+// Returns pgx.ErrNoRows if there is nothing to do.
+func (db *DB) ClaimDueGradePublication(ctx context.Context) (models.GradePublication, error) {
+	rows, err := db.connPool.Query(ctx, fmt.Sprintf(`
+	UPDATE grade_publications
+	SET published_at = (NOW() AT TIME ZONE 'UTC'), last_error = NULL, updated_at = (NOW() AT TIME ZONE 'UTC')
+	WHERE assignment_outline_id = (
+		SELECT assignment_outline_id FROM grade_publications
+		WHERE published_at IS NULL AND publish_at <= (NOW() AT TIME ZONE 'UTC')
+		ORDER BY publish_at
+		FOR UPDATE SKIP LOCKED
+		LIMIT 1
+	)
+	RETURNING %s`, gradePublicationFields))
+	if err != nil {
+		return models.GradePublication{}, errs.NewDBError(err)
+	}
+
+	return collectGradePublication(rows)
+}
+
+// Puts a claimed publication that failed back on the schedule to be retried later
+func (db *DB) RetryGradePublication(ctx context.Context, assignmentID int64, nextAttemptAt time.Time, lastError string) error {
+	_, err := db.connPool.Exec(ctx, `
+	UPDATE grade_publications
+	SET published_at = NULL, publish_at = $1, last_error = $2, updated_at = (NOW() AT TIME ZONE 'UTC')
+	WHERE assignment_outline_id = $3`,
+		nextAttemptAt.UTC(), lastError, assignmentID)
+	if err != nil {
+		return errs.NewDBError(err)
+	}
+
+	return nil
+}
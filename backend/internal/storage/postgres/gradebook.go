@@ -0,0 +1,55 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/CamPlume1/khoury-classroom/internal/errs"
+	"github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// Lists the students that have a row in a classroom's gradebook, ordered by name
+func (db *DB) GetGradebookStudents(ctx context.Context, classroomID int64) ([]models.GradebookStudent, error) {
+	rows, err := db.connPool.Query(ctx, `
+	SELECT u.id AS user_id, u.first_name, u.last_name, u.github_username, u.github_user_id, cm.student_identifier
+	FROM classroom_membership cm
+	JOIN users u ON u.id = cm.user_id
+	WHERE cm.classroom_id = $1 AND cm.classroom_role = $2 AND cm.status != $3
+	ORDER BY u.last_name, u.first_name, u.github_username`, classroomID, models.Student, models.UserStatusRemoved)
+	if err != nil {
+		return nil, errs.NewDBError(err)
+	}
+	defer rows.Close()
+
+	return pgx.CollectRows(rows, pgx.RowToStructByName[models.GradebookStudent])
+}
+
+// Lists every contributor's scores on the assignments of a classroom, optionally only one assignment
+func (db *DB) GetGradebookScores(ctx context.Context, classroomID int64, assignmentID *int64) ([]models.GradebookScore, error) {
+	rows, err := db.connPool.Query(ctx, `
+	SELECT wc.user_id, sws.assignment_outline_id, sws.manual_feedback_score, sws.auto_grader_score, sws.grades_published_timestamp
+	FROM student_works_with_scores sws
+	JOIN work_contributors wc ON wc.student_work_id = sws.id
+	JOIN assignment_outlines ao ON ao.id = sws.assignment_outline_id
+	WHERE ao.classroom_id = $1 AND ($2::INTEGER IS NULL OR ao.id = $2)`, classroomID, assignmentID)
+	if err != nil {
+		return nil, errs.NewDBError(err)
+	}
+	defer rows.Close()
+
+	return pgx.CollectRows(rows, pgx.RowToStructByName[models.GradebookScore])
+}
+
+// Sets the institution's ID of a student in a classroom, nil clears it
+func (db *DB) SetStudentIdentifier(ctx context.Context, classroomID int64, userID int64, studentIdentifier *string) error {
+	result, err := db.connPool.Exec(ctx, `
+	UPDATE classroom_membership SET student_identifier = $1
+	WHERE classroom_id = $2 AND user_id = $3`, studentIdentifier, classroomID, userID)
+	if err != nil {
+		return errs.NewDBError(err)
+	}
+	if result.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/CamPlume1/khoury-classroom/internal/errs"
+	"github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+const gradingAssignmentFields = `
+	ga.student_work_id,
+	sw.assignment_outline_id,
+	sw.repo_name,
+	sw.work_state,
+	ga.ta_user_id,
+	u.github_username AS ta_gh_username,
+	ga.assigned_by,
+	ga.created_at,
+	ga.updated_at
+`
+
+const gradingAssignmentJoins = `
+	JOIN student_works sw ON sw.id = ga.student_work_id
+	JOIN users u ON u.id = ga.ta_user_id
+`
+
+// Makes each paired TA the grader of their student work, replacing any earlier grader, and moves works that
+// haven't been graded yet to GRADING_ASSIGNED. Either every pairing is assigned or none are.
+func (db *DB) AssignGraders(ctx context.Context, pairings []models.GraderPairing, assignedBy int64) ([]models.GradingAssignment, error) {
+	tx, err := db.connPool.Begin(ctx)
+	if err != nil {
+		return nil, errs.NewDBError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	assignments := make([]models.GradingAssignment, 0, len(pairings))
+	for _, pairing := range pairings {
+		_, err = tx.Exec(ctx, `
+		INSERT INTO grading_assignments (student_work_id, ta_user_id, assigned_by)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (student_work_id) DO UPDATE
+		SET ta_user_id = EXCLUDED.ta_user_id, assigned_by = EXCLUDED.assigned_by, updated_at = (NOW() AT TIME ZONE 'UTC')`,
+			pairing.StudentWorkID, pairing.TAUserID, assignedBy)
+		if err != nil {
+			return nil, errs.NewDBError(err)
+		}
+
+		_, err = tx.Exec(ctx, `
+		UPDATE student_works
+		SET work_state = $1
+		WHERE id = $2 AND work_state NOT IN ($3, $4)`,
+			models.WorkStateGradingAssigned, pairing.StudentWorkID, models.WorkStateGradingCompleted, models.WorkStateGradePublished)
+		if err != nil {
+			return nil, errs.NewDBError(err)
+		}
+
+		rows, err := tx.Query(ctx, fmt.Sprintf(`
+		SELECT %s FROM grading_assignments ga %s
+		WHERE ga.student_work_id = $1`, gradingAssignmentFields, gradingAssignmentJoins), pairing.StudentWorkID)
+		if err != nil {
+			return nil, errs.NewDBError(err)
+		}
+		assignment, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[models.GradingAssignment])
+		if err != nil {
+			return nil, errs.NewDBError(err)
+		}
+		assignments = append(assignments, assignment)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, errs.NewDBError(err)
+	}
+
+	return assignments, nil
+}
+
+// Lists who grades each work of an assignment, optionally only the works of one TA
+func (db *DB) ListGradingAssignments(ctx context.Context, assignmentID int64, taUserID *int64) ([]models.GradingAssignment, error) {
+	rows, err := db.connPool.Query(ctx, fmt.Sprintf(`
+	SELECT %s FROM grading_assignments ga %s
+	WHERE sw.assignment_outline_id = $1 AND ($2::INTEGER IS NULL OR ga.ta_user_id = $2)
+	ORDER BY sw.work_state IN ($3, $4), ga.created_at`, gradingAssignmentFields, gradingAssignmentJoins),
+		assignmentID, taUserID, models.WorkStateGradingCompleted, models.WorkStateGradePublished)
+	if err != nil {
+		return nil, errs.NewDBError(err)
+	}
+	defer rows.Close()
+
+	return pgx.CollectRows(rows, pgx.RowToStructByName[models.GradingAssignment])
+}
+
+// Counts the works each TA in a classroom has been assigned but not yet graded
+func (db *DB) GetGraderLoads(ctx context.Context, classroomID int64) (map[int64]int, error) {
+	rows, err := db.connPool.Query(ctx, `
+	SELECT ga.ta_user_id, COUNT(*)
+	FROM grading_assignments ga
+	JOIN student_works sw ON sw.id = ga.student_work_id
+	JOIN assignment_outlines ao ON ao.id = sw.assignment_outline_id
+	WHERE ao.classroom_id = $1 AND sw.work_state NOT IN ($2, $3)
+	GROUP BY ga.ta_user_id`,
+		classroomID, models.WorkStateGradingCompleted, models.WorkStateGradePublished)
+	if err != nil {
+		return nil, errs.NewDBError(err)
+	}
+	defer rows.Close()
+
+	loads := make(map[int64]int)
+	for rows.Next() {
+		var taUserID int64
+		var load int
+		if err := rows.Scan(&taUserID, &load); err != nil {
+			return nil, errs.NewDBError(err)
+		}
+		loads[taUserID] = load
+	}
+
+	return loads, rows.Err()
+}
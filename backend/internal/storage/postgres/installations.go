@@ -0,0 +1,71 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/CamPlume1/khoury-classroom/internal/errs"
+	"github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+const installationFields = `installation_id, account_id, account_login, account_type, repository_selection,
+	suspended_at, uninstalled_at, created_at, updated_at`
+
+// Creates or updates an installation of the app
+func (db *DB) UpsertAppInstallation(ctx context.Context, installation models.AppInstallation) (models.AppInstallation, error) {
+	rows, err := db.connPool.Query(ctx, fmt.Sprintf(`
+	INSERT INTO app_installations
+		(installation_id, account_id, account_login, account_type, repository_selection, suspended_at, uninstalled_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	ON CONFLICT (installation_id) DO UPDATE SET
+		account_id = EXCLUDED.account_id,
+		account_login = EXCLUDED.account_login,
+		account_type = EXCLUDED.account_type,
+		repository_selection = COALESCE(EXCLUDED.repository_selection, app_installations.repository_selection),
+		suspended_at = EXCLUDED.suspended_at,
+		uninstalled_at = EXCLUDED.uninstalled_at,
+		updated_at = (NOW() AT TIME ZONE 'UTC')
+	RETURNING %s`, installationFields),
+		installation.InstallationID, installation.AccountID, installation.AccountLogin, installation.AccountType,
+		installation.RepositorySelection, installation.SuspendedAt, installation.UninstalledAt)
+	if err != nil {
+		return models.AppInstallation{}, errs.NewDBError(err)
+	}
+	defer rows.Close()
+
+	return pgx.CollectOneRow(rows, pgx.RowToStructByName[models.AppInstallation])
+}
+
+// Records an installation of the app that isn't known yet, leaving known installations as webhooks last left them.
+// Returns pgx.ErrNoRows if the installation is already known
+func (db *DB) CreateAppInstallation(ctx context.Context, installation models.AppInstallation) (models.AppInstallation, error) {
+	rows, err := db.connPool.Query(ctx, fmt.Sprintf(`
+	INSERT INTO app_installations
+		(installation_id, account_id, account_login, account_type, repository_selection, suspended_at, uninstalled_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	ON CONFLICT (installation_id) DO NOTHING
+	RETURNING %s`, installationFields),
+		installation.InstallationID, installation.AccountID, installation.AccountLogin, installation.AccountType,
+		installation.RepositorySelection, installation.SuspendedAt, installation.UninstalledAt)
+	if err != nil {
+		return models.AppInstallation{}, errs.NewDBError(err)
+	}
+	defer rows.Close()
+
+	return pgx.CollectOneRow(rows, pgx.RowToStructByName[models.AppInstallation])
+}
+
+// Lists the installations of the app that haven't been uninstalled, including suspended ones
+func (db *DB) ListAppInstallations(ctx context.Context) ([]models.AppInstallation, error) {
+	rows, err := db.connPool.Query(ctx, fmt.Sprintf(`
+	SELECT %s FROM app_installations
+	WHERE uninstalled_at IS NULL
+	ORDER BY account_login`, installationFields))
+	if err != nil {
+		return nil, errs.NewDBError(err)
+	}
+	defer rows.Close()
+
+	return pgx.CollectRows(rows, pgx.RowToStructByName[models.AppInstallation])
+}
@@ -0,0 +1,155 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/CamPlume1/khoury-classroom/internal/errs"
+	"github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// Gets the late policy of an assignment. Returns pgx.ErrNoRows if it has none.
+func (db *DB) GetLatePolicy(ctx context.Context, assignmentID int64) (models.LatePolicy, error) {
+	rows, err := db.connPool.Query(ctx, `
+	SELECT assignment_outline_id, grace_period_minutes, penalty_percent_per_day, max_penalty_percent, hard_cutoff_hours, allow_late_tokens
+	FROM late_policies
+	WHERE assignment_outline_id = $1`, assignmentID)
+	if err != nil {
+		return models.LatePolicy{}, errs.NewDBError(err)
+	}
+	defer rows.Close()
+
+	return pgx.CollectOneRow(rows, pgx.RowToStructByName[models.LatePolicy])
+}
+
+func (db *DB) UpsertLatePolicy(ctx context.Context, policy models.LatePolicy) (models.LatePolicy, error) {
+	rows, err := db.connPool.Query(ctx, `
+	INSERT INTO late_policies (assignment_outline_id, grace_period_minutes, penalty_percent_per_day, max_penalty_percent, hard_cutoff_hours, allow_late_tokens)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	ON CONFLICT (assignment_outline_id) DO UPDATE
+	SET grace_period_minutes = EXCLUDED.grace_period_minutes,
+		penalty_percent_per_day = EXCLUDED.penalty_percent_per_day,
+		max_penalty_percent = EXCLUDED.max_penalty_percent,
+		hard_cutoff_hours = EXCLUDED.hard_cutoff_hours,
+		allow_late_tokens = EXCLUDED.allow_late_tokens,
+		updated_at = (NOW() AT TIME ZONE 'UTC')
+	RETURNING assignment_outline_id, grace_period_minutes, penalty_percent_per_day, max_penalty_percent, hard_cutoff_hours, allow_late_tokens`,
+		policy.AssignmentOutlineID, policy.GracePeriodMinutes, policy.PenaltyPercentPerDay, policy.MaxPenaltyPercent, policy.HardCutoffHours, policy.AllowLateTokens)
+	if err != nil {
+		return models.LatePolicy{}, errs.NewDBError(err)
+	}
+	defer rows.Close()
+
+	policy, err = pgx.CollectOneRow(rows, pgx.RowToStructByName[models.LatePolicy])
+	if err != nil {
+		return models.LatePolicy{}, errs.NewDBError(err)
+	}
+
+	return policy, nil
+}
+
+// Removes the late policy of an assignment, so it stops accepting late submissions
+func (db *DB) DeleteLatePolicy(ctx context.Context, assignmentID int64) error {
+	_, err := db.connPool.Exec(ctx, `DELETE FROM late_policies WHERE assignment_outline_id = $1`, assignmentID)
+	if err != nil {
+		return errs.NewDBError(err)
+	}
+
+	return nil
+}
+
+// Gets how many late tokens each student in a classroom can spend, none unless a budget was set
+func (db *DB) GetLateTokenBudget(ctx context.Context, classroomID int64) (models.LateTokenBudget, error) {
+	budget := models.LateTokenBudget{ClassroomID: classroomID}
+	err := db.connPool.QueryRow(ctx, `
+	SELECT COALESCE((SELECT tokens_per_student FROM late_token_budgets WHERE classroom_id = $1), 0)`,
+		classroomID).Scan(&budget.TokensPerStudent)
+	if err != nil {
+		return models.LateTokenBudget{}, errs.NewDBError(err)
+	}
+
+	return budget, nil
+}
+
+func (db *DB) SetLateTokenBudget(ctx context.Context, budget models.LateTokenBudget) error {
+	_, err := db.connPool.Exec(ctx, `
+	INSERT INTO late_token_budgets (classroom_id, tokens_per_student)
+	VALUES ($1, $2)
+	ON CONFLICT (classroom_id) DO UPDATE
+	SET tokens_per_student = EXCLUDED.tokens_per_student, updated_at = (NOW() AT TIME ZONE 'UTC')`,
+		budget.ClassroomID, budget.TokensPerStudent)
+	if err != nil {
+		return errs.NewDBError(err)
+	}
+
+	return nil
+}
+
+// Counts the late tokens a student has spent across the assignments of a classroom
+func (db *DB) GetLateTokensSpent(ctx context.Context, classroomID int64, userID int64) (int, error) {
+	var spent int
+	err := db.connPool.QueryRow(ctx, `
+	SELECT COALESCE(SUM(ltu.tokens), 0)
+	FROM late_token_uses ltu
+	JOIN student_works sw ON sw.id = ltu.student_work_id
+	JOIN assignment_outlines ao ON ao.id = sw.assignment_outline_id
+	WHERE ao.classroom_id = $1 AND ltu.user_id = $2`,
+		classroomID, userID).Scan(&spent)
+	if err != nil {
+		return 0, errs.NewDBError(err)
+	}
+
+	return spent, nil
+}
+
+// Counts the late tokens spent on a student work, which push its deadline back a day each
+func (db *DB) GetLateTokensUsedOnWork(ctx context.Context, studentWorkID int64) (int, error) {
+	var used int
+	err := db.connPool.QueryRow(ctx, `
+	SELECT COALESCE(SUM(tokens), 0) FROM late_token_uses WHERE student_work_id = $1`,
+		studentWorkID).Scan(&used)
+	if err != nil {
+		return 0, errs.NewDBError(err)
+	}
+
+	return used, nil
+}
+
+// Spends a student's late tokens on one of their works. Returns pgx.ErrNoRows if they don't have enough left.
+func (db *DB) SpendLateTokens(ctx context.Context, classroomID int64, studentWorkID int64, userID int64, tokens int) error {
+	tx, err := db.connPool.Begin(ctx)
+	if err != nil {
+		return errs.NewDBError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	// serialize spending within the classroom so concurrent requests can't overdraw a budget
+	_, err = tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext('late_tokens'), $1::INTEGER)`, classroomID)
+	if err != nil {
+		return errs.NewDBError(err)
+	}
+
+	tag, err := tx.Exec(ctx, `
+	INSERT INTO late_token_uses (student_work_id, user_id, tokens)
+	SELECT $1, $2, $3
+	WHERE COALESCE((SELECT tokens_per_student FROM late_token_budgets WHERE classroom_id = $4), 0) - (
+		SELECT COALESCE(SUM(ltu.tokens), 0)
+		FROM late_token_uses ltu
+		JOIN student_works sw ON sw.id = ltu.student_work_id
+		JOIN assignment_outlines ao ON ao.id = sw.assignment_outline_id
+		WHERE ao.classroom_id = $4 AND ltu.user_id = $2
+	) >= $3`,
+		studentWorkID, userID, tokens, classroomID)
+	if err != nil {
+		return errs.NewDBError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return errs.NewDBError(err)
+	}
+
+	return nil
+}
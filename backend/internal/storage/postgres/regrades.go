@@ -0,0 +1,126 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/CamPlume1/khoury-classroom/internal/errs"
+	"github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+const regradeFields = `
+	rr.id,
+	rr.feedback_comment_id,
+	fc.student_work_id,
+	rr.student_user_id,
+	u.github_username AS student_gh_username,
+	rr.regrade_state,
+	rr.student_comment,
+	rr.github_comment_id,
+	ri.point_value AS original_point_value,
+	ri.explanation,
+	rr.outcome,
+	rr.adjusted_point_value,
+	rr.ta_user_id,
+	rr.ta_comment,
+	rr.resolved_at,
+	rr.created_at
+`
+
+const regradeJoins = `
+	JOIN feedback_comment fc ON fc.id = rr.feedback_comment_id
+	JOIN rubric_items ri ON ri.id = fc.rubric_item_id
+	JOIN users u ON u.id = rr.student_user_id
+`
+
+// Opens a regrade request against a feedback comment.
+// Returns pgx.ErrNoRows if the feedback comment already has an open regrade request.
+func (db *DB) CreateRegradeRequest(ctx context.Context, feedbackCommentID int64, studentUserID int64, studentComment string, githubCommentID *int64) (models.RegradeRequest, error) {
+	rows, err := db.connPool.Query(ctx, fmt.Sprintf(`
+	WITH rr AS (
+		INSERT INTO regrade_requests (feedback_comment_id, student_user_id, regrade_state, student_comment, github_comment_id)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT DO NOTHING
+		RETURNING *
+	)
+	SELECT %s FROM rr %s`, regradeFields, regradeJoins),
+		feedbackCommentID, studentUserID, models.RegradeStateRequested, studentComment, githubCommentID)
+	if err != nil {
+		return models.RegradeRequest{}, errs.NewDBError(err)
+	}
+	defer rows.Close()
+
+	return pgx.CollectOneRow(rows, pgx.RowToStructByName[models.RegradeRequest])
+}
+
+// Links a regrade request to the GitHub comment mirroring it
+func (db *DB) SetRegradeGitHubCommentID(ctx context.Context, regradeID int64, githubCommentID int64) error {
+	_, err := db.connPool.Exec(ctx, `
+	UPDATE regrade_requests SET github_comment_id = $1 WHERE id = $2`, githubCommentID, regradeID)
+	if err != nil {
+		return errs.NewDBError(err)
+	}
+
+	return nil
+}
+
+func (db *DB) GetRegradeRequest(ctx context.Context, regradeID int64) (models.RegradeRequest, error) {
+	rows, err := db.connPool.Query(ctx, fmt.Sprintf(`
+	SELECT %s FROM regrade_requests rr %s
+	WHERE rr.id = $1`, regradeFields, regradeJoins), regradeID)
+	if err != nil {
+		return models.RegradeRequest{}, errs.NewDBError(err)
+	}
+	defer rows.Close()
+
+	return pgx.CollectOneRow(rows, pgx.RowToStructByName[models.RegradeRequest])
+}
+
+// Lists the regrade requests on the works of an assignment, optionally only those in a given state
+func (db *DB) ListRegradeRequests(ctx context.Context, assignmentID int64, state *models.RegradeState) ([]models.RegradeRequest, error) {
+	rows, err := db.connPool.Query(ctx, fmt.Sprintf(`
+	SELECT %s FROM regrade_requests rr %s
+	JOIN student_works sw ON sw.id = fc.student_work_id
+	WHERE sw.assignment_outline_id = $1 AND ($2::REGRADE_STATE IS NULL OR rr.regrade_state = $2)
+	ORDER BY rr.created_at`, regradeFields, regradeJoins), assignmentID, state)
+	if err != nil {
+		return nil, errs.NewDBError(err)
+	}
+	defer rows.Close()
+
+	return pgx.CollectRows(rows, pgx.RowToStructByName[models.RegradeRequest])
+}
+
+func (db *DB) ListRegradeRequestsOnWork(ctx context.Context, studentWorkID int64) ([]models.RegradeRequest, error) {
+	rows, err := db.connPool.Query(ctx, fmt.Sprintf(`
+	SELECT %s FROM regrade_requests rr %s
+	WHERE fc.student_work_id = $1
+	ORDER BY rr.created_at`, regradeFields, regradeJoins), studentWorkID)
+	if err != nil {
+		return nil, errs.NewDBError(err)
+	}
+	defer rows.Close()
+
+	return pgx.CollectRows(rows, pgx.RowToStructByName[models.RegradeRequest])
+}
+
+// Finalizes an open regrade request. Returns pgx.ErrNoRows if it has already been finalized.
+func (db *DB) ResolveRegradeRequest(ctx context.Context, regradeID int64, taUserID int64, outcome models.RegradeOutcome, adjustedPointValue *int, taComment *string) (models.RegradeRequest, error) {
+	rows, err := db.connPool.Query(ctx, fmt.Sprintf(`
+	WITH rr AS (
+		UPDATE regrade_requests
+		SET regrade_state = $1, outcome = $2, adjusted_point_value = $3, ta_user_id = $4, ta_comment = $5,
+			resolved_at = (NOW() AT TIME ZONE 'UTC')
+		WHERE id = $6 AND regrade_state = $7
+		RETURNING *
+	)
+	SELECT %s FROM rr %s`, regradeFields, regradeJoins),
+		models.RegradeStateFinalized, outcome, adjustedPointValue, taUserID, taComment, regradeID, models.RegradeStateRequested)
+	if err != nil {
+		return models.RegradeRequest{}, errs.NewDBError(err)
+	}
+	defer rows.Close()
+
+	return pgx.CollectOneRow(rows, pgx.RowToStructByName[models.RegradeRequest])
+}
@@ -0,0 +1,86 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/CamPlume1/khoury-classroom/internal/errs"
+	"github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+const repositoryAlertFields = `id, classroom_id, assignment_outline_id, student_work_id, repo_name, kind, actor_gh_username,
+	ruleset_id, ruleset_name, reapplied_at, resolved_at, resolved_by, created_at`
+
+// Raises an alert about a classroom repository
+func (db *DB) CreateRepositoryAlert(ctx context.Context, alert models.RepositoryAlert) (models.RepositoryAlert, error) {
+	rows, err := db.connPool.Query(ctx, fmt.Sprintf(`
+	INSERT INTO repository_alerts
+		(classroom_id, assignment_outline_id, student_work_id, repo_name, kind, actor_gh_username, ruleset_id, ruleset_name)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	RETURNING %s`, repositoryAlertFields),
+		alert.ClassroomID, alert.AssignmentOutlineID, alert.StudentWorkID, alert.RepoName, alert.Kind,
+		alert.ActorGHUsername, alert.RulesetID, alert.RulesetName)
+	if err != nil {
+		return models.RepositoryAlert{}, errs.NewDBError(err)
+	}
+	defer rows.Close()
+
+	return pgx.CollectOneRow(rows, pgx.RowToStructByName[models.RepositoryAlert])
+}
+
+// Gets a repository alert. Returns pgx.ErrNoRows if it doesn't exist.
+func (db *DB) GetRepositoryAlert(ctx context.Context, alertID int64) (models.RepositoryAlert, error) {
+	rows, err := db.connPool.Query(ctx, fmt.Sprintf(`
+	SELECT %s FROM repository_alerts WHERE id = $1`, repositoryAlertFields), alertID)
+	if err != nil {
+		return models.RepositoryAlert{}, errs.NewDBError(err)
+	}
+	defer rows.Close()
+
+	return pgx.CollectOneRow(rows, pgx.RowToStructByName[models.RepositoryAlert])
+}
+
+// Lists the alerts about a classroom's repositories, newest first
+func (db *DB) ListRepositoryAlerts(ctx context.Context, classroomID int64, includeResolved bool) ([]models.RepositoryAlert, error) {
+	rows, err := db.connPool.Query(ctx, fmt.Sprintf(`
+	SELECT %s FROM repository_alerts
+	WHERE classroom_id = $1 AND ($2 OR resolved_at IS NULL)
+	ORDER BY id DESC`, repositoryAlertFields), classroomID, includeResolved)
+	if err != nil {
+		return nil, errs.NewDBError(err)
+	}
+	defer rows.Close()
+
+	return pgx.CollectRows(rows, pgx.RowToStructByName[models.RepositoryAlert])
+}
+
+// Marks a repository alert as dealt with. Returns pgx.ErrNoRows if it doesn't exist or was already resolved.
+func (db *DB) ResolveRepositoryAlert(ctx context.Context, alertID int64, resolvedBy int64) (models.RepositoryAlert, error) {
+	rows, err := db.connPool.Query(ctx, fmt.Sprintf(`
+	UPDATE repository_alerts
+	SET resolved_at = (NOW() AT TIME ZONE 'UTC'), resolved_by = $2
+	WHERE id = $1 AND resolved_at IS NULL
+	RETURNING %s`, repositoryAlertFields), alertID, resolvedBy)
+	if err != nil {
+		return models.RepositoryAlert{}, errs.NewDBError(err)
+	}
+	defer rows.Close()
+
+	return pgx.CollectOneRow(rows, pgx.RowToStructByName[models.RepositoryAlert])
+}
+
+// Records that the ruleset an alert is about was put back
+func (db *DB) MarkRepositoryAlertReapplied(ctx context.Context, alertID int64) (models.RepositoryAlert, error) {
+	rows, err := db.connPool.Query(ctx, fmt.Sprintf(`
+	UPDATE repository_alerts
+	SET reapplied_at = (NOW() AT TIME ZONE 'UTC')
+	WHERE id = $1
+	RETURNING %s`, repositoryAlertFields), alertID)
+	if err != nil {
+		return models.RepositoryAlert{}, errs.NewDBError(err)
+	}
+	defer rows.Close()
+
+	return pgx.CollectOneRow(rows, pgx.RowToStructByName[models.RepositoryAlert])
+}
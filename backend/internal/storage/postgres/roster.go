@@ -0,0 +1,201 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/CamPlume1/khoury-classroom/internal/errs"
+	"github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// Finds the user with a GitHub account, creating them from a roster entry if they haven't signed in yet.
+// Names and emails missing from an existing user are filled in from the entry.
+func (db *DB) GetOrCreateRosterUser(ctx context.Context, entry models.RosterEntry, githubUserID int64) (models.User, error) {
+	tx, err := db.connPool.Begin(ctx)
+	if err != nil {
+		return models.User{}, errs.NewDBError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	var user models.User
+	err = tx.QueryRow(ctx, `
+	UPDATE users
+	SET first_name = COALESCE(NULLIF(first_name, ''), $1),
+		last_name = COALESCE(NULLIF(last_name, ''), $2),
+		email = COALESCE(email, $3)
+	WHERE github_user_id = $4
+	RETURNING id, first_name, last_name, github_username, github_user_id`,
+		entry.FirstName, entry.LastName, entry.Email, githubUserID).Scan(
+		&user.ID,
+		&user.FirstName,
+		&user.LastName,
+		&user.GithubUsername,
+		&user.GithubUserID,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		err = tx.QueryRow(ctx, `
+		INSERT INTO users (first_name, last_name, github_username, github_user_id, email)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, first_name, last_name, github_username, github_user_id`,
+			entry.FirstName, entry.LastName, entry.GithubUsername, githubUserID, entry.Email).Scan(
+			&user.ID,
+			&user.FirstName,
+			&user.LastName,
+			&user.GithubUsername,
+			&user.GithubUserID,
+		)
+	}
+	if err != nil {
+		return models.User{}, errs.NewDBError(err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return models.User{}, errs.NewDBError(err)
+	}
+	return user, nil
+}
+
+// Records the section and institution's ID a roster lists a classroom member with, leaving unlisted values as they were
+func (db *DB) SetRosterDetails(ctx context.Context, classroomID int64, userID int64, section *string, studentIdentifier *string) error {
+	_, err := db.connPool.Exec(ctx, `
+	UPDATE classroom_membership
+	SET section = COALESCE($1, section), student_identifier = COALESCE($2, student_identifier)
+	WHERE classroom_id = $3 AND user_id = $4`, section, studentIdentifier, classroomID, userID)
+	if err != nil {
+		return errs.NewDBError(err)
+	}
+	return nil
+}
+
+// Saves the report of a classroom's latest roster reconciliation, replacing the last one
+func (db *DB) SaveRosterReconciliation(ctx context.Context, report models.RosterReconciliation) error {
+	_, err := db.connPool.Exec(ctx, `
+	INSERT INTO roster_reconciliations (classroom_id, report, reconciled_at)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (classroom_id) DO UPDATE
+	SET report = EXCLUDED.report, reconciled_at = EXCLUDED.reconciled_at`,
+		report.ClassroomID, report, report.ReconciledAt)
+	if err != nil {
+		return errs.NewDBError(err)
+	}
+	return nil
+}
+
+// Returns the report of a classroom's latest roster reconciliation, pgx.ErrNoRows if it hasn't been reconciled
+func (db *DB) GetRosterReconciliation(ctx context.Context, classroomID int64) (models.RosterReconciliation, error) {
+	var report models.RosterReconciliation
+	err := db.connPool.QueryRow(ctx, `
+	SELECT report FROM roster_reconciliations WHERE classroom_id = $1`, classroomID).Scan(&report)
+	return report, err
+}
+
+// Lists the classrooms that haven't had their roster reconciled since the given time
+func (db *DB) GetClassroomsDueForReconciliation(ctx context.Context, reconciledBefore time.Time) ([]models.Classroom, error) {
+	rows, err := db.connPool.Query(ctx, `
+	SELECT c.id, c.name, c.org_id, c.org_name, c.created_at, c.student_team_name, c.suspended_at
+	FROM classrooms c
+	LEFT JOIN roster_reconciliations rr ON rr.classroom_id = c.id
+	WHERE (rr.reconciled_at IS NULL OR rr.reconciled_at < $1) AND c.suspended_at IS NULL
+	ORDER BY rr.reconciled_at NULLS FIRST`, reconciledBefore)
+	if err != nil {
+		return nil, errs.NewDBError(err)
+	}
+	defer rows.Close()
+
+	return pgx.CollectRows(rows, pgx.RowToStructByName[models.Classroom])
+}
+
+// Records an imported roster and what happened to each of its rows
+func (db *DB) CreateRosterImport(ctx context.Context, classroomID int64, results []models.RosterImportResult) (models.RosterImport, error) {
+	tx, err := db.connPool.Begin(ctx)
+	if err != nil {
+		return models.RosterImport{}, errs.NewDBError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	rosterImport := models.RosterImport{ClassroomID: classroomID}
+	err = tx.QueryRow(ctx, `
+	INSERT INTO roster_imports (classroom_id)
+	VALUES ($1)
+	RETURNING id, created_at`, classroomID).Scan(&rosterImport.ID, &rosterImport.CreatedAt)
+	if err != nil {
+		return models.RosterImport{}, errs.NewDBError(err)
+	}
+
+	for _, result := range results {
+		_, err = tx.Exec(ctx, `
+		INSERT INTO roster_import_rows (roster_import_id, row_number, github_username, status, message, user_id, classroom_role)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+			rosterImport.ID, result.Row, result.GithubUsername, result.Status, result.Message, result.UserID, result.Role)
+		if err != nil {
+			return models.RosterImport{}, errs.NewDBError(err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return models.RosterImport{}, errs.NewDBError(err)
+	}
+
+	rosterImport.Results = results
+	rosterImport.Summarize()
+	return rosterImport, nil
+}
+
+// Returns a classroom's roster import with its rows in roster order, pgx.ErrNoRows if it doesn't exist
+func (db *DB) GetRosterImport(ctx context.Context, classroomID int64, rosterImportID int64) (models.RosterImport, error) {
+	rosterImport := models.RosterImport{ID: rosterImportID, ClassroomID: classroomID}
+	err := db.connPool.QueryRow(ctx, `
+	SELECT created_at FROM roster_imports WHERE id = $1 AND classroom_id = $2`,
+		rosterImportID, classroomID).Scan(&rosterImport.CreatedAt)
+	if err != nil {
+		return models.RosterImport{}, err
+	}
+
+	rows, err := db.connPool.Query(ctx, `
+	SELECT row_number, github_username, status, message, user_id, classroom_role
+	FROM roster_import_rows
+	WHERE roster_import_id = $1
+	ORDER BY row_number`, rosterImportID)
+	if err != nil {
+		return models.RosterImport{}, errs.NewDBError(err)
+	}
+	defer rows.Close()
+
+	rosterImport.Results, err = pgx.CollectRows(rows, pgx.RowToStructByName[models.RosterImportResult])
+	if err != nil {
+		return models.RosterImport{}, errs.NewDBError(err)
+	}
+	rosterImport.Summarize()
+	return rosterImport, nil
+}
+
+// Lists the oldest org invitations from roster imports that are still waiting to be sent
+func (db *DB) ListPendingRosterInvitations(ctx context.Context, limit int) ([]models.PendingRosterInvitation, error) {
+	rows, err := db.connPool.Query(ctx, `
+	SELECT rir.roster_import_id, ri.classroom_id, rir.row_number, rir.user_id, rir.classroom_role
+	FROM roster_import_rows rir
+	JOIN roster_imports ri ON ri.id = rir.roster_import_id
+	WHERE rir.status = $1
+	ORDER BY rir.roster_import_id, rir.row_number
+	LIMIT $2`, models.RosterImportPendingInvite, limit)
+	if err != nil {
+		return nil, errs.NewDBError(err)
+	}
+	defer rows.Close()
+
+	return pgx.CollectRows(rows, pgx.RowToStructByName[models.PendingRosterInvitation])
+}
+
+// Records whether a roster import row's org invitation was sent
+func (db *DB) FinishRosterInvitation(ctx context.Context, rosterImportID int64, row int, status models.RosterImportStatus, message string) error {
+	_, err := db.connPool.Exec(ctx, `
+	UPDATE roster_import_rows
+	SET status = $1, message = $2
+	WHERE roster_import_id = $3 AND row_number = $4`, status, message, rosterImportID, row)
+	if err != nil {
+		return errs.NewDBError(err)
+	}
+	return nil
+}
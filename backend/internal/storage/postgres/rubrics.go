@@ -8,9 +8,11 @@ import (
 	"github.com/jackc/pgx/v5"
 )
 
+const rubricItemFields = `id, rubric_id, section_id, position, point_value, explanation, created_at, deleted, version, original_item_id, superseded_by`
+
 func (db *DB) CreateRubric(ctx context.Context, rubricData models.Rubric) (models.Rubric, error) {
 	err := db.connPool.QueryRow(ctx, `INSERT INTO rubrics (name, org_id, classroom_id, reusable) VALUES ($1, $2, $3, $4) 
-        RETURNING id, name, org_id, classroom_id, reusable, created_at`,
+        RETURNING id, name, org_id, classroom_id, reusable, created_at, version`,
 		rubricData.Name,
 		rubricData.OrgID,
 		rubricData.ClassroomID,
@@ -20,7 +22,8 @@ func (db *DB) CreateRubric(ctx context.Context, rubricData models.Rubric) (model
 		&rubricData.OrgID,
 		&rubricData.ClassroomID,
 		&rubricData.Reusable,
-		&rubricData.CreatedAt)
+		&rubricData.CreatedAt,
+		&rubricData.Version)
 	if err != nil {
 		return models.Rubric{}, errs.NewDBError(err)
 	}
@@ -29,16 +32,23 @@ func (db *DB) CreateRubric(ctx context.Context, rubricData models.Rubric) (model
 }
 
 func (db *DB) AddItemToRubric(ctx context.Context, rubricItemData models.RubricItem) (models.RubricItem, error) {
-	err := db.connPool.QueryRow(ctx, `INSERT INTO rubric_items (rubric_id, point_value, explanation) VALUES ($1, $2, $3) 
-        RETURNING id, rubric_id, point_value, explanation, created_at`,
+	// items added to a rubric start out in its current version
+	err := db.connPool.QueryRow(ctx, `INSERT INTO rubric_items (rubric_id, section_id, position, point_value, explanation, version)
+        VALUES ($1, $2, $3, $4, $5, COALESCE((SELECT version FROM rubrics WHERE id = $1), 1)) 
+        RETURNING id, rubric_id, section_id, position, point_value, explanation, created_at, version`,
 		rubricItemData.RubricID,
+		rubricItemData.SectionID,
+		rubricItemData.Position,
 		rubricItemData.PointValue,
 		rubricItemData.Explanation).Scan(
 		&rubricItemData.ID,
 		&rubricItemData.RubricID,
+		&rubricItemData.SectionID,
+		&rubricItemData.Position,
 		&rubricItemData.PointValue,
 		&rubricItemData.Explanation,
-		&rubricItemData.CreatedAt)
+		&rubricItemData.CreatedAt,
+		&rubricItemData.Version)
 
 	if err != nil {
 		return models.RubricItem{}, errs.NewDBError(err)
@@ -49,13 +59,15 @@ func (db *DB) AddItemToRubric(ctx context.Context, rubricItemData models.RubricI
 
 func (db *DB) GetRubric(ctx context.Context, rubricID int64) (models.Rubric, error) {
 	var rubric models.Rubric
-	err := db.connPool.QueryRow(ctx, "SELECT * FROM rubrics WHERE id = $1", rubricID).Scan(
+	err := db.connPool.QueryRow(ctx, "SELECT id, name, org_id, classroom_id, reusable, created_at, deleted_at, version FROM rubrics WHERE id = $1", rubricID).Scan(
 		&rubric.ID,
 		&rubric.Name,
 		&rubric.OrgID,
 		&rubric.ClassroomID,
 		&rubric.Reusable,
-		&rubric.CreatedAt)
+		&rubric.CreatedAt,
+		&rubric.DeletedAt,
+		&rubric.Version)
 	if err != nil {
 		return models.Rubric{}, errs.NewDBError(err)
 	}
@@ -64,7 +76,10 @@ func (db *DB) GetRubric(ctx context.Context, rubricID int64) (models.Rubric, err
 }
 
 func (db *DB) GetRubricItems(ctx context.Context, rubricID int64) ([]models.RubricItem, error) {
-	rows, err := db.connPool.Query(ctx, "SELECT * FROM rubric_items WHERE rubric_id = $1 AND deleted = FALSE", rubricID)
+	rows, err := db.connPool.Query(ctx, `
+	SELECT `+rubricItemFields+`
+	FROM rubric_items WHERE rubric_id = $1 AND deleted = FALSE AND superseded_by IS NULL
+	ORDER BY position, id`, rubricID)
 	if err != nil {
 		return nil, errs.NewDBError(err)
 	}
@@ -77,7 +92,7 @@ func (db *DB) UpdateRubric(ctx context.Context, rubricID int64, rubricData model
 	var updatedRubric models.Rubric
 	err := db.connPool.QueryRow(ctx, `UPDATE rubrics SET name = $1, org_id = $2, classroom_id = $3,
         reusable = $4, created_at = $5 WHERE id = $6 
-        RETURNING id, name, org_id, classroom_id, reusable, created_at`,
+        RETURNING id, name, org_id, classroom_id, reusable, created_at, version`,
 		rubricData.Name,
 		rubricData.OrgID,
 		rubricData.ClassroomID,
@@ -89,7 +104,8 @@ func (db *DB) UpdateRubric(ctx context.Context, rubricID int64, rubricData model
 		&updatedRubric.OrgID,
 		&updatedRubric.ClassroomID,
 		&updatedRubric.Reusable,
-		&updatedRubric.CreatedAt)
+		&updatedRubric.CreatedAt,
+		&updatedRubric.Version)
 	if err != nil {
 		return models.Rubric{}, errs.NewDBError(err)
 	}
@@ -98,21 +114,28 @@ func (db *DB) UpdateRubric(ctx context.Context, rubricID int64, rubricData model
 
 func (db *DB) UpdateRubricItem(ctx context.Context, rubricItemData models.RubricItem) (models.RubricItem, error) {
 	var updatedItem models.RubricItem
-	err := db.connPool.QueryRow(ctx, `UPDATE rubric_items SET rubric_id = $1, point_value = $2, explanation = $3, created_at = $4, deleted = $5
-        WHERE id = $6
-        RETURNING id, rubric_id, point_value, explanation, created_at, deleted`,
+	err := db.connPool.QueryRow(ctx, `UPDATE rubric_items SET rubric_id = $1, point_value = $2, explanation = $3, created_at = $4, deleted = $5,
+        section_id = $6, position = $7
+        WHERE id = $8 AND superseded_by IS NULL
+        RETURNING id, rubric_id, section_id, position, point_value, explanation, created_at, deleted, version, original_item_id`,
 		rubricItemData.RubricID,
 		rubricItemData.PointValue,
 		rubricItemData.Explanation,
 		rubricItemData.CreatedAt,
 		rubricItemData.Deleted,
+		rubricItemData.SectionID,
+		rubricItemData.Position,
 		rubricItemData.ID).Scan(
 		&updatedItem.ID,
 		&updatedItem.RubricID,
+		&updatedItem.SectionID,
+		&updatedItem.Position,
 		&updatedItem.PointValue,
 		&updatedItem.Explanation,
 		&updatedItem.CreatedAt,
-		&updatedItem.Deleted)
+		&updatedItem.Deleted,
+		&updatedItem.Version,
+		&updatedItem.OriginalItemID)
 	if err != nil {
 		return models.RubricItem{}, errs.NewDBError(err)
 	}
@@ -120,7 +143,7 @@ func (db *DB) UpdateRubricItem(ctx context.Context, rubricItemData models.Rubric
 }
 
 func (db *DB) GetRubricsInClassroom(ctx context.Context, classroomID int64) ([]models.Rubric, error) {
-	rows, err := db.connPool.Query(ctx, "SELECT * FROM rubrics WHERE classroom_id = $1", classroomID)
+	rows, err := db.connPool.Query(ctx, "SELECT * FROM rubrics WHERE classroom_id = $1 AND deleted_at IS NULL", classroomID)
 	if err != nil {
 		return nil, errs.NewDBError(err)
 	}
@@ -128,3 +151,374 @@ func (db *DB) GetRubricsInClassroom(ctx context.Context, classroomID int64) ([]m
 	defer rows.Close()
 	return pgx.CollectRows(rows, pgx.RowToStructByName[models.Rubric])
 }
+
+// Soft deletes a rubric, hiding it from its classroom while feedback given with its items keeps it around
+func (db *DB) DeleteRubric(ctx context.Context, rubricID int64) error {
+	_, err := db.connPool.Exec(ctx, `
+	UPDATE rubrics SET deleted_at = (NOW() AT TIME ZONE 'UTC')
+	WHERE id = $1 AND deleted_at IS NULL`, rubricID)
+	if err != nil {
+		return errs.NewDBError(err)
+	}
+	return nil
+}
+
+const rubricSectionFields = `id, rubric_id, name, position, max_points, min_points, created_at, deleted`
+
+// Lists the sections of a rubric in order
+func (db *DB) GetRubricSections(ctx context.Context, rubricID int64) ([]models.RubricSection, error) {
+	rows, err := db.connPool.Query(ctx, `
+	SELECT `+rubricSectionFields+` FROM rubric_sections
+	WHERE rubric_id = $1 AND deleted = FALSE
+	ORDER BY position, id`, rubricID)
+	if err != nil {
+		return nil, errs.NewDBError(err)
+	}
+	defer rows.Close()
+
+	return pgx.CollectRows(rows, pgx.RowToStructByName[models.RubricSection])
+}
+
+func (db *DB) CreateRubricSection(ctx context.Context, section models.RubricSection) (models.RubricSection, error) {
+	rows, err := db.connPool.Query(ctx, `
+	INSERT INTO rubric_sections (rubric_id, name, position, max_points, min_points)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING `+rubricSectionFields,
+		section.RubricID, section.Name, section.Position, section.MaxPoints, section.MinPoints)
+	if err != nil {
+		return models.RubricSection{}, errs.NewDBError(err)
+	}
+	defer rows.Close()
+
+	section, err = pgx.CollectOneRow(rows, pgx.RowToStructByName[models.RubricSection])
+	if err != nil {
+		return models.RubricSection{}, errs.NewDBError(err)
+	}
+	return section, nil
+}
+
+// Updates the name and caps of a rubric section. Returns pgx.ErrNoRows if the rubric has no such section.
+func (db *DB) UpdateRubricSection(ctx context.Context, section models.RubricSection) (models.RubricSection, error) {
+	rows, err := db.connPool.Query(ctx, `
+	UPDATE rubric_sections SET name = $1, max_points = $2, min_points = $3
+	WHERE id = $4 AND rubric_id = $5 AND deleted = FALSE
+	RETURNING `+rubricSectionFields,
+		section.Name, section.MaxPoints, section.MinPoints, section.ID, section.RubricID)
+	if err != nil {
+		return models.RubricSection{}, errs.NewDBError(err)
+	}
+	defer rows.Close()
+
+	return pgx.CollectOneRow(rows, pgx.RowToStructByName[models.RubricSection])
+}
+
+// Soft deletes a rubric section, moving its items out of it so they are no longer capped.
+// Returns pgx.ErrNoRows if the rubric has no such section.
+func (db *DB) DeleteRubricSection(ctx context.Context, rubricID int64, sectionID int64) error {
+	tx, err := db.connPool.Begin(ctx)
+	if err != nil {
+		return errs.NewDBError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	result, err := tx.Exec(ctx, `
+	UPDATE rubric_sections SET deleted = TRUE
+	WHERE id = $1 AND rubric_id = $2 AND deleted = FALSE`, sectionID, rubricID)
+	if err != nil {
+		return errs.NewDBError(err)
+	}
+	if result.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE rubric_items SET section_id = NULL WHERE section_id = $1`, sectionID)
+	if err != nil {
+		return errs.NewDBError(err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return errs.NewDBError(err)
+	}
+	return nil
+}
+
+// Orders a rubric's sections and items by their position in the request, moving items between sections.
+// Returns pgx.ErrNoRows if a section or item isn't part of the rubric.
+func (db *DB) ReorderRubric(ctx context.Context, rubricID int64, order models.RubricOrderRequest) error {
+	tx, err := db.connPool.Begin(ctx)
+	if err != nil {
+		return errs.NewDBError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	for position, sectionID := range order.SectionIDs {
+		result, err := tx.Exec(ctx, `
+		UPDATE rubric_sections SET position = $1
+		WHERE id = $2 AND rubric_id = $3 AND deleted = FALSE`, position, sectionID, rubricID)
+		if err != nil {
+			return errs.NewDBError(err)
+		}
+		if result.RowsAffected() == 0 {
+			return pgx.ErrNoRows
+		}
+	}
+
+	for position, item := range order.Items {
+		result, err := tx.Exec(ctx, `
+		UPDATE rubric_items ri SET position = $1, section_id = $2
+		WHERE ri.id = $3 AND ri.rubric_id = $4 AND ri.superseded_by IS NULL
+			AND ($2::INTEGER IS NULL OR EXISTS (
+				SELECT 1 FROM rubric_sections rs WHERE rs.id = $2 AND rs.rubric_id = $4 AND rs.deleted = FALSE
+			))`, position, item.SectionID, item.ID, rubricID)
+		if err != nil {
+			return errs.NewDBError(err)
+		}
+		if result.RowsAffected() == 0 {
+			return pgx.ErrNoRows
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return errs.NewDBError(err)
+	}
+	return nil
+}
+
+// Creates a rubric along with all of its sections and items, in the order they're given
+func (db *DB) CreateFullRubric(ctx context.Context, rubricData models.Rubric, rubric models.RubricExport) (models.FullRubric, error) {
+	tx, err := db.connPool.Begin(ctx)
+	if err != nil {
+		return models.FullRubric{}, errs.NewDBError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, `INSERT INTO rubrics (name, org_id, classroom_id, reusable) VALUES ($1, $2, $3, $4)
+	RETURNING id, created_at`,
+		rubricData.Name, rubricData.OrgID, rubricData.ClassroomID, rubricData.Reusable).Scan(&rubricData.ID, &rubricData.CreatedAt)
+	if err != nil {
+		return models.FullRubric{}, errs.NewDBError(err)
+	}
+
+	fullRubric := models.FullRubric{
+		Rubric:      rubricData,
+		Sections:    []models.RubricSection{},
+		RubricItems: []models.RubricItem{},
+	}
+
+	addItems := func(sectionID *int64, items []models.RubricItemExport) error {
+		for _, item := range items {
+			rows, err := tx.Query(ctx, `
+			INSERT INTO rubric_items (rubric_id, section_id, position, point_value, explanation)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING `+rubricItemFields,
+				rubricData.ID, sectionID, len(fullRubric.RubricItems), item.PointValue, item.Explanation)
+			if err != nil {
+				return err
+			}
+			createdItem, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[models.RubricItem])
+			if err != nil {
+				return err
+			}
+			fullRubric.RubricItems = append(fullRubric.RubricItems, createdItem)
+		}
+		return nil
+	}
+
+	for position, section := range rubric.Sections {
+		rows, err := tx.Query(ctx, `
+		INSERT INTO rubric_sections (rubric_id, name, position, max_points, min_points)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING `+rubricSectionFields,
+			rubricData.ID, section.Name, position, section.MaxPoints, section.MinPoints)
+		if err != nil {
+			return models.FullRubric{}, errs.NewDBError(err)
+		}
+		createdSection, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[models.RubricSection])
+		if err != nil {
+			return models.FullRubric{}, errs.NewDBError(err)
+		}
+		fullRubric.Sections = append(fullRubric.Sections, createdSection)
+
+		if err := addItems(&createdSection.ID, section.Items); err != nil {
+			return models.FullRubric{}, errs.NewDBError(err)
+		}
+	}
+	if err := addItems(nil, rubric.Items); err != nil {
+		return models.FullRubric{}, errs.NewDBError(err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return models.FullRubric{}, errs.NewDBError(err)
+	}
+	return fullRubric, nil
+}
+
+// Gets any version of a rubric item. Returns pgx.ErrNoRows if it doesn't exist.
+func (db *DB) GetRubricItem(ctx context.Context, itemID int64) (models.RubricItem, error) {
+	rows, err := db.connPool.Query(ctx, `SELECT `+rubricItemFields+` FROM rubric_items WHERE id = $1`, itemID)
+	if err != nil {
+		return models.RubricItem{}, errs.NewDBError(err)
+	}
+	defer rows.Close()
+
+	return pgx.CollectOneRow(rows, pgx.RowToStructByName[models.RubricItem])
+}
+
+// Lists the current items of a rubric that feedback has been given with
+func (db *DB) GetGradedRubricItemIDs(ctx context.Context, rubricID int64) ([]int64, error) {
+	rows, err := db.connPool.Query(ctx, `
+	SELECT ri.id FROM rubric_items ri
+	WHERE ri.rubric_id = $1 AND ri.superseded_by IS NULL
+		AND EXISTS (SELECT 1 FROM feedback_comment fc WHERE fc.rubric_item_id = ri.id)`, rubricID)
+	if err != nil {
+		return nil, errs.NewDBError(err)
+	}
+
+	itemIDs, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		return nil, errs.NewDBError(err)
+	}
+	return itemIDs, nil
+}
+
+// Creates the next version of a rubric, replacing each of the given items with a new version of it.
+// The replaced items are kept, with their feedback, as part of the versions before.
+func (db *DB) ReviseRubricItems(ctx context.Context, rubricID int64, createdBy int64, items []models.RubricItem) (int, []models.RubricItem, error) {
+	tx, err := db.connPool.Begin(ctx)
+	if err != nil {
+		return 0, nil, errs.NewDBError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	var version int
+	err = tx.QueryRow(ctx, `
+	UPDATE rubrics SET version = version + 1 WHERE id = $1
+	RETURNING version`, rubricID).Scan(&version)
+	if err != nil {
+		return 0, nil, errs.NewDBError(err)
+	}
+
+	_, err = tx.Exec(ctx, `
+	INSERT INTO rubric_versions (rubric_id, version, created_by) VALUES ($1, $2, $3)`, rubricID, version, createdBy)
+	if err != nil {
+		return 0, nil, errs.NewDBError(err)
+	}
+
+	var revisedItems []models.RubricItem
+	for _, item := range items {
+		rows, err := tx.Query(ctx, `
+		INSERT INTO rubric_items (rubric_id, section_id, position, point_value, explanation, version, original_item_id)
+		SELECT ri.rubric_id, $2, $3, $4, $5, $6, COALESCE(ri.original_item_id, ri.id)
+		FROM rubric_items ri
+		WHERE ri.id = $1 AND ri.rubric_id = $7 AND ri.superseded_by IS NULL
+		RETURNING `+rubricItemFields,
+			item.ID, item.SectionID, item.Position, item.PointValue, item.Explanation, version, rubricID)
+		if err != nil {
+			return 0, nil, errs.NewDBError(err)
+		}
+		revisedItem, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[models.RubricItem])
+		if err != nil {
+			return 0, nil, errs.NewDBError(err)
+		}
+
+		_, err = tx.Exec(ctx, `UPDATE rubric_items SET superseded_by = $1 WHERE id = $2`, revisedItem.ID, item.ID)
+		if err != nil {
+			return 0, nil, errs.NewDBError(err)
+		}
+		revisedItems = append(revisedItems, revisedItem)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, nil, errs.NewDBError(err)
+	}
+	return version, revisedItems, nil
+}
+
+// Lists the versions of a rubric after its first
+func (db *DB) GetRubricVersions(ctx context.Context, rubricID int64) ([]models.RubricVersion, error) {
+	rows, err := db.connPool.Query(ctx, `
+	SELECT rubric_id, version, created_by, created_at FROM rubric_versions
+	WHERE rubric_id = $1
+	ORDER BY version`, rubricID)
+	if err != nil {
+		return nil, errs.NewDBError(err)
+	}
+	defer rows.Close()
+
+	return pgx.CollectRows(rows, pgx.RowToStructByName[models.RubricVersion])
+}
+
+// Lists every version of a rubric item, oldest first
+func (db *DB) GetRubricItemHistory(ctx context.Context, itemID int64) ([]models.RubricItem, error) {
+	rows, err := db.connPool.Query(ctx, `
+	SELECT `+rubricItemFields+` FROM rubric_items
+	WHERE COALESCE(original_item_id, id) = (SELECT COALESCE(original_item_id, id) FROM rubric_items WHERE id = $1)
+	ORDER BY version, id`, itemID)
+	if err != nil {
+		return nil, errs.NewDBError(err)
+	}
+	defer rows.Close()
+
+	return pgx.CollectRows(rows, pgx.RowToStructByName[models.RubricItem])
+}
+
+// Moves the feedback given with earlier versions of a rubric item onto the given (current) version, so it's
+// scored with its points. Works whose grades were already published are listed but left as they were graded.
+// Returns how the score of each affected work changes, only committing the change when apply is set.
+func (db *DB) PropagateRubricItem(ctx context.Context, itemID int64, apply bool) ([]models.RubricPropagationWork, error) {
+	tx, err := db.connPool.Begin(ctx)
+	if err != nil {
+		return nil, errs.NewDBError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+	SELECT fc.student_work_id, sw.repo_name, COUNT(*)::INTEGER AS feedback_count,
+		sws.manual_feedback_score AS previous_score, sw.grades_published_timestamp IS NOT NULL AS grades_published
+	FROM feedback_comment fc
+	JOIN rubric_items ri ON ri.id = fc.rubric_item_id
+	JOIN student_works sw ON sw.id = fc.student_work_id
+	JOIN student_works_with_scores sws ON sws.id = fc.student_work_id
+	WHERE COALESCE(ri.original_item_id, ri.id) = (SELECT COALESCE(original_item_id, id) FROM rubric_items WHERE id = $1)
+		AND ri.id <> $1 AND fc.deleted_at IS NULL
+	GROUP BY fc.student_work_id, sw.repo_name, sws.manual_feedback_score, sw.grades_published_timestamp
+	ORDER BY sw.repo_name`, itemID)
+	if err != nil {
+		return nil, errs.NewDBError(err)
+	}
+	works, err := pgx.CollectRows(rows, pgx.RowToStructByNameLax[models.RubricPropagationWork])
+	if err != nil {
+		return nil, errs.NewDBError(err)
+	}
+	if len(works) == 0 {
+		return works, nil
+	}
+
+	_, err = tx.Exec(ctx, `
+	UPDATE feedback_comment fc SET rubric_item_id = $1
+	FROM rubric_items ri, student_works sw
+	WHERE ri.id = fc.rubric_item_id AND ri.id <> $1
+		AND sw.id = fc.student_work_id AND sw.grades_published_timestamp IS NULL AND fc.deleted_at IS NULL
+		AND COALESCE(ri.original_item_id, ri.id) = (SELECT COALESCE(original_item_id, id) FROM rubric_items WHERE id = $1)`, itemID)
+	if err != nil {
+		return nil, errs.NewDBError(err)
+	}
+
+	for i := range works {
+		err = tx.QueryRow(ctx, `SELECT manual_feedback_score FROM student_works_with_scores WHERE id = $1`,
+			works[i].StudentWorkID).Scan(&works[i].NewScore)
+		if err != nil {
+			return nil, errs.NewDBError(err)
+		}
+		if works[i].PreviousScore != nil && works[i].NewScore != nil {
+			works[i].ScoreDelta = *works[i].NewScore - *works[i].PreviousScore
+		}
+	}
+
+	if apply {
+		if err := tx.Commit(ctx); err != nil {
+			return nil, errs.NewDBError(err)
+		}
+	}
+	return works, nil
+}
@@ -0,0 +1,207 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/CamPlume1/khoury-classroom/internal/errs"
+	"github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/CamPlume1/khoury-classroom/internal/storage"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// running jobs that haven't had a heartbeat for this long are assumed to belong to a crashed replica.
+// The scheduler sends one well within it for as long as a job runs.
+const staleScheduledJobTimeout = 2 * time.Minute
+
+const scheduledJobFields = `id, job_type, payload, recurring, state, attempts, max_attempts, last_error,
+	run_at, started_at, finished_at, created_at, updated_at`
+
+func collectScheduledJob(rows pgx.Rows) (models.ScheduledJob, error) {
+	defer rows.Close()
+	return pgx.CollectOneRow(rows, pgx.RowToStructByName[models.ScheduledJob])
+}
+
+// Schedules a job to run at its run_at time.
+// Returns pgx.ErrNoRows if the job is recurring and a run of it is already scheduled.
+func (db *DB) EnqueueScheduledJob(ctx context.Context, job models.ScheduledJob) (models.ScheduledJob, error) {
+	rows, err := db.connPool.Query(ctx, `
+	INSERT INTO scheduled_jobs (job_type, payload, recurring, max_attempts, run_at)
+	VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT DO NOTHING
+	RETURNING `+scheduledJobFields,
+		job.JobType, job.Payload, job.Recurring, job.MaxAttempts, job.RunAt.UTC())
+	if err != nil {
+		return models.ScheduledJob{}, errs.NewDBError(err)
+	}
+
+	return collectScheduledJob(rows)
+}
+
+// Returns pgx.ErrNoRows if the job doesn't exist
+func (db *DB) GetScheduledJob(ctx context.Context, jobID int64) (models.ScheduledJob, error) {
+	rows, err := db.connPool.Query(ctx, `
+	SELECT `+scheduledJobFields+` FROM scheduled_jobs WHERE id = $1`, jobID)
+	if err != nil {
+		return models.ScheduledJob{}, errs.NewDBError(err)
+	}
+
+	return collectScheduledJob(rows)
+}
+
+// Lists the latest scheduled jobs, optionally only those of a given type or in a given state
+func (db *DB) ListScheduledJobs(ctx context.Context, jobType *string, state *models.ScheduledJobState, limit int) ([]models.ScheduledJob, error) {
+	rows, err := db.connPool.Query(ctx, `
+	SELECT `+scheduledJobFields+` FROM scheduled_jobs
+	WHERE ($1::VARCHAR IS NULL OR job_type = $1) AND ($2::SCHEDULED_JOB_STATE IS NULL OR state = $2)
+	ORDER BY run_at DESC, id DESC
+	LIMIT $3`, jobType, state, limit)
+	if err != nil {
+		return nil, errs.NewDBError(err)
+	}
+	defer rows.Close()
+
+	return pgx.CollectRows(rows, pgx.RowToStructByName[models.ScheduledJob])
+}
+
+// Claims the next job of one of the given types to run (pending and due, or abandoned by a crashed replica),
+// earliest first. Returns pgx.ErrNoRows if there is nothing to do.
+func (db *DB) ClaimNextScheduledJob(ctx context.Context, jobTypes []string) (models.ScheduledJob, error) {
+	rows, err := db.connPool.Query(ctx, `
+	UPDATE scheduled_jobs
+	SET state = $1, attempts = attempts + 1, started_at = (NOW() AT TIME ZONE 'UTC'), updated_at = (NOW() AT TIME ZONE 'UTC')
+	WHERE id = (
+		SELECT id FROM scheduled_jobs
+		WHERE job_type = ANY($2)
+			AND ((state = $3 AND run_at <= (NOW() AT TIME ZONE 'UTC'))
+				OR (state = $1 AND updated_at < (NOW() AT TIME ZONE 'UTC') - $4::INTERVAL))
+		ORDER BY run_at
+		FOR UPDATE SKIP LOCKED
+		LIMIT 1
+	)
+	RETURNING `+scheduledJobFields,
+		models.ScheduledJobStateRunning, jobTypes, models.ScheduledJobStatePending, staleScheduledJobTimeout)
+	if err != nil {
+		return models.ScheduledJob{}, errs.NewDBError(err)
+	}
+
+	return collectScheduledJob(rows)
+}
+
+// Puts the running jobs that stopped sending heartbeats back on the schedule to run immediately, for a new leader
+// to take over the jobs the previous one was running when it crashed. Jobs a previous leader is still running are
+// left to it. Returns how many jobs were reset.
+func (db *DB) ResetStaleScheduledJobs(ctx context.Context) (int64, error) {
+	tag, err := db.connPool.Exec(ctx, `
+	UPDATE scheduled_jobs
+	SET state = $1, run_at = (NOW() AT TIME ZONE 'UTC'), updated_at = (NOW() AT TIME ZONE 'UTC')
+	WHERE state = $2 AND updated_at < (NOW() AT TIME ZONE 'UTC') - $3::INTERVAL`,
+		models.ScheduledJobStatePending, models.ScheduledJobStateRunning, staleScheduledJobTimeout)
+	if err != nil {
+		return 0, errs.NewDBError(err)
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// Records that a running job is still running, so it isn't taken for abandoned
+func (db *DB) HeartbeatScheduledJob(ctx context.Context, jobID int64) error {
+	_, err := db.connPool.Exec(ctx, `
+	UPDATE scheduled_jobs SET updated_at = (NOW() AT TIME ZONE 'UTC')
+	WHERE id = $1 AND state = $2`,
+		jobID, models.ScheduledJobStateRunning)
+	if err != nil {
+		return errs.NewDBError(err)
+	}
+
+	return nil
+}
+
+// Puts a claimed job back on the schedule to be retried later
+func (db *DB) RetryScheduledJob(ctx context.Context, jobID int64, runAt time.Time, lastError *string) error {
+	_, err := db.connPool.Exec(ctx, `
+	UPDATE scheduled_jobs
+	SET state = $1, run_at = $2, last_error = $3, updated_at = (NOW() AT TIME ZONE 'UTC')
+	WHERE id = $4`,
+		models.ScheduledJobStatePending, runAt.UTC(), lastError, jobID)
+	if err != nil {
+		return errs.NewDBError(err)
+	}
+
+	return nil
+}
+
+// Marks a job as finished, either succeeded or failed for good
+func (db *DB) FinishScheduledJob(ctx context.Context, jobID int64, state models.ScheduledJobState, lastError *string) error {
+	_, err := db.connPool.Exec(ctx, `
+	UPDATE scheduled_jobs
+	SET state = $1, last_error = $2, finished_at = (NOW() AT TIME ZONE 'UTC'), updated_at = (NOW() AT TIME ZONE 'UTC')
+	WHERE id = $3`,
+		state, lastError, jobID)
+	if err != nil {
+		return errs.NewDBError(err)
+	}
+
+	return nil
+}
+
+// Puts a failed job back on the schedule to run immediately with a fresh set of attempts.
+// Returns pgx.ErrNoRows if the job hasn't failed.
+func (db *DB) RequeueScheduledJob(ctx context.Context, jobID int64) (models.ScheduledJob, error) {
+	rows, err := db.connPool.Query(ctx, `
+	UPDATE scheduled_jobs
+	SET state = $1, attempts = 0, run_at = (NOW() AT TIME ZONE 'UTC'), finished_at = NULL, updated_at = (NOW() AT TIME ZONE 'UTC')
+	WHERE id = $2 AND state = $3
+	RETURNING `+scheduledJobFields,
+		models.ScheduledJobStatePending, jobID, models.ScheduledJobStateFailed)
+	if err != nil {
+		return models.ScheduledJob{}, errs.NewDBError(err)
+	}
+
+	return collectScheduledJob(rows)
+}
+
+// A session-level advisory lock, held on its own connection so it outlives any one query
+type advisoryLock struct {
+	conn *pgxpool.Conn
+	key  int64
+}
+
+// Takes the advisory lock with the given key if nobody else holds it.
+// Returns pgx.ErrNoRows if it is held elsewhere, e.g. by another replica.
+func (db *DB) TryAdvisoryLock(ctx context.Context, key int64) (storage.Lock, error) {
+	conn, err := db.pool.Acquire(ctx)
+	if err != nil {
+		return nil, errs.NewDBError(err)
+	}
+
+	var acquired bool
+	err = conn.QueryRow(ctx, `SELECT pg_try_advisory_lock($1)`, key).Scan(&acquired)
+	if err != nil {
+		conn.Release()
+		return nil, errs.NewDBError(err)
+	}
+	if !acquired {
+		conn.Release()
+		return nil, pgx.ErrNoRows
+	}
+
+	return &advisoryLock{conn: conn, key: key}, nil
+}
+
+// Postgres drops the lock along with the connection, so the lock is only still held if the connection is
+func (l *advisoryLock) Check(ctx context.Context) error {
+	if err := l.conn.Ping(ctx); err != nil {
+		return errs.NewDBError(err)
+	}
+	return nil
+}
+
+func (l *advisoryLock) Release(ctx context.Context) {
+	// If unlocking fails the connection is broken, and closing it is what lets the lock go
+	if _, err := l.conn.Exec(ctx, `SELECT pg_advisory_unlock($1)`, l.key); err != nil {
+		l.conn.Conn().Close(ctx)
+	}
+	l.conn.Release()
+}
@@ -0,0 +1,47 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/CamPlume1/khoury-classroom/internal/errs"
+	"github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+const submissionAttemptFields = `sa.id, sa.student_work_id, sa.pull_number, sa.head_sha, sa.action, sa.opened_by,
+	u.github_username AS opened_by_gh_login, sa.created_at`
+
+// Records a pull request opened or updated into the submission branch of a student work
+func (db *DB) CreateSubmissionAttempt(ctx context.Context, attempt models.SubmissionAttempt) (models.SubmissionAttempt, error) {
+	rows, err := db.connPool.Query(ctx, fmt.Sprintf(`
+	WITH sa AS (
+		INSERT INTO submission_attempts (student_work_id, pull_number, head_sha, action, opened_by)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING *
+	)
+	SELECT %s FROM sa
+	LEFT JOIN users u ON u.id = sa.opened_by`, submissionAttemptFields),
+		attempt.StudentWorkID, attempt.PullNumber, attempt.HeadSHA, attempt.Action, attempt.OpenedBy)
+	if err != nil {
+		return models.SubmissionAttempt{}, errs.NewDBError(err)
+	}
+	defer rows.Close()
+
+	return pgx.CollectOneRow(rows, pgx.RowToStructByName[models.SubmissionAttempt])
+}
+
+// Lists every submission attempt on a student work, oldest first
+func (db *DB) ListSubmissionAttempts(ctx context.Context, studentWorkID int64) ([]models.SubmissionAttempt, error) {
+	rows, err := db.connPool.Query(ctx, fmt.Sprintf(`
+	SELECT %s FROM submission_attempts sa
+	LEFT JOIN users u ON u.id = sa.opened_by
+	WHERE sa.student_work_id = $1
+	ORDER BY sa.id`, submissionAttemptFields), studentWorkID)
+	if err != nil {
+		return nil, errs.NewDBError(err)
+	}
+	defer rows.Close()
+
+	return pgx.CollectRows(rows, pgx.RowToStructByName[models.SubmissionAttempt])
+}
@@ -0,0 +1,91 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/CamPlume1/khoury-classroom/internal/errs"
+	"github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+const snapshotFields = `id, student_work_id, commit_sha, tag_name, reason, created_by, created_at`
+
+// Pins a commit of a student work for grading, replacing its current snapshot
+func (db *DB) CreateSubmissionSnapshot(ctx context.Context, snapshot models.SubmissionSnapshot) (models.SubmissionSnapshot, error) {
+	rows, err := db.connPool.Query(ctx, fmt.Sprintf(`
+	INSERT INTO submission_snapshots (student_work_id, commit_sha, tag_name, reason, created_by)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING %s`, snapshotFields),
+		snapshot.StudentWorkID, snapshot.CommitSHA, snapshot.TagName, snapshot.Reason, snapshot.CreatedBy)
+	if err != nil {
+		return models.SubmissionSnapshot{}, errs.NewDBError(err)
+	}
+	defer rows.Close()
+
+	return pgx.CollectOneRow(rows, pgx.RowToStructByName[models.SubmissionSnapshot])
+}
+
+// Gets the snapshot a student work is graded against. Returns pgx.ErrNoRows if it has none.
+func (db *DB) GetSubmissionSnapshot(ctx context.Context, studentWorkID int64) (models.SubmissionSnapshot, error) {
+	rows, err := db.connPool.Query(ctx, fmt.Sprintf(`
+	SELECT %s FROM submission_snapshots
+	WHERE student_work_id = $1
+	ORDER BY id DESC
+	LIMIT 1`, snapshotFields), studentWorkID)
+	if err != nil {
+		return models.SubmissionSnapshot{}, errs.NewDBError(err)
+	}
+	defer rows.Close()
+
+	return pgx.CollectOneRow(rows, pgx.RowToStructByName[models.SubmissionSnapshot])
+}
+
+// Lists every snapshot of a student work, oldest first
+func (db *DB) ListSubmissionSnapshots(ctx context.Context, studentWorkID int64) ([]models.SubmissionSnapshot, error) {
+	rows, err := db.connPool.Query(ctx, fmt.Sprintf(`
+	SELECT %s FROM submission_snapshots
+	WHERE student_work_id = $1
+	ORDER BY id`, snapshotFields), studentWorkID)
+	if err != nil {
+		return nil, errs.NewDBError(err)
+	}
+	defer rows.Close()
+
+	return pgx.CollectRows(rows, pgx.RowToStructByName[models.SubmissionSnapshot])
+}
+
+// Gets up to limit works after the given ID whose deadline has passed without them being snapshotted
+func (db *DB) GetWorksDueForSnapshot(ctx context.Context, afterID int, limit int) ([]models.StudentWork, error) {
+	rows, err := db.connPool.Query(ctx, fmt.Sprintf(`
+	SELECT %s FROM %s
+	WHERE sw.id IN (
+		SELECT swl.student_work_id FROM student_work_lateness swl
+		WHERE swl.deadline <= (NOW() AT TIME ZONE 'UTC')
+			AND swl.student_work_id > $1
+			AND NOT EXISTS (SELECT 1 FROM submission_snapshots ss WHERE ss.student_work_id = swl.student_work_id)
+		ORDER BY swl.student_work_id
+		LIMIT $2
+	)
+	ORDER BY sw.id`, DesiredFields, JoinedTable), afterID, limit)
+	if err != nil {
+		return nil, errs.NewDBError(err)
+	}
+	defer rows.Close()
+
+	rawWorks, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.RawStudentWork])
+	if err != nil {
+		return nil, errs.NewDBError(err)
+	}
+
+	// works with several contributors appear once per contributor
+	works := []models.StudentWork{}
+	for _, rawWork := range rawWorks {
+		if len(works) > 0 && works[len(works)-1].ID == rawWork.ID {
+			continue
+		}
+		works = append(works, rawWork.StudentWork)
+	}
+
+	return works, nil
+}
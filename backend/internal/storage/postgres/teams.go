@@ -0,0 +1,318 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/CamPlume1/khoury-classroom/internal/errs"
+	"github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+const teamFields = `t.id, t.assignment_outline_id, t.name, t.join_token, t.created_by, t.created_at`
+
+const teamMemberFields = `tm.team_id, tm.user_id, u.first_name, u.last_name, u.github_username, tm.joined_at`
+
+// Returns the team sizes of a group assignment, pgx.ErrNoRows if they haven't been configured
+func (db *DB) GetGroupSettings(ctx context.Context, assignmentID int64) (models.GroupSettings, error) {
+	rows, err := db.connPool.Query(ctx, `
+	SELECT assignment_outline_id, min_team_size, max_team_size, updated_at
+	FROM group_settings WHERE assignment_outline_id = $1`, assignmentID)
+	if err != nil {
+		return models.GroupSettings{}, errs.NewDBError(err)
+	}
+	defer rows.Close()
+
+	return pgx.CollectOneRow(rows, pgx.RowToStructByName[models.GroupSettings])
+}
+
+func (db *DB) UpsertGroupSettings(ctx context.Context, settings models.GroupSettings) (models.GroupSettings, error) {
+	rows, err := db.connPool.Query(ctx, `
+	INSERT INTO group_settings (assignment_outline_id, min_team_size, max_team_size)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (assignment_outline_id) DO UPDATE
+	SET min_team_size = EXCLUDED.min_team_size, max_team_size = EXCLUDED.max_team_size,
+		updated_at = (NOW() AT TIME ZONE 'UTC')
+	RETURNING assignment_outline_id, min_team_size, max_team_size, updated_at`,
+		settings.AssignmentOutlineID, settings.MinTeamSize, settings.MaxTeamSize)
+	if err != nil {
+		return models.GroupSettings{}, errs.NewDBError(err)
+	}
+	defer rows.Close()
+
+	settings, err = pgx.CollectOneRow(rows, pgx.RowToStructByName[models.GroupSettings])
+	if err != nil {
+		return models.GroupSettings{}, errs.NewDBError(err)
+	}
+	return settings, nil
+}
+
+func (db *DB) CreateTeam(ctx context.Context, assignmentID int64, name string, joinToken string, createdBy int64) (models.Team, error) {
+	rows, err := db.connPool.Query(ctx, `
+	INSERT INTO teams (assignment_outline_id, name, join_token, created_by)
+	VALUES ($1, $2, $3, $4)
+	RETURNING id, assignment_outline_id, name, join_token, created_by, created_at`,
+		assignmentID, name, joinToken, createdBy)
+	if err != nil {
+		return models.Team{}, errs.NewDBError(err)
+	}
+	defer rows.Close()
+
+	team, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[models.Team])
+	if err != nil {
+		return models.Team{}, errs.NewDBError(err)
+	}
+	team.Members = []models.TeamMember{}
+	return team, nil
+}
+
+// Creates a team with its creator as its first member, so a team is never left without the student who made it.
+// Returns pgx.ErrNoRows if the creator is already on a team for the assignment.
+func (db *DB) CreateTeamWithMember(ctx context.Context, assignmentID int64, name string, joinToken string, userID int64) (models.Team, error) {
+	tx, err := db.connPool.Begin(ctx)
+	if err != nil {
+		return models.Team{}, errs.NewDBError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+	INSERT INTO teams (assignment_outline_id, name, join_token, created_by)
+	VALUES ($1, $2, $3, $4)
+	RETURNING id, assignment_outline_id, name, join_token, created_by, created_at`,
+		assignmentID, name, joinToken, userID)
+	if err != nil {
+		return models.Team{}, errs.NewDBError(err)
+	}
+	team, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[models.Team])
+	if err != nil {
+		return models.Team{}, errs.NewDBError(err)
+	}
+
+	tag, err := tx.Exec(ctx, `
+	INSERT INTO team_members (team_id, assignment_outline_id, user_id)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (assignment_outline_id, user_id) DO NOTHING`, team.ID, assignmentID, userID)
+	if err != nil {
+		return models.Team{}, errs.NewDBError(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return models.Team{}, pgx.ErrNoRows
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return models.Team{}, errs.NewDBError(err)
+	}
+	return db.GetTeam(ctx, team.ID)
+}
+
+// Gets a team and its members. Returns pgx.ErrNoRows if it doesn't exist.
+func (db *DB) GetTeam(ctx context.Context, teamID int64) (models.Team, error) {
+	rows, err := db.connPool.Query(ctx, `
+	SELECT `+teamFields+` FROM teams t WHERE t.id = $1`, teamID)
+	if err != nil {
+		return models.Team{}, errs.NewDBError(err)
+	}
+	return db.collectTeam(ctx, rows)
+}
+
+// Gets the team a join token belongs to. Returns pgx.ErrNoRows if no team has it.
+func (db *DB) GetTeamByToken(ctx context.Context, joinToken string) (models.Team, error) {
+	rows, err := db.connPool.Query(ctx, `
+	SELECT `+teamFields+` FROM teams t WHERE t.join_token = $1`, joinToken)
+	if err != nil {
+		return models.Team{}, errs.NewDBError(err)
+	}
+	return db.collectTeam(ctx, rows)
+}
+
+// Gets the team a student is on for an assignment. Returns pgx.ErrNoRows if they aren't on one.
+func (db *DB) GetTeamOfUser(ctx context.Context, assignmentID int64, userID int64) (models.Team, error) {
+	rows, err := db.connPool.Query(ctx, `
+	SELECT `+teamFields+` FROM teams t
+	JOIN team_members tm ON tm.team_id = t.id
+	WHERE tm.assignment_outline_id = $1 AND tm.user_id = $2`, assignmentID, userID)
+	if err != nil {
+		return models.Team{}, errs.NewDBError(err)
+	}
+	return db.collectTeam(ctx, rows)
+}
+
+func (db *DB) collectTeam(ctx context.Context, rows pgx.Rows) (models.Team, error) {
+	team, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[models.Team])
+	if err != nil {
+		return models.Team{}, err
+	}
+
+	team.Members, err = db.ListTeamMembers(ctx, team.ID)
+	if err != nil {
+		return models.Team{}, err
+	}
+	return team, nil
+}
+
+// Lists the teams of an assignment with their members
+func (db *DB) ListTeams(ctx context.Context, assignmentID int64) ([]models.Team, error) {
+	rows, err := db.connPool.Query(ctx, `
+	SELECT `+teamFields+` FROM teams t
+	WHERE t.assignment_outline_id = $1
+	ORDER BY t.name`, assignmentID)
+	if err != nil {
+		return nil, errs.NewDBError(err)
+	}
+	teams, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.Team])
+	if err != nil {
+		return nil, errs.NewDBError(err)
+	}
+
+	rows, err = db.connPool.Query(ctx, `
+	SELECT `+teamMemberFields+` FROM team_members tm
+	JOIN users u ON u.id = tm.user_id
+	WHERE tm.assignment_outline_id = $1
+	ORDER BY tm.joined_at`, assignmentID)
+	if err != nil {
+		return nil, errs.NewDBError(err)
+	}
+	members, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.TeamMember])
+	if err != nil {
+		return nil, errs.NewDBError(err)
+	}
+
+	membersByTeam := make(map[int64][]models.TeamMember)
+	for _, member := range members {
+		membersByTeam[member.TeamID] = append(membersByTeam[member.TeamID], member)
+	}
+	for i := range teams {
+		teams[i].Members = membersByTeam[teams[i].ID]
+		if teams[i].Members == nil {
+			teams[i].Members = []models.TeamMember{}
+		}
+	}
+	return teams, nil
+}
+
+func (db *DB) ListTeamMembers(ctx context.Context, teamID int64) ([]models.TeamMember, error) {
+	rows, err := db.connPool.Query(ctx, `
+	SELECT `+teamMemberFields+` FROM team_members tm
+	JOIN users u ON u.id = tm.user_id
+	WHERE tm.team_id = $1
+	ORDER BY tm.joined_at`, teamID)
+	if err != nil {
+		return nil, errs.NewDBError(err)
+	}
+	defer rows.Close()
+
+	return pgx.CollectRows(rows, pgx.RowToStructByName[models.TeamMember])
+}
+
+// Adds a student to a team, so long as it has fewer than maxSize members (no limit if maxSize isn't positive).
+// Returns pgx.ErrNoRows if the team is full.
+func (db *DB) AddTeamMember(ctx context.Context, teamID int64, userID int64, maxSize int) error {
+	tx, err := db.connPool.Begin(ctx)
+	if err != nil {
+		return errs.NewDBError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	// lock the team so concurrent joins can't overfill it
+	var assignmentID int64
+	var size int
+	err = tx.QueryRow(ctx, `
+	SELECT t.assignment_outline_id, (SELECT COUNT(*) FROM team_members tm WHERE tm.team_id = t.id)
+	FROM teams t WHERE t.id = $1
+	FOR UPDATE`, teamID).Scan(&assignmentID, &size)
+	if err != nil {
+		return errs.NewDBError(err)
+	}
+	if maxSize > 0 && size >= maxSize {
+		return pgx.ErrNoRows
+	}
+
+	_, err = tx.Exec(ctx, `
+	INSERT INTO team_members (team_id, assignment_outline_id, user_id)
+	VALUES ($1, $2, $3)`, teamID, assignmentID, userID)
+	if err != nil {
+		return errs.NewDBError(err)
+	}
+
+	// teammates joining after the fork was made share it too
+	_, err = tx.Exec(ctx, `
+	INSERT INTO work_contributors (user_id, student_work_id)
+	SELECT $1, sw.id FROM student_works sw WHERE sw.team_id = $2
+	ON CONFLICT DO NOTHING`, userID, teamID)
+	if err != nil {
+		return errs.NewDBError(err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return errs.NewDBError(err)
+	}
+	return nil
+}
+
+// Removes a student from a team, deleting the team once its last member leaves if it was never forked.
+// Returns pgx.ErrNoRows if they weren't on the team.
+func (db *DB) RemoveTeamMember(ctx context.Context, teamID int64, userID int64) error {
+	tx, err := db.connPool.Begin(ctx)
+	if err != nil {
+		return errs.NewDBError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	result, err := tx.Exec(ctx, `DELETE FROM team_members WHERE team_id = $1 AND user_id = $2`, teamID, userID)
+	if err != nil {
+		return errs.NewDBError(err)
+	}
+	if result.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	_, err = tx.Exec(ctx, `
+	DELETE FROM teams t
+	WHERE t.id = $1
+		AND NOT EXISTS (SELECT 1 FROM team_members tm WHERE tm.team_id = t.id)
+		AND NOT EXISTS (SELECT 1 FROM fork_jobs fj WHERE fj.team_id = t.id)
+		AND NOT EXISTS (SELECT 1 FROM student_works sw WHERE sw.team_id = t.id)`, teamID)
+	if err != nil {
+		return errs.NewDBError(err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return errs.NewDBError(err)
+	}
+	return nil
+}
+
+// Replaces a team's join token, so the old one can no longer be used
+func (db *DB) SetTeamJoinToken(ctx context.Context, teamID int64, joinToken string) error {
+	_, err := db.connPool.Exec(ctx, `UPDATE teams SET join_token = $1 WHERE id = $2`, joinToken, teamID)
+	if err != nil {
+		return errs.NewDBError(err)
+	}
+	return nil
+}
+
+// Shares a student work with a team, making every member a contributor
+func (db *DB) AttachTeamToWork(ctx context.Context, studentWorkID int64, teamID int64) error {
+	tx, err := db.connPool.Begin(ctx)
+	if err != nil {
+		return errs.NewDBError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `UPDATE student_works SET team_id = $1 WHERE id = $2`, teamID, studentWorkID)
+	if err != nil {
+		return errs.NewDBError(err)
+	}
+
+	_, err = tx.Exec(ctx, `
+	INSERT INTO work_contributors (user_id, student_work_id)
+	SELECT tm.user_id, $1 FROM team_members tm WHERE tm.team_id = $2
+	ON CONFLICT DO NOTHING`, studentWorkID, teamID)
+	if err != nil {
+		return errs.NewDBError(err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return errs.NewDBError(err)
+	}
+	return nil
+}
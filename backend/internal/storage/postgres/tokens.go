@@ -0,0 +1,33 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/CamPlume1/khoury-classroom/internal/errs"
+)
+
+// Deletes the classroom and assignment invitation tokens that expired before the given time,
+// returning how many were deleted
+func (db *DB) DeleteExpiredTokens(ctx context.Context, before time.Time) (int64, error) {
+	tx, err := db.connPool.Begin(ctx)
+	if err != nil {
+		return 0, errs.NewDBError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	var deleted int64
+	for _, table := range []string{"classroom_tokens", "assignment_outline_tokens", "assignment_tokens"} {
+		tag, err := tx.Exec(ctx, `DELETE FROM `+table+` WHERE expires_at < $1`, before.UTC())
+		if err != nil {
+			return 0, errs.NewDBError(err)
+		}
+		deleted += tag.RowsAffected()
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, errs.NewDBError(err)
+	}
+
+	return deleted, nil
+}
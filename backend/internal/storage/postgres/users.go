@@ -51,6 +51,27 @@ func (db *DB) GetUserByGitHubID(ctx context.Context, githubUserID int64) (models
 	return user, nil
 }
 
+// GitHub usernames are case insensitive
+func (db *DB) GetUserByGitHubUsername(ctx context.Context, githubUsername string) (models.User, error) {
+	var user models.User
+	err := db.connPool.QueryRow(ctx, `
+	SELECT u.id, u.first_name, u.last_name, u.github_username, u.github_user_id
+	FROM users u
+	WHERE LOWER(u.github_username) = LOWER($1)`, githubUsername).Scan(
+		&user.ID,
+		&user.FirstName,
+		&user.LastName,
+		&user.GithubUsername,
+		&user.GithubUserID,
+	)
+
+	if err != nil {
+		return models.User{}, err
+	}
+
+	return user, nil
+}
+
 func (db *DB) GetUserByID(ctx context.Context, userID int64) (models.User, error) {
 	var user models.User
 	err := db.connPool.QueryRow(ctx, `SELECT id, first_name, last_name, github_username, github_user_id FROM users WHERE id = $1`, userID).Scan(
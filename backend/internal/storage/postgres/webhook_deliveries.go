@@ -0,0 +1,160 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/CamPlume1/khoury-classroom/internal/errs"
+	"github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// deliveries stuck processing for longer than this are assumed to belong to a crashed worker
+const staleWebhookDeliveryTimeout = 10 * time.Minute
+
+const webhookDeliveryFields = `delivery_id, event, action, org_name, repo_name, payload, state, attempts, last_error,
+	next_attempt_at, received_at, processed_at, updated_at`
+
+// A delivery waits until the earlier deliveries of its repository are done with, so that events about a repository
+// are applied one at a time and in the order GitHub sent them
+const webhookDeliveryInOrder = `(wd.repo_name IS NULL OR NOT EXISTS (
+	SELECT 1 FROM webhook_deliveries earlier
+	WHERE earlier.repo_name = wd.repo_name AND earlier.delivery_id <> wd.delivery_id
+		AND (earlier.state = 'PROCESSING' OR (earlier.state = 'PENDING' AND earlier.received_at < wd.received_at))))`
+
+func collectWebhookDelivery(rows pgx.Rows) (models.WebhookDelivery, error) {
+	defer rows.Close()
+	return pgx.CollectOneRow(rows, pgx.RowToStructByName[models.WebhookDelivery])
+}
+
+// Stores a newly received webhook delivery, pending processing.
+// Returns pgx.ErrNoRows if the delivery was already received, e.g. when GitHub redelivers it.
+func (db *DB) RecordWebhookDelivery(ctx context.Context, delivery models.WebhookDelivery) (models.WebhookDelivery, error) {
+	rows, err := db.connPool.Query(ctx, `
+	INSERT INTO webhook_deliveries (delivery_id, event, action, org_name, repo_name, payload)
+	VALUES ($1, $2, $3, $4, LOWER($5), $6)
+	ON CONFLICT (delivery_id) DO NOTHING
+	RETURNING `+webhookDeliveryFields,
+		delivery.DeliveryID, delivery.Event, delivery.Action, delivery.OrgName, delivery.RepoName, delivery.Payload)
+	if err != nil {
+		return models.WebhookDelivery{}, errs.NewDBError(err)
+	}
+
+	return collectWebhookDelivery(rows)
+}
+
+// Returns pgx.ErrNoRows if the delivery was never received
+func (db *DB) GetWebhookDelivery(ctx context.Context, deliveryID string) (models.WebhookDelivery, error) {
+	rows, err := db.connPool.Query(ctx, `
+	SELECT `+webhookDeliveryFields+` FROM webhook_deliveries WHERE delivery_id = $1`, deliveryID)
+	if err != nil {
+		return models.WebhookDelivery{}, errs.NewDBError(err)
+	}
+
+	return collectWebhookDelivery(rows)
+}
+
+// Lists the latest deliveries of events in an organization, optionally only those in a given state
+func (db *DB) ListWebhookDeliveries(ctx context.Context, orgName string, state *models.WebhookDeliveryState, limit int) ([]models.WebhookDelivery, error) {
+	rows, err := db.connPool.Query(ctx, `
+	SELECT `+webhookDeliveryFields+` FROM webhook_deliveries
+	WHERE LOWER(org_name) = LOWER($1) AND ($2::WEBHOOK_DELIVERY_STATE IS NULL OR state = $2)
+	ORDER BY received_at DESC
+	LIMIT $3`, orgName, state, limit)
+	if err != nil {
+		return nil, errs.NewDBError(err)
+	}
+	defer rows.Close()
+
+	return pgx.CollectRows(rows, pgx.RowToStructByName[models.WebhookDelivery])
+}
+
+// Claims the next delivery to process (pending and due, or abandoned by a crashed worker), oldest first,
+// skipping deliveries waiting on an earlier delivery of their repository. Returns pgx.ErrNoRows if there is nothing to do.
+func (db *DB) ClaimNextWebhookDelivery(ctx context.Context) (models.WebhookDelivery, error) {
+	rows, err := db.connPool.Query(ctx, `
+	UPDATE webhook_deliveries
+	SET state = $1, attempts = attempts + 1, updated_at = (NOW() AT TIME ZONE 'UTC')
+	WHERE delivery_id = (
+		SELECT wd.delivery_id FROM webhook_deliveries wd
+		WHERE ((wd.state = $2 AND wd.next_attempt_at <= (NOW() AT TIME ZONE 'UTC'))
+				OR (wd.state = $1 AND wd.updated_at < (NOW() AT TIME ZONE 'UTC') - $3::INTERVAL))
+			AND `+webhookDeliveryInOrder+`
+		ORDER BY wd.received_at
+		FOR UPDATE SKIP LOCKED
+		LIMIT 1
+	)
+	RETURNING `+webhookDeliveryFields,
+		models.WebhookDeliveryStateProcessing, models.WebhookDeliveryStatePending, staleWebhookDeliveryTimeout)
+	if err != nil {
+		return models.WebhookDelivery{}, errs.NewDBError(err)
+	}
+
+	return collectWebhookDelivery(rows)
+}
+
+// Claims a pending delivery, regardless of when it is next due.
+// Returns pgx.ErrNoRows if it isn't pending, e.g. because a worker already claimed it, or if it's waiting on an
+// earlier delivery of its repository.
+func (db *DB) ClaimWebhookDelivery(ctx context.Context, deliveryID string) (models.WebhookDelivery, error) {
+	rows, err := db.connPool.Query(ctx, `
+	UPDATE webhook_deliveries
+	SET state = $1, attempts = attempts + 1, updated_at = (NOW() AT TIME ZONE 'UTC')
+	WHERE delivery_id = (
+		SELECT wd.delivery_id FROM webhook_deliveries wd
+		WHERE wd.delivery_id = $2 AND wd.state = $3 AND `+webhookDeliveryInOrder+`
+		FOR UPDATE SKIP LOCKED
+	)
+	RETURNING `+webhookDeliveryFields,
+		models.WebhookDeliveryStateProcessing, deliveryID, models.WebhookDeliveryStatePending)
+	if err != nil {
+		return models.WebhookDelivery{}, errs.NewDBError(err)
+	}
+
+	return collectWebhookDelivery(rows)
+}
+
+// Puts a claimed delivery back on the queue to be retried later
+func (db *DB) RetryWebhookDelivery(ctx context.Context, deliveryID string, nextAttemptAt time.Time, lastError *string) error {
+	_, err := db.connPool.Exec(ctx, `
+	UPDATE webhook_deliveries
+	SET state = $1, next_attempt_at = $2, last_error = $3, updated_at = (NOW() AT TIME ZONE 'UTC')
+	WHERE delivery_id = $4`,
+		models.WebhookDeliveryStatePending, nextAttemptAt.UTC(), lastError, deliveryID)
+	if err != nil {
+		return errs.NewDBError(err)
+	}
+
+	return nil
+}
+
+// Marks a delivery as finished, either processed or failed for good
+func (db *DB) FinishWebhookDelivery(ctx context.Context, deliveryID string, state models.WebhookDeliveryState, lastError *string) error {
+	_, err := db.connPool.Exec(ctx, `
+	UPDATE webhook_deliveries
+	SET state = $1, last_error = $2, updated_at = (NOW() AT TIME ZONE 'UTC'),
+		processed_at = CASE WHEN $1 = $3 THEN (NOW() AT TIME ZONE 'UTC') ELSE processed_at END
+	WHERE delivery_id = $4`,
+		state, lastError, models.WebhookDeliveryStateProcessed, deliveryID)
+	if err != nil {
+		return errs.NewDBError(err)
+	}
+
+	return nil
+}
+
+// Puts a failed delivery back on the queue to run immediately with a fresh set of attempts.
+// Returns pgx.ErrNoRows if the delivery hasn't failed.
+func (db *DB) ReplayWebhookDelivery(ctx context.Context, deliveryID string) (models.WebhookDelivery, error) {
+	rows, err := db.connPool.Query(ctx, `
+	UPDATE webhook_deliveries
+	SET state = $1, attempts = 0, next_attempt_at = (NOW() AT TIME ZONE 'UTC'), updated_at = (NOW() AT TIME ZONE 'UTC')
+	WHERE delivery_id = $2 AND state = $3
+	RETURNING `+webhookDeliveryFields,
+		models.WebhookDeliveryStatePending, deliveryID, models.WebhookDeliveryStateFailed)
+	if err != nil {
+		return models.WebhookDelivery{}, errs.NewDBError(err)
+	}
+
+	return collectWebhookDelivery(rows)
+}
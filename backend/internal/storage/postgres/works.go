@@ -21,11 +21,16 @@ const DesiredFields = `
 	sw.manual_feedback_score,
 	sw.auto_grader_score,
 	sw.grades_published_timestamp,
+	sw.submitted_at,
+	sw.late_penalty_percent,
 	sw.work_state,
 	sw.created_at,
 	sw.commit_amount,
 	sw.first_commit_date,
 	sw.last_commit_date,
+	sw.repo_deleted_at,
+	sw.repo_archived,
+	sw.repo_public,
 	u.first_name,
 	u.last_name,
 	u.github_username
@@ -215,6 +220,32 @@ WHERE sw.repo_name = $1
 	return work.StudentWork, nil
 }
 
+// Follows a student work's repository to its new name on GitHub
+func (db *DB) RenameWorkRepo(ctx context.Context, studentWorkID int, repoName string) error {
+	_, err := db.connPool.Exec(ctx, `
+	UPDATE student_works
+	SET repo_name = $1
+	WHERE id = $2`, repoName, studentWorkID)
+	if err != nil {
+		return errs.NewDBError(err)
+	}
+
+	return nil
+}
+
+// Records what happened to a student work's repository on GitHub
+func (db *DB) UpdateWorkRepoFlags(ctx context.Context, studentWork models.StudentWork) error {
+	_, err := db.connPool.Exec(ctx, `
+	UPDATE student_works
+	SET repo_deleted_at = $1, repo_archived = $2, repo_public = $3
+	WHERE id = $4`, studentWork.RepoDeletedAt, studentWork.RepoArchived, studentWork.RepoPublic, studentWork.ID)
+	if err != nil {
+		return errs.NewDBError(err)
+	}
+
+	return nil
+}
+
 func (db *DB) GetWorkByGitHubUserID(ctx context.Context, classroomID int, assignmentID int, gitHubUserID int64) (models.StudentWork, error) {
 	query := fmt.Sprintf(`
 	SELECT %s FROM %s
@@ -246,8 +277,9 @@ func (db *DB) UpdateStudentWork(ctx context.Context, studentWork models.StudentW
 			work_state = $5,
 			commit_amount = $6,
 			first_commit_date = $7,
-			last_commit_date = $8
-		WHERE id = $9
+			last_commit_date = $8,
+			submitted_at = $9
+		WHERE id = $10
 	`, studentWork.AssignmentOutlineID,
 		studentWork.RepoName,
 		studentWork.UniqueDueDate,
@@ -256,6 +288,7 @@ func (db *DB) UpdateStudentWork(ctx context.Context, studentWork models.StudentW
 		studentWork.CommitAmount,
 		studentWork.FirstCommitDate,
 		studentWork.LastCommitDate,
+		studentWork.SubmittedAt,
 		studentWork.ID,
 	)
 
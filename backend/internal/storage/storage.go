@@ -9,6 +9,8 @@ import (
 
 type Storage interface {
 	Close(context.Context)
+	// Runs fn with a store whose queries all run in one transaction, committed only if fn succeeds
+	WithTx(ctx context.Context, fn func(store Storage) error) error
 	FeedbackComment
 	Works
 	Test
@@ -20,12 +22,37 @@ type Storage interface {
 	AssignmentTemplate
 	AssignmentBaseRepo
 	Deadline
+	ForkJob
+	Autograder
+	Regrade
+	GradePublication
+	GradingAssignment
+	LatePolicy
+	Gradebook
+	Roster
+	Team
+	GradingAudit
+	SubmissionSnapshot
+	SubmissionAttempt
+	AppInstallation
+	RepositoryAlert
+	BranchLayout
+	WebhookDelivery
+	ScheduledJob
+	Token
 }
 
 type FeedbackComment interface {
 	GetFeedbackOnWork(ctx context.Context, studentWorkID int) ([]models.PRReviewCommentResponse, error)
-	CreateFeedbackComment(ctx context.Context, TAUserID int64, studentWorkID int, comment models.PRReviewCommentResponse) error
-	CreateFeedbackCommentFromRubricItem(ctx context.Context, TAUserID int64, studentWorkID int, comment models.PRReviewCommentResponse) error
+	CreateFeedbackComment(ctx context.Context, TAUserID int64, studentWorkID int, comment models.PRReviewCommentResponse) (models.FeedbackComment, error)
+	CreateFeedbackCommentFromRubricItem(ctx context.Context, TAUserID int64, studentWorkID int, comment models.PRReviewCommentResponse) (models.FeedbackComment, error)
+	GetFeedbackComment(ctx context.Context, feedbackCommentID int64) (models.FeedbackComment, error)
+	GetFeedbackCommentByGitHubID(ctx context.Context, githubCommentID int64) (models.FeedbackComment, error)
+	ImportReviewFeedback(ctx context.Context, TAUserID int64, studentWorkID int, reviewID int64, comments []models.PRReviewCommentResponse) ([]models.FeedbackComment, error)
+	SetFeedbackAcknowledged(ctx context.Context, feedbackCommentID int64, acknowledgedBy *int64) error
+	EditFeedbackComment(ctx context.Context, feedbackCommentID int64, changedBy int64, edit models.PRReviewCommentResponse) (models.FeedbackComment, error)
+	DeleteFeedbackComment(ctx context.Context, feedbackCommentID int64, changedBy int64) error
+	GetFeedbackCommentHistory(ctx context.Context, feedbackCommentID int64) ([]models.FeedbackCommentRevision, error)
 }
 
 type Works interface {
@@ -35,6 +62,8 @@ type Works interface {
 
 	UpdateStudentWork(ctx context.Context, UpdateStudentWork models.StudentWork) (models.StudentWork, error)
 	GetWorkByRepoName(ctx context.Context, repoName string) (models.StudentWork, error)
+	UpdateWorkRepoFlags(ctx context.Context, studentWork models.StudentWork) error
+	RenameWorkRepo(ctx context.Context, studentWorkID int, repoName string) error
 	GetWorkByGitHubUserID(ctx context.Context, classroomID int, assignmentID int, gitHubUserID int64) (models.StudentWork, error)
 }
 
@@ -61,6 +90,8 @@ type Classroom interface {
 	GetUserInClassroom(ctx context.Context, classroomID int64, userID int64) (models.ClassroomUser, error)
 	GetClassroomsInOrg(ctx context.Context, orgID int64) ([]models.Classroom, error)
 	GetUserClassroomsInOrg(ctx context.Context, orgID int64, userID int64) ([]models.ClassroomUser, error)
+	GetUserMembershipsInOrg(ctx context.Context, orgID int64, userID int64) ([]models.ClassroomUser, error)
+	SetOrgClassroomsSuspended(ctx context.Context, orgID int64, suspended bool) ([]models.Classroom, error)
 	CreateClassroomToken(ctx context.Context, tokenData models.ClassroomToken) (models.ClassroomToken, error)
 	GetClassroomToken(ctx context.Context, token string) (models.ClassroomToken, error)
 	GetPermanentClassroomTokenByClassroomIDAndRole(ctx context.Context, classroomID int64, classroomRole models.ClassroomRole) (models.ClassroomToken, error)
@@ -70,6 +101,7 @@ type Classroom interface {
 type User interface {
 	CreateUser(ctx context.Context, userToCreate models.User) (models.User, error)
 	GetUserByGitHubID(ctx context.Context, githubUserID int64) (models.User, error)
+	GetUserByGitHubUsername(ctx context.Context, githubUsername string) (models.User, error)
 	GetUserByID(ctx context.Context, userID int64) (models.User, error)
 }
 
@@ -101,6 +133,8 @@ type AssignmentBaseRepo interface {
 	CreateBaseRepo(ctx context.Context, baseRepoData models.AssignmentBaseRepo) error
 	GetBaseRepoByID(ctx context.Context, id int64) (models.AssignmentBaseRepo, error)
 	UpdateBaseRepoInitialized(ctx context.Context, id int64, initialized bool) error
+	RenameBaseRepo(ctx context.Context, id int64, name string) error
+	SetBaseRepoDeleted(ctx context.Context, id int64, deleted bool) error
 }
 
 type Rubric interface {
@@ -111,8 +145,193 @@ type Rubric interface {
 	UpdateRubric(ctx context.Context, rubricID int64, rubricData models.Rubric) (models.Rubric, error)
 	UpdateRubricItem(ctx context.Context, rubricItemData models.RubricItem) (models.RubricItem, error)
 	GetRubricsInClassroom(ctx context.Context, classroomID int64) ([]models.Rubric, error)
+	DeleteRubric(ctx context.Context, rubricID int64) error
+	GetRubricSections(ctx context.Context, rubricID int64) ([]models.RubricSection, error)
+	CreateRubricSection(ctx context.Context, section models.RubricSection) (models.RubricSection, error)
+	UpdateRubricSection(ctx context.Context, section models.RubricSection) (models.RubricSection, error)
+	DeleteRubricSection(ctx context.Context, rubricID int64, sectionID int64) error
+	ReorderRubric(ctx context.Context, rubricID int64, order models.RubricOrderRequest) error
+	CreateFullRubric(ctx context.Context, rubricData models.Rubric, rubric models.RubricExport) (models.FullRubric, error)
+	GetRubricItem(ctx context.Context, itemID int64) (models.RubricItem, error)
+	GetGradedRubricItemIDs(ctx context.Context, rubricID int64) ([]int64, error)
+	ReviseRubricItems(ctx context.Context, rubricID int64, createdBy int64, items []models.RubricItem) (int, []models.RubricItem, error)
+	GetRubricVersions(ctx context.Context, rubricID int64) ([]models.RubricVersion, error)
+	GetRubricItemHistory(ctx context.Context, itemID int64) ([]models.RubricItem, error)
+	PropagateRubricItem(ctx context.Context, itemID int64, apply bool) ([]models.RubricPropagationWork, error)
 }
 
 type Deadline interface {
 	GetDeadlineForRepo(ctx context.Context, repoName string) (*time.Time, error)
+	UpdateRepoDeadline(ctx context.Context, repoName string, due *time.Time) error
+	GrantDeadlineExtension(ctx context.Context, extension models.DeadlineExtension) (models.DeadlineExtension, error)
+	RevokeDeadlineExtension(ctx context.Context, assignmentID int64, extensionID int64, revokedBy int64) (models.DeadlineExtension, error)
+	ListDeadlineExtensions(ctx context.Context, assignmentID int64, includeRevoked bool) ([]models.DeadlineExtension, error)
+	GetActiveDeadlineExtension(ctx context.Context, assignmentID int64, userID int64) (models.DeadlineExtension, error)
+}
+
+type ForkJob interface {
+	CreateForkJob(ctx context.Context, jobData models.ForkJob) (models.ForkJob, error)
+	GetForkJob(ctx context.Context, jobID int64) (models.ForkJob, error)
+	GetForkJobByAssignmentAndUser(ctx context.Context, assignmentID int64, userID int64) (models.ForkJob, error)
+	GetForkJobByTeam(ctx context.Context, teamID int64) (models.ForkJob, error)
+	ListUnfinishedForkJobs(ctx context.Context, classroomID int64) ([]models.ForkJob, error)
+	AssignmentHasForkJobs(ctx context.Context, assignmentID int64) (bool, error)
+	ForkJobRepoNameExists(ctx context.Context, repoName string) (bool, error)
+	ClaimNextForkJob(ctx context.Context) (models.ForkJob, error)
+	ClaimForkJobByRepoName(ctx context.Context, orgName string, repoName string) (models.ForkJob, error)
+	RetryForkJob(ctx context.Context, jobID int64, nextAttemptAt time.Time, lastError *string) error
+	ResetForkJob(ctx context.Context, jobID int64) (models.ForkJob, error)
+	CompleteForkJobStep(ctx context.Context, jobID int64, step models.ForkJobStep) error
+	FinishForkJob(ctx context.Context, jobID int64, state models.ForkJobState, lastError *string) error
+}
+
+type Autograder interface {
+	GetAutograderConfig(ctx context.Context, assignmentID int64) (models.AutograderConfig, error)
+	UpsertAutograderConfig(ctx context.Context, config models.AutograderConfig) (models.AutograderConfig, error)
+	RecordAutograderResult(ctx context.Context, studentWorkID int64, commitSHA string, verified bool, tests []models.AutograderTestResult) (models.AutograderResult, error)
+	GetAutograderResults(ctx context.Context, studentWorkID int64) ([]models.AutograderResult, error)
+}
+
+type Regrade interface {
+	CreateRegradeRequest(ctx context.Context, feedbackCommentID int64, studentUserID int64, studentComment string, githubCommentID *int64) (models.RegradeRequest, error)
+	SetRegradeGitHubCommentID(ctx context.Context, regradeID int64, githubCommentID int64) error
+	GetRegradeRequest(ctx context.Context, regradeID int64) (models.RegradeRequest, error)
+	ListRegradeRequests(ctx context.Context, assignmentID int64, state *models.RegradeState) ([]models.RegradeRequest, error)
+	ListRegradeRequestsOnWork(ctx context.Context, studentWorkID int64) ([]models.RegradeRequest, error)
+	ResolveRegradeRequest(ctx context.Context, regradeID int64, taUserID int64, outcome models.RegradeOutcome, adjustedPointValue *int, taComment *string) (models.RegradeRequest, error)
+}
+
+type GradePublication interface {
+	UpsertReviewDraft(ctx context.Context, studentWorkID int64, taUserID int64, body string) error
+	GetReviewDraft(ctx context.Context, studentWorkID int64) (models.ReviewDraft, error)
+	GetWorksWithUnpublishedGrades(ctx context.Context, assignmentID int64) ([]models.StudentWork, error)
+	PublishWorkGrades(ctx context.Context, studentWorkID int64, comments []models.PRReviewCommentResponse) error
+	GetGradePublication(ctx context.Context, assignmentID int64) (models.GradePublication, error)
+	UpsertGradePublication(ctx context.Context, assignmentID int64, publishAt time.Time, publishedAt *time.Time, userID int64) (models.GradePublication, error)
+	CancelGradePublication(ctx context.Context, assignmentID int64) error
+	ClaimDueGradePublication(ctx context.Context) (models.GradePublication, error)
+	RetryGradePublication(ctx context.Context, assignmentID int64, nextAttemptAt time.Time, lastError string) error
+}
+
+type GradingAssignment interface {
+	AssignGraders(ctx context.Context, pairings []models.GraderPairing, assignedBy int64) ([]models.GradingAssignment, error)
+	ListGradingAssignments(ctx context.Context, assignmentID int64, taUserID *int64) ([]models.GradingAssignment, error)
+	GetGraderLoads(ctx context.Context, classroomID int64) (map[int64]int, error)
+}
+
+type LatePolicy interface {
+	GetLatePolicy(ctx context.Context, assignmentID int64) (models.LatePolicy, error)
+	UpsertLatePolicy(ctx context.Context, policy models.LatePolicy) (models.LatePolicy, error)
+	DeleteLatePolicy(ctx context.Context, assignmentID int64) error
+	GetLateTokenBudget(ctx context.Context, classroomID int64) (models.LateTokenBudget, error)
+	SetLateTokenBudget(ctx context.Context, budget models.LateTokenBudget) error
+	GetLateTokensSpent(ctx context.Context, classroomID int64, userID int64) (int, error)
+	GetLateTokensUsedOnWork(ctx context.Context, studentWorkID int64) (int, error)
+	SpendLateTokens(ctx context.Context, classroomID int64, studentWorkID int64, userID int64, tokens int) error
+}
+
+type Gradebook interface {
+	GetGradebookStudents(ctx context.Context, classroomID int64) ([]models.GradebookStudent, error)
+	GetGradebookScores(ctx context.Context, classroomID int64, assignmentID *int64) ([]models.GradebookScore, error)
+	SetStudentIdentifier(ctx context.Context, classroomID int64, userID int64, studentIdentifier *string) error
+}
+
+type Roster interface {
+	GetOrCreateRosterUser(ctx context.Context, entry models.RosterEntry, githubUserID int64) (models.User, error)
+	SetRosterDetails(ctx context.Context, classroomID int64, userID int64, section *string, studentIdentifier *string) error
+	SaveRosterReconciliation(ctx context.Context, report models.RosterReconciliation) error
+	GetRosterReconciliation(ctx context.Context, classroomID int64) (models.RosterReconciliation, error)
+	CreateRosterImport(ctx context.Context, classroomID int64, results []models.RosterImportResult) (models.RosterImport, error)
+	GetRosterImport(ctx context.Context, classroomID int64, rosterImportID int64) (models.RosterImport, error)
+	ListPendingRosterInvitations(ctx context.Context, limit int) ([]models.PendingRosterInvitation, error)
+	FinishRosterInvitation(ctx context.Context, rosterImportID int64, row int, status models.RosterImportStatus, message string) error
+	GetClassroomsDueForReconciliation(ctx context.Context, reconciledBefore time.Time) ([]models.Classroom, error)
+}
+
+type Team interface {
+	GetGroupSettings(ctx context.Context, assignmentID int64) (models.GroupSettings, error)
+	UpsertGroupSettings(ctx context.Context, settings models.GroupSettings) (models.GroupSettings, error)
+	CreateTeam(ctx context.Context, assignmentID int64, name string, joinToken string, createdBy int64) (models.Team, error)
+	CreateTeamWithMember(ctx context.Context, assignmentID int64, name string, joinToken string, userID int64) (models.Team, error)
+	GetTeam(ctx context.Context, teamID int64) (models.Team, error)
+	GetTeamByToken(ctx context.Context, joinToken string) (models.Team, error)
+	GetTeamOfUser(ctx context.Context, assignmentID int64, userID int64) (models.Team, error)
+	ListTeams(ctx context.Context, assignmentID int64) ([]models.Team, error)
+	ListTeamMembers(ctx context.Context, teamID int64) ([]models.TeamMember, error)
+	AddTeamMember(ctx context.Context, teamID int64, userID int64, maxSize int) error
+	RemoveTeamMember(ctx context.Context, teamID int64, userID int64) error
+	SetTeamJoinToken(ctx context.Context, teamID int64, joinToken string) error
+	AttachTeamToWork(ctx context.Context, studentWorkID int64, teamID int64) error
+}
+
+type GradingAudit interface {
+	RecordGradingAudit(ctx context.Context, entry models.GradingAuditEntry) error
+	GetWorkAuditLog(ctx context.Context, studentWorkID int64) ([]models.GradingAuditEntry, error)
+	GetAssignmentAuditLog(ctx context.Context, assignmentID int64) ([]models.GradingAuditEntry, error)
+}
+
+type SubmissionSnapshot interface {
+	CreateSubmissionSnapshot(ctx context.Context, snapshot models.SubmissionSnapshot) (models.SubmissionSnapshot, error)
+	GetSubmissionSnapshot(ctx context.Context, studentWorkID int64) (models.SubmissionSnapshot, error)
+	ListSubmissionSnapshots(ctx context.Context, studentWorkID int64) ([]models.SubmissionSnapshot, error)
+	GetWorksDueForSnapshot(ctx context.Context, afterID int, limit int) ([]models.StudentWork, error)
+}
+
+type SubmissionAttempt interface {
+	CreateSubmissionAttempt(ctx context.Context, attempt models.SubmissionAttempt) (models.SubmissionAttempt, error)
+	ListSubmissionAttempts(ctx context.Context, studentWorkID int64) ([]models.SubmissionAttempt, error)
+}
+
+type AppInstallation interface {
+	UpsertAppInstallation(ctx context.Context, installation models.AppInstallation) (models.AppInstallation, error)
+	CreateAppInstallation(ctx context.Context, installation models.AppInstallation) (models.AppInstallation, error)
+	ListAppInstallations(ctx context.Context) ([]models.AppInstallation, error)
+}
+
+type RepositoryAlert interface {
+	CreateRepositoryAlert(ctx context.Context, alert models.RepositoryAlert) (models.RepositoryAlert, error)
+	GetRepositoryAlert(ctx context.Context, alertID int64) (models.RepositoryAlert, error)
+	ListRepositoryAlerts(ctx context.Context, classroomID int64, includeResolved bool) ([]models.RepositoryAlert, error)
+	ResolveRepositoryAlert(ctx context.Context, alertID int64, resolvedBy int64) (models.RepositoryAlert, error)
+	MarkRepositoryAlertReapplied(ctx context.Context, alertID int64) (models.RepositoryAlert, error)
+}
+
+type BranchLayout interface {
+	GetBranchLayout(ctx context.Context, assignmentID int64) (models.BranchLayout, error)
+	UpsertBranchLayout(ctx context.Context, layout models.BranchLayout) (models.BranchLayout, error)
+}
+
+type WebhookDelivery interface {
+	RecordWebhookDelivery(ctx context.Context, delivery models.WebhookDelivery) (models.WebhookDelivery, error)
+	GetWebhookDelivery(ctx context.Context, deliveryID string) (models.WebhookDelivery, error)
+	ListWebhookDeliveries(ctx context.Context, orgName string, state *models.WebhookDeliveryState, limit int) ([]models.WebhookDelivery, error)
+	ClaimNextWebhookDelivery(ctx context.Context) (models.WebhookDelivery, error)
+	ClaimWebhookDelivery(ctx context.Context, deliveryID string) (models.WebhookDelivery, error)
+	RetryWebhookDelivery(ctx context.Context, deliveryID string, nextAttemptAt time.Time, lastError *string) error
+	FinishWebhookDelivery(ctx context.Context, deliveryID string, state models.WebhookDeliveryState, lastError *string) error
+	ReplayWebhookDelivery(ctx context.Context, deliveryID string) (models.WebhookDelivery, error)
+}
+
+type ScheduledJob interface {
+	EnqueueScheduledJob(ctx context.Context, job models.ScheduledJob) (models.ScheduledJob, error)
+	GetScheduledJob(ctx context.Context, jobID int64) (models.ScheduledJob, error)
+	ListScheduledJobs(ctx context.Context, jobType *string, state *models.ScheduledJobState, limit int) ([]models.ScheduledJob, error)
+	ClaimNextScheduledJob(ctx context.Context, jobTypes []string) (models.ScheduledJob, error)
+	ResetStaleScheduledJobs(ctx context.Context) (int64, error)
+	HeartbeatScheduledJob(ctx context.Context, jobID int64) error
+	RetryScheduledJob(ctx context.Context, jobID int64, runAt time.Time, lastError *string) error
+	FinishScheduledJob(ctx context.Context, jobID int64, state models.ScheduledJobState, lastError *string) error
+	RequeueScheduledJob(ctx context.Context, jobID int64) (models.ScheduledJob, error)
+	TryAdvisoryLock(ctx context.Context, key int64) (Lock, error)
+}
+
+// A lock held until it is released or the database connection holding it drops
+type Lock interface {
+	// Returns an error if the lock may have been lost
+	Check(ctx context.Context) error
+	Release(ctx context.Context)
+}
+
+type Token interface {
+	DeleteExpiredTokens(ctx context.Context, before time.Time) (int64, error)
 }
@@ -0,0 +1,47 @@
+package tokensweeper
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/CamPlume1/khoury-classroom/internal/scheduler"
+	"github.com/CamPlume1/khoury-classroom/internal/storage"
+)
+
+// The scheduled job type that deletes expired tokens
+const JobName = "sweep_expired_tokens"
+
+const (
+	sweepInterval = time.Hour
+	// how long an expired token is kept, so following an old invite link says it expired rather than that it doesn't exist
+	retention = 30 * 24 * time.Hour
+)
+
+// Deletes classroom and assignment invitation tokens some time after they expire
+type Sweeper struct {
+	store storage.Storage
+}
+
+func New(store storage.Storage) *Sweeper {
+	return &Sweeper{store: store}
+}
+
+// The recurring job that sweeps the tokens past their retention
+func (s *Sweeper) Job() scheduler.JobType {
+	return scheduler.JobType{Name: JobName, Run: s.sweep, Every: sweepInterval}
+}
+
+func (s *Sweeper) sweep(ctx context.Context, _ models.ScheduledJob) error {
+	deleted, err := s.store.DeleteExpiredTokens(ctx, time.Now().Add(-retention))
+	if err != nil {
+		return fmt.Errorf("failed to delete expired tokens: %w", err)
+	}
+
+	if deleted > 0 {
+		slog.Info("Deleted expired tokens", "tokens", deleted)
+	}
+	return nil
+}
@@ -11,4 +11,5 @@ type Params struct {
 	Store     storage.Storage
 	GitHubApp github.GitHubAppClient
 	Domains config.Domains
+	Admins    config.Admins
 }
@@ -0,0 +1,94 @@
+package webhookqueue
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/CamPlume1/khoury-classroom/internal/storage"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	pollInterval = 5 * time.Second
+	maxAttempts  = 10
+	maxBackoff   = 30 * time.Minute
+)
+
+// Processes the payload of a webhook delivery
+type Handler func(ctx context.Context, delivery models.WebhookDelivery) error
+
+// Processes stored webhook deliveries in the background, retrying the ones that fail
+type Queue struct {
+	store  storage.Storage
+	handle Handler
+}
+
+func New(store storage.Storage, handle Handler) *Queue {
+	return &Queue{store: store, handle: handle}
+}
+
+// Polls for deliveries to process until the context is cancelled
+func (q *Queue) Start(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		q.drain(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Processes claimed deliveries until there are none left that are due
+func (q *Queue) drain(ctx context.Context) {
+	for ctx.Err() == nil {
+		delivery, err := q.store.ClaimNextWebhookDelivery(ctx)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return
+		}
+		if err != nil {
+			slog.Error("Failed to claim webhook delivery", "error", err)
+			return
+		}
+
+		q.Run(ctx, delivery)
+	}
+}
+
+// Processes a claimed delivery and records the outcome
+func (q *Queue) Run(ctx context.Context, delivery models.WebhookDelivery) {
+	err := q.handle(ctx, delivery)
+
+	switch {
+	case err == nil:
+		err = q.store.FinishWebhookDelivery(ctx, delivery.DeliveryID, models.WebhookDeliveryStateProcessed, nil)
+	case delivery.Attempts < maxAttempts:
+		slog.Warn("Webhook delivery failed, retrying", "delivery_id", delivery.DeliveryID, "event", delivery.Event, "attempts", delivery.Attempts, "error", err)
+		message := err.Error()
+		err = q.store.RetryWebhookDelivery(ctx, delivery.DeliveryID, time.Now().Add(backoff(delivery.Attempts)), &message)
+	default:
+		slog.Error("Webhook delivery failed", "delivery_id", delivery.DeliveryID, "event", delivery.Event, "error", err)
+		message := err.Error()
+		err = q.store.FinishWebhookDelivery(ctx, delivery.DeliveryID, models.WebhookDeliveryStateFailed, &message)
+	}
+
+	if err != nil {
+		slog.Error("Failed to record webhook delivery outcome", "delivery_id", delivery.DeliveryID, "error", err)
+	}
+}
+
+// Exponential backoff between attempts, capped at maxBackoff
+func backoff(attempts int) time.Duration {
+	delay := 5 * time.Second
+	for i := 1; i < attempts && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxBackoff)
+}
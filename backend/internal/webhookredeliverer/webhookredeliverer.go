@@ -0,0 +1,99 @@
+package webhookredeliverer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/CamPlume1/khoury-classroom/internal/github"
+	"github.com/CamPlume1/khoury-classroom/internal/models"
+	"github.com/CamPlume1/khoury-classroom/internal/scheduler"
+	"github.com/CamPlume1/khoury-classroom/internal/storage"
+	"github.com/jackc/pgx/v5"
+)
+
+// The scheduled job type that redelivers webhook events that never reached the app
+const JobName = "redeliver_webhooks"
+
+const (
+	pollInterval = 10 * time.Minute
+	// how far back the delivery log is checked, GitHub keeps it for three days
+	lookback = 24 * time.Hour
+	// how many times an event is delivered before it's given up on
+	maxDeliveryAttempts = 4
+)
+
+// Redelivers webhook events GitHub failed to deliver, e.g. while the app was down or couldn't store them. Events the
+// app did store are retried by the webhook delivery queue instead.
+type Redeliverer struct {
+	store     storage.Storage
+	appClient github.GitHubAppClient
+}
+
+func New(store storage.Storage, appClient github.GitHubAppClient) *Redeliverer {
+	return &Redeliverer{store: store, appClient: appClient}
+}
+
+// The recurring job that checks the app's delivery log. An event that can't be redelivered is tried again on the
+// next run, so the job itself isn't retried.
+func (r *Redeliverer) Job() scheduler.JobType {
+	return scheduler.JobType{Name: JobName, Run: r.reconcile, Every: pollInterval, MaxAttempts: 1}
+}
+
+func (r *Redeliverer) reconcile(ctx context.Context, _ models.ScheduledJob) error {
+	deliveries, err := r.appClient.ListHookDeliveries(ctx, time.Now().UTC().Add(-lookback))
+	if err != nil {
+		return fmt.Errorf("failed to list hook deliveries: %w", err)
+	}
+
+	// every attempt at delivering an event shares its GUID, newest first
+	attempts := make(map[string][]models.HookDelivery)
+	var guids []string
+	for _, delivery := range deliveries {
+		if _, ok := attempts[delivery.GUID]; !ok {
+			guids = append(guids, delivery.GUID)
+		}
+		attempts[delivery.GUID] = append(attempts[delivery.GUID], delivery)
+	}
+
+	for _, guid := range guids {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if !r.needsRedelivery(attempts[guid]) {
+			continue
+		}
+
+		_, err := r.store.GetWebhookDelivery(ctx, guid)
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("failed to get webhook delivery: %w", err)
+		}
+
+		latest := attempts[guid][0]
+		if err := r.appClient.RedeliverHookDelivery(ctx, latest.ID); err != nil {
+			slog.Error("Failed to redeliver webhook", "delivery_id", guid, "event", latest.Event, "error", err)
+			continue
+		}
+		slog.Info("Redelivered webhook", "delivery_id", guid, "event", latest.Event, "attempts", len(attempts[guid]))
+	}
+	return nil
+}
+
+// An event needs redelivering if every attempt went unanswered or hit a server error, and it hasn't run out of
+// attempts. Events the app rejected, e.g. ones it doesn't handle, would only be rejected again.
+func (r *Redeliverer) needsRedelivery(attempts []models.HookDelivery) bool {
+	if len(attempts) >= maxDeliveryAttempts {
+		return false
+	}
+	for _, attempt := range attempts {
+		if attempt.StatusCode > 0 && attempt.StatusCode < 500 {
+			return false
+		}
+	}
+	return true
+}